@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,7 +10,9 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"parserr/helpers"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,18 +29,34 @@ const (
 	APIEpisodeURL = APIURL + "/episode"
 	// APIMovieURL ...
 	APIMovieURL = APIURL + "/movie"
+	// APISeriesURL ...
+	APISeriesURL = APIURL + "/series"
+	// APIEpisodeFileURL ...
+	APIEpisodeFileURL = APIURL + "/episodefile"
 	// StatusCompleted ...
 	StatusCompleted = "Completed"
 	// TrackedDownloadStatusWarning ...
 	TrackedDownloadStatusWarning = "Warning"
-	// MaxTime Max interval to check series and clean them
+	// MaxTime Max time to wait for a command to complete, unless the
+	// CommandWait of the api says otherwise
 	MaxTime = time.Second * 30
-	// CheckInterval Time between requests to check if rescan is completed
+	// CheckInterval Time between requests to check if a command is
+	// completed, unless the CommandWait of the api says otherwise
 	CheckInterval = time.Second * 5
 	// DefaultRetries ...
 	DefaultRetries = 3
+	// DefaultHistoryPageSize Records fetched per history request
+	DefaultHistoryPageSize = 10
 )
 
+// DownloadFolder Folder where downloads are completed
+type DownloadFolder struct {
+	Path string
+	// Strategy Name of the strategy used to fix files of this folder,
+	// empty to use the default one of the api
+	Strategy string
+}
+
 // Scanneable Can execute Scan to check new files
 type Scanneable interface {
 	ScanCommand() CommandBody
@@ -63,32 +82,124 @@ type Config interface {
 	GetURL() string
 	GetAPIKey() string
 	GetDownloadFolder() string
+	GetDownloadFolders() []DownloadFolder
 	GetType() string
+	GetName() string
+	GetPathCache() *PathCache
+	GetWebURL(path string) string
 }
 
-// RRAPI Complete Sonarr/Radarr API
+// RRAPI Complete Sonarr/Radarr/Lidarr API
 type RRAPI interface {
 	Config
 	Scanneable
 	Renameable
 	DownloadFinishedChecker
 	DownloadScanner
-	GetQueue() (queue []QueueElem, err error)
-	DeleteQueueItem(id int) error
-	GetHistory(page int) (history History, err error)
-	GetEpisode(id int) (episode Episode, err error)
-	GetMovie(id int) (movie Movie, err error)
-	ExecuteCommand(c CommandBody) (cs CommandStatus, err error)
-	ExecuteCommandAndWait(c CommandBody, retries int) (cs CommandStatus, err error)
-	GetCommandStatus(id int) (cs CommandStatus, err error)
+	GetQueue(ctx context.Context) (queue []QueueElem, err error)
+	GetQueuePaged(ctx context.Context, page, pageSize int, opts QueueOptions) (p QueuePage, err error)
+	GetAllQueue(ctx context.Context, opts QueueOptions) (queue []QueueElem, err error)
+	DeleteQueueItem(ctx context.Context, id int, opts DeleteQueueOptions) error
+	AddToBlocklist(ctx context.Context, queueID int) error
+	GetBlocklist(ctx context.Context, page int) (blocklist Blocklist, err error)
+	DeleteBlocklistItem(ctx context.Context, id int) error
+	SetEpisodeMonitored(ctx context.Context, id int, monitored bool) error
+	SetSeasonMonitored(ctx context.Context, seriesID, season int, monitored bool) error
+	SetMovieMonitored(ctx context.Context, id int, monitored bool) error
+	GetHistory(ctx context.Context, page int) (history History, err error)
+	GetHistoryFiltered(ctx context.Context, page int, opts HistoryOptions) (history History, err error)
+	FiltersHistory() bool
+	GetEpisode(ctx context.Context, id int) (episode Episode, err error)
+	GetEpisodesBySeries(ctx context.Context, seriesID int) (episodes []Episode, err error)
+	GetMovie(ctx context.Context, id int) (movie Movie, err error)
+	GetMovies(ctx context.Context) (movies []Movie, err error)
+	UpdateMovie(ctx context.Context, movie Movie) error
+	GetMovieFile(ctx context.Context, id int) (file MediaFile, err error)
+	GetMovieFiles(ctx context.Context, movieID int) (files []MediaFile, err error)
+	DeleteMovieFile(ctx context.Context, id int) error
+	GetSeriesByID(ctx context.Context, id int) (series Series, err error)
+	GetSeries(ctx context.Context) (series []Series, err error)
+	UpdateSeries(ctx context.Context, series Series) error
+	GetEpisodeFile(ctx context.Context, id int) (file MediaFile, err error)
+	GetEpisodeFiles(ctx context.Context, seriesID int) (files []MediaFile, err error)
+	DeleteEpisodeFile(ctx context.Context, id int) error
+	GetArtist(ctx context.Context, id int) (artist Artist, err error)
+	GetTracks(ctx context.Context, albumID int) (tracks []Track, err error)
+	SetAlbumMonitored(ctx context.Context, id int, monitored bool) error
+	GetNamingConfig(ctx context.Context) (naming NamingConfig, err error)
+	SeasonPath(ctx context.Context, seriesID, season int) (dir string, err error)
+	GetMediaManagementConfig(ctx context.Context) (config MediaManagementConfig, err error)
+	GetRootFolders(ctx context.Context) (folders []RootFolder, err error)
+	GetDiskSpace(ctx context.Context) (disks []DiskSpace, err error)
+	GetSystemStatus(ctx context.Context) (status SystemStatus, err error)
+	GetHealth(ctx context.Context) (checks []HealthCheck, err error)
+	GetTags(ctx context.Context) (tags []Tag, err error)
+	GetQualityProfiles(ctx context.Context) (profiles []QualityProfile, err error)
+	CreateTag(ctx context.Context, label string) (tag Tag, err error)
+	ApplyTag(ctx context.Context, id, tagID int) error
+	GetPath(ctx context.Context, id int) (path string, err error)
+	ExecuteCommand(ctx context.Context, c CommandBody) (cs CommandStatus, err error)
+	ExecuteCommandAndWait(ctx context.Context, c CommandBody, retries int) (cs CommandStatus, err error)
+	GetCommandStatus(ctx context.Context, id int) (cs CommandStatus, err error)
+	WatchCommand(ctx context.Context, id int) <-chan CommandUpdate
+	GetManualImport(ctx context.Context, folder, downloadID string) (items []ManualImportItem, err error)
+	ExecuteManualImport(ctx context.Context, items []ManualImportItem, mode string) (cs CommandStatus, err error)
+	Parse(ctx context.Context, title string) (p ParseResult, err error)
 }
 
 // API ..
+// Safe for concurrent use as long as its fields are not modified once shared.
 type API struct {
-	URL            string
-	APIKey         string
-	DownloadFolder string
-	Type           string
+	// Name Identify the instance in logs, state and reports
+	Name   string
+	URL    string
+	APIKey string
+	// URLBase Path prefix every endpoint is built under, like /sonarr, for
+	// instances behind a reverse proxy
+	URLBase string
+	// DownloadFolders Searched in order when looking for downloaded files
+	DownloadFolders []DownloadFolder
+	Type            string
+	// ReadOnly Only GET requests are sent, mutations are just logged
+	ReadOnly bool
+	// Clock Used to wait for commands, RealClock if nil
+	Clock Clock
+	// HistoryPageSize Records fetched per history request,
+	// DefaultHistoryPageSize if 0
+	HistoryPageSize int
+	// HTTPClient Used for every request, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// HTTPS Connect using TLS even if URL is a bare host or an http url
+	HTTPS bool
+	// PathCache Cache of GetPath, not used if nil
+	PathCache *PathCache
+	// ResponseCache Cache of GET responses, not used if nil
+	ResponseCache *ResponseCache
+	// Timeouts Timeout of the requests by endpoint
+	Timeouts Timeouts
+	// APIKeyInQuery Send the api key as a query parameter instead of the
+	// X-Api-Key header, for old versions
+	APIKeyInQuery bool
+	// Version Version of the api, APIVersionLegacy or APIVersion3, detected
+	// on the first request if 0
+	Version int
+	// APIPath Path every endpoint is under, like /api/v1, instead of the one
+	// of the version
+	APIPath string
+	// Endpoints Names of the endpoints for forks that renamed them
+	Endpoints Endpoints
+	// Retry How requests failing with transient errors are retried, they
+	// aren't if it's the zero value
+	Retry Retry
+	// RateLimiter Throttles the requests to the instance, not used if nil
+	RateLimiter *RateLimiter
+	// Middlewares Wrap the transport of every request, the first one being
+	// the outermost
+	Middlewares []Middleware
+	// CommandWait How long and how often commands are checked until they
+	// complete
+	CommandWait CommandWait
+	detector    *versionDetector
 }
 
 // GetURL ...
@@ -101,9 +212,17 @@ func (a API) GetAPIKey() string {
 	return a.APIKey
 }
 
-// GetDownloadFolder ...
+// GetDownloadFolder Return the path of the main download folder
 func (a API) GetDownloadFolder() string {
-	return a.DownloadFolder
+	if len(a.DownloadFolders) == 0 {
+		return ""
+	}
+	return a.DownloadFolders[0].Path
+}
+
+// GetDownloadFolders ...
+func (a API) GetDownloadFolders() []DownloadFolder {
+	return a.DownloadFolders
 }
 
 // GetType ...
@@ -111,34 +230,48 @@ func (a API) GetType() string {
 	return a.Type
 }
 
+// GetName ...
+func (a API) GetName() string {
+	return a.Name
+}
+
+// GetPathCache ...
+func (a API) GetPathCache() *PathCache {
+	return a.PathCache
+}
+
 // Sonarr ...
 type Sonarr struct{ API }
 
-// NewSonarr Create an API
-func NewSonarr(url, apiKey, downloadFolder string) Sonarr {
-	return Sonarr{
-		API{
-			URL:            url,
-			APIKey:         apiKey,
-			DownloadFolder: downloadFolder,
-			Type:           TypeShow,
-		},
+// NewSonarr Create an API configured with the options
+func NewSonarr(url, apiKey, downloadFolder string, opts ...Option) Sonarr {
+	a := API{
+		Name:            "sonarr",
+		URL:             url,
+		APIKey:          apiKey,
+		DownloadFolders: []DownloadFolder{{Path: downloadFolder}},
+		Type:            TypeShow,
+		detector:        &versionDetector{},
 	}
+	a.apply(opts)
+	return Sonarr{a}
 }
 
 // Radarr ...
 type Radarr struct{ API }
 
-// NewRadarr Create an API
-func NewRadarr(url, apiKey, downloadFolder string) Radarr {
-	return Radarr{
-		API{
-			URL:            url,
-			APIKey:         apiKey,
-			DownloadFolder: downloadFolder,
-			Type:           TypeMovie,
-		},
+// NewRadarr Create an API configured with the options
+func NewRadarr(url, apiKey, downloadFolder string, opts ...Option) Radarr {
+	a := API{
+		Name:            "radarr",
+		URL:             url,
+		APIKey:          apiKey,
+		DownloadFolders: []DownloadFolder{{Path: downloadFolder}},
+		Type:            TypeMovie,
+		detector:        &versionDetector{},
 	}
+	a.apply(opts)
+	return Radarr{a}
 }
 
 // DownloadScan Create a command instance to force to rescan series form disk
@@ -146,9 +279,13 @@ func (s Sonarr) DownloadScan(path string) CommandBody {
 	return CommandBody{Name: "DownloadedEpisodesScan", Path: path}
 }
 
-// DownloadScan Create a command instance to force to rescan movies form disk
+// DownloadScan Create a command instance to force to rescan movies form
+// disk, only radarr v3 and later can import from a path
 func (r Radarr) DownloadScan(path string) CommandBody {
-	panic(fmt.Errorf("radarr doesn't implement DownloadScan"))
+	if r.version() < APIVersion3 {
+		panic(fmt.Errorf("radarr doesn't implement DownloadScan"))
+	}
+	return CommandBody{Name: "DownloadedMoviesScan", Path: path}
 }
 
 // ScanCommand Create a command instance to force to rescan series form disk
@@ -171,77 +308,105 @@ func (s Sonarr) RenameCommand(ids []int) CommandBody {
 
 // RenameCommand ...
 func (r Radarr) RenameCommand(ids []int) CommandBody {
+	name := "RenameMovies"
+	if r.version() >= APIVersion3 {
+		name = "RenameMovie"
+	}
 	return CommandBody{
-		Name:     "RenameMovies",
+		Name:     name,
 		MovieIds: ids,
 	}
 }
 
-// NewAPI Return an instance of an API
-func NewAPI(url, apiKey, downloadFolder, apiType string) RRAPI {
+// NewAPI Return an instance of an API configured with the options
+func NewAPI(url, apiKey, downloadFolder, apiType string, opts ...Option) RRAPI {
 	if apiType == TypeMovie {
-		return Radarr{
-			API{
-				URL:            url,
-				APIKey:         apiKey,
-				DownloadFolder: downloadFolder,
-				Type:           apiType,
-			},
-		}
+		return NewRadarr(url, apiKey, downloadFolder, opts...)
 	}
-	return Sonarr{
-		API{
-			URL:            url,
-			APIKey:         apiKey,
-			DownloadFolder: downloadFolder,
-			Type:           apiType,
-		},
+	if apiType == TypeMusic {
+		return NewLidarr(url, apiKey, downloadFolder, opts...)
 	}
+	a := NewSonarr(url, apiKey, downloadFolder, opts...)
+	a.Type = apiType
+	return a
 }
 
 // CheckFinishedDownloadsCommand ...
 func (a API) CheckFinishedDownloadsCommand() CommandBody {
+	if a.version() >= APIVersion3 {
+		return CommandBody{Name: "RefreshMonitoredDownloads"}
+	}
 	return CommandBody{
 		Name: "CheckForFinishedDownload",
 	}
 }
 
-// GetQueue ...
-func (a API) GetQueue() (queue []QueueElem, err error) {
-	body, err := get(a.getURL(APIQueueURL).String())
-	if err != nil {
-		return
+// DeleteQueueItem Remove the item from the queue, doing what the options say
+// with its download and release
+func (a API) DeleteQueueItem(ctx context.Context, id int, opts DeleteQueueOptions) (err error) {
+	if a.wouldDo(helpers.ActionQueueRemoval, "delete queue item %d%s", id, opts) {
+		return nil
 	}
-	err = json.Unmarshal(body, &queue)
-	return
+	u := a.getURL(APIQueueURL + "/" + strconv.Itoa(id))
+	u.RawQuery = opts.query(u.Query(), a.version()).Encode()
+	return a.delete(ctx, u)
 }
 
-// DeleteQueueItem ...
-func (a API) DeleteQueueItem(id int) (err error) {
-	u := a.getURL(APIQueueURL + "/" + strconv.Itoa(id)).String()
-	client := &http.Client{}
-	req, err := http.NewRequest("DELETE", u, nil)
+// delete Send a DELETE request to the url, transient errors are retried
+func (a API) delete(ctx context.Context, u *url.URL) error {
+	defer a.invalidate()
+	_, err := a.retry(ctx, true, func() ([]byte, error) {
+		return nil, a.deleteOnce(ctx, u)
+	})
+	return err
+}
+
+func (a API) deleteOnce(ctx context.Context, u *url.URL) (err error) {
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	err = a.RateLimiter.Wait(ctx)
 	if err != nil {
 		return
 	}
-	res, err := client.Do(req)
+	res, err := a.clientFor(req.URL.String()).Do(req)
 	if err != nil {
 		return
 	}
-	if res.StatusCode != 200 {
-		return fmt.Errorf("error deleting item from queue, status code %d", res.StatusCode)
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return newAPIError(req, res, body)
 	}
 	return nil
 }
 
 // GetHistory ...
-func (a API) GetHistory(page int) (history History, err error) {
+func (a API) GetHistory(ctx context.Context, page int) (history History, err error) {
+	return a.GetHistoryFiltered(ctx, page, HistoryOptions{})
+}
+
+// GetHistoryFiltered Return a page of the records matching the options,
+// filtered by the instance if FiltersHistory, by parserr otherwise
+func (a API) GetHistoryFiltered(ctx context.Context, page int, opts HistoryOptions) (history History, err error) {
 	u := a.getURL(APIHistoryURL)
 	query := u.Query()
 	query.Add("page", strconv.Itoa(page))
-	query.Add("pageSize", "10")
+	query.Add("pageSize", strconv.Itoa(a.historyPageSize()))
+	if a.FiltersHistory() {
+		query.Add("sortKey", "date")
+		query.Add("sortDirection", "descending")
+		query.Add("includeSeries", "true")
+		query.Add("includeEpisode", "true")
+		query.Add("includeMovie", "true")
+		query.Add("includeArtist", "true")
+		query.Add("includeAlbum", "true")
+		opts.addTo(query)
+	}
 	u.RawQuery = query.Encode()
-	body, err := get(u.String())
+	body, err := a.get(ctx, u.String())
 	if err != nil {
 		return
 	}
@@ -249,13 +414,55 @@ func (a API) GetHistory(page int) (history History, err error) {
 	if history.PageSize == 0 {
 		return history, fmt.Errorf("history fetched 0 results, no more items")
 	}
+	if !a.FiltersHistory() {
+		history.Records = opts.filter(history.Records)
+	}
 	return
 }
 
+// FiltersHistory Return true if the instance filters the history, the v3
+// api does
+func (a API) FiltersHistory() bool {
+	return a.version() >= APIVersion3
+}
+
+func (a API) historyPageSize() int {
+	if a.HistoryPageSize <= 0 {
+		return DefaultHistoryPageSize
+	}
+	return a.HistoryPageSize
+}
+
+// WalkHistory Call fn with every history record, from the newest to the
+// oldest, fetching one page at a time so only a page is kept in memory.
+// The walk ends when fn returns true or there are no more records.
+func WalkHistory(ctx context.Context, a RRAPI, fn func(hr HistoryRec) (stop bool)) error {
+	return WalkFilteredHistory(ctx, a, HistoryOptions{}, fn)
+}
+
+// WalkFilteredHistory WalkHistory over the records matching the options
+func WalkFilteredHistory(ctx context.Context, a RRAPI, opts HistoryOptions, fn func(hr HistoryRec) (stop bool)) error {
+	for page := 1; ; page++ {
+		history, err := a.GetHistoryFiltered(ctx, page, opts)
+		if err != nil {
+			return err
+		}
+		// pages filtered by parserr can be empty before the last one
+		if len(history.Records) == 0 && page*history.PageSize >= history.TotalRecords {
+			return nil
+		}
+		for _, hr := range history.Records {
+			if fn(hr) {
+				return nil
+			}
+		}
+	}
+}
+
 // GetEpisode ...
-func (a API) GetEpisode(id int) (episode Episode, err error) {
+func (a API) GetEpisode(ctx context.Context, id int) (episode Episode, err error) {
 	u := a.getURL(APIEpisodeURL + "/" + strconv.Itoa(id))
-	body, err := get(u.String())
+	body, err := a.get(ctx, u.String())
 	if err != nil {
 		return
 	}
@@ -263,10 +470,24 @@ func (a API) GetEpisode(id int) (episode Episode, err error) {
 	return
 }
 
+// GetEpisodesBySeries Return every episode of the series
+func (a API) GetEpisodesBySeries(ctx context.Context, seriesID int) (episodes []Episode, err error) {
+	u := a.getURL(APIEpisodeURL)
+	query := u.Query()
+	query.Set("seriesId", strconv.Itoa(seriesID))
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &episodes)
+	return
+}
+
 // GetMovie ...
-func (a API) GetMovie(id int) (movie Movie, err error) {
+func (a API) GetMovie(ctx context.Context, id int) (movie Movie, err error) {
 	u := a.getURL(APIMovieURL + "/" + strconv.Itoa(id))
-	body, err := get(u.String())
+	body, err := a.get(ctx, u.String())
 	if err != nil {
 		return
 	}
@@ -274,92 +495,402 @@ func (a API) GetMovie(id int) (movie Movie, err error) {
 	return
 }
 
+// GetSeriesByID ...
+func (a API) GetSeriesByID(ctx context.Context, id int) (series Series, err error) {
+	u := a.getURL(APISeriesURL + "/" + strconv.Itoa(id))
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &series)
+	return
+}
+
+// GetEpisodeFile ...
+func (a API) GetEpisodeFile(ctx context.Context, id int) (file MediaFile, err error) {
+	u := a.getURL(APIEpisodeFileURL + "/" + strconv.Itoa(id))
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &file)
+	return
+}
+
+// GetEpisodeFiles Return the files of the series in the library
+func (a API) GetEpisodeFiles(ctx context.Context, seriesID int) (files []MediaFile, err error) {
+	u := a.getURL(APIEpisodeFileURL)
+	query := u.Query()
+	query.Set("seriesId", strconv.Itoa(seriesID))
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &files)
+	return
+}
+
+// DeleteEpisodeFile Delete the file from the library and from disk, into the
+// recycle bin if the instance has one
+func (a API) DeleteEpisodeFile(ctx context.Context, id int) error {
+	if a.wouldDo(helpers.ActionDelete, "delete episode file %d", id) {
+		return nil
+	}
+	return a.delete(ctx, a.getURL(APIEpisodeFileURL+"/"+strconv.Itoa(id)))
+}
+
+// GetPath Return the path of the series, movie or artist with the given id,
+// depending on the type of the api
+func (a API) GetPath(ctx context.Context, id int) (path string, err error) {
+	if a.PathCache != nil {
+		if path, ok := a.PathCache.Get(id); ok {
+			return path, nil
+		}
+	}
+	if a.Type == TypeMovie {
+		var movie Movie
+		movie, err = a.GetMovie(ctx, id)
+		path = movie.Path
+	} else if a.Type == TypeMusic {
+		var artist Artist
+		artist, err = a.GetArtist(ctx, id)
+		path = artist.Path
+	} else {
+		var series Series
+		series, err = a.GetSeriesByID(ctx, id)
+		path = series.Path
+	}
+	if err != nil {
+		return
+	}
+	if a.PathCache != nil {
+		a.PathCache.Set(id, path)
+	}
+	return
+}
+
 // ExecuteCommand ...
-func (a API) ExecuteCommand(c CommandBody) (cs CommandStatus, err error) {
-	log.Printf("executing: %s", c.Name)
+func (a API) ExecuteCommand(ctx context.Context, c CommandBody) (cs CommandStatus, err error) {
+	return a.executeCommand(ctx, c, true)
+}
+
+// executeCommand Send the command, resending it if it couldn't reach the
+// instance only if resend
+func (a API) executeCommand(ctx context.Context, c CommandBody, resend bool) (cs CommandStatus, err error) {
+	if a.wouldDo(helpers.ActionChange, "execute %s", c.Name) {
+		cs.Name = c.Name
+		cs.State = CommandStateCompleted
+		return
+	}
+	helpers.Logf(ctx, "executing: %s", c.Name)
 	j, err := json.Marshal(c)
 	if err != nil {
 		return
 	}
-	body, err := post(a.getURL(APICommandURL).String(), bytes.NewReader(j))
-	err = json.Unmarshal(body, &cs)
-	return
+	u := a.getURL(APICommandURL).String()
+	var body []byte
+	if resend {
+		body, err = a.post(ctx, u, bytes.NewReader(j))
+	} else {
+		body, err = a.send(ctx, "POST", u, j)
+		a.invalidate()
+	}
+	if err != nil {
+		return
+	}
+	if a.PathCache != nil && isRescan(c) {
+		a.PathCache.Invalidate()
+	}
+	return decodeCommandStatus(body)
+}
+
+// isRescan Return true if the command can change series, movie or artist paths
+func isRescan(c CommandBody) bool {
+	switch c.Name {
+	case "RescanSeries", "RescanMovie", "RescanArtist",
+		"DownloadedEpisodesScan", "DownloadedMoviesScan", "DownloadedAlbumsScan":
+		return true
+	}
+	return false
 }
 
-// ExecuteCommandAndWait ...
-func (a API) ExecuteCommandAndWait(c CommandBody, retries int) (cs CommandStatus, err error) {
+// ExecuteCommandAndWait Execute the command and wait until it's completed,
+// stops waiting as soon as the context is done, the instance rejects the
+// command with an error that retrying won't fix or the command fails, is
+// aborted or cancelled. Commands not finished in time are sent again.
+func (a API) ExecuteCommandAndWait(ctx context.Context, c CommandBody, retries int) (cs CommandStatus, err error) {
 	for i := 0; i < retries; i++ {
-		cs, err = a.ExecuteCommand(c)
+		cs, err = a.ExecuteCommand(ctx, c)
+		if ctx.Err() != nil {
+			return cs, ctx.Err()
+		}
+		if apiErr, ok := IsAPIError(err); ok && !apiErr.Temporary() {
+			return cs, err
+		}
 		if err != nil {
 			continue
 		}
-		totalWait := CheckInterval
-		for totalWait <= MaxTime {
-			time.Sleep(CheckInterval)
-			cs, err = a.GetCommandStatus(cs.ID)
-			if err == nil {
-				if cs.State == CommandStateCompleted {
-					log.Printf("finished %s successfully", c.Name)
-					return
-				}
-				log.Printf("waiting response from %s", c.Name)
-			}
-			totalWait += CheckInterval
+		cs, err = a.waitCommand(ctx, c.Name, cs)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if _, ok := err.(CommandFailedError); ok {
+			return
 		}
 		if i != retries-1 {
-			log.Printf("timeout, retring another time: %d of %d", i+1, retries)
+			helpers.Logf(ctx, "%s, retring another time: %d of %d", err, i+1, retries)
 		}
 	}
 	return cs, fmt.Errorf("timeout checking command %s, not completed", c.Name)
 }
 
+// waitCommand Watch the command until it finishes, returning an error if it
+// isn't completed, a CommandFailedError if it won't ever be
+func (a API) waitCommand(ctx context.Context, name string, cs CommandStatus) (CommandStatus, error) {
+	if cs.State == CommandStateCompleted {
+		return cs, nil
+	}
+	var err error
+	for update := range a.WatchCommand(ctx, cs.ID) {
+		if update.Status.State != "" {
+			cs = update.Status
+		}
+		err = update.Err
+		if err == nil && !cs.Finished() {
+			helpers.Logf(ctx, "waiting response from %s", name)
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return cs, err
+	}
+	switch cs.State {
+	case CommandStateCompleted:
+		helpers.Logf(ctx, "finished %s successfully", name)
+		return cs, nil
+	case CommandStateFailed, CommandStateAborted, CommandStateCancelled:
+		return cs, CommandFailedError{Name: name, State: cs.State}
+	}
+	return cs, fmt.Errorf("command %s %s, not completed", name, cs.State)
+}
+
 // GetCommandStatus ...
-func (a API) GetCommandStatus(id int) (cs CommandStatus, err error) {
+func (a API) GetCommandStatus(ctx context.Context, id int) (cs CommandStatus, err error) {
 	u := a.getURL(APICommandURL + "/" + strconv.Itoa(id))
-	body, err := get(u.String())
+	// The status is polled until completion, it's never cached
+	uncached := a
+	uncached.ResponseCache = nil
+	body, err := uncached.get(ctx, u.String())
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(body, &cs)
+	cs, err = decodeCommandStatus(body)
+	if cs.State == CommandStateCompleted {
+		a.invalidate()
+	}
 	return
 }
 
+// wouldDo Return true and log the mutation if the api is read-only,
+// in which case the mutation must not be executed
+func (a API) wouldDo(kind, format string, args ...interface{}) bool {
+	if !a.ReadOnly {
+		return false
+	}
+	log.Printf("read-only, would "+format, args...)
+	helpers.Planned(kind, format, args...)
+	return true
+}
+
+// httpClient Return the client used for every request
+func (a API) httpClient() *http.Client {
+	if a.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return a.HTTPClient
+}
+
+// clientFor Return the http client with the timeout of the endpoint of the
+// url, sending the api key in the headers unless it goes in the query and
+// the requests through the middlewares
+func (a API) clientFor(u string) *http.Client {
+	c := *a.httpClient()
+	if timeout := a.Timeouts.For(u); timeout > 0 {
+		c.Timeout = timeout
+	}
+	if !a.APIKeyInQuery {
+		c.Transport = APIKeyTransport{APIKey: a.APIKey, Base: c.Transport}
+	}
+	c.Transport = a.wrap(c.Transport)
+	return &c
+}
+
 // get Wrapper for http.Get. Add authentication handling automatically.
-func get(u string) (body []byte, err error) {
-	res, err := http.Get(u)
+// Error status codes are returned as APIError, transient errors are retried.
+func (a API) get(ctx context.Context, u string) ([]byte, error) {
+	return a.retry(ctx, true, func() ([]byte, error) {
+		return a.getOnce(ctx, u)
+	})
+}
+
+func (a API) getOnce(ctx context.Context, u string) (body []byte, err error) {
+	cache := a.ResponseCache
+	now := ClockOrReal(a.Clock).Now()
+	if cache != nil && !uncached(ctx) {
+		if body, ok := cache.fresh(u, now); ok {
+			return body, nil
+		}
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	if cache != nil {
+		cache.prepare(req)
+	}
+	err = a.RateLimiter.Wait(ctx)
 	if err != nil {
 		return
 	}
-	if res.StatusCode == 401 {
-		return nil, fmt.Errorf("authorization invalid")
+	res, err := a.clientFor(req.URL.String()).Do(req)
+	if err != nil {
+		return
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified && cache != nil {
+		if body, ok := cache.notModified(u, now); ok {
+			return body, nil
+		}
+	}
 	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	if res.StatusCode >= 300 {
+		return nil, newAPIError(req, res, body)
+	}
+	err = a.checkJSON(res, body)
+	if err == nil && cache != nil && res.StatusCode == http.StatusOK {
+		cache.store(u, res, body, now)
+	}
 	return
 }
 
+// checkJSON Return an error if the response is HTML instead of JSON, like
+// login pages or proxy errors returned when the url is wrong
+func (a API) checkJSON(res *http.Response, body []byte) error {
+	trimmed := bytes.TrimSpace(body)
+	html := strings.Contains(res.Header.Get("Content-Type"), "text/html") ||
+		bytes.HasPrefix(trimmed, []byte("<"))
+	if !html {
+		return nil
+	}
+	if len(trimmed) > 100 {
+		trimmed = trimmed[:100]
+	}
+	return fmt.Errorf("%s does not appear to be a sonarr/radarr/lidarr api (got HTML), check the url base: %s",
+		a.URL, trimmed)
+}
+
+// invalidate Forget the cached responses after modifying the instance
+func (a API) invalidate() {
+	if a.ResponseCache != nil {
+		a.ResponseCache.Invalidate()
+	}
+}
+
 // post Wrapper for http.Post. Add authentication handling automatically.
-func post(u string, bodyReq io.Reader) (body []byte, err error) {
-	res, err := http.Post(u, "application/json", bodyReq)
+// Error status codes are returned as APIError. POST requests, like
+// commands, aren't idempotent, so they are only retried when they couldn't
+// be sent at all.
+func (a API) post(ctx context.Context, u string, bodyReq io.Reader) ([]byte, error) {
+	defer a.invalidate()
+	j, err := ioutil.ReadAll(bodyReq)
+	if err != nil {
+		return nil, err
+	}
+	return a.retry(ctx, false, func() ([]byte, error) {
+		return a.send(ctx, "POST", u, j)
+	})
+}
+
+// put Send the body with a PUT request. Add authentication handling
+// automatically. Error status codes are returned as APIError, transient
+// errors are retried.
+func (a API) put(ctx context.Context, u string, bodyReq io.Reader) ([]byte, error) {
+	defer a.invalidate()
+	j, err := ioutil.ReadAll(bodyReq)
+	if err != nil {
+		return nil, err
+	}
+	return a.retry(ctx, true, func() ([]byte, error) {
+		return a.send(ctx, "PUT", u, j)
+	})
+}
+
+// send Send the JSON body with the method once
+func (a API) send(ctx context.Context, method, u string, j []byte) (body []byte, err error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(j))
 	if err != nil {
 		return
 	}
-	if res.StatusCode == 401 {
-		return nil, fmt.Errorf("authorization invalid")
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	err = a.RateLimiter.Wait(ctx)
+	if err != nil {
+		return
+	}
+	res, err := a.clientFor(u).Do(req)
+	if err != nil {
+		return
 	}
 	defer res.Body.Close()
 	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	if res.StatusCode >= 300 {
+		return nil, newAPIError(req, res, body)
+	}
+	err = a.checkJSON(res, body)
 	return
 }
 
+// GetWebURL Return the address of a page of the web interface
+func (a API) GetWebURL(path string) string {
+	u := a.getURL(path)
+	u.RawQuery = ""
+	return u.String()
+}
+
+// baseURL Return the scheme, host and url base of the instance. URL can be
+// a base url or a bare host, which uses http unless HTTPS is set, and
+// URLBase is added to its path.
+func (a API) baseURL() *url.URL {
+	u := &url.URL{Scheme: "http", Host: a.URL}
+	if strings.Contains(a.URL, "://") {
+		if parsed, err := url.Parse(a.URL); err == nil {
+			u = parsed
+		}
+	}
+	if a.HTTPS {
+		u.Scheme = "https"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + a.URLBase
+	return u
+}
+
 func (a API) getURL(path string) *url.URL {
-	u := &url.URL{
-		Scheme: "http",
-		Host:   a.URL,
-		Path:   path,
-	}
-	q := u.Query()
-	q.Set("apikey", a.APIKey)
-	u.RawQuery = q.Encode()
+	u := a.baseURL()
+	u.Path += a.versionedPath(path)
+	if a.APIKeyInQuery {
+		q := u.Query()
+		q.Set("apikey", a.APIKey)
+		u.RawQuery = q.Encode()
+	}
 	return u
 }
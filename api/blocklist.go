@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"parserr/helpers"
+	"strconv"
+	"time"
+)
+
+const (
+	// APIBlocklistURL Blocklist of sonarr v4, radarr v4 and lidarr
+	APIBlocklistURL = APIURL + "/blocklist"
+	// APIBlacklistURL Blocklist of older versions, before it was renamed
+	APIBlacklistURL = APIURL + "/blacklist"
+)
+
+// Blocklist Page of the releases that won't be downloaded again
+type Blocklist struct {
+	Page         int
+	PageSize     int
+	TotalRecords int
+	Records      []BlocklistItem
+}
+
+// BlocklistItem Release that won't be downloaded again
+type BlocklistItem struct {
+	ID          int
+	SeriesID    int
+	EpisodeIDs  []int
+	MovieID     int
+	ArtistID    int
+	AlbumIDs    []int
+	SourceTitle string
+	Date        time.Time
+	Protocol    string
+	Indexer     string
+	Message     string
+}
+
+// AddToBlocklist Remove the item from the queue and blocklist its release
+// so it isn't downloaded again
+func (a API) AddToBlocklist(ctx context.Context, queueID int) (err error) {
+	return a.DeleteQueueItem(ctx, queueID, DeleteQueueOptions{Blocklist: true})
+}
+
+// DeleteQueueOptions What is done with the download and the release of a
+// queue item when it's removed, the zero value removes the download from the
+// download client and leaves the release allowed
+type DeleteQueueOptions struct {
+	// Blocklist The release won't be downloaded again
+	Blocklist bool
+	// KeepInClient The download is left in the download client, so it keeps
+	// seeding, instead of being removed with its files
+	KeepInClient bool
+	// SkipRedownload No other release is searched once blocklisted
+	SkipRedownload bool
+}
+
+// String Describe the options for logs, empty for the zero value
+func (o DeleteQueueOptions) String() (s string) {
+	if o.Blocklist {
+		s += ", blocklisting its release"
+	}
+	if o.KeepInClient {
+		s += ", keeping its download in the client"
+	}
+	if o.SkipRedownload {
+		s += ", without searching another release"
+	}
+	return
+}
+
+// query Add the parameters of the options to the query of the delete
+// request for the version of the api
+func (o DeleteQueueOptions) query(query url.Values, version int) url.Values {
+	query.Set("removeFromClient", strconv.FormatBool(!o.KeepInClient))
+	if o.Blocklist {
+		query.Set("blacklist", "true")
+		if version >= APIVersion3 {
+			// Renamed in sonarr v4
+			query.Set("blocklist", "true")
+		}
+	}
+	if o.SkipRedownload {
+		query.Set("skipRedownload", "true")
+	}
+	return query
+}
+
+// GetBlocklist Return a page of the blocklist, newest first
+func (a API) GetBlocklist(ctx context.Context, page int) (blocklist Blocklist, err error) {
+	var body []byte
+	err = a.blocklistEndpoint(func(endpoint string) error {
+		u := a.getURL(endpoint)
+		query := u.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("pageSize", strconv.Itoa(a.historyPageSize()))
+		query.Set("sortKey", "date")
+		query.Set("sortDirection", "descending")
+		u.RawQuery = query.Encode()
+		body, err = a.get(ctx, u.String())
+		return err
+	})
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &blocklist)
+	return
+}
+
+// DeleteBlocklistItem Remove the release from the blocklist so it can be
+// downloaded again
+func (a API) DeleteBlocklistItem(ctx context.Context, id int) error {
+	if a.wouldDo(helpers.ActionDelete, "delete blocklist item %d", id) {
+		return nil
+	}
+	return a.blocklistEndpoint(func(endpoint string) error {
+		return a.delete(ctx, a.getURL(endpoint+"/"+strconv.Itoa(id)))
+	})
+}
+
+// blocklistEndpoint Call fn with the blocklist endpoint of the instance,
+// instances of the v3 api are asked for the renamed one first and for the
+// old one if they don't know it
+func (a API) blocklistEndpoint(fn func(endpoint string) error) error {
+	if a.version() < APIVersion3 {
+		return fn(APIBlacklistURL)
+	}
+	err := fn(APIBlocklistURL)
+	if apiErr, ok := IsAPIError(err); ok && apiErr.NotFound() {
+		return fn(APIBlacklistURL)
+	}
+	return err
+}
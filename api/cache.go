@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PathCache Paths of series and movies by id, invalidated after rescans
+// since they can change the paths. Safe for concurrent use.
+type PathCache struct {
+	paths  map[int]string
+	hits   int
+	misses int
+	mutex  sync.Mutex
+}
+
+// NewPathCache ...
+func NewPathCache() *PathCache {
+	return &PathCache{paths: make(map[int]string)}
+}
+
+// Get Return the cached path of the id
+func (c *PathCache) Get(id int) (path string, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	path, ok = c.paths[id]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return
+}
+
+// Set ...
+func (c *PathCache) Set(id int, path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paths[id] = path
+}
+
+// Invalidate Forget every path
+func (c *PathCache) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paths = make(map[int]string)
+}
+
+// Stats Return the hits and misses of the cache
+func (c *PathCache) Stats() (hits, misses int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *PathCache) String() string {
+	hits, misses := c.Stats()
+	rate := 0.0
+	if hits+misses > 0 {
+		rate = float64(hits) / float64(hits+misses) * 100
+	}
+	return fmt.Sprintf("path cache: %d hits, %d misses, %.0f%% hit rate", hits, misses, rate)
+}
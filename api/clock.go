@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock Source of time used when waiting, replaceable so waits can be
+// simulated without actually sleeping
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// RealClock Clock backed by the time package
+type RealClock struct{}
+
+// Now ...
+func (c RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep ...
+func (c RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// FakeClock Clock that never sleeps, sleeping just moves its time forward
+type FakeClock struct {
+	Time  time.Time
+	mutex sync.Mutex
+}
+
+// Now ...
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.Time
+}
+
+// Sleep ...
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Time = c.Time.Add(d)
+}
+
+// ClockOrReal Return the clock or a RealClock if it's nil
+func ClockOrReal(c Clock) Clock {
+	if c == nil {
+		return RealClock{}
+	}
+	return c
+}
+
+// SleepContext Sleep using the clock, returning the error of the context as
+// soon as it's done. Only a RealClock can be interrupted while sleeping.
+func SleepContext(ctx context.Context, clock Clock, d time.Duration) error {
+	if _, ok := clock.(RealClock); ok {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
+		}
+	}
+	clock.Sleep(d)
+	return ctx.Err()
+}
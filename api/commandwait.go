@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvCommandTimeout Max time to wait for a command to complete before
+	// sending it again, MaxTime if empty
+	EnvCommandTimeout = "PARSERR_COMMAND_TIMEOUT"
+	// EnvCommandTimeouts Comma separated list of command=timeout overriding
+	// EnvCommandTimeout, like RescanSeries=10m
+	EnvCommandTimeouts = "PARSERR_COMMAND_TIMEOUTS"
+	// EnvCommandInterval Time between checks of the status of a command,
+	// CheckInterval if empty
+	EnvCommandInterval = "PARSERR_COMMAND_INTERVAL"
+	// EnvCommandMaxInterval The time between checks is doubled after every
+	// one up to this time, it's fixed if empty
+	EnvCommandMaxInterval = "PARSERR_COMMAND_MAX_INTERVAL"
+)
+
+// CommandWait How ExecuteCommandAndWait waits for the commands, the zero
+// value checks every CheckInterval for up to MaxTime
+type CommandWait struct {
+	// Timeout Max time to wait for a command, MaxTime if 0
+	Timeout time.Duration
+	// ByCommand Timeouts of the commands by name, overriding Timeout
+	ByCommand map[string]time.Duration
+	// Interval Time before the first check, CheckInterval if 0
+	Interval time.Duration
+	// MaxInterval Longest time between checks, the interval is doubled
+	// after every check until reaching it. Fixed if not longer than Interval.
+	MaxInterval time.Duration
+}
+
+// ParseCommandTimeouts Parse a list like RescanSeries=10m,DownloadedEpisodesScan=5m
+func ParseCommandTimeouts(value string) (timeouts map[string]time.Duration, err error) {
+	timeouts = make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid command timeout %q, use command=duration", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid command timeout %q, use a positive duration", entry)
+		}
+		timeouts[strings.ToLower(strings.TrimSpace(parts[0]))] = d
+	}
+	return timeouts, nil
+}
+
+// WithCommandWait Wait for the commands as w says
+func WithCommandWait(w CommandWait) Option {
+	return func(a *API) {
+		a.CommandWait = w
+	}
+}
+
+// timeout Return the time to wait for the command with the name
+func (w CommandWait) timeout(name string) time.Duration {
+	if d, ok := w.ByCommand[strings.ToLower(name)]; ok {
+		return d
+	}
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	return MaxTime
+}
+
+// first Return the time to wait before the first check
+func (w CommandWait) first() time.Duration {
+	if w.Interval > 0 {
+		return w.Interval
+	}
+	return CheckInterval
+}
+
+// next Return the time to wait after waiting d
+func (w CommandWait) next(d time.Duration) time.Duration {
+	if w.MaxInterval <= d {
+		return d
+	}
+	d *= 2
+	if d > w.MaxInterval {
+		d = w.MaxInterval
+	}
+	return d
+}
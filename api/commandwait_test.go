@@ -0,0 +1,46 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCommandTimeouts(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]time.Duration
+		err   bool
+	}{
+		{"", map[string]time.Duration{}, false},
+		{"RescanSeries=10m, DownloadedEpisodesScan = 5m", map[string]time.Duration{"rescanseries": 10 * time.Minute, "downloadedepisodesscan": 5 * time.Minute}, false},
+		{"RescanSeries", nil, true},
+		{"RescanSeries=ten", nil, true},
+		{"RescanSeries=-1m", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCommandTimeouts(tt.value)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCommandTimeouts(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestCommandWaitNext(t *testing.T) {
+	tests := []struct {
+		max  time.Duration
+		d    time.Duration
+		want time.Duration
+	}{
+		{0, 2 * time.Second, 2 * time.Second},
+		{time.Second, 2 * time.Second, 2 * time.Second},
+		{10 * time.Second, 2 * time.Second, 4 * time.Second},
+		{10 * time.Second, 8 * time.Second, 10 * time.Second},
+		{10 * time.Second, 10 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := (CommandWait{MaxInterval: tt.max}).next(tt.d); got != tt.want {
+			t.Errorf("next(%s) with max %s = %s, want %s", tt.d, tt.max, got, tt.want)
+		}
+	}
+}
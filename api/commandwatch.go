@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// CommandUpdate State of a watched command. Updates with an error are the
+// last ones, the command couldn't be checked anymore.
+type CommandUpdate struct {
+	Status CommandStatus
+	Err    error
+}
+
+// CommandFailedError Returned when a command finished without completing,
+// sending it again would fail the same way
+type CommandFailedError struct {
+	Name  string
+	State string
+}
+
+func (e CommandFailedError) Error() string {
+	return fmt.Sprintf("command %s %s", e.Name, e.State)
+}
+
+// WatchCommand Check the command with the id in the background as the
+// CommandWait of the api says, delivering an update every time its state
+// changes. The channel is closed once the command finishes, the wait times
+// out or the context is done, after delivering why. Commands sent in
+// read-only mode, which have no id, are reported as completed.
+func (a API) WatchCommand(ctx context.Context, id int) <-chan CommandUpdate {
+	updates := make(chan CommandUpdate, 1)
+	if id == 0 && a.ReadOnly {
+		updates <- CommandUpdate{Status: CommandStatus{State: CommandStateCompleted}}
+		close(updates)
+		return updates
+	}
+	go a.watchCommand(ctx, id, updates)
+	return updates
+}
+
+// watchCommand Poll the command until it finishes, closing updates
+func (a API) watchCommand(ctx context.Context, id int, updates chan<- CommandUpdate) {
+	defer close(updates)
+	clock := ClockOrReal(a.Clock)
+	send := func(u CommandUpdate) bool {
+		select {
+		case updates <- u:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	cs, err := a.GetCommandStatus(ctx, id)
+	if err != nil {
+		send(CommandUpdate{Err: err})
+		return
+	}
+	if !send(CommandUpdate{Status: cs}) || cs.Finished() {
+		return
+	}
+	deadline := clock.Now().Add(a.CommandWait.timeout(cs.Name))
+	interval := a.CommandWait.first()
+	for {
+		wait := interval
+		if left := deadline.Sub(clock.Now()); wait > left {
+			wait = left
+		}
+		if err := SleepContext(ctx, clock, wait); err != nil {
+			send(CommandUpdate{Status: cs, Err: err})
+			return
+		}
+		interval = a.CommandWait.next(interval)
+		current, err := a.GetCommandStatus(ctx, id)
+		if err != nil {
+			send(CommandUpdate{Status: cs, Err: err})
+			return
+		}
+		changed := current.State != cs.State
+		cs = current
+		if changed && !send(CommandUpdate{Status: cs}) {
+			return
+		}
+		if cs.Finished() {
+			return
+		}
+		if !clock.Now().Before(deadline) {
+			send(CommandUpdate{Status: cs, Err: fmt.Errorf("timeout watching command %s, not finished", cs.Name)})
+			return
+		}
+	}
+}
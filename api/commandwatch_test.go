@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// commandServer Server running every command sent, reporting it with the
+// state when checked
+func commandServer(state string, posts *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "POST" {
+			atomic.AddInt32(posts, 1)
+			w.Write([]byte(`{"id":1,"name":"RescanSeries","state":"started"}`))
+			return
+		}
+		w.Write([]byte(`{"id":1,"name":"RescanSeries","state":"` + state + `"}`))
+	}))
+}
+
+func TestExecuteCommandAndWait(t *testing.T) {
+	tests := []struct {
+		state     string
+		wantErr   bool
+		wantPosts int32
+	}{
+		{CommandStateCompleted, false, 1},
+		{CommandStateFailed, true, 1},
+		{CommandStateAborted, true, 1},
+		{CommandStateCancelled, true, 1},
+		{CommandStateOrphaned, true, 3},
+		{"started", true, 3},
+	}
+	for _, tt := range tests {
+		var posts int32
+		srv := commandServer(tt.state, &posts)
+		a := NewSonarr(srv.URL, "key", "/downloads", WithVersion(APIVersion3)).API
+		a.Clock = &FakeClock{Time: time.Now()}
+		_, err := a.ExecuteCommandAndWait(context.Background(), CommandBody{Name: "RescanSeries"}, 3)
+		srv.Close()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.state, err, tt.wantErr)
+		}
+		if posts != tt.wantPosts {
+			t.Errorf("%s: sent %d times, want %d", tt.state, posts, tt.wantPosts)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// APIDiskSpaceURL ...
+const APIDiskSpaceURL = APIURL + "/diskspace"
+
+// DiskSpace Space of a disk mounted on the host of the instance
+type DiskSpace struct {
+	// Path Where the disk is mounted
+	Path       string
+	Label      string
+	FreeSpace  int64
+	TotalSpace int64
+}
+
+// GetDiskSpace Return the space of every disk the instance sees
+func (a API) GetDiskSpace(ctx context.Context) (disks []DiskSpace, err error) {
+	body, err := a.get(ctx, a.getURL(APIDiskSpaceURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &disks)
+	return
+}
+
+// DiskSpaceOf Return the disk the path is in, the one mounted deepest
+func DiskSpaceOf(disks []DiskSpace, p string) (disk DiskSpace, ok bool) {
+	p = path.Clean(p)
+	for _, d := range disks {
+		mount := path.Clean(d.Path)
+		if p != mount && !strings.HasPrefix(p, strings.TrimSuffix(mount, "/")+"/") {
+			continue
+		}
+		if !ok || len(mount) > len(path.Clean(disk.Path)) {
+			disk, ok = d, true
+		}
+	}
+	return
+}
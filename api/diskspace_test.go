@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+func TestDiskSpaceOf(t *testing.T) {
+	disks := []DiskSpace{{Path: "/"}, {Path: "/mnt/media"}, {Path: "/mnt/media/tv/"}, {Path: "/mnt/media2"}}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/mnt/media/tv/Show/Season 01", "/mnt/media/tv/", true},
+		{"/mnt/media/movies", "/mnt/media", true},
+		{"/mnt/media", "/mnt/media", true},
+		{"/mnt/media2/tv", "/mnt/media2", true},
+		{"/mnt/mediaX/tv", "/", true},
+		{"/data/../mnt/media/tv", "/mnt/media/tv/", true},
+	}
+	for _, tt := range tests {
+		disk, ok := DiskSpaceOf(disks, tt.path)
+		if ok != tt.ok || disk.Path != tt.want {
+			t.Errorf("DiskSpaceOf(%s) = %s, %v, want %s", tt.path, disk.Path, ok, tt.want)
+		}
+	}
+	if _, ok := DiskSpaceOf(disks[1:], "/data/tv"); ok {
+		t.Error("found the disk of a path on no disk")
+	}
+}
@@ -0,0 +1,10 @@
+// Package api Clients for the Sonarr, Radarr and Lidarr APIs.
+//
+// Sonarr, Radarr, Lidarr and API values are safe for concurrent use by multiple
+// goroutines: their methods have value receivers and never modify the client,
+// and the http.Client they use is safe for concurrent use. Fields must not be
+// modified once the client is being shared. Clocks, transports and limiters
+// provided by this package (RealClock, FakeClock, RecordingTransport,
+// RateLimiter) are safe for concurrent use too, custom ones shared between
+// clients must be as well.
+package api
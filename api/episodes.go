@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// episodeNumberPatterns Season and episode numbers in names like S05E27 or 5x27
+var episodeNumberPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|[^a-z0-9])s([0-9]{1,2})[ ._-]?e([0-9]{1,3})(?:[^0-9]|$)`),
+	regexp.MustCompile(`(?:^|[^0-9])([0-9]{1,2})x([0-9]{2,3})(?:[^0-9]|$)`),
+}
+
+// absoluteNumberPatterns Absolute numbers in anime names like
+// "[Group] Show - 123 [1080p]", "Show - 123v2" or "Show.E123", the first
+// group is the part naming the episode and the second one the number
+var absoluteNumberPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)[ ._]-[ ._]?(([0-9]{1,4})(?:v[0-9])?)(?:[ ._\[\(]|$)`),
+	regexp.MustCompile(`(?i)(?:^|[ ._\]])((?:e|ep)([0-9]{1,4})(?:v[0-9])?)(?:[ ._\[\(]|$)`),
+}
+
+// yearPattern Years in names like "Show - 2024", never absolute numbers
+var yearPattern = regexp.MustCompile(`^(?:19|20)[0-9]{2}$`)
+
+// EpisodeNumbers Return the season and episode numbers of the name, ok is
+// false if it has none
+func EpisodeNumbers(name string) (season, episode int, ok bool) {
+	for _, pattern := range episodeNumberPatterns {
+		match := pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		season, _ = strconv.Atoi(match[1])
+		episode, _ = strconv.Atoi(match[2])
+		return season, episode, true
+	}
+	return 0, 0, false
+}
+
+// AbsoluteNumber Return the absolute episode number of a name without
+// season and episode numbers, ok is false if it has none
+func AbsoluteNumber(name string) (absolute int, ok bool) {
+	if _, _, ok := EpisodeNumbers(name); ok {
+		return 0, false
+	}
+	loc := absoluteNumberLoc(name)
+	if loc == nil {
+		return 0, false
+	}
+	absolute, _ = strconv.Atoi(name[loc[4]:loc[5]])
+	return absolute, absolute > 0
+}
+
+// absoluteNumberLoc Return the location of the absolute number in the name
+// without its extension, like regexp.FindStringSubmatchIndex. Years are
+// skipped, the number can come after them.
+func absoluteNumberLoc(name string) []int {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	for _, pattern := range absoluteNumberPatterns {
+		for offset := 0; offset < len(name); {
+			loc := pattern.FindStringSubmatchIndex(name[offset:])
+			if loc == nil {
+				break
+			}
+			for i := range loc {
+				if loc[i] >= 0 {
+					loc[i] += offset
+				}
+			}
+			if !yearPattern.MatchString(name[loc[4]:loc[5]]) {
+				return loc
+			}
+			offset = loc[5]
+		}
+	}
+	return nil
+}
+
+// EpisodeByAbsolute Return the episode of the series with the absolute
+// number. Releases number episodes as the scene does, which differs from
+// TVDB for some series, so the scene number is tried first. Series whose
+// episodes have no absolute numbers on TVDB are counted in season order,
+// leaving specials out.
+func EpisodeByAbsolute(episodes []Episode, absolute int) (Episode, bool) {
+	if absolute <= 0 {
+		return Episode{}, false
+	}
+	for _, ep := range episodes {
+		if ep.SceneAbsoluteEpisodeNumber == absolute {
+			return ep, true
+		}
+	}
+	numbered := false
+	for _, ep := range episodes {
+		if ep.AbsoluteEpisodeNumber == absolute {
+			return ep, true
+		}
+		numbered = numbered || ep.AbsoluteEpisodeNumber > 0
+	}
+	if numbered {
+		return Episode{}, false
+	}
+	var regular []Episode
+	for _, ep := range episodes {
+		if ep.SeasonNumber > 0 {
+			regular = append(regular, ep)
+		}
+	}
+	sort.Slice(regular, func(i, j int) bool {
+		if regular[i].SeasonNumber != regular[j].SeasonNumber {
+			return regular[i].SeasonNumber < regular[j].SeasonNumber
+		}
+		return regular[i].EpisodeNumber < regular[j].EpisodeNumber
+	})
+	if absolute > len(regular) {
+		return Episode{}, false
+	}
+	return regular[absolute-1], true
+}
+
+// EpisodeByNumbers Return the episode of the series releases name with the
+// season and episode numbers. Series using scene numbering are named with the
+// scene numbers, which can be a season off the TVDB ones, so those are
+// tried first.
+func EpisodeByNumbers(episodes []Episode, series Series, season, episode int) (Episode, bool) {
+	for _, ep := range episodes {
+		s, e := ep.ReleaseNumbers(series)
+		if s == season && e == episode {
+			return ep, true
+		}
+	}
+	for _, ep := range episodes {
+		if ep.SeasonNumber == season && ep.EpisodeNumber == episode {
+			return ep, true
+		}
+	}
+	return Episode{}, false
+}
+
+// matchesAbsolute Return true if the name has the absolute number of the
+// episode, as TVDB or the scene numbers it
+func matchesAbsolute(name string, episode Episode) bool {
+	absolute, ok := AbsoluteNumber(name)
+	if !ok {
+		return false
+	}
+	return absolute == episode.AbsoluteEpisodeNumber || absolute == episode.SceneAbsoluteEpisodeNumber
+}
+
+// WithSeasonEpisode Return the name, without extension, replacing its
+// absolute number with the season and episode numbers releases of the
+// episode use, so the *arr places it in the right season. ok is false if it
+// has no absolute number.
+func WithSeasonEpisode(name string, series Series, episode Episode) (string, bool) {
+	if _, ok := AbsoluteNumber(name); !ok {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	loc := absoluteNumberLoc(name)
+	season, number := episode.ReleaseNumbers(series)
+	numbers := fmt.Sprintf("S%.2dE%.2d", season, number)
+	return name[:loc[2]] + numbers + name[loc[3]:], true
+}
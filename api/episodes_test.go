@@ -0,0 +1,25 @@
+package api
+
+import "testing"
+
+func TestAbsoluteNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+		ok   bool
+	}{
+		{"[Group] Show - 123 [1080p].mkv", 123, true},
+		{"Show - 05v2.mkv", 5, true},
+		{"Show.E1071.mkv", 1071, true},
+		{"Show - 2024.mkv", 0, false},
+		{"Show - 1999 [720p].mkv", 0, false},
+		{"Show - 2024 - 05 [1080p].mkv", 5, true},
+		{"Show.S01E02.mkv", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := AbsoluteNumber(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: %d %v, want %d %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"parserr/helpers"
+	"strings"
+)
+
+// maxErrorBody Bytes of a response without error message shown in the error
+const maxErrorBody = 200
+
+// APIError Response of the instance with an error status code, callers can
+// tell failures worth retrying from the ones that won't go away
+type APIError struct {
+	Method string
+	// Endpoint Path of the request, without the query where the api key
+	// may be
+	Endpoint   string
+	StatusCode int
+	// Message Error of the response JSON, empty if it had none
+	Message string
+	Body    []byte
+}
+
+// newAPIError Return the APIError of the response, reading the message of
+// the error JSON sonarr, radarr and lidarr answer with
+func newAPIError(req *http.Request, res *http.Response, body []byte) APIError {
+	return APIError{
+		Method:     req.Method,
+		Endpoint:   req.URL.Path,
+		StatusCode: res.StatusCode,
+		Message:    errorMessage(body),
+		Body:       body,
+	}
+}
+
+func (e APIError) Error() string {
+	if e.Unauthorized() {
+		return fmt.Sprintf("%s %s: authorization invalid", e.Method, e.Endpoint)
+	}
+	msg := e.Message
+	if msg == "" {
+		msg = strings.TrimSpace(string(e.Body))
+		if len(msg) > maxErrorBody {
+			msg = msg[:maxErrorBody] + "..."
+		}
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s failed, status code %d: %s", e.Method, e.Endpoint, e.StatusCode, msg)
+}
+
+// Unauthorized The api key is wrong or lacks permissions, retrying won't help
+func (e APIError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// NotFound The item or the endpoint doesn't exist
+func (e APIError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// Temporary The instance couldn't answer right now and the request may
+// succeed if retried
+func (e APIError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout
+}
+
+// IsAPIError Return the APIError of err or of any error it wraps, ok is
+// false if there is none
+func IsAPIError(err error) (e APIError, ok bool) {
+	for ; err != nil; err = helpers.Unwrap(err) {
+		if e, ok = err.(APIError); ok {
+			return
+		}
+	}
+	return
+}
+
+// errorMessage Return the message of an error response, which is an object
+// with message and description or a list of validation failures
+func errorMessage(body []byte) string {
+	var obj struct {
+		Message     string `json:"message"`
+		Description string `json:"description"`
+	}
+	if json.Unmarshal(body, &obj) == nil && obj.Message != "" {
+		if obj.Description != "" && obj.Description != obj.Message {
+			return obj.Message + ": " + obj.Description
+		}
+		return obj.Message
+	}
+	var failures []struct {
+		PropertyName string `json:"propertyName"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if json.Unmarshal(body, &failures) != nil {
+		return ""
+	}
+	var msgs []string
+	for _, f := range failures {
+		if f.ErrorMessage == "" {
+			continue
+		}
+		if f.PropertyName != "" {
+			msgs = append(msgs, f.PropertyName+": "+f.ErrorMessage)
+		} else {
+			msgs = append(msgs, f.ErrorMessage)
+		}
+	}
+	return strings.Join(msgs, ", ")
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// extrasFolders Folders of the extras of a series or movie, by their
+// lowercase name, as Plex and Jellyfin name them
+var extrasFolders = map[string]string{
+	"behind the scenes": "Behind The Scenes",
+	"deleted scenes":    "Deleted Scenes",
+	"extras":            "Extras",
+	"featurettes":       "Featurettes",
+	"interviews":        "Interviews",
+	"music videos":      "Music Videos",
+	"other":             "Other",
+	"sample":            "Samples",
+	"samples":           "Samples",
+	"scenes":            "Scenes",
+	"shorts":            "Shorts",
+	"trailers":          "Trailers",
+}
+
+// extrasSuffixes Folders of the extras named with a lowercase suffix, like
+// movie-trailer.mkv. -scene, -short and -other are left out, they are also
+// names of release groups.
+var extrasSuffixes = map[string]string{
+	"-behindthescenes": "Behind The Scenes",
+	"-deleted":         "Deleted Scenes",
+	"-featurette":      "Featurettes",
+	"-interview":       "Interviews",
+	"-sample":          "Samples",
+	"-trailer":         "Trailers",
+}
+
+// ExtrasFolder Return the folder of the library the extra belongs in, ok is
+// false if the path isn't an extra. Extras are files in an extras folder or
+// with an extras suffix, the path must be relative to the download folder so
+// the folders above it aren't taken for extras folders.
+func ExtrasFolder(p string) (folder string, ok bool) {
+	p = filepath.ToSlash(p)
+	parts := strings.Split(p, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if folder, ok = extrasFolders[strings.ToLower(part)]; ok {
+			return
+		}
+	}
+	name := parts[len(parts)-1]
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	for suffix, folder := range extrasSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return folder, true
+		}
+	}
+	return "", false
+}
+
+// IsExtra Return true if the path is of a featurette, trailer, music video
+// or any other extra of a series or movie instead of the media itself
+func IsExtra(p string) bool {
+	_, ok := ExtrasFolder(p)
+	return ok
+}
+
+// withoutExtras Return the paths that aren't extras, relative to any of the
+// download folders
+func withoutExtras(paths []string, folders []DownloadFolder) (media []string) {
+	for _, p := range paths {
+		relative := p
+		for _, folder := range folders {
+			prefix := filepath.Clean(folder.Path) + string(filepath.Separator)
+			if strings.HasPrefix(p, prefix) {
+				relative = strings.TrimPrefix(p, prefix)
+				break
+			}
+		}
+		if IsExtra(relative) {
+			log.Printf("is an extra, skipping: %s", p)
+			continue
+		}
+		media = append(media, p)
+	}
+	return
+}
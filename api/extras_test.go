@@ -0,0 +1,26 @@
+package api
+
+import "testing"
+
+func TestExtrasFolder(t *testing.T) {
+	tests := []struct {
+		path   string
+		folder string
+		ok     bool
+	}{
+		{"Movie (2020)/Featurettes/Making of.mkv", "Featurettes", true},
+		{"Movie (2020)/behind the scenes/a.mkv", "Behind The Scenes", true},
+		{"Movie (2020)/Sample/movie.mkv", "Samples", true},
+		{"Movie (2020)/movie-trailer.mkv", "Trailers", true},
+		{"Movie (2020)/movie-deleted.mp4", "Deleted Scenes", true},
+		{"Movie.2020.1080p-SCENE.mkv", "", false},
+		{"Movie (2020)/movie.mkv", "", false},
+		{"Extras.mkv", "", false},
+	}
+	for _, tt := range tests {
+		folder, ok := ExtrasFolder(tt.path)
+		if folder != tt.folder || ok != tt.ok {
+			t.Errorf("ExtrasFolder(%s) = %q, %v, want %q, %v", tt.path, folder, ok, tt.folder, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fixture Sanitized response recorded from an instance
+type Fixture struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query"`
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+// RecordingTransport Save every response as a fixture in Dir, created if it
+// doesn't exist, the api key is removed from the query and replaced in the
+// body. Every instance must record in its own folder, fixtures are numbered
+// per transport.
+type RecordingTransport struct {
+	Dir    string
+	APIKey string
+	Next   http.RoundTripper
+	mutex  sync.Mutex
+	count  int
+}
+
+// Record Return a copy of the client recording fixtures in dir
+func Record(c *http.Client, dir, apiKey string) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	recorder := *c
+	recorder.Transport = &RecordingTransport{Dir: dir, APIKey: apiKey, Next: c.Transport}
+	return &recorder
+}
+
+// RoundTrip ...
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	fixture := Fixture{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  sanitizeQuery(req.URL.Query()),
+		Status: res.StatusCode,
+		Body:   string(body),
+	}
+	if t.APIKey != "" {
+		fixture.Body = strings.Replace(fixture.Body, t.APIKey, "APIKEY", -1)
+	}
+	err = t.save(fixture)
+	if err != nil {
+		log.Printf("cannot record fixture of %s: %s", req.URL.Path, err)
+	}
+	return res, nil
+}
+
+func (t *RecordingTransport) save(f Fixture) error {
+	t.mutex.Lock()
+	t.count++
+	n := t.count
+	t.mutex.Unlock()
+	j, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(t.Dir, 0775)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%04d_%s%s.json", n, f.Method, strings.Replace(f.Path, "/", "_", -1))
+	return ioutil.WriteFile(path.Join(t.Dir, name), j, 0664)
+}
+
+func sanitizeQuery(q url.Values) string {
+	q.Del("apikey")
+	return q.Encode()
+}
+
+// FixtureServer Replay recorded fixtures. Requests are matched by method,
+// path and query, repeated requests get the following fixtures recorded for
+// them and the last one once they are exhausted.
+type FixtureServer struct {
+	fixtures map[string][]Fixture
+	served   map[string]int
+	mutex    sync.Mutex
+}
+
+// NewFixtureServer Load every fixture recorded in dir
+func NewFixtureServer(dir string) (*FixtureServer, error) {
+	files, err := filepath.Glob(path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	s := &FixtureServer{fixtures: make(map[string][]Fixture), served: make(map[string]int)}
+	for _, file := range files {
+		j, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		err = json.Unmarshal(j, &f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %s", file, err)
+		}
+		key := fixtureKey(f.Method, f.Path, f.Query)
+		s.fixtures[key] = append(s.fixtures[key], f)
+	}
+	return s, nil
+}
+
+// ServeHTTP ...
+func (s *FixtureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := fixtureKey(r.Method, r.URL.Path, sanitizeQuery(r.URL.Query()))
+	s.mutex.Lock()
+	fixtures := s.fixtures[key]
+	i := s.served[key]
+	if i < len(fixtures)-1 {
+		s.served[key]++
+	}
+	s.mutex.Unlock()
+	if len(fixtures) == 0 {
+		http.Error(w, "no fixture for "+key, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(fixtures[i].Status)
+	w.Write([]byte(fixtures[i].Body))
+}
+
+func fixtureKey(method, path, query string) string {
+	return method + " " + path + "?" + query
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordPerInstance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"key":"secret"}`))
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"sonarr", "sonarr-4k"} {
+		c := Record(nil, filepath.Join(dir, name), "secret")
+		res, err := c.Get(srv.URL + "/api/v3/queue?apikey=secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	for _, name := range []string{"sonarr", "sonarr-4k"} {
+		files, err := filepath.Glob(filepath.Join(dir, name, "*.json"))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("%s: %d fixtures recorded, want 1", name, len(files))
+		}
+		s, err := NewFixtureServer(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := s.fixtures[fixtureKey("GET", "/api/v3/queue", "")]
+		if len(f) != 1 || f[0].Body != `{"key":"APIKEY"}` {
+			t.Errorf("%s: fixtures %v, want the queue without the api key", name, f)
+		}
+	}
+}
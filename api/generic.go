@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// EnvGenericName Name of a generic *arr, like whisparr, it's required
+	EnvGenericName = "ARR_NAME"
+	// EnvGenericURL ...
+	EnvGenericURL = "ARR_URL"
+	// EnvGenericURLBase Path prefix of the *arr behind a reverse proxy
+	EnvGenericURLBase = "ARR_URL_BASE"
+	// EnvGenericSocket Unix socket to connect to the *arr
+	EnvGenericSocket = "ARR_SOCKET"
+	// EnvGenericAPIKey ...
+	EnvGenericAPIKey = "ARR_APIKEY"
+	// EnvGenericDownloadFolder Comma separated list of folders, each one can
+	// set its strategy as path=strategy
+	EnvGenericDownloadFolder = "ARR_DOWNLOAD_FOLDER"
+	// EnvGenericType Media of the *arr, show, movie or music, the api of the
+	// *arr it's forked from
+	EnvGenericType = "ARR_TYPE"
+	// EnvGenericAPIPath Path every endpoint is under, /api/v3 if empty
+	EnvGenericAPIPath = "ARR_API_PATH"
+	// EnvGenericEndpoints Comma separated list of endpoint=name for the
+	// endpoints the fork renamed, like movie=scene
+	EnvGenericEndpoints = "ARR_ENDPOINTS"
+	// EnvGenericScanCommand ...
+	EnvGenericScanCommand = "ARR_SCAN_COMMAND"
+	// EnvGenericRenameCommand ...
+	EnvGenericRenameCommand = "ARR_RENAME_COMMAND"
+	// EnvGenericDownloadScanCommand ...
+	EnvGenericDownloadScanCommand = "ARR_DOWNLOAD_SCAN_COMMAND"
+)
+
+// Endpoints Names of the endpoints by the name sonarr and radarr give them,
+// like movie=scene
+type Endpoints map[string]string
+
+// ParseEndpoints Parse a list like movie=scene,moviefile=scenefile
+func ParseEndpoints(value string) (Endpoints, error) {
+	e := make(Endpoints)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, use endpoint=name", entry)
+		}
+		e[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.Trim(strings.TrimSpace(parts[1]), "/")
+	}
+	return e, nil
+}
+
+// rename Return the path, like /movie/1, with its endpoint renamed
+func (e Endpoints) rename(path string) string {
+	if len(e) == 0 {
+		return path
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	name, ok := e[parts[0]]
+	if !ok {
+		return path
+	}
+	parts[0] = name
+	return "/" + strings.Join(parts, "/")
+}
+
+// GenericCommands Names of the commands of a generic *arr, empty ones use
+// the names of the *arr of its type
+type GenericCommands struct {
+	Scan         string
+	Rename       string
+	DownloadScan string
+}
+
+// Generic *arr configured with the names of its commands and endpoints, for
+// forks of sonarr, radarr or lidarr like whisparr
+type Generic struct {
+	API
+	Commands GenericCommands
+}
+
+// NewGeneric Create an API of the type, using the v3 api unless another
+// version or path is given in the options
+func NewGeneric(url, apiKey, downloadFolder, apiType string, commands GenericCommands, opts ...Option) Generic {
+	a := API{
+		Name:            apiType,
+		URL:             url,
+		APIKey:          apiKey,
+		DownloadFolders: []DownloadFolder{{Path: downloadFolder}},
+		Type:            apiType,
+		Version:         APIVersion3,
+	}
+	a.apply(opts)
+	var base RRAPI = Sonarr{a}
+	if apiType == TypeMovie {
+		base = Radarr{a}
+	} else if apiType == TypeMusic {
+		base = Lidarr{a}
+	}
+	if commands.Scan == "" {
+		commands.Scan = base.ScanCommand().Name
+	}
+	if commands.Rename == "" {
+		commands.Rename = base.RenameCommand(nil).Name
+	}
+	if commands.DownloadScan == "" {
+		commands.DownloadScan = base.DownloadScan("").Name
+	}
+	return Generic{API: a, Commands: commands}
+}
+
+// ScanCommand ...
+func (g Generic) ScanCommand() CommandBody {
+	return CommandBody{Name: g.Commands.Scan}
+}
+
+// RenameCommand Create the command with the ids in the field of the type
+func (g Generic) RenameCommand(ids []int) CommandBody {
+	c := CommandBody{Name: g.Commands.Rename}
+	switch g.Type {
+	case TypeMovie:
+		c.MovieIds = ids
+	case TypeMusic:
+		c.ArtistIds = ids
+	default:
+		c.SeriesIds = ids
+	}
+	return c
+}
+
+// DownloadScan ...
+func (g Generic) DownloadScan(path string) CommandBody {
+	return CommandBody{Name: g.Commands.DownloadScan, Path: path}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	// APIHealthURL ...
+	APIHealthURL = APIURL + "/health"
+	// HealthWarning ...
+	HealthWarning = "warning"
+	// HealthError ...
+	HealthError = "error"
+)
+
+// HealthCheck Problem found by the instance checking itself, like being
+// unable to communicate with the download client
+type HealthCheck struct {
+	// Source Name of the check, like DownloadClientCheck
+	Source string
+	// Type ok, notice, warning or error
+	Type    string
+	Message string
+	WikiURL string `json:"wikiUrl"`
+}
+
+// GetHealth Return the problems the instance has found in itself
+func (a API) GetHealth(ctx context.Context) (checks []HealthCheck, err error) {
+	body, err := a.get(ctx, a.getURL(APIHealthURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &checks)
+	return
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// historyEventIDs Ids of the event types in the v3 api, which filters by id
+// but returns names
+var historyEventIDs = map[string]int{
+	HistoryEventGrabbed:                1,
+	HistoryEventDownloadFolderImported: 3,
+	HistoryEventDownloadFailed:         4,
+}
+
+// HistoryOptions Filters of the history, the zero value matches every record
+type HistoryOptions struct {
+	// EventType Only records of the event, like HistoryEventGrabbed
+	EventType string
+	// DownloadID Only records of the download
+	DownloadID string
+}
+
+// addTo Add the filters to the query of a v3 history request
+func (o HistoryOptions) addTo(query url.Values) {
+	if id, ok := historyEventIDs[o.EventType]; ok {
+		query.Set("eventType", strconv.Itoa(id))
+	}
+	if o.DownloadID != "" {
+		query.Set("downloadId", o.DownloadID)
+	}
+}
+
+// filter Return the records matching the options, for instances that can't
+// filter them
+func (o HistoryOptions) filter(records []HistoryRec) []HistoryRec {
+	if o == (HistoryOptions{}) {
+		return records
+	}
+	var matching []HistoryRec
+	for _, hr := range records {
+		if o.EventType != "" && hr.EventType != o.EventType {
+			continue
+		}
+		if o.DownloadID != "" && hr.DownloadID != o.DownloadID {
+			continue
+		}
+		matching = append(matching, hr)
+	}
+	return matching
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ParseURL Validate the address of an instance, either a host like
+// localhost:8989 or a base url like https://localhost:8989/sonarr, and return
+// it normalized. Hosts are returned as ParseHost does.
+func ParseURL(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "://") {
+		return ParseHost(value)
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %q: %s", value, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid scheme %q in url %q, use http or https", u.Scheme, value)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid url %q, it can't have a query or fragment", value)
+	}
+	u.Host, err = ParseHost(u.Host)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String(), nil
+}
+
+// ParseURLBase Validate the path prefix of an instance behind a reverse
+// proxy, like sonarr or /sonarr/, and return it as /sonarr. Empty or / mean
+// no prefix.
+func ParseURLBase(value string) (string, error) {
+	value = strings.Trim(strings.TrimSpace(value), "/")
+	if value == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(value, "?#:") {
+		return "", fmt.Errorf("invalid url base %q, expected a path like /sonarr", value)
+	}
+	return path.Clean("/" + value), nil
+}
+
+// ParseHost Validate the address of an instance and return it ready to be
+// used as the host of a URL. Accepts hostnames and IPv4 or IPv6 addresses,
+// optionally followed by a port. Bare IPv6 addresses are bracketed.
+func ParseHost(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("empty host")
+	}
+	if strings.Contains(value, "/") {
+		return "", fmt.Errorf("invalid host %q, expected host or host:port", value)
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		if ip.To4() == nil {
+			return "[" + value + "]", nil
+		}
+		return value, nil
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		if ip := net.ParseIP(value[1 : len(value)-1]); ip == nil || ip.To4() != nil {
+			return "", fmt.Errorf("invalid IPv6 address %q", value)
+		}
+		return value, nil
+	}
+	if !strings.Contains(value, ":") {
+		if err := validateHostname(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %s", value, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return "", fmt.Errorf("invalid port %q in host %q", port, value)
+	}
+	if ip := net.ParseIP(host); ip == nil {
+		if err := validateHostname(host); err != nil {
+			return "", err
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func validateHostname(host string) error {
+	if host == "" {
+		return fmt.Errorf("empty host")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid hostname %q", host)
+		}
+		for _, r := range label {
+			valid := r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+			if !valid {
+				return fmt.Errorf("invalid character %q in hostname %q", r, host)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"parserr/helpers"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// APIV1URL Root of the api of lidarr
+	APIV1URL = APIURL + "/v1"
+	// APIArtistURL ...
+	APIArtistURL = APIURL + "/artist"
+	// APIAlbumURL ...
+	APIAlbumURL = APIURL + "/album"
+	// APITrackURL ...
+	APITrackURL = APIURL + "/track"
+)
+
+// Artist ...
+type Artist struct {
+	ID              int
+	ArtistName      string
+	ForeignArtistID string
+	Path            string
+}
+
+// Album ...
+type Album struct {
+	ID       int
+	Title    string
+	ArtistID int
+}
+
+// Track ...
+type Track struct {
+	ID                  int
+	Title               string
+	TrackNumber         string
+	AbsoluteTrackNumber int
+	HasFile             bool
+}
+
+// Lidarr ...
+type Lidarr struct{ API }
+
+// NewLidarr Create an API configured with the options, lidarr only has the
+// v1 api, which works like the v3 one of sonarr
+func NewLidarr(url, apiKey, downloadFolder string, opts ...Option) Lidarr {
+	a := API{
+		Name:            "lidarr",
+		URL:             url,
+		APIKey:          apiKey,
+		DownloadFolders: []DownloadFolder{{Path: downloadFolder}},
+		Type:            TypeMusic,
+		Version:         APIVersion3,
+		APIPath:         APIV1URL,
+	}
+	a.apply(opts)
+	return Lidarr{a}
+}
+
+// DownloadScan Create a command instance to force to import the albums of a folder
+func (l Lidarr) DownloadScan(path string) CommandBody {
+	return CommandBody{Name: "DownloadedAlbumsScan", Path: path}
+}
+
+// ScanCommand Create a command instance to force to rescan artists from disk
+func (l Lidarr) ScanCommand() CommandBody {
+	return CommandBody{Name: "RescanArtist"}
+}
+
+// RenameCommand ...
+func (l Lidarr) RenameCommand(ids []int) CommandBody {
+	return CommandBody{
+		Name:      "RenameArtist",
+		ArtistIds: ids,
+	}
+}
+
+// GetArtist ...
+func (a API) GetArtist(ctx context.Context, id int) (artist Artist, err error) {
+	u := a.getURL(APIArtistURL + "/" + strconv.Itoa(id))
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &artist)
+	return
+}
+
+// GetTracks Return the tracks of the album
+func (a API) GetTracks(ctx context.Context, albumID int) (tracks []Track, err error) {
+	u := a.getURL(APITrackURL)
+	query := u.Query()
+	query.Set("albumId", strconv.Itoa(albumID))
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &tracks)
+	return
+}
+
+// SetAlbumMonitored ...
+func (a API) SetAlbumMonitored(ctx context.Context, id int, monitored bool) error {
+	if a.wouldDo(helpers.ActionChange, "set album %d monitored %v", id, monitored) {
+		return nil
+	}
+	return a.update(ctx, APIAlbumURL, id, func(resource map[string]interface{}) error {
+		resource["monitored"] = monitored
+		return nil
+	})
+}
+
+// TrackFilename Return the name of the file of the track, like 01 - Title.flac
+func TrackFilename(t Track, extension string) string {
+	title := strings.Replace(t.Title, "/", "-", -1)
+	return fmt.Sprintf("%02d - %s%s", t.AbsoluteTrackNumber, title, extension)
+}
+
+// trackNumber Matches the number of a track in a file name, optionally
+// preceded by the disc like 1-01
+var trackNumber = regexp.MustCompile(`(?:^|[^0-9])(?:[0-9]{1,2}-)?([0-9]{1,3})(?:[^0-9]|$)`)
+
+// MatchTrack Return the track the file belongs to, the one with the longest
+// title contained in the file name or, if none, the one with its number
+func MatchTrack(tracks []Track, filename string) (track Track, ok bool) {
+	name := normalizeTitle(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	for _, t := range tracks {
+		title := normalizeTitle(t.Title)
+		if title != "" && strings.Contains(name, title) && len(title) > len(normalizeTitle(track.Title)) {
+			track, ok = t, true
+		}
+	}
+	if ok {
+		return
+	}
+	match := trackNumber.FindStringSubmatch(filepath.Base(filename))
+	if match == nil {
+		return
+	}
+	n, _ := strconv.Atoi(match[1])
+	for _, t := range tracks {
+		if t.AbsoluteTrackNumber == n {
+			return t, true
+		}
+	}
+	return
+}
+
+// normalizeTitle Lower case the title keeping only letters and numbers
+func normalizeTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, title)
+}
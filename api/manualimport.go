@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// APIManualImportURL ...
+	APIManualImportURL = APIURL + "/manualimport"
+	// ImportModeAuto Move usenet downloads and copy, or hardlink, torrents
+	ImportModeAuto = "auto"
+	// ImportModeMove ...
+	ImportModeMove = "move"
+	// ImportModeCopy ...
+	ImportModeCopy = "copy"
+	// RejectionPermanent Rejection that won't go away retrying the import
+	RejectionPermanent = "permanent"
+)
+
+// ManualImportItem File of a folder with what the instance would import it
+// as, and why it wouldn't
+type ManualImportItem struct {
+	ID           int
+	Path         string
+	RelativePath string
+	FolderName   string
+	Name         string
+	Size         int64
+	Series       Series
+	SeasonNumber int
+	Episodes     []Episode
+	Movie        Movie
+	// Quality Kept as sent by the instance to send it back on import
+	Quality json.RawMessage
+	// Languages Kept as sent by the instance to send it back on import
+	Languages    json.RawMessage
+	ReleaseGroup string
+	DownloadID   string
+	Rejections   []ManualImportRejection
+}
+
+// ManualImportRejection Reason the instance wouldn't import a file
+type ManualImportRejection struct {
+	Reason string
+	Type   string
+}
+
+// Importable Return true if nothing stops the instance from importing the
+// file and it has been matched to a movie or episodes
+func (i ManualImportItem) Importable() bool {
+	return len(i.Rejections) == 0 && (i.Movie.ID != 0 || (i.Series.ID != 0 && len(i.Episodes) > 0))
+}
+
+// Rejection Return the reasons the file wouldn't be imported, empty if none
+func (i ManualImportItem) Rejection() string {
+	var reasons []string
+	for _, r := range i.Rejections {
+		reasons = append(reasons, r.Reason)
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// ManualImportFile File of a ManualImport command
+type ManualImportFile struct {
+	Path         string          `json:"path"`
+	FolderName   string          `json:"folderName,omitempty"`
+	SeriesID     int             `json:"seriesId,omitempty"`
+	EpisodeIDs   []int           `json:"episodeIds,omitempty"`
+	MovieID      int             `json:"movieId,omitempty"`
+	Quality      json.RawMessage `json:"quality,omitempty"`
+	Languages    json.RawMessage `json:"languages,omitempty"`
+	ReleaseGroup string          `json:"releaseGroup,omitempty"`
+	DownloadID   string          `json:"downloadId,omitempty"`
+}
+
+// GetManualImport Return the files of the folder, or of the download if
+// downloadID isn't empty, as the instance would import them
+func (a API) GetManualImport(ctx context.Context, folder, downloadID string) (items []ManualImportItem, err error) {
+	u := a.getURL(APIManualImportURL)
+	query := u.Query()
+	if folder != "" {
+		query.Set("folder", folder)
+	}
+	if downloadID != "" {
+		query.Set("downloadId", downloadID)
+	}
+	query.Set("filterExistingFiles", "true")
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &items)
+	return
+}
+
+// ExecuteManualImport Import the files as matched by GetManualImport,
+// waiting for the command to finish. The command is sent once, even on
+// errors that are retried elsewhere, as the instance may have received it
+// and sending it again could import the files twice.
+func (a API) ExecuteManualImport(ctx context.Context, items []ManualImportItem, mode string) (cs CommandStatus, err error) {
+	if mode == "" {
+		mode = ImportModeAuto
+	}
+	c := CommandBody{Name: "ManualImport", ImportMode: mode}
+	for _, i := range items {
+		if len(i.Rejections) > 0 {
+			return cs, fmt.Errorf("cannot import %s: %s", i.Path, i.Rejection())
+		}
+		f := ManualImportFile{
+			Path:         i.Path,
+			FolderName:   i.FolderName,
+			SeriesID:     i.Series.ID,
+			MovieID:      i.Movie.ID,
+			Quality:      i.Quality,
+			Languages:    i.Languages,
+			ReleaseGroup: i.ReleaseGroup,
+			DownloadID:   i.DownloadID,
+		}
+		for _, e := range i.Episodes {
+			f.EpisodeIDs = append(f.EpisodeIDs, e.ID)
+		}
+		c.Files = append(c.Files, f)
+	}
+	cs, err = a.executeCommand(ctx, c, false)
+	if err != nil {
+		return
+	}
+	return a.waitCommand(ctx, c.Name, cs)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecuteManualImportSentOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter)
+		opts    []Option
+	}{
+		{"unavailable", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, nil},
+		{"timeout", func(w http.ResponseWriter) {
+			time.Sleep(200 * time.Millisecond)
+		}, []Option{WithTimeout(20 * time.Millisecond)}},
+	}
+	for _, tt := range tests {
+		var posts int32
+		handler := tt.handler
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				atomic.AddInt32(&posts, 1)
+			}
+			handler(w)
+		}))
+		opts := append([]Option{WithVersion(APIVersion3), WithRetry(Retry{Attempts: 4})}, tt.opts...)
+		a := NewSonarr(srv.URL, "key", "/downloads", opts...).API
+		items := []ManualImportItem{{Path: "/downloads/Show.S01E01.mkv", Series: Series{ID: 1}}}
+		_, err := a.ExecuteManualImport(context.Background(), items, "")
+		srv.Close()
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if n := atomic.LoadInt32(&posts); n != 1 {
+			t.Errorf("%s: ManualImport sent %d times, want 1", tt.name, n)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 const (
@@ -15,10 +17,14 @@ const (
 	TypeMovie = "movie"
 	// TypeShow ...
 	TypeShow = "show"
+	// TypeMusic ...
+	TypeMusic = "music"
 )
 
 // Media ...
 type Media struct {
+	// Instance Name of the api the media belongs to
+	Instance      string
 	HistoryRec    HistoryRec
 	QueueElem     QueueElem
 	FileLocOri    string
@@ -27,25 +33,55 @@ type Media struct {
 	FilenameFinal string
 	Type          string
 	FileExtension string
+	// DownloadFolder Folder where the file has been found
+	DownloadFolder DownloadFolder
+	// Confidence How sure we are that FilenameOri is the right file, from 0 to 1
+	Confidence float64
+	// AudioLanguages Languages of the audio tracks, if the file has been probed
+	AudioLanguages []string
+	// SubtitleLanguages Languages of the subtitle tracks, if the file has been probed
+	SubtitleLanguages []string
+	// Verification Whether the media server shows the media once fixed, if checked
+	Verification string
+	// Grabbed When the release was sent to the download client, zero if its
+	// grab isn't in the history
+	Grabbed time.Time
+	// WarningSince When the first history record after the grab happened,
+	// usually the failed import that left the download in warning, zero if
+	// there is none
+	WarningSince time.Time
+	// DownloadMedia Media of its download in the queue, including the ones
+	// left out of the run, 0 if unknown
+	DownloadMedia int
 }
 
 // NewMedia Generate a new Media struct with correct type and names
 func NewMedia(a RRAPI, hr HistoryRec, qe QueueElem) (m Media, err error) {
 	m.Type = a.GetType()
+	m.Instance = a.GetName()
 	m.HistoryRec = hr
 	m.QueueElem = qe
-	filename, err := m.guessOriginalFilename()
+	candidates, err := m.guessOriginalFilenames()
 	if err != nil {
 		return
 	}
-	m.FilenameOri = filename
+	m.FilenameOri = candidates[0]
+	m.Confidence = 1 / float64(len(candidates))
 	m.FileExtension = filepath.Ext(m.FilenameOri)
 	finalname, err := m.guessFinalFilename()
 	if err != nil {
 		return
 	}
 	m.FilenameFinal = finalname + m.FileExtension
-	location, err := helpers.FindFile(a.GetDownloadFolder(), m.FilenameOri)
+	var location string
+	err = fmt.Errorf("no download folders configured")
+	for _, folder := range a.GetDownloadFolders() {
+		location, err = helpers.FindFile(folder.Path, m.FilenameOri)
+		if err == nil {
+			m.DownloadFolder = folder
+			break
+		}
+	}
 	if err != nil {
 		return
 	}
@@ -54,6 +90,81 @@ func NewMedia(a RRAPI, hr HistoryRec, qe QueueElem) (m Media, err error) {
 	return
 }
 
+// NewMediaFromFiles Generate a new Media struct choosing the file among the
+// ones the download client reports for the download, instead of guessing its
+// name from the status messages
+func NewMediaFromFiles(a RRAPI, hr HistoryRec, qe QueueElem, paths []string) (m Media, err error) {
+	m.Type = a.GetType()
+	m.Instance = a.GetName()
+	m.HistoryRec = hr
+	m.QueueElem = qe
+	byName := make(map[string]string)
+	var names []string
+	for _, p := range withoutExtras(paths, a.GetDownloadFolders()) {
+		byName[filepath.Base(p)] = p
+		names = append(names, filepath.Base(p))
+	}
+	candidates, err := m.filterFilenames(names)
+	if err != nil {
+		return
+	}
+	m.FilenameOri = candidates[0]
+	m.Confidence = 1 / float64(len(candidates))
+	m.FileExtension = filepath.Ext(m.FilenameOri)
+	finalname, err := m.guessFinalFilename()
+	if err != nil {
+		return
+	}
+	m.FilenameFinal = finalname + m.FileExtension
+	location := byName[m.FilenameOri]
+	if _, err = os.Stat(location); err != nil {
+		return m, fmt.Errorf("file reported by the download client not found: %s", err)
+	}
+	for _, folder := range a.GetDownloadFolders() {
+		if strings.HasPrefix(location, filepath.Clean(folder.Path)+string(filepath.Separator)) {
+			m.DownloadFolder = folder
+			break
+		}
+	}
+	m.FileLocOri = location
+	m.FileLocFinal = location
+	return
+}
+
+// NewManualMedia Generate a new Media struct for a file downloaded manually,
+// outside the queue, with the series or movie the instance matched its name with
+func NewManualMedia(a RRAPI, p ParseResult, file string) (m Media, err error) {
+	if !p.Matched() {
+		return m, fmt.Errorf("%s does not match any %s of %s", filepath.Base(file), a.GetType(), a.GetName())
+	}
+	m.Type = a.GetType()
+	m.Instance = a.GetName()
+	m.FilenameOri = filepath.Base(file)
+	m.FileExtension = filepath.Ext(m.FilenameOri)
+	m.FilenameFinal = m.FilenameOri
+	m.Confidence = 1
+	m.HistoryRec.SourceTitle = strings.TrimSuffix(m.FilenameOri, m.FileExtension)
+	m.QueueElem.Title = m.FilenameOri
+	if p.Movie != nil {
+		m.QueueElem.Movie = *p.Movie
+	} else if p.Artist != nil {
+		m.QueueElem.Artist = *p.Artist
+		m.QueueElem.Album = p.Albums[0]
+	} else {
+		m.QueueElem.Series = *p.Series
+		m.QueueElem.Episode = p.Episodes[0]
+	}
+	for _, folder := range a.GetDownloadFolders() {
+		if strings.HasPrefix(file, filepath.Clean(folder.Path)+string(filepath.Separator)) {
+			m.DownloadFolder = folder
+			break
+		}
+	}
+	m.FileLocOri = file
+	m.FileLocFinal = file
+	return
+}
+
 // IsBroken ...
 func (m Media) IsBroken() bool {
 	return m.HistoryRec.TrackedDownloadStatus == TrackedDownloadStatusWarning
@@ -61,23 +172,36 @@ func (m Media) IsBroken() bool {
 
 // HasBeenDetected Return true if the show has been detected,
 // false otherwise (including errors)
-func (m Media) HasBeenDetected(a RRAPI) bool {
+func (m Media) HasBeenDetected(ctx context.Context, a RRAPI) bool {
 	if m.Type == TypeMovie {
-		movie, err := a.GetMovie(m.QueueElem.Movie.ID)
+		movie, err := a.GetMovie(ctx, m.QueueElem.Movie.ID)
 		if err != nil {
-			log.Printf("cannot detect if movie %s has been detected", m.QueueElem.Title)
+			helpers.Logf(ctx, "cannot detect if movie %s has been detected", m.QueueElem.Title)
 			return false
 		}
 		return movie.HasFile
 	}
 	if m.Type == TypeShow {
-		ep, err := a.GetEpisode(m.QueueElem.Episode.ID)
+		ep, err := a.GetEpisode(ctx, m.QueueElem.Episode.ID)
 		if err != nil {
-			log.Printf("cannot detect if episode %s has been detected", m.QueueElem.Title)
+			helpers.Logf(ctx, "cannot detect if episode %s has been detected", m.QueueElem.Title)
 			return false
 		}
 		return ep.HasFile
 	}
+	if m.Type == TypeMusic {
+		tracks, err := a.GetTracks(ctx, m.QueueElem.Album.ID)
+		if err != nil || len(tracks) == 0 {
+			helpers.Logf(ctx, "cannot detect if album %s has been detected", m.QueueElem.Title)
+			return false
+		}
+		for _, track := range tracks {
+			if !track.HasFile {
+				return false
+			}
+		}
+		return true
+	}
 	return false
 }
 
@@ -93,44 +217,104 @@ func (m Media) DeleteFile() error {
 	return err
 }
 
-// GuessFileName ...
-func (m Media) guessOriginalFilename() (string, error) {
+// guessOriginalFilenames Return every file that could be the original one,
+// the first one is the best guess
+func (m Media) guessOriginalFilenames() ([]string, error) {
+	var names []string
+	for _, message := range m.QueueElem.StatusMessages {
+		names = append(names, message.Title)
+	}
+	return m.filterFilenames(names)
+}
+
+// FilenameCandidates Return the names that could be the file of the media,
+// the first one is the best guess
+func (m Media) FilenameCandidates(names []string) ([]string, error) {
+	return m.filterFilenames(names)
+}
+
+// filterFilenames Return the names that could be the file of the media, the
+// first one is the best guess
+func (m Media) filterFilenames(names []string) ([]string, error) {
+	if m.Type != TypeMusic {
+		names = withoutExtras(names, nil)
+	}
 	if m.Type == TypeMovie {
-		return guessMovieFileName(m)
+		return filterMovieFileNames(m, names)
 	}
 	if m.Type == TypeShow {
-		return guessShowFileName(m)
+		return filterShowFileNames(m, names)
+	}
+	if m.Type == TypeMusic {
+		return filterMusicFileNames(m, names)
 	}
-	return "", fmt.Errorf("cannot guess filename of unrecognized media type: %s", m.Type)
+	return nil, fmt.Errorf("cannot guess filename of unrecognized media type: %s", m.Type)
 }
 
-func guessShowFileName(m Media) (string, error) {
+func filterShowFileNames(m Media, names []string) (candidates []string, err error) {
 	episode := m.QueueElem.Episode
-	regexString := fmt.Sprintf("%d.{0,4}%d", episode.SeasonNumber, episode.EpisodeNumber)
+	season, number := episode.ReleaseNumbers(m.QueueElem.Series)
+	regexString := fmt.Sprintf("%d.{0,4}%d", season, number)
 	regex := regexp.MustCompile(regexString)
-	for _, message := range m.QueueElem.StatusMessages {
-		if regex.MatchString(message.Title) {
-			extension := filepath.Ext(message.Title)
-			validExtensions := map[string]bool{".mkv": true, ".mp4": true, ".avi": true}
-			if validExtensions[extension] {
-				return message.Title, nil
+	for _, name := range names {
+		if regex.MatchString(name) || matchesAbsolute(name, episode) {
+			if IsVideo(name) {
+				candidates = append(candidates, name)
+				continue
 			}
-			log.Printf("is not a valid file, skipping: %s\n", message.Title)
+			log.Printf("is not a valid file, skipping: %s\n", name)
 		}
 	}
-	return "", fmt.Errorf("impossible to guess file name for %s", m.QueueElem.Title)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("impossible to guess file name for %s", m.QueueElem.Title)
+	}
+	return candidates, nil
 }
 
-func guessMovieFileName(m Media) (string, error) {
-	for _, message := range m.QueueElem.StatusMessages {
-		extension := filepath.Ext(message.Title)
-		validExtensions := map[string]bool{".mkv": true, ".mp4": true, ".avi": true}
-		if validExtensions[extension] {
-			return message.Title, nil
+func filterMovieFileNames(m Media, names []string) (candidates []string, err error) {
+	for _, name := range names {
+		if IsVideo(name) {
+			candidates = append(candidates, name)
+			continue
+		}
+		log.Printf("is not a valid file, skipping: %s\n", name)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("impossible to guess file name for %s", m.QueueElem.Title)
+	}
+	return candidates, nil
+}
+
+// videoExtensions Files considered episodes or movies
+var videoExtensions = map[string]bool{".mkv": true, ".mp4": true, ".avi": true}
+
+// IsVideo Return true if the file is an episode or a movie
+func IsVideo(name string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// audioExtensions Files considered music
+var audioExtensions = map[string]bool{
+	".flac": true, ".mp3": true, ".m4a": true, ".ogg": true, ".opus": true, ".wav": true, ".aac": true,
+}
+
+func filterMusicFileNames(m Media, names []string) (candidates []string, err error) {
+	for _, name := range names {
+		if audioExtensions[strings.ToLower(filepath.Ext(name))] {
+			candidates = append(candidates, name)
+			continue
 		}
-		log.Printf("is not a valid file, skipping: %s\n", message.Title)
+		log.Printf("is not a valid file, skipping: %s\n", name)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("impossible to guess file name for %s", m.QueueElem.Title)
 	}
-	return "", fmt.Errorf("impossible to guess file name for %s", m.QueueElem.Title)
+	return candidates, nil
+}
+
+// IsAudio Return true if the file is a music track
+func IsAudio(name string) bool {
+	return audioExtensions[strings.ToLower(filepath.Ext(name))]
 }
 
 // GuessFinalName ...
@@ -141,6 +325,10 @@ func (m Media) guessFinalFilename() (string, error) {
 	if m.Type == TypeShow {
 		return m.guessShowFinalName()
 	}
+	if m.Type == TypeMusic {
+		// Tracks are renamed once matched with the album, see MatchTrack
+		return strings.TrimSuffix(m.FilenameOri, m.FileExtension), nil
+	}
 	return "", fmt.Errorf("cannot guess finalname of file with type %q", m.Type)
 }
 
@@ -162,18 +350,22 @@ func (m Media) guessMovieFinalName() (string, error) {
 }
 
 func (m Media) guessShowFinalName() (string, error) {
+	if final, ok := WithSeasonEpisode(m.FilenameOri, m.QueueElem.Series, m.QueueElem.Episode); ok {
+		return final, nil
+	}
 	finalTitle := m.HistoryRec.SourceTitle
 	if len(m.QueueElem.StatusMessages) == 1 {
 		return finalTitle, nil
 	}
-	episode := m.QueueElem.Episode
+	// named as releases are, the *arr parses them with scene numbers
+	season, number := m.QueueElem.Episode.ReleaseNumbers(m.QueueElem.Series)
 	regexString := fmt.Sprintf("[.\\-_ ]([\\-_0-9sSeExX]{2,10})[.\\-_ ]")
 	regex := regexp.MustCompile(regexString)
 	if !regex.MatchString(finalTitle) {
 		return "", fmt.Errorf("unable to guess final episode name of %s", m.FilenameOri)
 	}
 	match := regex.FindString(finalTitle)
-	new := fmt.Sprintf(".S%.2dE%.2d.", episode.SeasonNumber, episode.EpisodeNumber)
+	new := fmt.Sprintf(".S%.2dE%.2d.", season, number)
 	finalTitle = strings.Replace(finalTitle, match, new, 1)
 	return finalTitle, nil
 }
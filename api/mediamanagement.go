@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// APIMediaManagementURL ...
+const APIMediaManagementURL = APIURL + "/config/mediamanagement"
+
+// MediaManagementConfig Media management settings of the instance
+type MediaManagementConfig struct {
+	// SetPermissions Apply the chmod and chown settings to imported files
+	SetPermissions bool   `json:"setPermissionsLinux"`
+	FileChmod      string `json:"fileChmod"`
+	FolderChmod    string `json:"folderChmod"`
+	ChownUser      string `json:"chownUser"`
+	ChownGroup     string `json:"chownGroup"`
+	// RecycleBin Folder where replaced files are moved to, deleted if empty
+	RecycleBin string `json:"recycleBin"`
+}
+
+// GetMediaManagementConfig ...
+func (a API) GetMediaManagementConfig(ctx context.Context) (config MediaManagementConfig, err error) {
+	body, err := a.get(ctx, a.getURL(APIMediaManagementURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &config)
+	return
+}
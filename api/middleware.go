@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// Middleware Wrap the transport requests to the instance are sent through,
+// to log, meter or modify them. Next is never nil.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc Adapt a function to http.RoundTripper, for middlewares
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip ...
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware Send every request through the middlewares, the first one
+// being the outermost. They see the requests before the api key header is
+// added, and must be safe for concurrent use.
+func WithMiddleware(m ...Middleware) Option {
+	return func(a *API) {
+		a.Middlewares = append(a.Middlewares, m...)
+	}
+}
+
+// Hooks Callbacks around every request to the instance, nil ones are skipped
+type Hooks struct {
+	// OnRequest Called before sending the request, it can modify its headers
+	OnRequest func(req *http.Request)
+	// OnResponse Called with the response or the error of the request and
+	// how long it took, the body must not be read
+	OnResponse func(req *http.Request, res *http.Response, err error, elapsed time.Duration)
+	// Clock Used to time the requests, RealClock if nil
+	Clock Clock
+}
+
+// WithHooks Call the hooks around every request
+func WithHooks(h Hooks) Option {
+	return WithMiddleware(h.Middleware)
+}
+
+// Middleware Return the transport calling the hooks around next
+func (h Hooks) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if h.OnRequest != nil {
+			// transports must not modify the request they are given
+			hooked := *req
+			hooked.Header = make(http.Header, len(req.Header))
+			for k, v := range req.Header {
+				hooked.Header[k] = v
+			}
+			h.OnRequest(&hooked)
+			req = &hooked
+		}
+		clock := ClockOrReal(h.Clock)
+		start := clock.Now()
+		res, err := next.RoundTrip(req)
+		if h.OnResponse != nil {
+			h.OnResponse(req, res, err, clock.Now().Sub(start))
+		}
+		return res, err
+	})
+}
+
+// wrap Return the transport sending through the middlewares to base
+func (a API) wrap(base http.RoundTripper) http.RoundTripper {
+	if len(a.Middlewares) == 0 {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(a.Middlewares) - 1; i >= 0; i-- {
+		base = a.Middlewares[i](base)
+	}
+	return base
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"parserr/helpers"
+	"strconv"
+)
+
+// SetEpisodeMonitored ...
+func (a API) SetEpisodeMonitored(ctx context.Context, id int, monitored bool) error {
+	if a.wouldDo(helpers.ActionChange, "set episode %d monitored %v", id, monitored) {
+		return nil
+	}
+	return a.update(ctx, APIEpisodeURL, id, func(resource map[string]interface{}) error {
+		resource["monitored"] = monitored
+		return nil
+	})
+}
+
+// SetSeasonMonitored ...
+func (a API) SetSeasonMonitored(ctx context.Context, seriesID, season int, monitored bool) error {
+	if a.wouldDo(helpers.ActionChange, "set season %d of series %d monitored %v", season, seriesID, monitored) {
+		return nil
+	}
+	return a.update(ctx, APISeriesURL, seriesID, func(resource map[string]interface{}) error {
+		seasons, _ := resource["seasons"].([]interface{})
+		for _, s := range seasons {
+			s, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if n, _ := s["seasonNumber"].(float64); int(n) == season {
+				s["monitored"] = monitored
+				return nil
+			}
+		}
+		return fmt.Errorf("series %d has no season %d", seriesID, season)
+	})
+}
+
+// SetMovieMonitored ...
+func (a API) SetMovieMonitored(ctx context.Context, id int, monitored bool) error {
+	if a.wouldDo(helpers.ActionChange, "set movie %d monitored %v", id, monitored) {
+		return nil
+	}
+	return a.update(ctx, APIMovieURL, id, func(resource map[string]interface{}) error {
+		resource["monitored"] = monitored
+		return nil
+	})
+}
+
+// update Fetch the resource, modify it and send it back. The resource is
+// kept as raw JSON so fields unknown to parserr are sent back untouched.
+func (a API) update(ctx context.Context, path string, id int, modify func(resource map[string]interface{}) error) error {
+	u := a.getURL(path + "/" + strconv.Itoa(id))
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return err
+	}
+	var resource map[string]interface{}
+	err = json.Unmarshal(body, &resource)
+	if err != nil {
+		return err
+	}
+	err = modify(resource)
+	if err != nil {
+		return err
+	}
+	j, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	// The v3 api only accepts updates with the id in the path
+	if a.version() >= APIVersion3 {
+		path = path + "/" + strconv.Itoa(id)
+	}
+	_, err = a.put(ctx, a.getURL(path).String(), bytes.NewReader(j))
+	return err
+}
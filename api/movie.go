@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"parserr/helpers"
+	"strconv"
+)
+
+// APIMovieFileURL ...
+const APIMovieFileURL = APIURL + "/moviefile"
+
+// GetMovies Return every movie of the library
+func (a API) GetMovies(ctx context.Context) (movies []Movie, err error) {
+	body, err := a.get(ctx, a.getURL(APIMovieURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &movies)
+	return
+}
+
+// UpdateMovie Save the path and monitoring of the movie. Fields parserr
+// doesn't know are sent back untouched.
+func (a API) UpdateMovie(ctx context.Context, movie Movie) error {
+	if a.wouldDo(helpers.ActionChange, "update movie %d (%s)", movie.ID, movie.Title) {
+		return nil
+	}
+	return a.update(ctx, APIMovieURL, movie.ID, func(resource map[string]interface{}) error {
+		resource["path"] = movie.Path
+		resource["monitored"] = movie.Monitored
+		if movie.RootFolderPath != "" {
+			resource["rootFolderPath"] = movie.RootFolderPath
+		}
+		return nil
+	})
+}
+
+// GetMovieFile ...
+func (a API) GetMovieFile(ctx context.Context, id int) (file MediaFile, err error) {
+	u := a.getURL(APIMovieFileURL + "/" + strconv.Itoa(id))
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &file)
+	return
+}
+
+// DeleteMovieFile Delete the file from the library and from disk, into the
+// recycle bin if the instance has one
+func (a API) DeleteMovieFile(ctx context.Context, id int) error {
+	if a.wouldDo(helpers.ActionDelete, "delete movie file %d", id) {
+		return nil
+	}
+	return a.delete(ctx, a.getURL(APIMovieFileURL+"/"+strconv.Itoa(id)))
+}
+
+// GetMovieFiles Return the files of the movie in the library
+func (a API) GetMovieFiles(ctx context.Context, movieID int) (files []MediaFile, err error) {
+	u := a.getURL(APIMovieFileURL)
+	query := u.Query()
+	query.Set("movieId", strconv.Itoa(movieID))
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &files)
+	return
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// APINamingURL ...
+const APINamingURL = APIURL + "/config/naming"
+
+// seasonToken Matches {season} and {season:00} like tokens
+var seasonToken = regexp.MustCompile(`\{season(:0+)?\}`)
+
+// NamingConfig Naming settings of the instance
+type NamingConfig struct {
+	SeasonFolderFormat string `json:"seasonFolderFormat"`
+}
+
+// GetNamingConfig ...
+func (a API) GetNamingConfig(ctx context.Context) (naming NamingConfig, err error) {
+	body, err := a.get(ctx, a.getURL(APINamingURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &naming)
+	return
+}
+
+// SeasonFolder Return the name of the folder of a season following the
+// format of the naming config, like "Season {season:00}"
+func (n NamingConfig) SeasonFolder(series Series, season int) string {
+	name := seasonToken.ReplaceAllStringFunc(n.SeasonFolderFormat, func(token string) string {
+		padding := strings.Count(token, "0")
+		return fmt.Sprintf("%0*d", padding, season)
+	})
+	name = strings.Replace(name, "{Series Title}", series.Title, -1)
+	return strings.Replace(name, "/", " ", -1)
+}
+
+// SeasonPath Return the folder where the episodes of a season belong,
+// honoring whether the series uses season folders
+func (a API) SeasonPath(ctx context.Context, seriesID, season int) (dir string, err error) {
+	series, err := a.GetSeriesByID(ctx, seriesID)
+	if err != nil {
+		return
+	}
+	if series.Path == "" {
+		return "", fmt.Errorf("series %d has no path", seriesID)
+	}
+	if !series.SeasonFolder {
+		return series.Path, nil
+	}
+	naming, err := a.GetNamingConfig(ctx)
+	if err != nil {
+		return
+	}
+	return path.Join(series.Path, naming.SeasonFolder(series, season)), nil
+}
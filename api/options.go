@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option Configure an API when creating it with NewSonarr, NewRadarr or NewAPI
+type Option func(a *API)
+
+// WithHTTPClient Send every request with the client instead of
+// http.DefaultClient
+func WithHTTPClient(c *http.Client) Option {
+	return func(a *API) {
+		a.HTTPClient = c
+	}
+}
+
+// WithTransport Send every request through the transport, keeping the rest
+// of the settings of the client
+func WithTransport(t http.RoundTripper) Option {
+	return func(a *API) {
+		c := *a.httpClient()
+		c.Transport = t
+		a.HTTPClient = &c
+	}
+}
+
+// WithTimeout Give up on requests taking longer than the timeout, endpoints
+// with their own timeout keep it
+func WithTimeout(d time.Duration) Option {
+	return func(a *API) {
+		a.Timeouts.Default = d
+	}
+}
+
+// WithTimeouts Use the timeouts by endpoint
+func WithTimeouts(t Timeouts) Option {
+	return func(a *API) {
+		a.Timeouts = t
+	}
+}
+
+// WithVersion Use the version of the api instead of detecting it, like
+// APIVersion3 for radarr v3 and later
+func WithVersion(v int) Option {
+	return func(a *API) {
+		a.Version = v
+	}
+}
+
+// apply Configure the api with the options, in order
+func (a *API) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(a)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// APIParseURL ...
+const APIParseURL = APIURL + "/parse"
+
+// ParseResult Series and episodes, movie, or artist and albums, the instance
+// matches a title with, they are empty if the title doesn't belong to the library
+type ParseResult struct {
+	Title    string    `json:"title"`
+	Series   *Series   `json:"series"`
+	Episodes []Episode `json:"episodes"`
+	Movie    *Movie    `json:"movie"`
+	Artist   *Artist   `json:"artist"`
+	Albums   []Album   `json:"albums"`
+}
+
+// Matched Return true if the title belongs to a series, movie or album of
+// the library
+func (p ParseResult) Matched() bool {
+	return p.Movie != nil || (p.Series != nil && len(p.Episodes) > 0) ||
+		(p.Artist != nil && len(p.Albums) > 0)
+}
+
+// Parse Match a release title, like the name of a file, with the library
+func (a API) Parse(ctx context.Context, title string) (p ParseResult, err error) {
+	u := a.getURL(APIParseURL)
+	query := u.Query()
+	query.Set("title", title)
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &p)
+	return
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// APIQualityProfileURL ...
+	APIQualityProfileURL = APIURL + "/qualityprofile"
+	// APIProfileURL Quality profiles of older versions, before they were
+	// renamed
+	APIProfileURL = APIURL + "/profile"
+)
+
+// QualityProfile Qualities the instance downloads for a series, movie or
+// artist and the one it stops upgrading at
+type QualityProfile struct {
+	ID   int
+	Name string
+	// Cutoff Id of the quality, or group, upgrades stop at
+	Cutoff int
+	Items  []QualityProfileItem
+}
+
+// QualityProfileItem Quality of a profile, or group of qualities if it has
+// items
+type QualityProfileItem struct {
+	// ID Id of the group, qualities use the one of Quality
+	ID      int
+	Name    string
+	Quality QualityDefinition
+	Items   []QualityProfileItem
+	Allowed bool
+}
+
+// QualityDefinition Quality known by the instance, like WEBDL-1080p
+type QualityDefinition struct {
+	ID         int
+	Name       string
+	Source     string
+	Resolution int
+}
+
+// GetQualityProfiles ...
+func (a API) GetQualityProfiles(ctx context.Context) (profiles []QualityProfile, err error) {
+	endpoint := APIQualityProfileURL
+	if a.version() < APIVersion3 && a.APIPath == "" {
+		endpoint = APIProfileURL
+	}
+	body, err := a.get(ctx, a.getURL(endpoint).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &profiles)
+	return
+}
+
+// QualityProfileOf Return the profile with the id, ok is false if there is
+// none
+func QualityProfileOf(profiles []QualityProfile, id int) (profile QualityProfile, ok bool) {
+	for _, p := range profiles {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return QualityProfile{}, false
+}
+
+// Allows Return true if the profile downloads the quality with the name.
+// Qualities of allowed groups are allowed.
+func (p QualityProfile) Allows(quality string) bool {
+	return allows(p.Items, quality, false)
+}
+
+func allows(items []QualityProfileItem, quality string, group bool) bool {
+	for _, item := range items {
+		if len(item.Items) > 0 {
+			if allows(item.Items, quality, group || item.Allowed) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(item.Quality.Name, quality) {
+			return item.Allowed || group
+		}
+	}
+	return false
+}
+
+// QualityProfileID Return the id of the quality profile of the series or
+// movie of the media, 0 if unknown
+func (m Media) QualityProfileID() int {
+	if m.Type == TypeMovie {
+		return firstNonZero(m.QueueElem.Movie.QualityProfileID, m.QueueElem.Movie.ProfileID)
+	}
+	return firstNonZero(m.QueueElem.Series.QualityProfileID, m.QueueElem.Series.ProfileID)
+}
+
+func firstNonZero(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package api
+
+import "testing"
+
+func TestQualityProfileAllows(t *testing.T) {
+	quality := func(name string, allowed bool) QualityProfileItem {
+		return QualityProfileItem{Quality: QualityDefinition{Name: name}, Allowed: allowed}
+	}
+	profile := QualityProfile{Items: []QualityProfileItem{
+		quality("SDTV", false),
+		quality("HDTV-720p", true),
+		{Name: "WEB 1080p", Allowed: true, Items: []QualityProfileItem{quality("WEBDL-1080p", false), quality("WEBRip-1080p", false)}},
+		{Name: "WEB 2160p", Items: []QualityProfileItem{quality("WEBDL-2160p", false)}},
+	}}
+	tests := []struct {
+		quality string
+		want    bool
+	}{
+		{"HDTV-720p", true},
+		{"hdtv-720p", true},
+		{"SDTV", false},
+		{"WEBRip-1080p", true},
+		{"WEBDL-2160p", false},
+		{"Bluray-1080p", false},
+	}
+	for _, tt := range tests {
+		if got := profile.Allows(tt.quality); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.quality, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// DefaultQueuePageSize Records fetched per queue request by GetAllQueue
+const DefaultQueuePageSize = 100
+
+// QueueOptions What the records of the queue include. The v3 api only
+// includes the media asked for, the legacy one always includes them.
+type QueueOptions struct {
+	IncludeSeries  bool
+	IncludeEpisode bool
+	IncludeMovie   bool
+	IncludeArtist  bool
+	IncludeAlbum   bool
+	// IncludeUnknown Include the downloads the *arr couldn't match with any
+	// media, only supported by the v3 api
+	IncludeUnknown bool
+}
+
+// QueueIncludeAll Include every media the records belong to
+var QueueIncludeAll = QueueOptions{
+	IncludeSeries:  true,
+	IncludeEpisode: true,
+	IncludeMovie:   true,
+	IncludeArtist:  true,
+	IncludeAlbum:   true,
+}
+
+// QueuePage Page of the queue, starting at 1
+type QueuePage struct {
+	Page         int
+	PageSize     int
+	TotalRecords int
+	Records      []QueueElem
+}
+
+// GetQueue Return the whole queue including every media
+func (a API) GetQueue(ctx context.Context) (queue []QueueElem, err error) {
+	return a.GetAllQueue(ctx, QueueIncludeAll)
+}
+
+// GetAllQueue Return the whole queue, walking every page of it
+func (a API) GetAllQueue(ctx context.Context, opts QueueOptions) (queue []QueueElem, err error) {
+	for page := 1; ; page++ {
+		var p QueuePage
+		p, err = a.GetQueuePaged(ctx, page, DefaultQueuePageSize, opts)
+		if err != nil {
+			return
+		}
+		queue = append(queue, p.Records...)
+		if len(p.Records) == 0 || len(queue) >= p.TotalRecords {
+			return
+		}
+	}
+}
+
+// GetQueuePaged Return a page of the queue, the statuses are capitalized
+// like the legacy api does. The legacy api isn't paginated, its queue is
+// fetched whole and paginated here.
+func (a API) GetQueuePaged(ctx context.Context, page, pageSize int, opts QueueOptions) (p QueuePage, err error) {
+	if a.version() < APIVersion3 {
+		return a.getLegacyQueuePage(ctx, page, pageSize)
+	}
+	u := a.getURL(APIQueueURL)
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("pageSize", strconv.Itoa(pageSize))
+	include := map[string]bool{
+		"includeSeries":  opts.IncludeSeries,
+		"includeEpisode": opts.IncludeEpisode,
+		"includeMovie":   opts.IncludeMovie,
+		"includeArtist":  opts.IncludeArtist,
+		"includeAlbum":   opts.IncludeAlbum,
+		// every *arr names it after its media, the others ignore it
+		"includeUnknownSeriesItems": opts.IncludeUnknown,
+		"includeUnknownMovieItems":  opts.IncludeUnknown,
+		"includeUnknownArtistItems": opts.IncludeUnknown,
+	}
+	for key, value := range include {
+		if value {
+			query.Set(key, "true")
+		}
+	}
+	u.RawQuery = query.Encode()
+	body, err := a.get(ctx, u.String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &p)
+	if err != nil {
+		return
+	}
+	for i := range p.Records {
+		p.Records[i].Status = capitalize(p.Records[i].Status)
+		p.Records[i].TrackedDownloadStatus = capitalize(p.Records[i].TrackedDownloadStatus)
+	}
+	return
+}
+
+// getLegacyQueuePage Return a page of the whole legacy queue
+func (a API) getLegacyQueuePage(ctx context.Context, page, pageSize int) (p QueuePage, err error) {
+	body, err := a.get(ctx, a.getURL(APIQueueURL).String())
+	if err != nil {
+		return
+	}
+	var queue []QueueElem
+	err = json.Unmarshal(body, &queue)
+	if err != nil {
+		return
+	}
+	p = QueuePage{Page: page, PageSize: pageSize, TotalRecords: len(queue)}
+	start := (page - 1) * pageSize
+	if page < 1 || pageSize < 1 || start >= len(queue) {
+		return p, nil
+	}
+	end := start + pageSize
+	if end > len(queue) {
+		end = len(queue)
+	}
+	p.Records = queue[start:end]
+	return p, nil
+}
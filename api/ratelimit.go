@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvRateLimit Max requests per second sent to every instance, unlimited
+	// if 0
+	EnvRateLimit = "PARSERR_RATE_LIMIT"
+	// EnvRateBurst Requests sent at once before being throttled, the rate
+	// limit by default
+	EnvRateBurst = "PARSERR_RATE_BURST"
+)
+
+// RateLimiter Token bucket throttling the requests to an instance, holding
+// up to Burst tokens refilled at Rate per second. Safe for concurrent use.
+type RateLimiter struct {
+	// Rate Requests per second
+	Rate  float64
+	Burst int
+	// Clock Used to refill the bucket and wait for tokens, RealClock if nil
+	Clock  Clock
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+// NewRateLimiter Return a limiter of rate requests per second, nil if rate
+// is 0 so requests aren't limited. Burst is at least 1.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{Rate: rate, Burst: burst}
+}
+
+// Wait Take a token, waiting until there is one or the context is done. A
+// nil limiter never waits.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	clock := ClockOrReal(l.Clock)
+	wait := l.reserve(clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+	return SleepContext(ctx, clock, wait)
+}
+
+// reserve Take a token, returning how long to wait until it's available.
+// Tokens can be owed so concurrent callers wait in turns.
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.last.IsZero() {
+		l.tokens = float64(l.Burst)
+	} else if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.Rate
+		if l.tokens > float64(l.Burst) {
+			l.tokens = float64(l.Burst)
+		}
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.Rate * float64(time.Second))
+}
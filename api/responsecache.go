@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EnvCacheTTL Time GET responses are reused without asking the instance
+const EnvCacheTTL = "PARSERR_CACHE_TTL"
+
+// cachedResponse ...
+type cachedResponse struct {
+	body         []byte
+	etag         string
+	lastModified string
+	fetched      time.Time
+}
+
+// ResponseCache Bodies of GET responses by url. Responses younger than TTL
+// are reused, older ones are revalidated with conditional requests when the
+// instance sends ETag or Last-Modified. Safe for concurrent use.
+type ResponseCache struct {
+	TTL       time.Duration
+	responses map[string]cachedResponse
+	mutex     sync.Mutex
+}
+
+type uncachedKey struct{}
+
+// Uncached Return a context whose GET requests always ask the instance,
+// revalidating the cached responses instead of reusing them, for checks that
+// can't see stale state
+func Uncached(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uncachedKey{}, true)
+}
+
+// uncached Return true if the cached responses can't be reused in the context
+func uncached(ctx context.Context) bool {
+	skip, _ := ctx.Value(uncachedKey{}).(bool)
+	return skip
+}
+
+// NewResponseCache ...
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{TTL: ttl, responses: make(map[string]cachedResponse)}
+}
+
+// fresh Return the body of the url if it has been fetched less than TTL ago
+func (c *ResponseCache) fresh(u string, now time.Time) (body []byte, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.responses[u]
+	if !ok || now.Sub(r.fetched) >= c.TTL {
+		return nil, false
+	}
+	return r.body, true
+}
+
+// prepare Add the conditional headers of the cached response of the url
+func (c *ResponseCache) prepare(req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.responses[req.URL.String()]
+	if !ok {
+		return
+	}
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	if r.lastModified != "" {
+		req.Header.Set("If-Modified-Since", r.lastModified)
+	}
+}
+
+// notModified Return the cached body of the url, marking it as fetched now
+func (c *ResponseCache) notModified(u string, now time.Time) (body []byte, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.responses[u]
+	if !ok {
+		return nil, false
+	}
+	r.fetched = now
+	c.responses[u] = r
+	return r.body, true
+}
+
+// store Cache the response of the url
+func (c *ResponseCache) store(u string, res *http.Response, body []byte, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.responses[u] = cachedResponse{
+		body:         body,
+		etag:         res.Header.Get("ETag"),
+		lastModified: res.Header.Get("Last-Modified"),
+		fetched:      now,
+	}
+}
+
+// Invalidate Forget every response, the instance has been modified
+func (c *ResponseCache) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.responses = make(map[string]cachedResponse)
+}
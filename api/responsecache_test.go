@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUncached(t *testing.T) {
+	var gets int32
+	srv := countingServer(http.StatusOK, map[string]*int32{"GET": &gets})
+	defer srv.Close()
+	a := NewSonarr(srv.URL, "key", "/downloads", WithVersion(APIVersion3)).API
+	a.ResponseCache = NewResponseCache(time.Hour)
+	u := a.getURL(APIQueueURL).String()
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := a.get(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gets != 1 {
+		t.Fatalf("%d requests, want the second one cached", gets)
+	}
+	if _, err := a.get(Uncached(ctx), u); err != nil {
+		t.Fatal(err)
+	}
+	if gets != 2 {
+		t.Errorf("%d requests, want the uncached one sent", gets)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/url"
+	"parserr/helpers"
+	"time"
+)
+
+const (
+	// EnvRetryAttempts Max times a request failing with a transient error is
+	// sent, 1 to never retry
+	EnvRetryAttempts = "PARSERR_RETRY_ATTEMPTS"
+	// EnvRetryBackoff Wait before the first retry, doubled after every one
+	EnvRetryBackoff = "PARSERR_RETRY_BACKOFF"
+	// EnvRetryJitter Percentage of every wait that is randomized
+	EnvRetryJitter = "PARSERR_RETRY_JITTER"
+	// DefaultRetryAttempts ...
+	DefaultRetryAttempts = 4
+	// DefaultRetryBackoff ...
+	DefaultRetryBackoff = time.Second
+	// DefaultRetryJitter ...
+	DefaultRetryJitter = 20
+	// DefaultRetryMaxBackoff ...
+	DefaultRetryMaxBackoff = 30 * time.Second
+)
+
+// Retry How requests failing with transient errors, like the 502 and 503
+// sonarr returns while scanning the library or connections refused while
+// it restarts, are retried. The zero value never retries.
+type Retry struct {
+	// Attempts Max times a request is sent, once if 0 or 1
+	Attempts int
+	// Backoff Wait before the first retry, doubled after every retry
+	Backoff time.Duration
+	// MaxBackoff Longest wait between retries, unlimited if 0
+	MaxBackoff time.Duration
+	// Jitter Fraction of every wait that is randomized, from 0 to 1, so
+	// clients failing at once don't retry at once
+	Jitter float64
+}
+
+// DefaultRetry Retry used by parserr unless configured otherwise
+func DefaultRetry() Retry {
+	return Retry{
+		Attempts:   DefaultRetryAttempts,
+		Backoff:    DefaultRetryBackoff,
+		MaxBackoff: DefaultRetryMaxBackoff,
+		Jitter:     DefaultRetryJitter / 100.0,
+	}
+}
+
+// WithRetry Retry the requests failing with transient errors
+func WithRetry(r Retry) Option {
+	return func(a *API) {
+		a.Retry = r
+	}
+}
+
+// wait Return the time to wait after the given failed attempt, starting at 1
+func (r Retry) wait(attempt int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < attempt && (r.MaxBackoff <= 0 || d < r.MaxBackoff); i++ {
+		d *= 2
+	}
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	if r.Jitter > 0 {
+		// rand's top level functions are safe for concurrent use
+		d -= time.Duration(r.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// retryable Return true if the error may not happen again, the instance
+// being busy or unreachable, and the context is still alive. Requests that
+// aren't idempotent are only retried if they weren't sent, the instance may
+// have run them even if the response never arrived.
+func retryable(ctx context.Context, err error, idempotent bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if !idempotent {
+		return notSent(err)
+	}
+	if apiErr, ok := IsAPIError(err); ok {
+		return apiErr.Temporary()
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// notSent Return true if the error happened connecting to the instance,
+// like a connection refused, so the request never reached it
+func notSent(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// retry Call send until it succeeds, fails with an error that isn't
+// transient or runs out of attempts, waiting between attempts as Retry says
+func (a API) retry(ctx context.Context, idempotent bool, send func() ([]byte, error)) (body []byte, err error) {
+	clock := ClockOrReal(a.Clock)
+	for attempt := 1; ; attempt++ {
+		body, err = send()
+		if err == nil || attempt >= a.Retry.Attempts || !retryable(ctx, err, idempotent) {
+			return
+		}
+		wait := a.Retry.wait(attempt)
+		helpers.Logf(ctx, "%s, retrying in %s (%d of %d)", err, wait, attempt, a.Retry.Attempts)
+		if sleepErr := SleepContext(ctx, clock, wait); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
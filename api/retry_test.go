@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingServer Server answering every request with the status, counting
+// the requests by method
+func countingServer(status int, counts map[string]*int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n, ok := counts[r.Method]; ok {
+			atomic.AddInt32(n, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte("{}"))
+	}))
+}
+
+func TestRetry(t *testing.T) {
+	var gets, posts, puts, deletes int32
+	counts := map[string]*int32{"GET": &gets, "POST": &posts, "PUT": &puts, "DELETE": &deletes}
+	srv := countingServer(http.StatusServiceUnavailable, counts)
+	defer srv.Close()
+	a := NewSonarr(srv.URL, "key", "/downloads", WithVersion(APIVersion3), WithRetry(Retry{Attempts: 3})).API
+	ctx := context.Background()
+	u := a.getURL(APIQueueURL)
+	if _, err := a.get(ctx, u.String()); err == nil {
+		t.Error("get: expected an error")
+	}
+	if _, err := a.post(ctx, u.String(), strings.NewReader("{}")); err == nil {
+		t.Error("post: expected an error")
+	}
+	if _, err := a.put(ctx, u.String(), strings.NewReader("{}")); err == nil {
+		t.Error("put: expected an error")
+	}
+	if err := a.delete(ctx, u); err == nil {
+		t.Error("delete: expected an error")
+	}
+	tests := []struct {
+		method string
+		count  int32
+		want   int32
+	}{
+		{"GET", gets, 3},
+		{"POST", posts, 1},
+		{"PUT", puts, 3},
+		{"DELETE", deletes, 3},
+	}
+	for _, tt := range tests {
+		if tt.count != tt.want {
+			t.Errorf("%s sent %d times, want %d", tt.method, tt.count, tt.want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errTest("connection refused")}
+	read := &net.OpError{Op: "read", Net: "tcp", Err: errTest("connection reset")}
+	busy := APIError{StatusCode: http.StatusServiceUnavailable}
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		want       bool
+	}{
+		{"dial idempotent", dial, true, true},
+		{"dial post", dial, false, true},
+		{"read idempotent", read, true, true},
+		{"read post", read, false, false},
+		{"5xx idempotent", busy, true, true},
+		{"5xx post", busy, false, false},
+		{"4xx", APIError{StatusCode: http.StatusBadRequest}, true, false},
+	}
+	for _, tt := range tests {
+		if got := retryable(context.Background(), tt.err, tt.idempotent); got != tt.want {
+			t.Errorf("%s: retryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if retryable(ctx, dial, true) {
+		t.Error("retried with the context done")
+	}
+}
+
+type errTest string
+
+func (e errTest) Error() string { return string(e) }
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// APIRootFolderURL ...
+const APIRootFolderURL = APIURL + "/rootfolder"
+
+// RootFolder Folder of the library where series, movies or artists are kept
+type RootFolder struct {
+	ID   int
+	Path string
+	// FreeSpace Bytes available as seen by the instance
+	FreeSpace  int64
+	Accessible bool
+}
+
+// GetRootFolders ...
+func (a API) GetRootFolders(ctx context.Context) (folders []RootFolder, err error) {
+	body, err := a.get(ctx, a.getURL(APIRootFolderURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &folders)
+	return
+}
+
+// RootFolderOf Return the root folder the path is in, the deepest one if
+// they are nested
+func RootFolderOf(folders []RootFolder, p string) (folder RootFolder, ok bool) {
+	p = path.Clean(p)
+	for _, f := range folders {
+		root := path.Clean(f.Path)
+		if p != root && !strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/") {
+			continue
+		}
+		if !ok || len(root) > len(path.Clean(folder.Path)) {
+			folder, ok = f, true
+		}
+	}
+	return
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"parserr/helpers"
+)
+
+// GetSeries Return every series of the library
+func (a API) GetSeries(ctx context.Context) (series []Series, err error) {
+	body, err := a.get(ctx, a.getURL(APISeriesURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &series)
+	return
+}
+
+// UpdateSeries Save the path, season folder, monitoring and numbering
+// settings of the series and the monitoring of its seasons. Fields parserr
+// doesn't know are sent back untouched.
+func (a API) UpdateSeries(ctx context.Context, series Series) error {
+	if a.wouldDo(helpers.ActionChange, "update series %d (%s)", series.ID, series.Title) {
+		return nil
+	}
+	return a.update(ctx, APISeriesURL, series.ID, func(resource map[string]interface{}) error {
+		resource["path"] = series.Path
+		resource["seasonFolder"] = series.SeasonFolder
+		resource["monitored"] = series.Monitored
+		resource["useSceneNumbering"] = series.UseSceneNumbering
+		if series.SeriesType != "" {
+			resource["seriesType"] = series.SeriesType
+		}
+		if series.RootFolderPath != "" {
+			resource["rootFolderPath"] = series.RootFolderPath
+		}
+		seasons, _ := resource["seasons"].([]interface{})
+		for _, s := range seasons {
+			s, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			n, _ := s["seasonNumber"].(float64)
+			if season, ok := series.Season(int(n)); ok {
+				s["monitored"] = season.Monitored
+			}
+		}
+		return nil
+	})
+}
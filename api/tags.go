@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"parserr/helpers"
+	"strings"
+)
+
+// APITagURL ...
+const APITagURL = APIURL + "/tag"
+
+// Tag Label series, movies and artists can be given in the instance
+type Tag struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+}
+
+// GetTags ...
+func (a API) GetTags(ctx context.Context) (tags []Tag, err error) {
+	body, err := a.get(ctx, a.getURL(APITagURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &tags)
+	return
+}
+
+// CreateTag Create the tag with the label, the instance lowercases it
+func (a API) CreateTag(ctx context.Context, label string) (tag Tag, err error) {
+	if a.wouldDo(helpers.ActionChange, "create tag %s", label) {
+		return Tag{Label: label}, nil
+	}
+	j, err := json.Marshal(Tag{Label: label})
+	if err != nil {
+		return
+	}
+	body, err := a.post(ctx, a.getURL(APITagURL).String(), bytes.NewReader(j))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &tag)
+	return
+}
+
+// ApplyTag Give the tag to the series, movie or artist with the id,
+// depending on the type of the api. The rest of its tags are kept.
+func (a API) ApplyTag(ctx context.Context, id, tagID int) error {
+	if a.wouldDo(helpers.ActionChange, "tag %s %d with tag %d", a.Type, id, tagID) {
+		return nil
+	}
+	path := APISeriesURL
+	if a.Type == TypeMovie {
+		path = APIMovieURL
+	} else if a.Type == TypeMusic {
+		path = APIArtistURL
+	}
+	return a.update(ctx, path, id, func(resource map[string]interface{}) error {
+		tags, _ := resource["tags"].([]interface{})
+		for _, t := range tags {
+			if n, _ := t.(float64); int(n) == tagID {
+				return nil
+			}
+		}
+		resource["tags"] = append(tags, tagID)
+		return nil
+	})
+}
+
+// EnsureTag Return the tag of the instance with the label, creating it if
+// it doesn't exist
+func EnsureTag(ctx context.Context, a RRAPI, label string) (Tag, error) {
+	tags, err := a.GetTags(ctx)
+	if err != nil {
+		return Tag{}, fmt.Errorf("cannot get tags: %s", err)
+	}
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Label, label) {
+			return tag, nil
+		}
+	}
+	tag, err := a.CreateTag(ctx, label)
+	if err != nil {
+		return Tag{}, fmt.Errorf("cannot create tag %s: %s", label, err)
+	}
+	return tag, nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvTimeout Timeout of the requests to the instances, none if empty
+	EnvTimeout = "PARSERR_TIMEOUT"
+	// EnvTimeouts Comma separated list of endpoint=timeout overriding
+	// EnvTimeout, like history=2m,command=5m
+	EnvTimeouts = "PARSERR_TIMEOUTS"
+)
+
+// Timeouts Timeout of the requests by endpoint, being the endpoint the part
+// of the path after /api/, like queue, history or command, whatever the url
+// base of the instance is
+type Timeouts struct {
+	// Default Used for endpoints without their own timeout, none if 0
+	Default    time.Duration
+	ByEndpoint map[string]time.Duration
+}
+
+// ParseTimeouts Parse a list like history=2m,command=5m
+func ParseTimeouts(def time.Duration, value string) (t Timeouts, err error) {
+	t = Timeouts{Default: def, ByEndpoint: make(map[string]time.Duration)}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return t, fmt.Errorf("invalid timeout %q, use endpoint=duration", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return t, fmt.Errorf("invalid timeout %q: %s", entry, err)
+		}
+		t.ByEndpoint[strings.ToLower(strings.TrimSpace(parts[0]))] = d
+	}
+	return t, nil
+}
+
+// apiVersionPrefix Version in the path of the endpoint, like v3/ or v1/
+var apiVersionPrefix = regexp.MustCompile(`^v[0-9]+/`)
+
+// For Return the timeout of the requests to the url
+func (t Timeouts) For(u string) time.Duration {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return t.Default
+	}
+	path := parsed.Path
+	if i := strings.Index(path, APIURL+"/"); i != -1 {
+		path = apiVersionPrefix.ReplaceAllString(path[i+len(APIURL)+1:], "")
+	}
+	endpoint := strings.ToLower(strings.SplitN(path, "/", 2)[0])
+	if d, ok := t.ByEndpoint[endpoint]; ok {
+		return d
+	}
+	return t.Default
+}
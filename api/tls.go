@@ -0,0 +1,32 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// NewTLSConfig Return a TLS configuration authenticating with the client
+// certificate, for instances behind mutual TLS reverse proxies. If caFile is
+// not empty the server certificate is verified against it instead of the
+// system pool.
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load client certificate: %s", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read certificate authority: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
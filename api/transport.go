@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// DialFunc Open a connection to an instance, used to reach instances that
+// aren't exposed on TCP, like through a unix socket or a tsnet server
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// UnixSocketDialer Return a DialFunc connecting always to the socket,
+// whatever the address of the request is
+func UnixSocketDialer(path string) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// NewClient Return an http client connecting with the dialer and the TLS
+// configuration, the defaults are used for any of them that is nil
+func NewClient(dial DialFunc, tlsConfig *tls.Config) *http.Client {
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	if dial != nil {
+		transport.DialContext = dial
+		transport.Proxy = nil
+	}
+	return &http.Client{Transport: transport}
+}
+
+// APIKeyTransport Authenticate every request with the X-Api-Key header, so
+// the key doesn't end up in logs, proxies or browser history
+type APIKeyTransport struct {
+	APIKey string
+	// Base Used to send the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+// RoundTrip ...
+func (t APIKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authenticated := *req
+	authenticated.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authenticated.Header[k] = v
+	}
+	authenticated.Header.Set("X-Api-Key", t.APIKey)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(&authenticated)
+}
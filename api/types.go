@@ -1,51 +1,130 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 const (
+	// HistoryEventGrabbed Event of a release sent to the download client
+	HistoryEventGrabbed = "grabbed"
+	// HistoryEventDownloadFolderImported Event of a download imported
+	HistoryEventDownloadFolderImported = "downloadFolderImported"
+	// HistoryEventDownloadFailed Event of a download the client failed
+	HistoryEventDownloadFailed = "downloadFailed"
+	// EnvSonarrName ...
+	EnvSonarrName = "SONARR_NAME"
 	// EnvSonarrURL ...
 	EnvSonarrURL = "SONARR_URL"
+	// EnvSonarrURLBase Path prefix of sonarr behind a reverse proxy, like /sonarr
+	EnvSonarrURLBase = "SONARR_URL_BASE"
+	// EnvSonarrAPIVersion Version of the api of sonarr, detected if empty
+	EnvSonarrAPIVersion = "SONARR_API_VERSION"
+	// EnvSonarrSocket Unix socket to connect to sonarr
+	EnvSonarrSocket = "SONARR_SOCKET"
 	// EnvSonarrAPIKey ...
 	EnvSonarrAPIKey = "SONARR_APIKEY"
-	// EnvSonarrDownloadFolder ...
+	// EnvSonarrDownloadFolder Comma separated list of folders, each one can
+	// set its strategy as path=strategy
 	EnvSonarrDownloadFolder = "SONARR_DOWNLOAD_FOLDER"
+	// EnvRadarrName ...
+	EnvRadarrName = "RADARR_NAME"
 	// EnvRadarrURL ...
 	EnvRadarrURL = "RADARR_URL"
+	// EnvRadarrURLBase Path prefix of radarr behind a reverse proxy, like /radarr
+	EnvRadarrURLBase = "RADARR_URL_BASE"
+	// EnvRadarrAPIVersion Version of the api of radarr, detected if empty
+	EnvRadarrAPIVersion = "RADARR_API_VERSION"
+	// EnvRadarrSocket Unix socket to connect to radarr
+	EnvRadarrSocket = "RADARR_SOCKET"
 	// EnvRadarrAPIKey ...
 	EnvRadarrAPIKey = "RADARR_APIKEY"
-	// EnvRadarrDownloadFolder ...
+	// EnvRadarrDownloadFolder Comma separated list of folders, each one can
+	// set its strategy as path=strategy
 	EnvRadarrDownloadFolder = "RADARR_DOWNLOAD_FOLDER"
+	// EnvLidarrName ...
+	EnvLidarrName = "LIDARR_NAME"
+	// EnvLidarrURL ...
+	EnvLidarrURL = "LIDARR_URL"
+	// EnvLidarrURLBase Path prefix of lidarr behind a reverse proxy, like /lidarr
+	EnvLidarrURLBase = "LIDARR_URL_BASE"
+	// EnvLidarrSocket Unix socket to connect to lidarr
+	EnvLidarrSocket = "LIDARR_SOCKET"
+	// EnvLidarrAPIKey ...
+	EnvLidarrAPIKey = "LIDARR_APIKEY"
+	// EnvLidarrDownloadFolder Comma separated list of folders, each one can
+	// set its strategy as path=strategy
+	EnvLidarrDownloadFolder = "LIDARR_DOWNLOAD_FOLDER"
+	// EnvReadOnly Only send GET requests, mutations are logged
+	EnvReadOnly = "PARSERR_READ_ONLY"
+	// EnvHistoryPageSize Records fetched per history request
+	EnvHistoryPageSize = "PARSERR_HISTORY_PAGE_SIZE"
+	// EnvTLSCert Client certificate for mutual TLS, enables https
+	EnvTLSCert = "PARSERR_TLS_CERT"
+	// EnvTLSKey Key of the client certificate
+	EnvTLSKey = "PARSERR_TLS_KEY"
+	// EnvTLSCA Certificate authority of the server, system pool if empty
+	EnvTLSCA = "PARSERR_TLS_CA"
+	// EnvAPIKeyInQuery Send the api key as a query parameter, for old versions
+	EnvAPIKeyInQuery = "PARSERR_APIKEY_IN_QUERY"
+	// EnvRecordFolder Save every api response as a fixture in this folder
+	EnvRecordFolder = "PARSERR_RECORD_FOLDER"
 	// StatusWarning ...
 	StatusWarning = "Warning"
 	// CommandStateCompleted ...
 	CommandStateCompleted = "completed"
+	// CommandStateFailed ...
+	CommandStateFailed = "failed"
+	// CommandStateAborted ...
+	CommandStateAborted = "aborted"
+	// CommandStateCancelled ...
+	CommandStateCancelled = "cancelled"
+	// CommandStateOrphaned The instance restarted while running the command
+	CommandStateOrphaned = "orphaned"
 )
 
 // HistoryRec ...
 type HistoryRec struct {
+	ID                    int
 	DownloadID            string
+	Date                  time.Time
+	EventType             string
+	Data                  HistoryData
 	SourceTitle           string
 	Status                string
 	TrackedDownloadStatus string
 	Movie                 Movie
 	Series                Series
 	Episode               Episode
+	Artist                Artist
+	Album                 Album
 	Quality               Quality
 }
 
 func (h HistoryRec) String() string {
-	format := "HistoryRecord\nDownloadID: %s\nSourceTitle: %s\nStatus: %s\nTrackedDownloadStatus: %s\n%s%s%s%s\n"
-	return fmt.Sprintf(format, h.DownloadID, h.SourceTitle, h.Status, h.TrackedDownloadStatus, h.Movie, h.Series, h.Episode, h.Quality)
+	format := "HistoryRecord\nDownloadID: %s\nDate: %s\nSourceTitle: %s\nStatus: %s\nTrackedDownloadStatus: %s\n%s%s%s%s\n"
+	return fmt.Sprintf(format, h.DownloadID, h.Date, h.SourceTitle, h.Status, h.TrackedDownloadStatus, h.Movie, h.Series, h.Episode, h.Quality)
 }
 
-// Path Return the path of the movie / show
+// Path Return the path of the movie / show / artist
 func (h HistoryRec) Path() string {
 	if h.Series.Path != "" {
 		return h.Series.Path
 	}
+	if h.Artist.Path != "" {
+		return h.Artist.Path
+	}
 	return h.Movie.Path
 }
 
+// HistoryData Details of a history event, which ones are set depends on the
+// event type
+type HistoryData struct {
+	GUID           string `json:"guid"`
+	Indexer        string `json:"indexer"`
+	DownloadClient string `json:"downloadClient"`
+}
+
 // QueueElem ...
 type QueueElem struct {
 	ID                    int
@@ -56,8 +135,11 @@ type QueueElem struct {
 	Movie                 Movie
 	Series                Series
 	Episode               Episode
+	Artist                Artist
+	Album                 Album
 	Quality               Quality
 	StatusMessages        []StatusMessage
+	Indexer               string
 }
 
 func (q QueueElem) String() string {
@@ -65,19 +147,23 @@ func (q QueueElem) String() string {
 	return fmt.Sprintf(format, q.ID, q.DownloadID, q.Title, q.Status, q.TrackedDownloadStatus, q.Movie, q.Series, q.Episode, q.Quality, q.StatusMessages)
 }
 
-// Path Return the path of the movie / show
+// Path Return the path of the movie / show / artist
 func (q QueueElem) Path() string {
 	if q.Series.Path != "" {
 		return q.Series.Path
 	}
+	if q.Artist.Path != "" {
+		return q.Artist.Path
+	}
 	return q.Movie.Path
 }
 
 // History ...
 type History struct {
-	Page     int
-	PageSize int
-	Records  []HistoryRec
+	Page         int
+	PageSize     int
+	TotalRecords int
+	Records      []HistoryRec
 }
 
 func (h History) String() string {
@@ -91,18 +177,106 @@ type Episode struct {
 	SeasonNumber  int
 	EpisodeNumber int
 	HasFile       bool
+	AirDateUtc    time.Time
+	Title         string
+	Overview      string
+	// AirDate Local air date, like 2006-01-02
+	AirDate       string
+	EpisodeFileID int
+	TvDbEpisodeID int
+	// AbsoluteEpisodeNumber Number of the episode counting from the first
+	// one of the series, used by anime, zero if unknown
+	AbsoluteEpisodeNumber int
+	// SceneAbsoluteEpisodeNumber Absolute number releases use when it
+	// differs from the TVDB one, zero if it doesn't
+	SceneAbsoluteEpisodeNumber int
+	// SceneSeasonNumber Season releases use when the scene numbers the
+	// series differently than TVDB, SceneEpisodeNumber is zero if it doesn't
+	SceneSeasonNumber  int
+	SceneEpisodeNumber int
+}
+
+// ReleaseNumbers Return the season and episode numbers releases of the
+// episode are named with, the scene ones if the series uses them
+func (e Episode) ReleaseNumbers(series Series) (season, episode int) {
+	if series.UseSceneNumbering && e.SceneEpisodeNumber > 0 {
+		return e.SceneSeasonNumber, e.SceneEpisodeNumber
+	}
+	return e.SeasonNumber, e.EpisodeNumber
 }
 
 func (e Episode) String() string {
-	format := "Episode\nID: %d\nSeasonNumber: %d\nEpisodeNumber: %d\nHasFile: %v\n"
-	return fmt.Sprintf(format, e.ID, e.SeasonNumber, e.EpisodeNumber, e.HasFile)
+	format := "Episode\nID: %d\nSeasonNumber: %d\nEpisodeNumber: %d\nHasFile: %v\nAirDateUtc: %s\n"
+	return fmt.Sprintf(format, e.ID, e.SeasonNumber, e.EpisodeNumber, e.HasFile, e.AirDateUtc)
 }
 
 // Series ...
 type Series struct {
-	ID    int
-	Title string
-	Path  string
+	ID        int
+	Title     string
+	TitleSlug string
+	Path      string
+	// SeasonFolder Episodes are kept in a folder per season
+	SeasonFolder bool
+	// UseSceneNumbering Releases are parsed with the scene numbers of the
+	// episodes instead of the TVDB ones
+	UseSceneNumbering bool
+	Monitored         bool
+	Year              int
+	TvdbID            int
+	// SeriesType standard, daily or anime
+	SeriesType     string
+	RootFolderPath string
+	// Runtime Usual length of the episodes in minutes, 0 if unknown
+	Runtime int
+	// Tags Ids of the tags of the series
+	Tags []int
+	// QualityProfileID Quality profile of the episodes
+	QualityProfileID int
+	// ProfileID Quality profile in older versions
+	ProfileID int
+	Seasons   []Season
+	// Statistics Counts of the whole series, only filled by the v3 api
+	Statistics SeriesStatistics
+}
+
+// Season Season of a series
+type Season struct {
+	SeasonNumber int
+	Monitored    bool
+	Statistics   SeasonStatistics
+}
+
+// SeasonStatistics Counts of the episodes and files of a season
+type SeasonStatistics struct {
+	EpisodeFileCount  int
+	EpisodeCount      int
+	TotalEpisodeCount int
+	SizeOnDisk        int64
+	PercentOfEpisodes float64
+	PreviousAiring    time.Time
+	NextAiring        time.Time
+}
+
+// SeriesStatistics Counts of the episodes and files of a series
+type SeriesStatistics struct {
+	SeasonCount       int
+	EpisodeFileCount  int
+	EpisodeCount      int
+	TotalEpisodeCount int
+	SizeOnDisk        int64
+	PercentOfEpisodes float64
+}
+
+// Season Return the season with the number, ok is false if the series
+// doesn't have it
+func (s Series) Season(number int) (season Season, ok bool) {
+	for _, season := range s.Seasons {
+		if season.SeasonNumber == number {
+			return season, true
+		}
+	}
+	return Season{}, false
 }
 
 func (s Series) String() string {
@@ -111,10 +285,41 @@ func (s Series) String() string {
 
 // Movie ...
 type Movie struct {
-	ID      int
-	Title   string
-	Path    string
-	HasFile bool
+	ID        int
+	Title     string
+	TitleSlug string
+	Path      string
+	HasFile   bool
+	Year      int
+	Overview  string
+	ImdbID    string
+	TmdbID    int
+	MovieFile MediaFile
+	Monitored bool
+	// RootFolderPath Library folder the folder of the movie is in
+	RootFolderPath string
+	SizeOnDisk     int64
+	// Runtime Length of the movie in minutes, 0 if unknown
+	Runtime int
+	// Tags Ids of the tags of the movie
+	Tags []int
+	// QualityProfileID ...
+	QualityProfileID int
+	// ProfileID Quality profile in older versions
+	ProfileID int
+}
+
+// MediaFile File of an episode or movie in the library
+type MediaFile struct {
+	ID   int
+	Path string
+	// RelativePath Path inside the folder of the series or movie
+	RelativePath string
+	Size         int64
+	DateAdded    time.Time
+	// SceneName Release the file was imported from, if known
+	SceneName string
+	Quality   Quality
 }
 
 func (m Movie) String() string {
@@ -163,6 +368,18 @@ func (c Command) String() string {
 type CommandStatus struct {
 	Command
 	State string `json:"state"`
+	// Status State of the command in the v3 api
+	Status string `json:"status"`
+}
+
+// Finished Return true if the command won't change its state anymore,
+// whether it completed or not
+func (c CommandStatus) Finished() bool {
+	switch c.State {
+	case CommandStateCompleted, CommandStateFailed, CommandStateAborted, CommandStateCancelled, CommandStateOrphaned:
+		return true
+	}
+	return false
 }
 
 func (c CommandStatus) String() string {
@@ -175,6 +392,13 @@ type CommandBody struct {
 	Path      string `json:"path,omitempty"`
 	SeriesIds []int  `json:"seriesIds,omitempty"`
 	MovieIds  []int  `json:"movieIds,omitempty"`
+	SeriesID  int    `json:"seriesId,omitempty"`
+	MovieID   int    `json:"movieId,omitempty"`
+	ArtistIds []int  `json:"artistIds,omitempty"`
+	ArtistID  int    `json:"artistId,omitempty"`
+	// Files Files of a ManualImport command
+	Files      []ManualImportFile `json:"files,omitempty"`
+	ImportMode string             `json:"importMode,omitempty"`
 }
 
 func (c CommandBody) String() string {
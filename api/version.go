@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// APIVersionLegacy Api under /api, of sonarr v2 and radarr v0.2
+	APIVersionLegacy = 2
+	// APIVersion3 Api under /api/v3, of sonarr v3 and v4 and radarr v3 and later
+	APIVersion3 = 3
+	// APIV3URL ...
+	APIV3URL = APIURL + "/v3"
+	// APISystemStatusURL ...
+	APISystemStatusURL = APIURL + "/system/status"
+)
+
+// supportedMajors Major versions of every app known to work, by app name
+var supportedMajors = map[string][2]int{
+	"Sonarr": {2, 4},
+	"Radarr": {0, 5},
+	"Lidarr": {0, 2},
+}
+
+// SystemStatus Status of the instance
+type SystemStatus struct {
+	Version string
+	// AppName Sonarr, Radarr or Lidarr, only reported by recent versions
+	AppName      string
+	InstanceName string
+	URLBase      string `json:"urlBase"`
+}
+
+// Major Return the major version, -1 if unknown
+func (s SystemStatus) Major() int {
+	major, err := strconv.Atoi(strings.SplitN(s.Version, ".", 2)[0])
+	if err != nil {
+		return -1
+	}
+	return major
+}
+
+// Supported Return an error if the version of the app isn't known to work,
+// apps that don't report their name aren't checked
+func (s SystemStatus) Supported() error {
+	majors, ok := supportedMajors[s.AppName]
+	if !ok {
+		return nil
+	}
+	major := s.Major()
+	if major < 0 {
+		return fmt.Errorf("unknown version %q of %s", s.Version, s.AppName)
+	}
+	if major < majors[0] {
+		return fmt.Errorf("%s %s is not supported, upgrade to v%d or later", s.AppName, s.Version, majors[0])
+	}
+	if major > majors[1] {
+		return fmt.Errorf("%s %s is newer than the latest supported, v%d, it might not work", s.AppName, s.Version, majors[1])
+	}
+	return nil
+}
+
+// GetSystemStatus Return the status of the instance, detecting the version
+// of the api first if needed
+func (a API) GetSystemStatus(ctx context.Context) (status SystemStatus, err error) {
+	body, err := a.get(ctx, a.getURL(APISystemStatusURL).String())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &status)
+	return
+}
+
+// versionDetector Version of the api detected on the first request, shared
+// by every copy of the API. A failed detection is tried again after a backoff
+// doubling up to maxDetectBackoff, the legacy api is used meanwhile.
+type versionDetector struct {
+	mutex   sync.Mutex
+	version int
+	// detecting Closed once the detection in progress is done
+	detecting chan struct{}
+	failures  int
+	retryAt   time.Time
+}
+
+const (
+	// minDetectBackoff Wait after the first failed detection
+	minDetectBackoff = 30 * time.Second
+	// maxDetectBackoff Longest wait between detections
+	maxDetectBackoff = 30 * time.Minute
+)
+
+// backoff Return how long to wait after the failures
+func (d *versionDetector) backoff() time.Duration {
+	wait := minDetectBackoff
+	for i := 1; i < d.failures && wait < maxDetectBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxDetectBackoff {
+		wait = maxDetectBackoff
+	}
+	return wait
+}
+
+// ParseAPIVersion Parse a version like 3 or v3, empty or auto mean it has
+// to be detected
+func ParseAPIVersion(value string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return 0, nil
+	case "2", "v2":
+		return APIVersionLegacy, nil
+	case "3", "v3", "4", "v4":
+		return APIVersion3, nil
+	}
+	return 0, fmt.Errorf("unknown api version %q, use v2, v3 or auto", value)
+}
+
+// version Return the version of the api, detecting it on the first call if
+// it's not set. APIs not created by NewSonarr or NewRadarr use the legacy one.
+// Calls made while it's being detected wait for it, without holding the lock
+// during the requests.
+func (a API) version() int {
+	if a.Version != 0 {
+		return a.Version
+	}
+	d := a.detector
+	if d == nil {
+		return APIVersionLegacy
+	}
+	clock := ClockOrReal(a.Clock)
+	d.mutex.Lock()
+	if d.version != 0 {
+		defer d.mutex.Unlock()
+		return d.version
+	}
+	if done := d.detecting; done != nil {
+		d.mutex.Unlock()
+		<-done
+		return a.version()
+	}
+	if clock.Now().Before(d.retryAt) {
+		d.mutex.Unlock()
+		return APIVersionLegacy
+	}
+	done := make(chan struct{})
+	d.detecting = done
+	d.mutex.Unlock()
+	v, err := a.DetectVersion(context.Background())
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.detecting = nil
+	close(done)
+	if err != nil {
+		d.failures++
+		wait := d.backoff()
+		d.retryAt = clock.Now().Add(wait)
+		log.Printf("cannot detect api version of %s, using v%d for %s: %s", a.Name, APIVersionLegacy, wait, err)
+		return APIVersionLegacy
+	}
+	log.Printf("%s uses api v%d", a.Name, v)
+	d.version = v
+	return v
+}
+
+// DetectVersion Ask the instance for its status, first under /api/v3 and
+// then under /api, returning the version of the api it answered on
+func (a API) DetectVersion(ctx context.Context) (int, error) {
+	var status SystemStatus
+	var err error
+	for _, v := range []int{APIVersion3, APIVersionLegacy} {
+		pinned := a
+		pinned.Version = v
+		pinned.ResponseCache = nil
+		var body []byte
+		body, err = pinned.get(ctx, pinned.getURL(APISystemStatusURL).String())
+		if err != nil {
+			continue
+		}
+		if err = json.Unmarshal(body, &status); err != nil {
+			continue
+		}
+		if status.Version == "" {
+			err = fmt.Errorf("status without version")
+			continue
+		}
+		return v, nil
+	}
+	return 0, err
+}
+
+// versionedPath Return the path of the endpoint for the version of the api,
+// under APIPath if it's set and renamed as Endpoints says
+func (a API) versionedPath(path string) string {
+	if !strings.HasPrefix(path, APIURL+"/") {
+		return path
+	}
+	rest := a.Endpoints.rename(strings.TrimPrefix(path, APIURL))
+	if a.APIPath != "" {
+		return a.APIPath + rest
+	}
+	if a.version() < APIVersion3 {
+		return APIURL + rest
+	}
+	return APIV3URL + rest
+}
+
+// decodeCommandStatus Decode the status of a command of any version of the api
+func decodeCommandStatus(body []byte) (cs CommandStatus, err error) {
+	err = json.Unmarshal(body, &cs)
+	if cs.State == "" {
+		cs.State = cs.Status
+	}
+	return
+}
+
+// capitalize Return the string with its first letter in upper case
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVersionDetectionBackoff(t *testing.T) {
+	var gets int32
+	srv := countingServer(http.StatusNotFound, map[string]*int32{"GET": &gets})
+	defer srv.Close()
+	a := NewSonarr(srv.URL, "key", "/downloads", WithRetry(Retry{Attempts: 1})).API
+	clock := &FakeClock{Time: time.Now()}
+	a.Clock = clock
+	for i := 0; i < 3; i++ {
+		if v := a.version(); v != APIVersionLegacy {
+			t.Fatalf("version %d, want the legacy one", v)
+		}
+	}
+	if gets != 2 {
+		t.Fatalf("%d detection requests, want 2", gets)
+	}
+	clock.Sleep(minDetectBackoff)
+	a.version()
+	if gets != 4 {
+		t.Errorf("%d detection requests after the backoff, want 4", gets)
+	}
+	a.version()
+	if gets != 4 {
+		t.Errorf("%d detection requests before the next backoff, want 4", gets)
+	}
+}
+
+func TestVersionDetectorBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, minDetectBackoff},
+		{2, 2 * minDetectBackoff},
+		{3, 4 * minDetectBackoff},
+		{20, maxDetectBackoff},
+	}
+	for _, tt := range tests {
+		d := versionDetector{failures: tt.failures}
+		if got := d.backoff(); got != tt.want {
+			t.Errorf("%d failures: %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
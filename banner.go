@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/client"
+	"parserr/parser"
+	"strings"
+)
+
+// logBanner Log the effective settings that modify or delete data, so a more
+// dangerous configuration than intended is noticed on startup
+func logBanner(safety parser.Safety, apis []api.RRAPI) {
+	lines := []string{"effective settings:"}
+	add := func(name, value string) {
+		lines = append(lines, "\t"+name+": "+value)
+	}
+	if readOnly() {
+		add("read-only", "yes, nothing will be modified")
+	} else {
+		add("read-only", "no")
+	}
+	add("safety", safety.Level)
+	add("overwrite existing files", yesNo(safety.AllowOverwrite()))
+	add("delete extracted archives", yesNo(safety.AllowDelete()))
+	for _, a := range apis {
+		var folders []string
+		for _, folder := range a.GetDownloadFolders() {
+			strategy := folder.Strategy
+			if strategy == "" {
+				strategy = parser.DefaultStrategy(a)
+			}
+			folders = append(folders, folder.Path+"="+strategy)
+		}
+		add("move mode of "+a.GetName(), strings.Join(folders, ", "))
+	}
+	add("blacklist failed releases", yesNo(envBoolValue(parser.EnvBlacklistFailed)))
+	if n := envIntValue(parser.EnvUnmonitorAfter, 0); n > 0 && envBoolValue(parser.EnvBlacklistFailed) {
+		add("unmonitor after blacklisted releases", os.Getenv(parser.EnvUnmonitorAfter))
+	}
+	cleanup := "no"
+	if os.Getenv(client.EnvClientType) != "" && envBoolValue(client.EnvClientCleanup) {
+		cleanup = "yes"
+		if category := os.Getenv(client.EnvClientImportedCategory); category != "" {
+			cleanup = "no, moved to category " + category
+		}
+	}
+	add("delete from download client", cleanup)
+	if copyMode() {
+		add("copy and delete originals once seeded", yesNo(safety.AllowDelete()))
+	}
+	if dir := os.Getenv(parser.EnvQuarantineFolder); dir != "" {
+		add("quarantine", dir)
+	}
+	if envBoolValue(parser.EnvMediaManagement) {
+		add("recycle replaced files", "yes, using the media management settings")
+	}
+	log.Print(strings.Join(lines, "\n"))
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"parserr/helpers"
+)
+
+const (
+	// EnvClientType Type of the download client, qbittorrent or sabnzbd
+	EnvClientType = "PARSERR_CLIENT_TYPE"
+	// EnvClientURL ...
+	EnvClientURL = "PARSERR_CLIENT_URL"
+	// EnvClientUsername ...
+	EnvClientUsername = "PARSERR_CLIENT_USERNAME"
+	// EnvClientPassword ...
+	EnvClientPassword = "PARSERR_CLIENT_PASSWORD"
+	// EnvClientAPIKey ...
+	EnvClientAPIKey = "PARSERR_CLIENT_APIKEY"
+	// EnvClientCleanup Remove downloads from the client history once imported
+	EnvClientCleanup = "PARSERR_CLIENT_CLEANUP"
+	// EnvClientImportedCategory Instead of removing torrents move them to
+	// this category
+	EnvClientImportedCategory = "PARSERR_CLIENT_IMPORTED_CATEGORY"
+	// TypeQBittorrent ...
+	TypeQBittorrent = "qbittorrent"
+	// TypeSabnzbd ...
+	TypeSabnzbd = "sabnzbd"
+)
+
+// Client Download client used by the *arr
+type Client interface {
+	GetType() string
+	// RemoveFromHistory Forget about a download without deleting its files
+	// so the *arr doesn't detect it again
+	RemoveFromHistory(downloadID string) error
+}
+
+// Config ...
+type Config struct {
+	Type     string
+	URL      string
+	Username string
+	Password string
+	APIKey   string
+	// ImportedCategory qBittorrent category for imported torrents, if set
+	// torrents are moved to it instead of being removed
+	ImportedCategory string
+	// ReadOnly Nothing is modified, actions are just logged
+	ReadOnly bool
+	// PathMap Translates the paths reported by the client to local ones
+	PathMap PathMap
+}
+
+// New Return the client of the configured type
+func New(c Config) (Client, error) {
+	switch c.Type {
+	case TypeQBittorrent:
+		return NewQBittorrent(c), nil
+	case TypeSabnzbd:
+		return Sabnzbd{Config: c}, nil
+	}
+	return nil, fmt.Errorf("unknown download client %q, use %q or %q", c.Type, TypeQBittorrent, TypeSabnzbd)
+}
+
+// GetType ...
+func (c Config) GetType() string {
+	return c.Type
+}
+
+// wouldDo Return true and log the action if the client is read-only, in
+// which case the action must not be executed
+func (c Config) wouldDo(kind, format string, args ...interface{}) bool {
+	if !c.ReadOnly {
+		return false
+	}
+	log.Printf("read-only, would "+format, args...)
+	helpers.Planned(kind, format, args...)
+	return true
+}
+
+// Seeder Client able to tell when a download doesn't need its files anymore
+type Seeder interface {
+	// SeedingDone Return true once the client has finished seeding the download
+	SeedingDone(downloadID string) (bool, error)
+}
+
+// File File of a download as reported by the client
+type File struct {
+	Path string
+	Size int64
+}
+
+// FileLister Client able to tell the files of a download
+type FileLister interface {
+	// Files Return the files of the download with their absolute paths
+	Files(downloadID string) ([]File, error)
+}
+
+// ActivityChecker Client able to tell what it's doing with a download
+type ActivityChecker interface {
+	// Activity Return what the client is doing with the files of the
+	// download, like downloading or rechecking, empty if nothing
+	Activity(downloadID string) (string, error)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvClientPathMap Comma separated list of remote=local paths translating
+// the paths reported by the download client to the ones seen by parserr
+const EnvClientPathMap = "PARSERR_CLIENT_PATH_MAP"
+
+// PathMapping Folder as reported by the download client and as seen locally
+type PathMapping struct {
+	Remote string
+	Local  string
+}
+
+// PathMap Translations between the paths of the download client host and
+// the local ones, like D:\Downloads=/mnt/downloads or
+// \\server\share=/mnt/share
+type PathMap []PathMapping
+
+// ParsePathMap Parse a comma separated list of remote=local paths
+func ParsePathMap(value string) (m PathMap, err error) {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := localIndex(entry)
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid path mapping %q, use remote=local", entry)
+		}
+		m = append(m, PathMapping{Remote: entry[:i], Local: entry[i+1:]})
+	}
+	return
+}
+
+// localIndex Return the index of the "=" starting the local path of the
+// entry, the first one followed by an absolute path, as remote paths can
+// contain "=" too. Return -1 if there is none.
+func localIndex(entry string) int {
+	for i := 0; i < len(entry); i++ {
+		if entry[i] == '=' && isAbsolute(entry[i+1:]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isAbsolute Tell if the path is absolute on any host, like /mnt, D:\ or
+// \\server\share
+func isAbsolute(p string) bool {
+	switch {
+	case strings.HasPrefix(p, "/"), strings.HasPrefix(p, `\\`):
+		return true
+	case len(p) >= 2 && p[1] == ':':
+		c := p[0] | 0x20
+		return c >= 'a' && c <= 'z'
+	}
+	return false
+}
+
+// Translate Return the local path of a path reported by the download client.
+// Windows paths are matched ignoring case and their separators are converted,
+// paths not matching any mapping are returned untouched.
+func (m PathMap) Translate(p string) string {
+	normalized := strings.Replace(p, `\`, "/", -1)
+	for _, mapping := range m {
+		remote := strings.TrimSuffix(strings.Replace(mapping.Remote, `\`, "/", -1), "/")
+		if len(normalized) < len(remote) || !strings.EqualFold(normalized[:len(remote)], remote) {
+			continue
+		}
+		rest := normalized[len(remote):]
+		if rest != "" && rest[0] != '/' {
+			continue
+		}
+		return strings.TrimSuffix(mapping.Local, "/") + rest
+	}
+	return p
+}
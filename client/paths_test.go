@@ -0,0 +1,28 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePathMap(t *testing.T) {
+	tests := []struct {
+		value string
+		want  PathMap
+		err   bool
+	}{
+		{`D:\Downloads=/mnt/downloads`, PathMap{{`D:\Downloads`, "/mnt/downloads"}}, false},
+		{`\\server\share=/mnt/share, /data=/mnt/data`, PathMap{{`\\server\share`, "/mnt/share"}, {"/data", "/mnt/data"}}, false},
+		{"/downloads/a=b=/mnt/a=b", PathMap{{"/downloads/a=b", "/mnt/a=b"}}, false},
+		{`/remote=D:\Downloads`, PathMap{{"/remote", `D:\Downloads`}}, false},
+		{"/downloads=", nil, true},
+		{"=/mnt/downloads", nil, true},
+		{"/downloads=mnt", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePathMap(tt.value)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePathMap(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
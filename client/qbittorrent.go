@@ -0,0 +1,180 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"parserr/helpers"
+	"path"
+	"strings"
+)
+
+// QBittorrent Client for the qBittorrent web API
+type QBittorrent struct {
+	Config
+	http *http.Client
+}
+
+// NewQBittorrent ...
+func NewQBittorrent(c Config) *QBittorrent {
+	jar, _ := cookiejar.New(nil)
+	return &QBittorrent{Config: c, http: &http.Client{Jar: jar}}
+}
+
+// RemoveFromHistory Remove the torrent keeping its files, or move it to the
+// imported category if there is one
+func (q *QBittorrent) RemoveFromHistory(downloadID string) error {
+	hash := strings.ToLower(downloadID)
+	if q.ImportedCategory != "" {
+		if q.wouldDo(helpers.ActionChange, "move torrent %s to category %s", hash, q.ImportedCategory) {
+			return nil
+		}
+		log.Printf("moving torrent %s to category %s", hash, q.ImportedCategory)
+		return q.post("/api/v2/torrents/setCategory", url.Values{"hashes": {hash}, "category": {q.ImportedCategory}})
+	}
+	if q.wouldDo(helpers.ActionDelete, "remove torrent %s", hash) {
+		return nil
+	}
+	log.Printf("removing torrent %s keeping its files", hash)
+	return q.post("/api/v2/torrents/delete", url.Values{"hashes": {hash}, "deleteFiles": {"false"}})
+}
+
+// Files Return the files of the torrent with the given hash
+func (q *QBittorrent) Files(downloadID string) (files []File, err error) {
+	hash := strings.ToLower(downloadID)
+	var properties struct {
+		SavePath string `json:"save_path"`
+	}
+	err = q.getJSON("/api/v2/torrents/properties", url.Values{"hash": {hash}}, &properties)
+	if err != nil {
+		return
+	}
+	var contents []struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}
+	err = q.getJSON("/api/v2/torrents/files", url.Values{"hash": {hash}}, &contents)
+	if err != nil {
+		return
+	}
+	for _, c := range contents {
+		local := q.PathMap.Translate(path.Join(properties.SavePath, c.Name))
+		files = append(files, File{Path: local, Size: c.Size})
+	}
+	return files, nil
+}
+
+// SeedingDone Return true if the torrent has reached its seeding limits,
+// which pauses it, or it's not in qBittorrent anymore
+func (q *QBittorrent) SeedingDone(downloadID string) (bool, error) {
+	var torrents []struct {
+		State string `json:"state"`
+	}
+	err := q.getJSON("/api/v2/torrents/info", url.Values{"hashes": {strings.ToLower(downloadID)}}, &torrents)
+	if err != nil {
+		return false, err
+	}
+	if len(torrents) == 0 {
+		return true, nil
+	}
+	switch torrents[0].State {
+	case "pausedUP", "stoppedUP":
+		return true, nil
+	}
+	return false, nil
+}
+
+// qbittorrentActivities Activity of the torrent states in which its files
+// are still being written
+var qbittorrentActivities = map[string]string{
+	"downloading":        "downloading",
+	"forcedDL":           "downloading",
+	"metaDL":             "downloading",
+	"forcedMetaDL":       "downloading",
+	"stalledDL":          "downloading",
+	"queuedDL":           "downloading",
+	"allocating":         "downloading",
+	"checkingDL":         "rechecking",
+	"checkingUP":         "rechecking",
+	"checkingResumeData": "rechecking",
+	"moving":             "moving",
+}
+
+// Activity Return what qBittorrent is doing with the files of the torrent,
+// seeding doesn't count as it doesn't change them
+func (q *QBittorrent) Activity(downloadID string) (string, error) {
+	var torrents []struct {
+		State string `json:"state"`
+	}
+	err := q.getJSON("/api/v2/torrents/info", url.Values{"hashes": {strings.ToLower(downloadID)}}, &torrents)
+	if err != nil || len(torrents) == 0 {
+		return "", err
+	}
+	return qbittorrentActivities[torrents[0].State], nil
+}
+
+func (q *QBittorrent) login() error {
+	res, err := q.http.PostForm(q.URL+"/api/v2/auth/login", url.Values{
+		"username": {q.Username},
+		"password": {q.Password},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return fmt.Errorf("qbittorrent login failed, status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+// getJSON Decode the response of a GET request logging in again if the
+// session expired
+func (q *QBittorrent) getJSON(path string, query url.Values, v interface{}) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err := q.http.Get(q.URL + path + "?" + query.Encode())
+		if err != nil {
+			return err
+		}
+		if res.StatusCode == 403 && attempt == 0 {
+			res.Body.Close()
+			err = q.login()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		defer res.Body.Close()
+		if res.StatusCode != 200 {
+			return fmt.Errorf("qbittorrent %s failed, status code %d", path, res.StatusCode)
+		}
+		return json.NewDecoder(res.Body).Decode(v)
+	}
+	return fmt.Errorf("qbittorrent %s failed, authorization invalid", path)
+}
+
+// post Send a form logging in again if the session expired
+func (q *QBittorrent) post(path string, form url.Values) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err := q.http.PostForm(q.URL+path, form)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode == 403 && attempt == 0 {
+			err = q.login()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if res.StatusCode != 200 {
+			return fmt.Errorf("qbittorrent %s failed, status code %d", path, res.StatusCode)
+		}
+		return nil
+	}
+	return fmt.Errorf("qbittorrent %s failed, authorization invalid", path)
+}
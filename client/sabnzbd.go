@@ -0,0 +1,144 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"parserr/helpers"
+	"path/filepath"
+	"strings"
+)
+
+// Sabnzbd Client for the SABnzbd API
+type Sabnzbd struct {
+	Config
+}
+
+// RemoveFromHistory Delete the job from the history keeping its files
+func (s Sabnzbd) RemoveFromHistory(downloadID string) error {
+	if s.wouldDo(helpers.ActionDelete, "delete %s from sabnzbd history", downloadID) {
+		return nil
+	}
+	log.Printf("deleting %s from sabnzbd history", downloadID)
+	_, err := s.call(url.Values{
+		"mode":      {"history"},
+		"name":      {"delete"},
+		"value":     {downloadID},
+		"del_files": {"0"},
+	})
+	return err
+}
+
+// Files Return the files of the job, found in the folder where it was
+// stored once completed
+func (s Sabnzbd) Files(downloadID string) (files []File, err error) {
+	body, err := s.call(url.Values{"mode": {"history"}, "nzo_ids": {downloadID}})
+	if err != nil {
+		return
+	}
+	var history struct {
+		History struct {
+			Slots []struct {
+				Storage string `json:"storage"`
+			} `json:"slots"`
+		} `json:"history"`
+	}
+	err = json.Unmarshal(body, &history)
+	if err != nil {
+		return
+	}
+	if len(history.History.Slots) == 0 || history.History.Slots[0].Storage == "" {
+		return nil, fmt.Errorf("%s not found in sabnzbd history", downloadID)
+	}
+	err = filepath.Walk(s.PathMap.Translate(history.History.Slots[0].Storage), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, File{Path: path, Size: info.Size()})
+		}
+		return nil
+	})
+	return
+}
+
+// SeedingDone Usenet downloads are never seeded
+func (s Sabnzbd) SeedingDone(downloadID string) (bool, error) {
+	return true, nil
+}
+
+// Activity Return downloading while the job is in the queue and the post
+// processing step, like verifying or extracting, until it's completed
+func (s Sabnzbd) Activity(downloadID string) (string, error) {
+	body, err := s.call(url.Values{"mode": {"queue"}, "nzo_ids": {downloadID}})
+	if err != nil {
+		return "", err
+	}
+	var queue struct {
+		Queue struct {
+			Slots []struct {
+				ID string `json:"nzo_id"`
+			} `json:"slots"`
+		} `json:"queue"`
+	}
+	err = json.Unmarshal(body, &queue)
+	if err != nil {
+		return "", err
+	}
+	for _, slot := range queue.Queue.Slots {
+		if slot.ID == downloadID {
+			return "downloading", nil
+		}
+	}
+	body, err = s.call(url.Values{"mode": {"history"}, "nzo_ids": {downloadID}})
+	if err != nil {
+		return "", err
+	}
+	var history struct {
+		History struct {
+			Slots []struct {
+				Status string `json:"status"`
+			} `json:"slots"`
+		} `json:"history"`
+	}
+	err = json.Unmarshal(body, &history)
+	if err != nil || len(history.History.Slots) == 0 {
+		return "", err
+	}
+	switch status := history.History.Slots[0].Status; status {
+	case "Completed", "Failed", "":
+		return "", nil
+	default:
+		return strings.ToLower(status), nil
+	}
+}
+
+// call Execute an api mode returning the body of the response
+func (s Sabnzbd) call(query url.Values) (body []byte, err error) {
+	query.Set("apikey", s.APIKey)
+	query.Set("output", "json")
+	res, err := http.Get(s.URL + "/api?" + query.Encode())
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("sabnzbd %s failed, status code %d", query.Get("mode"), res.StatusCode)
+	}
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	var status struct {
+		Status *bool  `json:"status"`
+		Error  string `json:"error"`
+	}
+	if json.Unmarshal(body, &status) == nil && status.Status != nil && !*status.Status {
+		return nil, fmt.Errorf("sabnzbd %s failed: %s", query.Get("mode"), status.Error)
+	}
+	return body, nil
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnvTorrentFolder Folder where the download client keeps its .torrent files
+const EnvTorrentFolder = "PARSERR_TORRENT_FOLDER"
+
+// TorrentFolder Read the files of a download from the .torrent files kept
+// by the download client, for when its API isn't available. The content of
+// the torrents is searched inside the download folders.
+type TorrentFolder struct {
+	Dir             string
+	DownloadFolders []string
+}
+
+// Files Return the files of the torrent with the given info hash that exist
+// in any of the download folders
+func (t TorrentFolder) Files(downloadID string) ([]File, error) {
+	torrent, err := t.find(strings.ToLower(downloadID))
+	if err != nil {
+		return nil, err
+	}
+	for _, folder := range t.DownloadFolders {
+		var files []File
+		for _, f := range torrent.Files {
+			p := path.Join(folder, f.Path)
+			if _, err := os.Stat(p); err == nil {
+				files = append(files, File{Path: p, Size: f.Size})
+			}
+		}
+		if len(files) > 0 {
+			return files, nil
+		}
+	}
+	return nil, fmt.Errorf("content of torrent %s not found in the download folders", downloadID)
+}
+
+// find Return the torrent with the info hash, clients usually name the
+// .torrent files after it, otherwise every torrent is checked
+func (t TorrentFolder) find(hash string) (Torrent, error) {
+	named := path.Join(t.Dir, hash+".torrent")
+	if torrent, err := ReadTorrent(named); err == nil && torrent.InfoHash == hash {
+		return torrent, nil
+	}
+	paths, err := filepath.Glob(path.Join(t.Dir, "*.torrent"))
+	if err != nil {
+		return Torrent{}, err
+	}
+	for _, p := range paths {
+		torrent, err := ReadTorrent(p)
+		if err == nil && torrent.InfoHash == hash {
+			return torrent, nil
+		}
+	}
+	return Torrent{}, fmt.Errorf("torrent %s not found in %s", hash, t.Dir)
+}
+
+// Torrent Metadata of a .torrent file
+type Torrent struct {
+	Name     string
+	InfoHash string
+	// Files Paths relative to the folder the torrent is saved in
+	Files []File
+}
+
+// ReadTorrent Parse a .torrent file
+func ReadTorrent(file string) (t Torrent, err error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	d := &bdecoder{data: data}
+	root, err := d.value(0)
+	if err != nil {
+		return t, fmt.Errorf("invalid torrent %s: %s", file, err)
+	}
+	meta, ok := root.(map[string]interface{})
+	if !ok || d.infoEnd == 0 {
+		return t, fmt.Errorf("invalid torrent %s: missing info", file)
+	}
+	hash := sha1.Sum(data[d.infoStart:d.infoEnd])
+	t.InfoHash = hex.EncodeToString(hash[:])
+	info, _ := meta["info"].(map[string]interface{})
+	t.Name, _ = info["name"].(string)
+	length, single := info["length"].(int64)
+	if single {
+		t.Files = []File{{Path: t.Name, Size: length}}
+		return t, nil
+	}
+	files, _ := info["files"].([]interface{})
+	for _, f := range files {
+		f, _ := f.(map[string]interface{})
+		size, _ := f["length"].(int64)
+		parts := []string{t.Name}
+		pieces, _ := f["path"].([]interface{})
+		for _, p := range pieces {
+			p, _ := p.(string)
+			parts = append(parts, p)
+		}
+		t.Files = append(t.Files, File{Path: path.Join(parts...), Size: size})
+	}
+	return t, nil
+}
+
+// bdecoder Decoder of bencoded data, it keeps the position of the info
+// dictionary of the root so its hash can be calculated
+type bdecoder struct {
+	data      []byte
+	pos       int
+	infoStart int
+	infoEnd   int
+}
+
+func (d *bdecoder) value(depth int) (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := d.index('e')
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated integer at %d", d.pos)
+		}
+		i, err := strconv.ParseInt(string(d.data[d.pos+1:end]), 10, 64)
+		d.pos = end + 1
+		return i, err
+	case c == 'l':
+		d.pos++
+		var list []interface{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := make(map[string]interface{})
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			key, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			start := d.pos
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.infoStart, d.infoEnd = start, d.pos
+			}
+			dict[key] = v
+		}
+		d.pos++
+		return dict, nil
+	case c >= '0' && c <= '9':
+		return d.str()
+	}
+	return nil, fmt.Errorf("invalid character %q at %d", d.data[d.pos], d.pos)
+}
+
+func (d *bdecoder) str() (string, error) {
+	colon := d.index(':')
+	if colon == -1 {
+		return "", fmt.Errorf("invalid string at %d", d.pos)
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || n < 0 || colon+1+n > len(d.data) {
+		return "", fmt.Errorf("invalid string length at %d", d.pos)
+	}
+	s := string(d.data[colon+1 : colon+1+n])
+	d.pos = colon + 1 + n
+	return s, nil
+}
+
+func (d *bdecoder) index(b byte) int {
+	for i := d.pos; i < len(d.data); i++ {
+		if d.data[i] == b {
+			return i
+		}
+	}
+	return -1
+}
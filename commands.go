@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/client"
+	"parserr/mediaserver"
+	"parserr/parser"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runCommand(name string, args []string, safety parser.Safety) {
+	switch name {
+	case "quarantine":
+		quarantineCommand(args, safety)
+	case "doctor":
+		doctorCommand(safety)
+	case "watch":
+		watchCommand(safety)
+	case "import":
+		importCommand(args, safety)
+	case "rename-library":
+		renameLibraryCommand(args, safety)
+	case "blackhole":
+		blackholeCommand(safety)
+	case "explain":
+		explainCommand(args, safety)
+	case "fix":
+		filter, err := parseFilter(args)
+		if err != nil {
+			log.Fatalf("%s, usage: parserr fix [--series <title>] [--movie <title>] [--artist <name>] [--queue-id <id>] [--download-id <id>] [--since <duration>]", err)
+		}
+		phaseCommand(safety, runPhases{fix: true, filter: filter})
+	case "clean":
+		phaseCommand(safety, runPhases{clean: true})
+	case "self-update":
+		selfUpdateCommand()
+	default:
+		log.Fatalf("unknown command %q", name)
+	}
+}
+
+// phaseCommand parserr fix | clean, run only one phase of a run so fixing
+// and cleaning can be scheduled separately
+func phaseCommand(safety parser.Safety, phases runPhases) {
+	log.Printf("running in %s mode", safety.Level)
+	state := loadState()
+	if phases.clean && state == nil {
+		log.Fatalf("clean requires %s", parser.EnvStateFile)
+	}
+	checkCopyMode(state)
+	apis := getAPIs()
+	logBanner(safety, apis)
+	checkInstances(context.Background(), apis)
+	if confirmPlan(context.Background(), safety, state, phases) {
+		runAll(context.Background(), apis, safety, state, nil, phases)
+	}
+}
+
+// parseFilter Return the filter of the media of a targeted fix, ids can be
+// given several times
+func parseFilter(args []string) (f parser.MediaFilter, err error) {
+	for i := 0; i < len(args); i++ {
+		name, value := args[i], ""
+		if n := strings.Index(name, "="); n >= 0 {
+			name, value = name[:n], name[n+1:]
+		} else if i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if value == "" {
+			return f, fmt.Errorf("missing value of %s", name)
+		}
+		switch name {
+		case "--series":
+			f.Series = value
+		case "--movie":
+			f.Movie = value
+		case "--artist":
+			f.Artist = value
+		case "--queue-id":
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return f, fmt.Errorf("invalid queue id %q", value)
+			}
+			f.QueueIDs = append(f.QueueIDs, id)
+		case "--download-id":
+			f.DownloadIDs = append(f.DownloadIDs, value)
+		case "--since":
+			f.Since, err = time.ParseDuration(value)
+			if err != nil || f.Since <= 0 {
+				return f, fmt.Errorf("invalid duration %q", value)
+			}
+		default:
+			return f, fmt.Errorf("unknown flag %s", name)
+		}
+	}
+	return
+}
+
+// optionalStrategies Strategies of the chain of newStrategy enabled by a
+// variable
+var optionalStrategies = []struct {
+	Name string
+	Env  string
+}{
+	{"TimeoutStrategy", parser.EnvItemTimeout},
+	{"TransliterateStrategy", parser.EnvTransliterate},
+	{"QualityStrategy", parser.EnvAnnotateQuality},
+	{"ProbeStrategy", parser.EnvProbe},
+	{"NFOStrategy", parser.EnvNFO},
+	{"ExtrasStrategy", parser.EnvExtras},
+	{"TagStrategy", parser.EnvTag},
+	{"VerifyStrategy", mediaserver.EnvMediaServerType},
+	{"QuarantineStrategy", parser.EnvQuarantineFolder},
+	{"ValidateStrategy", parser.EnvSizeLimits},
+	{"BlacklistStrategy", parser.EnvBlacklistFailed},
+	{"SeedStrategy", parser.EnvCopyUntilSeeded},
+	{"DeferredCleanStrategy", parser.EnvDeferredClean},
+	{"ClientCleanupStrategy", client.EnvClientCleanup},
+	{"StatefulStrategy", parser.EnvStateFile},
+	{"LockStrategy", parser.EnvStateFile},
+}
+
+// explainCommand parserr explain <queue-id>, walk through every decision a
+// run takes about the queue item, in read-only mode so nothing is changed
+func explainCommand(args []string, safety parser.Safety) {
+	if len(args) != 1 {
+		log.Fatal("usage: parserr explain <queue-id>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("invalid queue id %q", args[0])
+	}
+	os.Setenv(api.EnvReadOnly, "true")
+	ctx := context.Background()
+	state := loadState()
+	found := false
+	for _, a := range getAPIs() {
+		e, ok, err := parser.Explain(ctx, a, id, fileLister(a), historyIndex(a, state))
+		if err != nil {
+			log.Printf("cannot get the queue of %s: %s", a.GetName(), err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		fmt.Println(e)
+		for _, m := range e.Media {
+			explainStrategy(ctx, a, safety, state, m)
+		}
+		if len(e.Media) == 0 {
+			continue
+		}
+		fmt.Println("running the item in read-only mode:")
+		execute(ctx, a, safety, state, nil, runPhases{fix: true, filter: parser.MediaFilter{QueueIDs: []int{id}}})
+	}
+	if !found {
+		log.Fatalf("no instance has queue item %d", id)
+	}
+}
+
+// explainStrategy Print the strategy the media would be fixed with, where it
+// would end up and the optional strategies that aren't used
+func explainStrategy(ctx context.Context, a api.RRAPI, safety parser.Safety, state *parser.State, m *api.Media) {
+	name := m.DownloadFolder.Strategy
+	if name == "" {
+		name = parser.DefaultStrategy(a)
+	}
+	fmt.Printf("fixed with %s\n", name)
+	base, err := parser.NewStrategy(name, a, parser.FakeMover{}, safety)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if destined, ok := base.(parser.DestinationStrategy); ok {
+		dest, err := destined.Destination(ctx, m)
+		if err != nil {
+			fmt.Printf("cannot find its destination: %s\n", err)
+		} else {
+			fmt.Printf("moved to %s\n", dest)
+		}
+	}
+	chain := parser.StrategyChain(newStrategy(ctx, a, safety, state), m)
+	fmt.Printf("strategies, from the outermost: %s\n", strings.Join(chain, ", "))
+	used := make(map[string]bool)
+	for _, s := range chain {
+		used[s] = true
+	}
+	for _, o := range optionalStrategies {
+		if used[o.Name] {
+			continue
+		}
+		if os.Getenv(o.Env) == "" {
+			fmt.Printf("%s disabled, set %s\n", o.Name, o.Env)
+			continue
+		}
+		fmt.Printf("%s not used in read-only mode or with the rest of the settings\n", o.Name)
+	}
+}
+
+// quarantineCommand parserr quarantine [list | promote <name> | delete <name>]
+func quarantineCommand(args []string, safety parser.Safety) {
+	q, ok := quarantine()
+	if !ok {
+		log.Fatalf("quarantine folder not configured, set %s", parser.EnvQuarantineFolder)
+	}
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action == "list" {
+		entries, err := q.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, e := range entries {
+			fmt.Printf("%s\t%s\t%s\n", e.Name, e.Date.Format("2006-01-02 15:04"), e.Reason)
+		}
+		return
+	}
+	if len(args) != 2 {
+		log.Fatalf("usage: parserr quarantine [list | promote <name> | delete <name>]")
+	}
+	name := args[1]
+	switch action {
+	case "promote":
+		entry, err := q.Get(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		a, err := apiOf(entry.Instance, entry.APIType)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = q.Promote(context.Background(), name, parser.StrategyFactory(a, parser.BasicMover{}, safety))
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "delete":
+		err := q.Delete(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown quarantine action %q", action)
+	}
+}
+
+// watchCommand parserr watch, run every time the download folders change
+func watchCommand(safety parser.Safety) {
+	log.Printf("watching in %s mode", safety.Level)
+	state := loadState()
+	checkProfile(state)
+	checkCopyMode(state)
+	apis := getAPIs()
+	logBanner(safety, apis)
+	checkInstances(context.Background(), apis)
+	checkUpdate()
+	var dirs []string
+	for _, a := range apis {
+		for _, folder := range a.GetDownloadFolders() {
+			dirs = append(dirs, folder.Path)
+		}
+	}
+	watcher := parser.NewPollingWatcher(dirs, envDurationValue(parser.EnvWatchInterval))
+	if watcher.Interval <= 0 {
+		watcher.Interval = parser.DefaultWatchInterval
+	}
+	trackers := make(map[string]*parser.QueueTracker)
+	for _, a := range apis {
+		trackers[a.GetName()] = parser.NewQueueTracker()
+	}
+	wake := make(chan struct{}, 1)
+	notifySignals(wake, func() { logStatus(state) })
+	for {
+		runAll(context.Background(), apis, safety, state, trackers, defaultPhases)
+		watcher.Reset()
+		if watcher.Wait(wake) {
+			log.Printf("run requested")
+		} else {
+			log.Printf("changes detected in the download folders")
+		}
+	}
+}
+
+// importCommand parserr import <path>, move a file downloaded manually into
+// the library of the series, movie or album its name matches and rescan it
+func importCommand(args []string, safety parser.Safety) {
+	if len(args) != 1 {
+		log.Fatalf("usage: parserr import <path>")
+	}
+	file, err := filepath.Abs(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if info.IsDir() {
+		log.Fatalf("%s is a folder, import its files one by one", file)
+	}
+	ctx := context.Background()
+	title := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for _, a := range getAPIs() {
+		p, err := a.Parse(ctx, title)
+		if err != nil {
+			log.Printf("cannot match %s with %s: %s", title, a.GetName(), err)
+			continue
+		}
+		if !p.Matched() {
+			continue
+		}
+		m, err := api.NewManualMedia(a, p, file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var s parser.FixStrategy = parser.LibraryStrategy{API: a, Mover: safety.Mover(newMover(ctx, a)), Safety: safety}
+		if envBoolValue(parser.EnvTransliterate) {
+			s = parser.TransliterateStrategy{Strategy: s}
+		}
+		if a.GetType() == api.TypeMusic {
+			s = parser.TrackRenameStrategy{Strategy: s, API: a}
+		}
+		if envBoolValue(parser.EnvNFO) && !readOnly() {
+			s = parser.NFOStrategy{Strategy: s, API: a}
+		}
+		err = s.Fix(ctx, &m)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("imported %s into %s of %s", m.FilenameOri, m.FileLocFinal, a.GetName())
+		return
+	}
+	log.Fatalf("%s does not match any series, movie or album", title)
+}
+
+// renameLibraryCommand parserr rename-library <folder> [--apply], print where
+// every media file of the folder belongs according to the instances and move
+// them there if --apply is given
+func renameLibraryCommand(args []string, safety parser.Safety) {
+	if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "--apply") {
+		log.Fatalf("usage: parserr rename-library <folder> [--apply]")
+	}
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	apply := len(args) == 2
+	ctx := context.Background()
+	plan, err := parser.PlanLibraryRename(ctx, getAPIs(), dir, envBoolValue(parser.EnvTransliterate))
+	if err != nil {
+		log.Fatal(err)
+	}
+	moves := 0
+	for _, step := range plan {
+		if step.Err != nil {
+			fmt.Printf("%s\t%s\n", step.Media.FileLocOri, step.Err)
+			continue
+		}
+		fmt.Printf("%s\t%s\n", step.Media.FileLocOri, step.Dest)
+		moves++
+	}
+	if !apply {
+		log.Printf("%d files to move, run with --apply to move them", moves)
+		return
+	}
+	movers := make(map[string]parser.Mover)
+	moved, failed := parser.ApplyRename(ctx, plan, func(a api.RRAPI) parser.FixStrategy {
+		return libraryStrategy(ctx, a, safety, movers)
+	})
+	log.Printf("moved %d files", moved)
+	if failed > 0 {
+		closeStore()
+		os.Exit(1)
+	}
+}
+
+// blackholeCommand parserr blackhole, import every completed media file
+// dropped in the blackhole folder, for downloads the instances don't know about
+func blackholeCommand(safety parser.Safety) {
+	dir := os.Getenv(parser.EnvBlackholeFolder)
+	if dir == "" {
+		log.Fatalf("blackhole folder not configured, set %s", parser.EnvBlackholeFolder)
+	}
+	log.Printf("watching blackhole %s in %s mode", dir, safety.Level)
+	apis := getAPIs()
+	interval := envDurationValue(parser.EnvWatchInterval)
+	if interval <= 0 {
+		interval = parser.DefaultWatchInterval
+	}
+	transliterate := envBoolValue(parser.EnvTransliterate)
+	movers := make(map[string]parser.Mover)
+	blackhole := parser.NewBlackhole(dir)
+	ctx := context.Background()
+	for {
+		files, err := blackhole.Ready()
+		if err != nil {
+			log.Printf("cannot read blackhole %s: %s", dir, err)
+		}
+		for _, file := range files {
+			// Files that can't be imported are retried only if they change
+			blackhole.Handled(file)
+			step := parser.PlanRename(ctx, apis, file, transliterate)
+			if step.Err != nil {
+				log.Printf("cannot import %s: %s", file, step.Err)
+				continue
+			}
+			err = libraryStrategy(ctx, step.API, safety, movers).Fix(ctx, &step.Media)
+			if err != nil {
+				log.Printf("cannot import %s: %s", file, err)
+				continue
+			}
+			log.Printf("imported %s into %s of %s", file, step.Media.FileLocFinal, step.API.GetName())
+		}
+		time.Sleep(interval)
+	}
+}
+
+// libraryStrategy Return the strategy moving files into the library of the
+// api, the mover of each api is created once and kept in movers
+func libraryStrategy(ctx context.Context, a api.RRAPI, safety parser.Safety, movers map[string]parser.Mover) parser.FixStrategy {
+	move, ok := movers[a.GetName()]
+	if !ok {
+		move = safety.Mover(newMover(ctx, a))
+		movers[a.GetName()] = move
+	}
+	var s parser.FixStrategy = parser.LibraryStrategy{API: a, Mover: move, Safety: safety}
+	if envBoolValue(parser.EnvNFO) && !readOnly() {
+		s = parser.NFOStrategy{Strategy: s, API: a}
+	}
+	return s
+}
+
+// logStatus Log the state of the items, for debugging a running daemon
+func logStatus(state *parser.State) {
+	if state == nil {
+		log.Printf("no state, set %s to keep track of the items", parser.EnvStateFile)
+		return
+	}
+	log.Print(state.Summary())
+}
+
+// apiOf Return the configured api with the given name, or the first one
+// handling the media type if there is no api with that name
+func apiOf(name, t string) (api.RRAPI, error) {
+	apis := getAPIs()
+	for _, a := range apis {
+		if a.GetName() == name {
+			return a, nil
+		}
+	}
+	for _, a := range apis {
+		if a.GetType() == t {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("no api configured for %s media", t)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"parserr/parser"
+	"path"
+	"path/filepath"
+)
+
+// check Single item of the doctor checklist
+type check struct {
+	Name string
+	Err  error
+}
+
+func (c check) String() string {
+	if c.Err != nil {
+		return fmt.Sprintf("[FAIL] %s: %s", c.Name, c.Err)
+	}
+	return fmt.Sprintf("[PASS] %s", c.Name)
+}
+
+// doctorCommand parserr doctor, check the environment and run the pipeline
+// against a temporary library, exiting with 1 if any check fails
+func doctorCommand(safety parser.Safety) {
+	checks := []check{{"fix a fake download in a sandbox", sandboxCheck(safety)}}
+	for _, a := range getAPIs() {
+		_, err := a.GetQueue(context.Background())
+		checks = append(checks, check{fmt.Sprintf("connect to %s", a.GetName()), err})
+		if status, err := a.GetSystemStatus(context.Background()); err == nil {
+			checks = append(checks, check{fmt.Sprintf("%s version is supported", a.GetName()), instanceError(a, status)})
+		}
+		checks = append(checks, check{fmt.Sprintf("%s is healthy", a.GetName()), parser.CheckHealth(context.Background(), a, nil)})
+		for _, folder := range a.GetDownloadFolders() {
+			checks = append(checks, check{fmt.Sprintf("write on %s", folder.Path), parser.CheckWritable(folder.Path)})
+		}
+	}
+	if dir := os.Getenv(parser.EnvQuarantineFolder); dir != "" {
+		checks = append(checks, check{fmt.Sprintf("write on %s", dir), parser.CheckWritable(dir)})
+	}
+	if file := os.Getenv(parser.EnvStateFile); file != "" {
+		dir := filepath.Dir(file)
+		checks = append(checks, check{fmt.Sprintf("write on %s", dir), parser.CheckWritable(dir)})
+	}
+	failed := false
+	for _, c := range checks {
+		fmt.Println(c)
+		failed = failed || c.Err != nil
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// sandboxCheck Create a fake broken download in a temporary folder, find it
+// and fix it like a real run, then move it to a temporary library
+func sandboxCheck(safety parser.Safety) error {
+	root, err := ioutil.TempDir("", "parserr-doctor")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	downloads, library := path.Join(root, "downloads"), path.Join(root, "library")
+	for _, dir := range []string{downloads, library} {
+		if err = os.Mkdir(dir, 0775); err != nil {
+			return err
+		}
+	}
+	download := path.Join(downloads, "Show.S01E02.mkv")
+	if err = ioutil.WriteFile(download, []byte("parserr"), 0664); err != nil {
+		return err
+	}
+	a := api.NewSonarr("", "", downloads)
+	hr := api.HistoryRec{SourceTitle: "Show.S01E02.720p.HDTV.x264-GROUP"}
+	qe := api.QueueElem{
+		Title:          "Show.S01E02.mkv",
+		Episode:        api.Episode{SeasonNumber: 1, EpisodeNumber: 2},
+		StatusMessages: []api.StatusMessage{{Title: "Show.S01E02.mkv"}},
+	}
+	m, err := api.NewMediaFromFiles(a, hr, qe, []string{download})
+	if err != nil {
+		return fmt.Errorf("cannot match the download: %s", err)
+	}
+	s := parser.MaintainPathStrategy{API: a, Mover: safety.Mover(parser.BasicMover{}), Safety: safety}
+	if err = s.Fix(context.Background(), &m); err != nil {
+		return fmt.Errorf("cannot fix the download: %s", err)
+	}
+	dest := path.Join(library, m.FilenameFinal)
+	if err = s.Mover.Move(m.FileLocFinal, dest); err != nil {
+		return fmt.Errorf("cannot move to the library: %s", err)
+	}
+	_, err = os.Stat(dest)
+	return err
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"parserr/api"
+	"parserr/client"
+	"parserr/mediaserver"
+	"parserr/parser"
+	"parserr/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envString   = "string"
+	envInt      = "int"
+	envBool     = "bool"
+	envList     = "list"
+	envDuration = "duration"
+)
+
+// envVar Environment variable recognized by parserr
+type envVar struct {
+	Name        string
+	Type        string
+	Secret      bool
+	Description string
+}
+
+// envVars Every recognized environment variable, new variables must be added
+// here or they will be rejected as unknown
+var envVars = []envVar{
+	{api.EnvSonarrName, envString, false, "name of the sonarr instance"},
+	{api.EnvSonarrURL, envString, false, "address of sonarr"},
+	{api.EnvSonarrURLBase, envString, false, "url base of sonarr behind a reverse proxy"},
+	{api.EnvSonarrAPIVersion, envString, false, "api version of sonarr, v2, v3 or auto"},
+	{api.EnvSonarrSocket, envString, false, "unix socket to connect to sonarr"},
+	{api.EnvSonarrAPIKey, envString, true, "api key of sonarr"},
+	{api.EnvSonarrDownloadFolder, envList, false, "download folders of sonarr, path[=strategy]"},
+	{api.EnvRadarrName, envString, false, "name of the radarr instance"},
+	{api.EnvRadarrURL, envString, false, "address of radarr"},
+	{api.EnvRadarrURLBase, envString, false, "url base of radarr behind a reverse proxy"},
+	{api.EnvRadarrAPIVersion, envString, false, "api version of radarr, v2, v3 or auto"},
+	{api.EnvRadarrSocket, envString, false, "unix socket to connect to radarr"},
+	{api.EnvRadarrAPIKey, envString, true, "api key of radarr"},
+	{api.EnvRadarrDownloadFolder, envList, false, "download folders of radarr, path[=strategy]"},
+	{api.EnvLidarrName, envString, false, "name of the lidarr instance"},
+	{api.EnvLidarrURL, envString, false, "address of lidarr"},
+	{api.EnvLidarrURLBase, envString, false, "url base of lidarr behind a reverse proxy"},
+	{api.EnvLidarrSocket, envString, false, "unix socket to connect to lidarr"},
+	{api.EnvLidarrAPIKey, envString, true, "api key of lidarr"},
+	{api.EnvLidarrDownloadFolder, envList, false, "download folders of lidarr, path[=strategy]"},
+	{api.EnvGenericName, envString, false, "name of a generic *arr, like whisparr"},
+	{api.EnvGenericURL, envString, false, "address of the generic *arr"},
+	{api.EnvGenericURLBase, envString, false, "url base of the generic *arr behind a reverse proxy"},
+	{api.EnvGenericSocket, envString, false, "unix socket to connect to the generic *arr"},
+	{api.EnvGenericAPIKey, envString, true, "api key of the generic *arr"},
+	{api.EnvGenericDownloadFolder, envList, false, "download folders of the generic *arr, path[=strategy]"},
+	{api.EnvGenericType, envString, false, "media of the generic *arr, show, movie or music"},
+	{api.EnvGenericAPIPath, envString, false, "path of the api of the generic *arr, /api/v3 by default"},
+	{api.EnvGenericEndpoints, envList, false, "endpoints renamed by the generic *arr, endpoint=name"},
+	{api.EnvGenericScanCommand, envString, false, "command rescanning the library of the generic *arr"},
+	{api.EnvGenericRenameCommand, envString, false, "command renaming the files of the generic *arr"},
+	{api.EnvGenericDownloadScanCommand, envString, false, "command importing a folder in the generic *arr"},
+	{api.EnvReadOnly, envBool, false, "only log what would be modified"},
+	{api.EnvHistoryPageSize, envInt, false, "records fetched per history request"},
+	{api.EnvTimeout, envDuration, false, "timeout of the requests to the instances"},
+	{api.EnvTimeouts, envList, false, "timeouts by endpoint, endpoint=duration"},
+	{api.EnvAPIKeyInQuery, envBool, false, "send the api key as a query parameter, for old versions"},
+	{api.EnvCacheTTL, envDuration, false, "time api responses are reused without asking again"},
+	{api.EnvRetryAttempts, envInt, false, "max times a request failing with a transient error is sent"},
+	{api.EnvRetryBackoff, envDuration, false, "wait before the first retry, doubled after every one"},
+	{api.EnvRetryJitter, envInt, false, "percentage of every retry wait that is randomized"},
+	{api.EnvCommandTimeout, envDuration, false, "max time to wait for a command to complete"},
+	{api.EnvCommandTimeouts, envList, false, "timeouts by command, command=duration"},
+	{api.EnvCommandInterval, envDuration, false, "time between checks of the status of a command"},
+	{api.EnvCommandMaxInterval, envDuration, false, "longest time between checks of a command, doubled after every one until reaching it"},
+	{api.EnvRateLimit, envInt, false, "max requests per second sent to every instance"},
+	{api.EnvRateBurst, envInt, false, "requests sent at once before being throttled"},
+	{api.EnvRecordFolder, envString, false, "save every api response as a fixture here"},
+	{api.EnvTLSCert, envString, false, "client certificate for mutual tls, enables https"},
+	{api.EnvTLSKey, envString, false, "key of the client certificate"},
+	{api.EnvTLSCA, envString, false, "certificate authority of the instances"},
+	{parser.EnvSafety, envString, false, "strict or permissive"},
+	{parser.EnvStateFile, envString, false, "file keeping the state of fixed items"},
+	{store.EnvStateBackend, envString, false, "where the state is kept, json, memory, bbolt or sqlite"},
+	{envUpdateCheck, envBool, false, "log on startup when a newer release exists"},
+	{envUpdateChannel, envString, false, "releases considered by the update check, stable or prerelease"},
+	{parser.EnvQuarantineFolder, envString, false, "folder for files failing validation"},
+	{parser.EnvCopyUntilSeeded, envBool, false, "copy files and delete the originals once seeded"},
+	{parser.EnvBlackholeFolder, envString, false, "folder where completed files are imported from in blackhole mode"},
+	{parser.EnvCreateLibraryFolders, envBool, false, "create missing series and movie folders when moving to the library"},
+	{parser.EnvDeleteSuperseded, envBool, false, "delete episode and movie files replaced by fixed ones"},
+	{parser.EnvNFO, envBool, false, "write a kodi nfo file next to every imported media"},
+	{parser.EnvTag, envString, false, "tag given to the series, movies and artists of fixed media"},
+	{parser.EnvAnnotateQuality, envBool, false, "add the quality of the release to destination names without one"},
+	{parser.EnvTransliterate, envBool, false, "use only ascii characters in destination names"},
+	{parser.EnvMediaManagement, envBool, false, "apply the permissions and recycle bin of the instances"},
+	{parser.EnvSizeLimits, envList, false, "expected file sizes by quality, quality=min-max"},
+	{parser.EnvMinFileSize, envString, false, "files smaller than this size are placeholders of failed transfers, 0 disables the check"},
+	{parser.EnvBlacklistFailed, envBool, false, "blacklist the releases that can't be fixed"},
+	{parser.EnvBlacklistKeepInClient, envBool, false, "leave the downloads of blacklisted releases in the download client"},
+	{parser.EnvUnmonitorAfter, envInt, false, "unmonitor media after this many blacklisted releases"},
+	{parser.EnvUnmonitorScope, envString, false, "episode or season"},
+	{parser.EnvAuditLog, envString, false, "file recording why releases were blacklisted"},
+	{parser.EnvReportFile, envString, false, "file every run report is appended to as a JSON line"},
+	{envParallel, envBool, false, "run the instances at the same time"},
+	{envProfile, envString, false, "profile overriding the configuration, conservative, aggressive, cleanup-only or the name of a .env.<name> file"},
+	{parser.EnvMaxRuntime, envDuration, false, "max time a run can take, the rest of the items are left for the next one"},
+	{parser.EnvProbe, envBool, false, "report the languages of the audio and subtitle tracks"},
+	{parser.EnvFFProbe, envString, false, "path of the ffprobe binary"},
+	{parser.EnvAudioLanguages, envList, false, "audio languages wanted, files with none of them are rejected when probing"},
+	{parser.EnvExtras, envString, false, "skip or library, whether featurettes and other extras are moved to the library"},
+	{parser.EnvItemTimeout, envDuration, false, "max time fixing a single item can take"},
+	{parser.EnvHealthCheck, envString, false, "skip, alert or off, what to do when an instance can't import downloads on its own"},
+	{parser.EnvWatchInterval, envDuration, false, "time between checks for changes in watch mode"},
+	{parser.EnvAiringWindow, envDuration, false, "media airing within this time are fixed first"},
+	{parser.EnvWarningGrace, envDuration, false, "media in warning for less than this are left alone"},
+	{parser.EnvEscalateAfter, envDuration, false, "media in warning for longer than this are fixed first"},
+	{client.EnvClientType, envString, false, "download client, qbittorrent or sabnzbd"},
+	{client.EnvClientURL, envString, false, "address of the download client, like http://localhost:8080"},
+	{client.EnvClientUsername, envString, false, "username of the download client"},
+	{client.EnvClientPassword, envString, true, "password of the download client"},
+	{client.EnvClientAPIKey, envString, true, "api key of the download client"},
+	{client.EnvClientCleanup, envBool, false, "remove imported downloads from the client history"},
+	{client.EnvClientPathMap, envList, false, "paths of the download client host and local ones, remote=local"},
+	{client.EnvTorrentFolder, envString, false, "folder with the .torrent files of the download client"},
+	{client.EnvClientImportedCategory, envString, false, "qbittorrent category for imported torrents instead of removing them"},
+	{mediaserver.EnvMediaServerType, envString, false, "media server fixed media is verified against, plex or jellyfin"},
+	{mediaserver.EnvMediaServerURL, envString, false, "address of the media server, like http://localhost:32400"},
+	{mediaserver.EnvMediaServerToken, envString, true, "plex token or jellyfin api key"},
+	{mediaserver.EnvMediaServerWait, envDuration, false, "max time to wait for the media server to show fixed media"},
+	{parser.EnvDeferredClean, envBool, false, "verify and clean fixed media on the next run"},
+	{parser.EnvSonarrDeferredClean, envBool, false, "deferred clean of sonarr, overrides the global one"},
+	{parser.EnvRadarrDeferredClean, envBool, false, "deferred clean of radarr, overrides the global one"},
+	{parser.EnvLidarrDeferredClean, envBool, false, "deferred clean of lidarr, overrides the global one"},
+	{parser.EnvCleanSettle, envDuration, false, "min time between fixing a media and verifying it"},
+	{parser.EnvCleanAttempts, envInt, false, "runs a fixed media is verified before fixing it again"},
+	{parser.EnvIncrementalHistory, envBool, false, "keep the grabs seen by every run in the state, later runs only fetch newer records"},
+	{parser.EnvHistoryRetention, envDuration, false, "age of the oldest history walked and kept by the incremental history"},
+}
+
+// envPrefixes Variables starting with any of these are expected to be
+// recognized, shorter prefixes catch typos like SONAR_URL
+var envPrefixes = []string{"SONAR", "RADAR", "LIDAR", "ARR_", "PARSER"}
+
+// checkEnv Return an error if there is any unknown variable that looks like
+// a parserr one or any variable has a value of the wrong type
+func checkEnv() error {
+	errors := envErrors(unknownEnv(), func(v envVar) error { return v.check() })
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, ", "))
+	}
+	return nil
+}
+
+// envErrors Return the unknown variables and the variables whose value
+// doesn't pass check
+func envErrors(unknown []string, check func(envVar) error) (errors []string) {
+	for _, name := range unknown {
+		errors = append(errors, fmt.Sprintf("unknown environment variable %s%s", name, suggestEnv(name)))
+	}
+	for _, v := range envVars {
+		if err := check(v); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return
+}
+
+// envChoices Values accepted by the variables that take one of a few
+var envChoices = map[string][]string{
+	parser.EnvHealthCheck: parser.HealthModes,
+}
+
+func (v envVar) check() error {
+	return v.checkValue(os.Getenv(v.Name))
+}
+
+// checkValue Return an error if the value isn't of the type of the variable
+func (v envVar) checkValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if choices, ok := envChoices[v.Name]; ok && !containsString(choices, value) {
+		return fmt.Errorf("%s must be %s, got %q", v.Name, strings.Join(choices, ", "), value)
+	}
+	switch v.Type {
+	case envInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be an integer, got %q", v.Name, value)
+		}
+	case envBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", v.Name, value)
+		}
+	case envDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s must be a duration like 24h, got %q", v.Name, value)
+		}
+	}
+	return nil
+}
+
+func unknownEnv() []string {
+	var names []string
+	for _, kv := range os.Environ() {
+		names = append(names, strings.SplitN(kv, "=", 2)[0])
+	}
+	return unknownNames(names)
+}
+
+// unknownNames Return the names looking like parserr variables that aren't
+// recognized
+func unknownNames(names []string) (unknown []string) {
+	known := make(map[string]bool)
+	for _, v := range envVars {
+		known[v.Name] = true
+	}
+	for _, name := range names {
+		if known[name] {
+			continue
+		}
+		for _, prefix := range envPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				unknown = append(unknown, name)
+				break
+			}
+		}
+	}
+	return
+}
+
+// containsString Return true if the value is one of the values
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestEnv Return a hint with the closest recognized variable
+func suggestEnv(name string) string {
+	best, bestDistance := "", 4
+	for _, v := range envVars {
+		d := levenshtein(name, v.Name)
+		if d < bestDistance {
+			best, bestDistance = v.Name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", best)
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// envCommand parserr env, print every recognized variable with its value
+func envCommand() {
+	for _, v := range envVars {
+		value := os.Getenv(v.Name)
+		if v.Secret && value != "" {
+			value = "********"
+		}
+		fmt.Printf("%s=%s\n\t%s, %s\n", v.Name, value, v.Type, v.Description)
+	}
+	for _, name := range unknownEnv() {
+		fmt.Printf("%s is unknown%s\n", name, suggestEnv(name))
+	}
+	for _, v := range envVars {
+		if err := v.check(); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// envIntValue Return the integer value of an environment variable or def if
+// unset, values are validated by checkEnv
+func envIntValue(name string, def int) int {
+	i, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+// envBoolValue Return the boolean value of an environment variable, false if
+// unset, values are validated by checkEnv
+func envBoolValue(name string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(name))
+	return b
+}
+
+// envDurationValue Return the duration value of an environment variable, 0 if
+// unset, values are validated by checkEnv
+func envDurationValue(name string) time.Duration {
+	d, _ := time.ParseDuration(os.Getenv(name))
+	return d
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"parserr/parser"
+	"testing"
+)
+
+func TestCheckValue(t *testing.T) {
+	tests := []struct {
+		v       envVar
+		value   string
+		wantErr bool
+	}{
+		{envVar{Name: parser.EnvHealthCheck, Type: envString}, parser.HealthAlert, false},
+		{envVar{Name: parser.EnvHealthCheck, Type: envString}, "", false},
+		{envVar{Name: parser.EnvHealthCheck, Type: envString}, "skipp", true},
+		{envVar{Name: "PARSERR_WATCH_INTERVAL", Type: envDuration}, "30s", false},
+		{envVar{Name: "PARSERR_WATCH_INTERVAL", Type: envDuration}, "30", true},
+		{envVar{Name: "PARSERR_READ_ONLY", Type: envBool}, "yes", true},
+	}
+	for _, tt := range tests {
+		err := tt.v.checkValue(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s=%q: error %v, want error %v", tt.v.Name, tt.value, err, tt.wantErr)
+		}
+	}
+}
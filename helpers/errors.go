@@ -0,0 +1,11 @@
+package helpers
+
+// Unwrap Return the error wrapped by err, nil if it doesn't wrap any. Errors
+// wrap others with an Unwrap method, like the errors of the standard library.
+func Unwrap(err error) error {
+	u, ok := err.(interface{ Unwrap() error })
+	if !ok {
+		return nil
+	}
+	return u.Unwrap()
+}
@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+type logTagKey struct{}
+
+// WithLogTag Return a context whose lines logged with Logf end with tag,
+// like the context of the item being fixed. Every goroutine carries its own
+// tag, so items fixed at the same time are still told apart.
+func WithLogTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, logTagKey{}, tag)
+}
+
+// LogTag Return the tag of the lines logged with the context, if any
+func LogTag(ctx context.Context) string {
+	tag, _ := ctx.Value(logTagKey{}).(string)
+	return tag
+}
+
+// Logf Log like log.Printf ending the line with the tag of the context
+func Logf(ctx context.Context, format string, v ...interface{}) {
+	Tagf(LogTag(ctx), format, v...)
+}
+
+// Tagf Log like log.Printf ending the line with tag, if not empty
+func Tagf(tag, format string, v ...interface{}) {
+	line := fmt.Sprintf(format, v...)
+	if tag != "" {
+		line += " " + tag
+	}
+	log.Print(line)
+}
@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// ActionMove A file is moved or copied
+	ActionMove = "move"
+	// ActionDelete A file, download or record is deleted
+	ActionDelete = "delete"
+	// ActionQueueRemoval An item is removed from the queue of an instance
+	ActionQueueRemoval = "queue removal"
+	// ActionChange Anything else modified, like a command or a setting
+	ActionChange = "change"
+)
+
+// PlannedAction Action a read-only run would have taken
+type PlannedAction struct {
+	Kind        string
+	Description string
+}
+
+// Plan Actions a read-only run would have taken, in order. Safe for
+// concurrent use.
+type Plan struct {
+	mu      sync.Mutex
+	actions []PlannedAction
+}
+
+var (
+	planMu  sync.Mutex
+	current *Plan
+)
+
+// StartPlan Record every action read-only code would take in a new plan
+// until StopPlan
+func StartPlan() *Plan {
+	planMu.Lock()
+	defer planMu.Unlock()
+	current = &Plan{}
+	return current
+}
+
+// StopPlan Stop recording actions
+func StopPlan() {
+	planMu.Lock()
+	defer planMu.Unlock()
+	current = nil
+}
+
+// Planning Return true if actions are being recorded in a plan
+func Planning() bool {
+	planMu.Lock()
+	defer planMu.Unlock()
+	return current != nil
+}
+
+// Planned Record the action in the current plan, if any
+func Planned(kind, format string, args ...interface{}) {
+	planMu.Lock()
+	p := current
+	planMu.Unlock()
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.actions = append(p.actions, PlannedAction{Kind: kind, Description: fmt.Sprintf(format, args...)})
+}
+
+// Actions Return the actions of the plan
+func (p *Plan) Actions() []PlannedAction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PlannedAction(nil), p.actions...)
+}
+
+// Empty Return true if the plan has no actions
+func (p *Plan) Empty() bool {
+	return len(p.Actions()) == 0
+}
+
+// Summary Count the actions by kind, like 2 moves, 1 delete, 1 queue
+// removal
+func (p *Plan) Summary() string {
+	counts := make(map[string]int)
+	for _, a := range p.Actions() {
+		counts[a.Kind]++
+	}
+	var parts []string
+	for _, kind := range []string{ActionMove, ActionDelete, ActionQueueRemoval, ActionChange} {
+		if counts[kind] == 0 {
+			continue
+		}
+		if counts[kind] == 1 {
+			parts = append(parts, fmt.Sprintf("1 %s", kind))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d %ss", counts[kind], kind))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
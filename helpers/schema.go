@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaDraft JSON Schema version of the generated schemas
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// JSONSchema Return the JSON Schema of the values of type t as encoded by
+// encoding/json, following the json tags of its fields
+func JSONSchema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "description": "duration in nanoseconds"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := JSONSchema(t.Elem())
+		schema["type"] = []interface{}{schema["type"], "null"}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		// nil slices are encoded as null
+		return map[string]interface{}{"type": []interface{}{"array", "null"}, "items": JSONSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": []interface{}{"object", "null"}, "additionalProperties": JSONSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	// interfaces can hold anything
+	return map[string]interface{}{}
+}
+
+// structSchema Return the schema of the exported fields of the struct,
+// fields without omitempty are required
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if f.Anonymous && parts[0] == "" && f.Type.Kind() == reflect.Struct {
+			// embedded fields are encoded as fields of the struct
+			embedded := structSchema(f.Type)
+			for name, schema := range embedded["properties"].(map[string]interface{}) {
+				properties[name] = schema
+			}
+			required = append(required, embedded["required"].([]string)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if parts[0] != "" {
+			name = parts[0]
+		}
+		properties[name] = JSONSchema(f.Type)
+		omitempty := false
+		for _, option := range parts[1:] {
+			omitempty = omitempty || option == "omitempty"
+		}
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// transliterations ASCII replacement of the most common non-ASCII letters
+var transliterations = map[rune]string{}
+
+func init() {
+	groups := map[string]string{
+		"a": "àáâãäåāăą", "A": "ÀÁÂÃÄÅĀĂĄ",
+		"c": "çćĉċč", "C": "ÇĆĈĊČ",
+		"d": "ďđ", "D": "ĎĐ",
+		"e": "èéêëēĕėęě", "E": "ÈÉÊËĒĔĖĘĚ",
+		"g": "ĝğġģ", "G": "ĜĞĠĢ",
+		"h": "ĥħ", "H": "ĤĦ",
+		"i": "ìíîïĩīĭįı", "I": "ÌÍÎÏĨĪĬĮİ",
+		"j": "ĵ", "J": "Ĵ",
+		"k": "ķ", "K": "Ķ",
+		"l": "ĺļľŀł", "L": "ĹĻĽĿŁ",
+		"n": "ñńņňŉ", "N": "ÑŃŅŇ",
+		"o": "òóôõöøōŏő", "O": "ÒÓÔÕÖØŌŎŐ",
+		"r": "ŕŗř", "R": "ŔŖŘ",
+		"s": "śŝşš", "S": "ŚŜŞŠ",
+		"t": "ţťŧ", "T": "ŢŤŦ",
+		"u": "ùúûüũūŭůűų", "U": "ÙÚÛÜŨŪŬŮŰŲ",
+		"w": "ŵ", "W": "Ŵ",
+		"y": "ýÿŷ", "Y": "ÝŸŶ",
+		"z": "źżž", "Z": "ŹŻŽ",
+		"ae": "æ", "AE": "Æ",
+		"oe": "œ", "OE": "Œ",
+		"ss": "ß",
+		"th": "þ", "TH": "Þ",
+		"'":  "‘’´",
+		"\"": "“”«»",
+		"-":  "–—",
+	}
+	for ascii, letters := range groups {
+		for _, r := range letters {
+			transliterations[r] = ascii
+		}
+	}
+}
+
+// Transliterate Replace non-ASCII characters with their closest ASCII
+// equivalent, characters without one are replaced with an underscore
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r <= unicode.MaxASCII {
+			b.WriteRune(r)
+			continue
+		}
+		if ascii, ok := transliterations[r]; ok {
+			b.WriteString(ascii)
+			continue
+		}
+		b.WriteRune('_')
+	}
+	return b.String()
+}
@@ -1,37 +1,868 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"parserr/api"
+	"parserr/client"
+	"parserr/helpers"
+	"parserr/mediaserver"
 	"parserr/parser"
+	"parserr/store"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	godotenv.Load()
+	args := parseFlags(os.Args[1:])
+	err := applyProfile(currentProfile())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) > 0 {
+		// commands that must work with an invalid configuration
+		switch args[0] {
+		case "env":
+			envCommand()
+			return
+		case "config":
+			configCommand(args[1:])
+			return
+		case "report":
+			reportCommand(args[1:])
+			return
+		}
+	}
+	err = checkEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	closeStoreOnExit()
+	defer closeStore()
+	safety, err := parser.NewSafety(os.Getenv(parser.EnvSafety))
+	if err != nil {
+		log.Fatal(err)
+	}
+	safety.CreateLibraryFolders = envBoolValue(parser.EnvCreateLibraryFolders)
+	safety.DeleteSuperseded = envBoolValue(parser.EnvDeleteSuperseded)
+	if len(args) > 0 {
+		runCommand(args[0], args[1:], safety)
+		return
+	}
+	log.Printf("running in %s mode", safety.Level)
+	state := loadState()
+	checkProfile(state)
+	checkCopyMode(state)
+	if state == nil && envIntValue(parser.EnvUnmonitorAfter, 0) > 0 {
+		log.Printf("%s requires %s, media won't be unmonitored", parser.EnvUnmonitorAfter, parser.EnvStateFile)
+	}
 	apis := getAPIs()
+	logBanner(safety, apis)
+	checkInstances(context.Background(), apis)
+	checkUpdate()
+	if confirmPlan(context.Background(), safety, state, defaultPhases) {
+		runAll(context.Background(), apis, safety, state, nil, defaultPhases)
+	}
+}
+
+// runPhases Parts of a run, fixing the failed media and verifying and
+// cleaning the media fixed by previous runs
+type runPhases struct {
+	fix   bool
+	clean bool
+	// filter Media fixed by a targeted run, only the failed media are
+	// looked at in them
+	filter parser.MediaFilter
+}
+
+var allPhases = runPhases{fix: true, clean: true}
+
+// envParallel Run the instances at the same time instead of one after the
+// other
+const envParallel = "PARSERR_PARALLEL"
+
+// runAll Fix the media of every api, only the new or changed items of the
+// apis with a tracker
+func runAll(ctx context.Context, apis []api.RRAPI, safety parser.Safety, state *parser.State, trackers map[string]*parser.QueueTracker, phases runPhases) {
+	if runtime := maxRuntime(); runtime > 0 {
+		ctx = parser.WithRunDeadline(ctx, time.Now().Add(runtime), nil)
+	}
+	if state != nil && readOnly() {
+		// every step runs, planning its changes, but the state isn't saved
+		state = state.View()
+	}
+	if !envBoolValue(envParallel) {
+		for _, a := range apis {
+			execute(ctx, a, safety, state, trackers[a.GetName()], phases)
+		}
+		return
+	}
+	var wg sync.WaitGroup
 	for _, a := range apis {
-		execute(a)
+		wg.Add(1)
+		go func(a api.RRAPI) {
+			defer wg.Done()
+			execute(ctx, a, safety, state, trackers[a.GetName()], phases)
+		}(a)
 	}
+	wg.Wait()
 }
 
-func execute(a api.RRAPI) {
-	parser.ExtractAll(a.GetDownloadFolder())
-	a.ExecuteCommandAndWait(a.CheckFinishedDownloadsCommand(), api.DefaultRetries)
-	move := parser.BasicMover{}
-	files, err := parser.FailedMedia(a)
+// maxRuntimeFlag Value of --max-runtime, overriding parser.EnvMaxRuntime
+var maxRuntimeFlag time.Duration
+
+// maxRuntime Return the max time a run can take, 0 if unlimited
+func maxRuntime() time.Duration {
+	if maxRuntimeFlag > 0 {
+		return maxRuntimeFlag
+	}
+	return envDurationValue(parser.EnvMaxRuntime)
+}
+
+// globalFlags Flags accepted before or after any command, setting their value
+var globalFlags = map[string]func(value string) error{
+	"--max-runtime": func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("use a duration like 30m")
+		}
+		maxRuntimeFlag = d
+		return nil
+	},
+	"--profile": func(value string) error {
+		profileFlag = value
+		return nil
+	},
+}
+
+// globalSwitches Flags without value accepted before or after any command
+var globalSwitches = map[string]*bool{
+	"--yes": &yesFlag,
+}
+
+// parseFlags Return the arguments without the global flags, setting them
+func parseFlags(args []string) (rest []string) {
+	for i := 0; i < len(args); i++ {
+		if on, ok := globalSwitches[args[i]]; ok {
+			*on = true
+			continue
+		}
+		name := strings.SplitN(args[i], "=", 2)[0]
+		set, ok := globalFlags[name]
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+		value := strings.TrimPrefix(strings.TrimPrefix(args[i], name), "=")
+		if value == "" && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if err := set(value); err != nil {
+			log.Fatalf("invalid %s %q, %s", name, value, err)
+		}
+	}
+	return
+}
+
+// writeReport Log the report and append it to the report file, if any,
+// unless the run is only being planned
+func writeReport(report *parser.Report) {
+	report.Log()
+	if path := os.Getenv(parser.EnvReportFile); path != "" && !helpers.Planning() {
+		if err := report.Append(path); err != nil {
+			log.Printf("cannot write report to %s: %s", path, err)
+		}
+	}
+}
+
+// trackResults Make the tracker evaluate again the media left pending by
+// the run deadline, they haven't been fixed, and the failed ones after a
+// backoff
+func trackResults(tracker *parser.QueueTracker, files []*api.Media, report *parser.Report) {
+	statuses := make(map[string]string)
+	for _, item := range report.Items {
+		statuses[item.Title] = item.Status
+	}
+	for _, m := range files {
+		switch statuses[m.QueueElem.Title] {
+		case parser.ItemPending:
+			tracker.Forget(m)
+		case parser.ItemFailed, parser.ItemPanicked, parser.ItemTimedOut:
+			tracker.Failed(m)
+		}
+	}
+}
+
+func execute(ctx context.Context, a api.RRAPI, safety parser.Safety, state *parser.State, tracker *parser.QueueTracker, phases runPhases) {
+	if !envBoolValue(envParallel) {
+		// the prefix is shared by every instance running at the same time
+		log.SetPrefix("[" + a.GetName() + "] ")
+		defer log.SetPrefix("")
+	}
+	report := &parser.Report{Instance: a.GetName()}
+	defer writeReport(report)
+	if cache := a.GetPathCache(); cache != nil {
+		defer log.Print(cache)
+	}
+	if mode := os.Getenv(parser.EnvHealthCheck); mode != parser.HealthOff {
+		err := parser.CheckHealth(ctx, a, parser.LogNotifier{})
+		if err != nil && mode != parser.HealthAlert {
+			log.Printf("skipping instance, its queue warnings may not be about the files: %s", err)
+			return
+		}
+	}
+	if !readOnly() && phases.fix {
+		for _, dir := range destinations(a) {
+			if err := parser.CheckWritable(dir); err != nil {
+				log.Printf("skipping instance: %s", err)
+				return
+			}
+		}
+	}
+	targeted := !phases.filter.Empty()
+	for _, folder := range a.GetDownloadFolders() {
+		if !phases.fix || targeted {
+			break
+		}
+		if readOnly() {
+			log.Printf("read-only, would extract compressed files on: %s", folder.Path)
+			helpers.Planned(helpers.ActionChange, "extract compressed files on %s", folder.Path)
+			continue
+		}
+		if err := parser.ExtractFolder(folder.Path, a.GetName(), safety, fileLocks()); err != nil {
+			log.Print(err)
+		}
+	}
+	if !targeted {
+		a.ExecuteCommandAndWait(ctx, a.CheckFinishedDownloadsCommand(), api.DefaultRetries)
+	}
+	if err := parser.DeadlineReached(ctx, a.GetName()); err != nil {
+		log.Print(err)
+		return
+	}
+	if phases.clean && deferredClean(a) && state != nil {
+		cleaner := parser.Cleaner{
+			API:         a,
+			State:       state,
+			Settle:      envDurationValue(parser.EnvCleanSettle),
+			MaxAttempts: envIntValue(parser.EnvCleanAttempts, parser.DefaultCleanAttempts),
+		}
+		if c, ok := downloadClient(); ok && envBoolValue(client.EnvClientCleanup) {
+			cleaner.Client = c
+		}
+		cleaner.Clean(ctx, report)
+	}
+	if !phases.fix {
+		return
+	}
+	files, err := parser.FailedMediaIndexed(ctx, a, fileLister(a), historyIndex(a, state))
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	fixStrategy := parser.StrategyFactory(a, move)
-	err = parser.FixMedia(files, fixStrategy)
+	files = phases.filter.Filter(files, time.Now())
+	if tracker != nil {
+		files, _ = tracker.Track(files)
+	}
+	if state != nil {
+		files = state.Pending(files)
+	}
+	now := time.Now()
+	files = parser.SettledWarnings(files, now, envDurationValue(parser.EnvWarningGrace))
+	if c, ok := downloadClient(); ok {
+		if checker, ok := c.(client.ActivityChecker); ok {
+			var active []*api.Media
+			files, active = parser.IdleDownloads(files, checker)
+			for _, m := range active {
+				// checked again on the next watch cycle
+				if tracker != nil {
+					tracker.Forget(m)
+				}
+			}
+		}
+	}
+	parser.Prioritize(files, now, envDurationValue(parser.EnvAiringWindow), envDurationValue(parser.EnvEscalateAfter))
+	if len(files) > 0 {
+		defer librarySnapshot(ctx, a, files, report)()
+	}
+	fixStrategy := newStrategy(ctx, a, safety, state)
+	err = parser.FixMedia(ctx, files, fixStrategy, report)
 	if err != nil {
 		log.Println(err)
+	}
+	if tracker != nil {
+		trackResults(tracker, files, report)
+	}
+	report.Missing, err = parser.Missing(ctx, a, files)
+	if err != nil {
+		log.Printf("cannot find missing media: %s", err)
+	}
+	if c, ok := downloadClient(); ok && copyMode() && state != nil {
+		parser.CleanSeeded(ctx, a, state, c, envBoolValue(client.EnvClientCleanup), safety)
+	}
+}
+
+// librarySnapshot Take a snapshot of the media about to be fixed, returning
+// the function that takes it again once fixed and reports what changed
+func librarySnapshot(ctx context.Context, a api.RRAPI, files []*api.Media, report *parser.Report) func() {
+	before, err := parser.TakeSnapshot(ctx, a, files)
+	if err != nil {
+		log.Printf("cannot take library snapshot: %s", err)
+		return func() {}
+	}
+	return func() {
+		after, err := parser.TakeSnapshot(ctx, a, files)
+		if err != nil {
+			log.Printf("cannot take library snapshot: %s", err)
+			return
+		}
+		diff := parser.Diff(before, after)
+		report.Library = &diff
+	}
+}
+
+// fileLister Return what tells the files of the downloads of the api, the
+// download client or its torrent folder, nil if none is configured
+func fileLister(a api.RRAPI) (lister client.FileLister) {
+	if c, ok := downloadClient(); ok {
+		lister, _ = c.(client.FileLister)
+	}
+	if dir := os.Getenv(client.EnvTorrentFolder); lister == nil && dir != "" {
+		var folders []string
+		for _, folder := range a.GetDownloadFolders() {
+			folders = append(folders, folder.Path)
+		}
+		lister = client.TorrentFolder{Dir: dir, DownloadFolders: folders}
+	}
+	return
+}
+
+// historyIndex Return the incremental history of the api, nil if it's not
+// enabled. Read-only runs don't save it.
+func historyIndex(a api.RRAPI, state *parser.State) *parser.HistoryIndex {
+	if state == nil || !envBoolValue(parser.EnvIncrementalHistory) {
+		return nil
+	}
+	index := parser.NewHistoryIndex(state.Store, a.GetName(), envDurationValue(parser.EnvHistoryRetention))
+	index.ReadOnly = readOnly()
+	return index
+}
+
+// destinations Return the folders files are written to
+func destinations(a api.RRAPI) (dirs []string) {
+	for _, folder := range a.GetDownloadFolders() {
+		dirs = append(dirs, folder.Path)
+	}
+	if dir := os.Getenv(parser.EnvQuarantineFolder); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return
+}
+
+// newStrategy Return the fix strategy of the api, files that don't pass
+// validation are quarantined if a quarantine folder is configured and the
+// progress is recorded if there is a state
+func newStrategy(ctx context.Context, a api.RRAPI, safety parser.Safety, state *parser.State) parser.FixStrategy {
+	mover := newMover(ctx, a)
+	if state != nil {
+		mover = parser.JournalMover{Mover: mover, State: state}
+	}
+	fixStrategy := parser.StrategyFactory(a, mover, safety)
+	cache := parser.NewRunCache()
+	if timeout := envDurationValue(parser.EnvItemTimeout); timeout > 0 {
+		fixStrategy = parser.TimeoutStrategy{Strategy: fixStrategy, Timeout: timeout}
+	}
+	fixStrategy = parser.SelfHealStrategy{Strategy: fixStrategy, API: a, Cache: cache}
+	if a.GetType() == api.TypeShow {
+		fixStrategy = parser.EpisodeCheckStrategy{Strategy: fixStrategy, API: a, Cache: cache}
+		fixStrategy = parser.AbsoluteStrategy{Strategy: fixStrategy, API: a, Cache: cache}
+	}
+	if envBoolValue(parser.EnvTransliterate) {
+		fixStrategy = parser.TransliterateStrategy{Strategy: fixStrategy}
+	}
+	if envBoolValue(parser.EnvAnnotateQuality) {
+		fixStrategy = parser.QualityStrategy{Strategy: fixStrategy, API: a, Cache: cache}
+	}
+	if a.GetType() == api.TypeMusic {
+		fixStrategy = parser.TrackRenameStrategy{Strategy: fixStrategy, API: a}
+	}
+	if envBoolValue(parser.EnvProbe) {
+		ffprobe := os.Getenv(parser.EnvFFProbe)
+		if ffprobe == "" {
+			ffprobe = parser.DefaultFFProbe
+		}
+		fixStrategy = parser.ProbeStrategy{
+			Strategy:       fixStrategy,
+			Prober:         parser.Prober{FFProbe: ffprobe},
+			Notifier:       parser.LogNotifier{},
+			AudioLanguages: parser.ParseLanguages(os.Getenv(parser.EnvAudioLanguages)),
+		}
+	}
+	if envBoolValue(parser.EnvNFO) && !readOnly() {
+		fixStrategy = parser.NFOStrategy{Strategy: fixStrategy, API: a}
+	}
+	if os.Getenv(parser.EnvExtras) == parser.ExtrasLibrary && a.GetType() != api.TypeMusic {
+		fixStrategy = parser.ExtrasStrategy{Strategy: fixStrategy, API: a, Mover: newMover(ctx, a)}
+	}
+	if label := os.Getenv(parser.EnvTag); label != "" && !readOnly() {
+		fixStrategy = parser.TagStrategy{Strategy: fixStrategy, API: a, Label: label, Cache: cache}
+	}
+	if server, ok := mediaServer(); ok && !readOnly() {
+		fixStrategy = parser.VerifyStrategy{
+			Strategy: fixStrategy,
+			API:      a,
+			Server:   server,
+			Wait:     envDurationValue(mediaserver.EnvMediaServerWait),
+		}
+	}
+	validators := []parser.Validator{safety.Check}
+	limits := sizeLimits()
+	if len(limits) > 0 {
+		validators = append(validators, limits.Check)
+	}
+	q, ok := quarantine()
+	if ok && !readOnly() {
+		fixStrategy = parser.QuarantineStrategy{
+			Strategy:   fixStrategy,
+			Quarantine: q,
+			Validators: validators,
+		}
+	} else if len(limits) > 0 {
+		fixStrategy = parser.ValidateStrategy{Strategy: fixStrategy, Validators: []parser.Validator{limits.Check}}
+	}
+	if minSize := minFileSize(); minSize > 0 {
+		// out of the quarantine, empty files are worth nothing there
+		fixStrategy = parser.ValidateStrategy{Strategy: fixStrategy, Validators: []parser.Validator{parser.PlaceholderCheck(minSize)}}
+	}
+	if envBoolValue(parser.EnvBlacklistFailed) {
+		fixStrategy = parser.BlacklistStrategy{
+			Strategy:       fixStrategy,
+			API:            a,
+			KeepInClient:   envBoolValue(parser.EnvBlacklistKeepInClient),
+			State:          state,
+			UnmonitorAfter: envIntValue(parser.EnvUnmonitorAfter, 0),
+			UnmonitorScope: os.Getenv(parser.EnvUnmonitorScope),
+			Notifier:       parser.LogNotifier{},
+			Audit:          openAuditLog(),
+		}
+	}
+	if copyMode() && state != nil {
+		// Downloads are removed from the client once seeded by CleanSeeded
+		fixStrategy = parser.SeedStrategy{Strategy: fixStrategy, State: state}
+	} else if deferredClean(a) && state != nil {
+		// Verified and cleaned by the clean phase of a later run
+		fixStrategy = parser.DeferredCleanStrategy{Strategy: fixStrategy, State: state}
+	} else if c, ok := downloadClient(); ok && envBoolValue(client.EnvClientCleanup) {
+		fixStrategy = parser.ClientCleanupStrategy{Strategy: fixStrategy, API: a, Client: c}
+	}
+	if state != nil {
+		fixStrategy = parser.StatefulStrategy{Strategy: fixStrategy, State: state}
+	}
+	if !readOnly() {
+		// Instances sharing a download folder skip the files others are fixing
+		fixStrategy = parser.LockStrategy{Strategy: fixStrategy, Locks: fileLocks()}
+	}
+	return fixStrategy
+}
+
+// deferredClean Return true if the media of the api is verified and cleaned
+// on the run after fixing it, the setting of the instance overrides the
+// global one
+func deferredClean(a api.RRAPI) bool {
+	instance := parser.EnvSonarrDeferredClean
+	switch a.GetType() {
+	case api.TypeMovie:
+		instance = parser.EnvRadarrDeferredClean
+	case api.TypeMusic:
+		instance = parser.EnvLidarrDeferredClean
+	}
+	if os.Getenv(instance) != "" {
+		return envBoolValue(instance)
+	}
+	return envBoolValue(parser.EnvDeferredClean)
+}
+
+// newMover Return the mover of the api, which fakes every operation in
+// read-only mode, copies in copy mode and applies the media management settings
+func newMover(ctx context.Context, a api.RRAPI) parser.Mover {
+	var move parser.Mover = parser.BasicMover{}
+	if readOnly() {
+		return parser.FakeMover{}
+	}
+	if copyMode() {
+		move = parser.CopyMover{Mover: move}
+	}
+	if envBoolValue(parser.EnvMediaManagement) {
+		config, err := a.GetMediaManagementConfig(ctx)
+		if err != nil {
+			log.Printf("cannot get media management settings, using default permissions: %s", err)
+		} else {
+			move = parser.MediaManagementMover{Mover: move, Config: config}
+		}
+	}
+	return move
+}
+
+var (
+	// auditLog Log of the rejected releases, shared by every instance so
+	// the entries aren't interleaved
+	auditLog     *parser.AuditLog
+	auditLogOnce sync.Once
+	// locks Locks of the files being fixed and the folders being extracted,
+	// kept in memory without a state store so instances running at the
+	// same time still lock each other
+	locks     *parser.FileLocks
+	locksOnce sync.Once
+)
+
+// openAuditLog Return the audit log, configured on the first call
+func openAuditLog() *parser.AuditLog {
+	auditLogOnce.Do(func() {
+		auditLog = &parser.AuditLog{Path: os.Getenv(parser.EnvAuditLog)}
+	})
+	return auditLog
+}
+
+// fileLocks Return the locks of the files kept in the state store, or in
+// memory if there is no store
+func fileLocks() *parser.FileLocks {
+	locksOnce.Do(func() {
+		st := openStore()
+		if st == nil {
+			st = store.NewMemory()
+		}
+		locks = parser.NewFileLocks(st)
+	})
+	return locks
+}
+
+var (
+	// stateStore Store opened by openStore, shared by the state and the
+	// quarantine because bbolt locks its file
+	stateStore store.Store
+	storeMu    sync.Mutex
+)
+
+// openStore Return the store of the configured backend, or nil if there is no
+// state file configured and the backend isn't kept in memory
+func openStore() store.Store {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if stateStore != nil {
+		return stateStore
+	}
+	backend := os.Getenv(store.EnvStateBackend)
+	path := os.Getenv(parser.EnvStateFile)
+	if path == "" && backend != store.BackendMemory {
+		return nil
+	}
+	st, err := store.Open(backend, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stateStore = st
+	return st
+}
+
+// closeStore Close the store opened by openStore, if any, releasing the
+// lock bbolt keeps on its file
+func closeStore() {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if stateStore == nil {
+		return
+	}
+	err := stateStore.Close()
+	if err != nil {
+		log.Printf("cannot close the state store: %s", err)
+	}
+	stateStore = nil
+}
+
+// closeStoreOnExit Close the store when parserr is interrupted or
+// terminated, daemons never return from main
+func closeStoreOnExit() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("%s received, exiting", sig)
+		closeStore()
+		os.Exit(1)
+	}()
+}
+
+// loadState Return the state of previous runs, recovering interrupted items,
+// or nil if there is no state configured
+func loadState() *parser.State {
+	st := openStore()
+	if st == nil {
+		return nil
+	}
+	state, err := parser.NewState(st)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !readOnly() {
+		state.Recover(parser.BasicMover{})
+	}
+	return state
+}
+
+// copyMode Return true if files are copied and their originals deleted once
+// seeded
+func copyMode() bool {
+	return envBoolValue(parser.EnvCopyUntilSeeded)
+}
+
+// checkCopyMode Exit if copy mode is enabled without what it needs to know
+// when the originals can be deleted
+func checkCopyMode(state *parser.State) {
+	if !copyMode() {
 		return
 	}
+	if state == nil {
+		log.Fatalf("%s requires %s", parser.EnvCopyUntilSeeded, parser.EnvStateFile)
+	}
+	if os.Getenv(client.EnvClientType) == "" {
+		log.Fatalf("%s requires %s", parser.EnvCopyUntilSeeded, client.EnvClientType)
+	}
+}
+
+// sizeLimits Return the expected file sizes by quality
+func sizeLimits() parser.SizeLimits {
+	limits, err := parser.ParseSizeLimits(os.Getenv(parser.EnvSizeLimits))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return limits
+}
+
+func minFileSize() int64 {
+	size, err := parser.ParseMinFileSize(os.Getenv(parser.EnvMinFileSize))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return size
+}
+
+func quarantine() (q parser.Quarantine, ok bool) {
+	dir := os.Getenv(parser.EnvQuarantineFolder)
+	if dir == "" {
+		return q, false
+	}
+	q = parser.Quarantine{Dir: dir, Mover: parser.BasicMover{}, ReadOnly: readOnly()}
+	// entries are kept in databases, json and memory states keep them in
+	// sidecars so they are never lost or hard to find
+	switch os.Getenv(store.EnvStateBackend) {
+	case store.BackendBolt, store.BackendSQLite:
+		q.Store = openStore()
+	}
+	return q, true
+}
+
+// downloadClient Return the configured download client
+func downloadClient() (c client.Client, ok bool) {
+	if os.Getenv(client.EnvClientType) == "" {
+		return nil, false
+	}
+	pathMap, err := client.ParsePathMap(os.Getenv(client.EnvClientPathMap))
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err = client.New(client.Config{
+		Type:             os.Getenv(client.EnvClientType),
+		URL:              os.Getenv(client.EnvClientURL),
+		Username:         os.Getenv(client.EnvClientUsername),
+		Password:         os.Getenv(client.EnvClientPassword),
+		APIKey:           os.Getenv(client.EnvClientAPIKey),
+		ImportedCategory: os.Getenv(client.EnvClientImportedCategory),
+		ReadOnly:         readOnly(),
+		PathMap:          pathMap,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return c, true
+}
+
+// mediaServer Return the media server fixed media is verified against, if
+// one is configured
+func mediaServer() (s mediaserver.Server, ok bool) {
+	if os.Getenv(mediaserver.EnvMediaServerType) == "" {
+		return nil, false
+	}
+	s, err := mediaserver.New(mediaserver.Config{
+		Type:  os.Getenv(mediaserver.EnvMediaServerType),
+		URL:   os.Getenv(mediaserver.EnvMediaServerURL),
+		Token: os.Getenv(mediaserver.EnvMediaServerToken),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s, true
+}
+
+// httpClient Return the http client connecting through the unix socket, if
+// not empty, and with the configured client certificate, in which case https
+// must be used
+func httpClient(socket string) (c *http.Client, https bool) {
+	var dial api.DialFunc
+	if socket != "" {
+		dial = api.UnixSocketDialer(socket)
+	}
+	var tlsConfig *tls.Config
+	cert, key := os.Getenv(api.EnvTLSCert), os.Getenv(api.EnvTLSKey)
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			log.Fatalf("both %s and %s are required", api.EnvTLSCert, api.EnvTLSKey)
+		}
+		var err error
+		tlsConfig, err = api.NewTLSConfig(cert, key, os.Getenv(api.EnvTLSCA))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if dial == nil && tlsConfig == nil {
+		return nil, false
+	}
+	return api.NewClient(dial, tlsConfig), tlsConfig != nil
+}
+
+// timeouts Return the timeouts of the requests to the instances
+func timeouts() api.Timeouts {
+	t, err := api.ParseTimeouts(envDurationValue(api.EnvTimeout), os.Getenv(api.EnvTimeouts))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+// retryPolicy Return how requests failing with transient errors are retried
+func retryPolicy() api.Retry {
+	r := api.DefaultRetry()
+	r.Attempts = envIntValue(api.EnvRetryAttempts, api.DefaultRetryAttempts)
+	if d := envDurationValue(api.EnvRetryBackoff); d > 0 {
+		r.Backoff = d
+	}
+	r.Jitter = float64(envIntValue(api.EnvRetryJitter, api.DefaultRetryJitter)) / 100
+	return r
+}
+
+// commandWait Return how long and how often the commands sent to the
+// instances are checked
+func commandWait() api.CommandWait {
+	byCommand, err := api.ParseCommandTimeouts(os.Getenv(api.EnvCommandTimeouts))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return api.CommandWait{
+		Timeout:     envDurationValue(api.EnvCommandTimeout),
+		ByCommand:   byCommand,
+		Interval:    envDurationValue(api.EnvCommandInterval),
+		MaxInterval: envDurationValue(api.EnvCommandMaxInterval),
+	}
+}
+
+// rateLimiter Return a limiter of the requests to an instance, nil if they
+// aren't limited
+func rateLimiter() *api.RateLimiter {
+	rate := envIntValue(api.EnvRateLimit, 0)
+	return api.NewRateLimiter(float64(rate), envIntValue(api.EnvRateBurst, rate))
+}
+
+// urlBase Return the url base of the instance set in the environment
+// variable, which can't be combined with a url that has its own path
+func urlBase(u, env string) string {
+	base, err := api.ParseURLBase(os.Getenv(env))
+	if err != nil {
+		log.Fatalf("%s: %s", env, err)
+	}
+	if base != "" && strings.Contains(u, "://") {
+		if parsed, err := url.Parse(u); err == nil && parsed.Path != "" {
+			log.Fatalf("%s is set but the url already has a base path, use only one of them", env)
+		}
+	}
+	return base
+}
+
+// apiVersion Return the api version set in the environment variable, 0 to
+// detect it
+func apiVersion(env string) int {
+	v, err := api.ParseAPIVersion(os.Getenv(env))
+	if err != nil {
+		log.Fatalf("%s: %s", env, err)
+	}
+	return v
+}
+
+// readOnly Return true if nothing should be modified, neither through the
+// apis nor on disk
+func readOnly() bool {
+	return envBoolValue(api.EnvReadOnly)
+}
+
+// downloadFolders Parse a comma separated list of folders, each one
+// optionally followed by =strategy
+func downloadFolders(value string) (folders []api.DownloadFolder) {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var folder api.DownloadFolder
+		folder.Path, folder.Strategy = parser.SplitFolderStrategy(entry)
+		if i := strings.LastIndex(entry, "="); folder.Strategy == "" && i != -1 && !strings.ContainsAny(entry[i:], `/\`) {
+			// not a folder with "=" in its name but a misspelled strategy
+			if _, err := os.Stat(entry); err != nil {
+				log.Fatalf("download folder %s: unknown strategy %q", entry[:i], entry[i+1:])
+			}
+		}
+		folders = append(folders, folder)
+	}
+	return
+}
+
+// appNames App expected behind the apis of every type
+var appNames = map[string]string{api.TypeShow: "Sonarr", api.TypeMovie: "Radarr", api.TypeMusic: "Lidarr"}
+
+// checkInstances Log the app and version of every instance, detecting the
+// version of their api, and warn about the ones that might not work
+func checkInstances(ctx context.Context, apis []api.RRAPI) {
+	for _, a := range apis {
+		status, err := a.GetSystemStatus(ctx)
+		if err != nil {
+			log.Printf("cannot get the status of %s: %s", a.GetName(), err)
+			continue
+		}
+		log.Printf("%s is %s", a.GetName(), strings.TrimSpace(status.AppName+" "+status.Version))
+		if err := instanceError(a, status); err != nil {
+			log.Printf("warning: %s", err)
+		}
+	}
+}
+
+// instanceError Return an error if the instance isn't the app of the api
+// type or its version isn't supported
+func instanceError(a api.RRAPI, status api.SystemStatus) error {
+	_, generic := a.(api.Generic)
+	if app := appNames[a.GetType()]; !generic && status.AppName != "" && status.AppName != app {
+		return fmt.Errorf("%s is configured as %s but it is %s", a.GetName(), app, status.AppName)
+	}
+	return status.Supported()
 }
 
 func getAPIs() (apis []api.RRAPI) {
@@ -41,39 +872,174 @@ func getAPIs() (apis []api.RRAPI) {
 	if os.Getenv(api.EnvSonarrURL) != "" {
 		apis = append(apis, sonarr())
 	}
+	if os.Getenv(api.EnvLidarrURL) != "" {
+		apis = append(apis, lidarr())
+	}
+	if os.Getenv(api.EnvGenericURL) != "" {
+		apis = append(apis, generic())
+	}
 	return apis
 }
 
-func sonarr() api.RRAPI {
-	if os.Getenv(api.EnvSonarrAPIKey) == "" {
-		log.Fatal("empty sonarr apikey")
+// instanceEnv Variables configuring an instance, empty for the ones it
+// doesn't have
+type instanceEnv struct {
+	Name           string
+	URL            string
+	URLBase        string
+	APIVersion     string
+	Socket         string
+	APIKey         string
+	DownloadFolder string
+}
+
+var (
+	sonarrEnv = instanceEnv{
+		Name:           api.EnvSonarrName,
+		URL:            api.EnvSonarrURL,
+		URLBase:        api.EnvSonarrURLBase,
+		APIVersion:     api.EnvSonarrAPIVersion,
+		Socket:         api.EnvSonarrSocket,
+		APIKey:         api.EnvSonarrAPIKey,
+		DownloadFolder: api.EnvSonarrDownloadFolder,
+	}
+	radarrEnv = instanceEnv{
+		Name:           api.EnvRadarrName,
+		URL:            api.EnvRadarrURL,
+		URLBase:        api.EnvRadarrURLBase,
+		APIVersion:     api.EnvRadarrAPIVersion,
+		Socket:         api.EnvRadarrSocket,
+		APIKey:         api.EnvRadarrAPIKey,
+		DownloadFolder: api.EnvRadarrDownloadFolder,
+	}
+	lidarrEnv = instanceEnv{
+		Name:           api.EnvLidarrName,
+		URL:            api.EnvLidarrURL,
+		URLBase:        api.EnvLidarrURLBase,
+		Socket:         api.EnvLidarrSocket,
+		APIKey:         api.EnvLidarrAPIKey,
+		DownloadFolder: api.EnvLidarrDownloadFolder,
+	}
+	genericEnv = instanceEnv{
+		Name:           api.EnvGenericName,
+		URL:            api.EnvGenericURL,
+		URLBase:        api.EnvGenericURLBase,
+		Socket:         api.EnvGenericSocket,
+		APIKey:         api.EnvGenericAPIKey,
+		DownloadFolder: api.EnvGenericDownloadFolder,
+	}
+)
+
+// instanceHost Exit if the api key, download folder or url of the instance
+// are empty, return its url
+func instanceHost(name string, env instanceEnv) string {
+	if os.Getenv(env.APIKey) == "" {
+		log.Fatalf("empty %s apikey", name)
 	}
-	if os.Getenv(api.EnvSonarrDownloadFolder) == "" {
-		log.Fatal("empty sonarr download folder")
+	if os.Getenv(env.DownloadFolder) == "" {
+		log.Fatalf("empty %s download folder", name)
 	}
-	if os.Getenv(api.EnvSonarrURL) == "" {
-		log.Fatal("empty sonarr url")
+	if os.Getenv(env.URL) == "" {
+		log.Fatalf("empty %s url", name)
 	}
+	host, err := api.ParseURL(os.Getenv(env.URL))
+	if err != nil {
+		log.Fatalf("%s url: %s", name, err)
+	}
+	return host
+}
+
+// instanceOptions Options every instance is created with
+func instanceOptions() []api.Option {
+	return []api.Option{
+		api.WithTimeouts(timeouts()),
+		api.WithRetry(retryPolicy()),
+		api.WithCommandWait(commandWait()),
+	}
+}
+
+// configureInstance Configure the instance with its variables and the ones
+// shared by every instance
+func configureInstance(a *api.API, env instanceEnv) {
+	a.DownloadFolders = downloadFolders(os.Getenv(env.DownloadFolder))
+	a.URLBase = urlBase(a.URL, env.URLBase)
+	if env.APIVersion != "" {
+		a.Version = apiVersion(env.APIVersion)
+	}
+	if name := os.Getenv(env.Name); name != "" {
+		a.Name = name
+	}
+	a.ReadOnly = readOnly()
+	a.PathCache = api.NewPathCache()
+	a.ResponseCache = api.NewResponseCache(envDurationValue(api.EnvCacheTTL))
+	a.RateLimiter = rateLimiter()
+	a.HistoryPageSize = envIntValue(api.EnvHistoryPageSize, api.DefaultHistoryPageSize)
+	a.HTTPClient, a.HTTPS = httpClient(os.Getenv(env.Socket))
+	a.APIKeyInQuery = envBoolValue(api.EnvAPIKeyInQuery)
+	if dir := os.Getenv(api.EnvRecordFolder); dir != "" {
+		a.HTTPClient = api.Record(a.HTTPClient, filepath.Join(dir, a.Name), a.APIKey)
+	}
+}
+
+func sonarr() api.RRAPI {
+	host := instanceHost("sonarr", sonarrEnv)
 	log.Print("adding sonarr api")
-	return api.NewSonarr(
-		os.Getenv("SONARR_URL"),
-		os.Getenv("SONARR_APIKEY"),
-		os.Getenv("SONARR_DOWNLOAD_FOLDER"))
+	a := api.NewSonarr(host, os.Getenv(api.EnvSonarrAPIKey), os.Getenv(api.EnvSonarrDownloadFolder), instanceOptions()...)
+	configureInstance(&a.API, sonarrEnv)
+	return a
 }
 
 func radarr() api.RRAPI {
-	if os.Getenv(api.EnvRadarrAPIKey) == "" {
-		log.Fatal("empty radarr apikey")
+	host := instanceHost("radarr", radarrEnv)
+	log.Print("adding radarr api")
+	a := api.NewRadarr(host, os.Getenv(api.EnvRadarrAPIKey), os.Getenv(api.EnvRadarrDownloadFolder), instanceOptions()...)
+	configureInstance(&a.API, radarrEnv)
+	return a
+}
+
+func lidarr() api.RRAPI {
+	host := instanceHost("lidarr", lidarrEnv)
+	log.Print("adding lidarr api")
+	a := api.NewLidarr(host, os.Getenv(api.EnvLidarrAPIKey), os.Getenv(api.EnvLidarrDownloadFolder), instanceOptions()...)
+	configureInstance(&a.API, lidarrEnv)
+	return a
+}
+
+// generic Return the *arr configured with the names of its commands and
+// endpoints, for forks parserr doesn't know about
+func generic() api.RRAPI {
+	name := os.Getenv(api.EnvGenericName)
+	if name == "" {
+		log.Fatalf("empty %s, name the *arr like whisparr", api.EnvGenericName)
 	}
-	if os.Getenv(api.EnvRadarrDownloadFolder) == "" {
-		log.Fatal("empty radarr download folder")
+	host := instanceHost(name, genericEnv)
+	apiType := os.Getenv(api.EnvGenericType)
+	switch apiType {
+	case api.TypeShow, api.TypeMovie, api.TypeMusic:
+	default:
+		log.Fatalf("%s must be %s, %s or %s", api.EnvGenericType, api.TypeShow, api.TypeMovie, api.TypeMusic)
 	}
-	if os.Getenv(api.EnvRadarrURL) == "" {
-		log.Fatal("empty radarr url")
+	endpoints, err := api.ParseEndpoints(os.Getenv(api.EnvGenericEndpoints))
+	if err != nil {
+		log.Fatalf("%s endpoints: %s", name, err)
 	}
-	log.Print("adding radarr api")
-	return api.NewRadarr(
-		os.Getenv("RADARR_URL"),
-		os.Getenv("RADARR_APIKEY"),
-		os.Getenv("RADARR_DOWNLOAD_FOLDER"))
+	log.Printf("adding %s api", name)
+	a := api.NewGeneric(
+		host,
+		os.Getenv(api.EnvGenericAPIKey),
+		os.Getenv(api.EnvGenericDownloadFolder),
+		apiType,
+		api.GenericCommands{
+			Scan:         os.Getenv(api.EnvGenericScanCommand),
+			Rename:       os.Getenv(api.EnvGenericRenameCommand),
+			DownloadScan: os.Getenv(api.EnvGenericDownloadScanCommand),
+		},
+		instanceOptions()...)
+	a.APIPath = api.APIV3URL
+	if path := os.Getenv(api.EnvGenericAPIPath); path != "" {
+		a.APIPath = "/" + strings.Trim(path, "/")
+	}
+	a.Endpoints = endpoints
+	configureInstance(&a.API, genericEnv)
+	return a
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDownloadFolders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	withEquals := path.Join(dir, "a=b")
+	if err = os.Mkdir(withEquals, 0775); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		want  []api.DownloadFolder
+	}{
+		{"/downloads", []api.DownloadFolder{{Path: "/downloads"}}},
+		{"/downloads/tv=move-to-library, /downloads/anime", []api.DownloadFolder{
+			{Path: "/downloads/tv", Strategy: "move-to-library"},
+			{Path: "/downloads/anime"},
+		}},
+		{withEquals, []api.DownloadFolder{{Path: withEquals}}},
+		{withEquals + "=force-import", []api.DownloadFolder{{Path: withEquals, Strategy: "force-import"}}},
+		{"/downloads/a=b/c", []api.DownloadFolder{{Path: "/downloads/a=b/c"}}},
+	}
+	for _, tt := range tests {
+		if got := downloadFolders(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package mediaserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Jellyfin Client for the Jellyfin API, Emby uses the same one
+type Jellyfin struct {
+	Config
+}
+
+// jellyfinItems Response of the items endpoint
+type jellyfinItems struct {
+	Items []struct {
+		ID           string `json:"Id"`
+		Name         string
+		Type         string
+		Path         string
+		LocationType string
+	}
+}
+
+// Find Search the series and movies with the title and look for the file
+// among their episodes or their own files
+func (j Jellyfin) Find(ctx context.Context, title, file string) (item Item, ok bool, err error) {
+	var search jellyfinItems
+	err = j.items(ctx, url.Values{"searchTerm": {title}, "IncludeItemTypes": {"Series,Movie"}}, &search)
+	if err != nil {
+		return
+	}
+	for i, result := range search.Items {
+		var candidates jellyfinItems
+		candidates.Items = search.Items[i : i+1]
+		if result.Type == "Series" {
+			candidates.Items = nil
+			err = j.items(ctx, url.Values{"ParentId": {result.ID}, "IncludeItemTypes": {"Episode"}}, &candidates)
+			if err != nil {
+				return
+			}
+		}
+		for _, c := range candidates.Items {
+			if sameFile(c.Path, file) {
+				// Virtual items are known by the server but have no file
+				return Item{Title: c.Name, Path: c.Path, Playable: c.LocationType != "Virtual"}, true, nil
+			}
+		}
+	}
+	return
+}
+
+// items Query the items of every library with their paths
+func (j Jellyfin) items(ctx context.Context, query url.Values, v interface{}) error {
+	query.Set("Recursive", "true")
+	query.Set("Fields", "Path")
+	header := http.Header{}
+	header.Set("X-Emby-Token", j.Token)
+	return j.getJSON(ctx, "/Items?"+query.Encode(), header, v)
+}
@@ -0,0 +1,109 @@
+// Package mediaserver Clients for the media servers the library is played
+// from, used to check fixed media shows up in them.
+package mediaserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EnvMediaServerType Type of the media server, plex or jellyfin
+	EnvMediaServerType = "PARSERR_MEDIA_SERVER_TYPE"
+	// EnvMediaServerURL ...
+	EnvMediaServerURL = "PARSERR_MEDIA_SERVER_URL"
+	// EnvMediaServerToken Plex token or Jellyfin api key
+	EnvMediaServerToken = "PARSERR_MEDIA_SERVER_TOKEN"
+	// EnvMediaServerWait Max time to wait for the media server to show a
+	// fixed media, it's checked once if empty
+	EnvMediaServerWait = "PARSERR_MEDIA_SERVER_WAIT"
+	// TypePlex ...
+	TypePlex = "plex"
+	// TypeJellyfin ...
+	TypeJellyfin = "jellyfin"
+)
+
+// Server Media server able to find the items of its libraries
+type Server interface {
+	GetType() string
+	// Find Return the item of the file among the ones of the series or
+	// movie with the given title, ok is false if there is none
+	Find(ctx context.Context, title, file string) (item Item, ok bool, err error)
+}
+
+// Item Media of a library of the server
+type Item struct {
+	Title string
+	Path  string
+	// Playable The server can access the file
+	Playable bool
+}
+
+// Config ...
+type Config struct {
+	Type  string
+	URL   string
+	Token string
+	// HTTPClient Used for every request, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// New Return the media server of the configured type
+func New(c Config) (Server, error) {
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	switch c.Type {
+	case TypePlex:
+		return Plex{Config: c}, nil
+	case TypeJellyfin:
+		return Jellyfin{Config: c}, nil
+	}
+	return nil, fmt.Errorf("unknown media server %q, use %q or %q", c.Type, TypePlex, TypeJellyfin)
+}
+
+// GetType ...
+func (c Config) GetType() string {
+	return c.Type
+}
+
+// getJSON Request the endpoint with the header and decode the JSON response
+func (c Config) getJSON(ctx context.Context, endpoint string, header http.Header, v interface{}) error {
+	req, err := http.NewRequest("GET", c.URL+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header = header
+	req.Header.Set("Accept", "application/json")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 401 {
+		return fmt.Errorf("%s authorization invalid", c.Type)
+	}
+	if res.StatusCode != 200 {
+		return fmt.Errorf("%s request failed, status code %d", c.Type, res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// sameFile Return true if both paths have the same name, the media server
+// may see the library mounted somewhere else
+func sameFile(a, b string) bool {
+	return a != "" && path.Base(strings.Replace(a, "\\", "/", -1)) == filepath.Base(b)
+}
@@ -0,0 +1,78 @@
+package mediaserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Plex Client for the Plex Media Server API
+type Plex struct {
+	Config
+}
+
+// plexContainer Response of the Plex API
+type plexContainer struct {
+	MediaContainer struct {
+		Metadata []plexMetadata
+	}
+}
+
+// plexMetadata Show, movie or episode of a library
+type plexMetadata struct {
+	RatingKey string `json:"ratingKey"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Media     []struct {
+		Part []struct {
+			File       string `json:"file"`
+			Accessible *bool  `json:"accessible"`
+			Exists     *bool  `json:"exists"`
+		}
+	}
+}
+
+// Find Search the shows and movies with the title and look for the file
+// among their episodes or their own files
+func (p Plex) Find(ctx context.Context, title, file string) (item Item, ok bool, err error) {
+	var search plexContainer
+	err = p.get(ctx, "/search?"+url.Values{"query": {title}}.Encode(), &search)
+	if err != nil {
+		return
+	}
+	for _, result := range search.MediaContainer.Metadata {
+		var endpoint string
+		switch result.Type {
+		case "show":
+			endpoint = "/library/metadata/" + result.RatingKey + "/allLeaves?checkFiles=1"
+		case "movie":
+			endpoint = "/library/metadata/" + result.RatingKey + "?checkFiles=1"
+		default:
+			continue
+		}
+		var leaves plexContainer
+		err = p.get(ctx, endpoint, &leaves)
+		if err != nil {
+			return
+		}
+		for _, leaf := range leaves.MediaContainer.Metadata {
+			for _, media := range leaf.Media {
+				for _, part := range media.Part {
+					if !sameFile(part.File, file) {
+						continue
+					}
+					// The flags are only reported when plex checked the file
+					playable := (part.Accessible == nil || *part.Accessible) && (part.Exists == nil || *part.Exists)
+					return Item{Title: leaf.Title, Path: part.File, Playable: playable}, true, nil
+				}
+			}
+		}
+	}
+	return
+}
+
+func (p Plex) get(ctx context.Context, endpoint string, v interface{}) error {
+	header := http.Header{}
+	header.Set("X-Plex-Token", p.Token)
+	return p.getJSON(ctx, endpoint, header, v)
+}
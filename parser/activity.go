@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"parserr/api"
+	"parserr/client"
+)
+
+// IdleDownloads Split the media between the ones whose download the client
+// isn't doing anything with and the ones still being downloaded, rechecked
+// or post processed, which would be moved from under the client. Media the
+// client can't tell about are considered idle.
+func IdleDownloads(files []*api.Media, c client.ActivityChecker) (idle, active []*api.Media) {
+	activities := make(map[string]string)
+	for _, m := range files {
+		id := m.QueueElem.DownloadID
+		if id == "" {
+			idle = append(idle, m)
+			continue
+		}
+		activity, ok := activities[id]
+		if !ok {
+			var err error
+			activity, err = c.Activity(id)
+			if err != nil {
+				logItemf(m, "cannot check the activity of %s in the download client: %s", m.QueueElem.Title, err)
+			}
+			activities[id] = activity
+		}
+		if activity == "" {
+			idle = append(idle, m)
+			continue
+		}
+		logItemf(m, "%s still %s in the download client, leaving it for the next run", m.QueueElem.Title, activity)
+		active = append(active, m)
+	}
+	return
+}
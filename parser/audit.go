@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"parserr/api"
+	"sync"
+	"time"
+)
+
+// EnvAuditLog File every release rejected by parserr is recorded in, one
+// JSON object per line. Without it they are recorded in the standard logger.
+const EnvAuditLog = "PARSERR_AUDIT_LOG"
+
+// AuditEntry Something parserr did to a release and why
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Instance   string    `json:"instance"`
+	Title      string    `json:"title"`
+	DownloadID string    `json:"downloadId,omitempty"`
+	// Reason Kind of problem, like ReasonParseFailure
+	Reason string `json:"reason"`
+	// Message Error that made parserr act
+	Message string `json:"message,omitempty"`
+}
+
+// AuditLog Append-only log of the actions taken on releases, so it's known
+// later why a release was rejected. Safe for concurrent use.
+type AuditLog struct {
+	// Path File the entries are appended to, the standard logger if empty
+	Path string
+	// Clock Used to date the entries, RealClock if nil
+	Clock api.Clock
+	mu    sync.Mutex
+}
+
+// Record Append the entry, dating it if it has no time. A nil log records
+// in the standard logger.
+func (l *AuditLog) Record(entry AuditEntry) error {
+	var clock api.Clock
+	if l != nil {
+		clock = l.Clock
+	}
+	if entry.Time.IsZero() {
+		entry.Time = api.ClockOrReal(clock).Now()
+	}
+	if l == nil || l.Path == "" {
+		log.Printf("audit: %s %s (%s): %s", entry.Action, entry.Title, entry.Reason, entry.Message)
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// EnvBlackholeFolder Folder watched by parserr blackhole, where completed
+// files are imported without going through the queue
+const EnvBlackholeFolder = "PARSERR_BLACKHOLE_FOLDER"
+
+// Blackhole Folder where media files are dropped to be imported, a file is
+// considered completed once its size and modification time stop changing
+type Blackhole struct {
+	Dir   string
+	files map[string]blackholeFile
+}
+
+// blackholeFile Last seen state of a file and whether it has been handled
+// in that state
+type blackholeFile struct {
+	info    os.FileInfo
+	handled bool
+}
+
+// NewBlackhole ...
+func NewBlackhole(dir string) *Blackhole {
+	return &Blackhole{Dir: dir, files: make(map[string]blackholeFile)}
+}
+
+// Ready Return the media files that haven't changed since the previous call
+// and haven't been handled yet, files must be seen twice to be ready
+func (b *Blackhole) Ready() (ready []string, err error) {
+	current := make(map[string]blackholeFile)
+	err = filepath.Walk(b.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isMedia(file) {
+			return nil
+		}
+		f := blackholeFile{info: info}
+		if last, ok := b.files[file]; ok && sameFile(last.info, info) {
+			f.handled = last.handled
+			if !f.handled {
+				ready = append(ready, file)
+			}
+		}
+		current[file] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.files = current
+	return ready, nil
+}
+
+// Handled Don't return the file again unless it changes, whether it has
+// been imported or not
+func (b *Blackhole) Handled(file string) {
+	if f, ok := b.files[file]; ok {
+		f.handled = true
+		b.files[file] = f
+	}
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"parserr/helpers"
+)
+
+const (
+	// EnvBlacklistFailed Blacklist the release of the items that can't be fixed
+	EnvBlacklistFailed = "PARSERR_BLACKLIST_FAILED"
+	// EnvBlacklistKeepInClient Leave the downloads of blacklisted releases in
+	// the download client, so they keep seeding
+	EnvBlacklistKeepInClient = "PARSERR_BLACKLIST_KEEP_IN_CLIENT"
+	// EnvUnmonitorAfter Unmonitor the media once this many releases have been
+	// blacklisted, 0 disables it
+	EnvUnmonitorAfter = "PARSERR_UNMONITOR_AFTER"
+	// EnvUnmonitorScope What to unmonitor for shows, episode or season
+	EnvUnmonitorScope = "PARSERR_UNMONITOR_SCOPE"
+	// ItemBlacklisted The release of the item has been blacklisted
+	ItemBlacklisted = "blacklisted"
+	// UnmonitorEpisode ...
+	UnmonitorEpisode = "episode"
+	// UnmonitorSeason ...
+	UnmonitorSeason = "season"
+	// ReasonParseFailure The name of the release was parsed wrong
+	ReasonParseFailure = "parse-failure"
+	// ReasonCorruptFile The file of the release is broken or not what it
+	// claims to be
+	ReasonCorruptFile = "corrupt-file"
+	// ReasonWrongLanguage The release doesn't have the wanted languages
+	ReasonWrongLanguage = "wrong-language"
+	// ReasonPlaceholder The file of the release is empty, left by a failed
+	// transfer or a stub of a cloud mount
+	ReasonPlaceholder = "placeholder-file"
+	// ReasonFixFailed The release couldn't be fixed for any other reason
+	ReasonFixFailed = "fix-failed"
+)
+
+// RejectedError Returned when the release of the media is at fault, telling
+// why it was rejected
+type RejectedError struct {
+	// Reason Kind of problem, like ReasonParseFailure
+	Reason string
+	Err    error
+}
+
+func (e RejectedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap Return the error the release was rejected for
+func (e RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// asRejected Return the RejectedError of err or of any error it wraps
+func asRejected(err error) (RejectedError, bool) {
+	for ; err != nil; err = helpers.Unwrap(err) {
+		if rejected, ok := err.(RejectedError); ok {
+			return rejected, true
+		}
+	}
+	return RejectedError{}, false
+}
+
+// temporary Return true if the error, or the first error it wraps telling
+// it, may not happen again, like the instance being busy
+func temporary(err error) bool {
+	for ; err != nil; err = helpers.Unwrap(err) {
+		if t, ok := err.(interface{ Temporary() bool }); ok {
+			return t.Temporary()
+		}
+	}
+	return false
+}
+
+// rejectionReason Return the reason of the error, ReasonFixFailed if it
+// doesn't tell
+func rejectionReason(err error) string {
+	if rejected, ok := asRejected(err); ok && rejected.Reason != "" {
+		return rejected.Reason
+	}
+	return ReasonFixFailed
+}
+
+// BlacklistStrategy Blacklist the releases rejected with a RejectedError,
+// the ones at fault. Other failures, like the instance or the filesystem
+// being unavailable or the safety level refusing a fix, say nothing about
+// the release and leave it alone. Once the media has had too many releases
+// blacklisted it's considered dead and it's unmonitored, stopping the
+// endless failure loop.
+type BlacklistStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	// KeepInClient Leave the download in the download client instead of
+	// removing it with the release
+	KeepInClient bool
+	// State Keeps how many releases have been blacklisted per media,
+	// media are never unmonitored without it
+	State *State
+	// UnmonitorAfter Blacklisted releases needed to unmonitor, 0 disables it
+	UnmonitorAfter int
+	// UnmonitorScope UnmonitorEpisode or UnmonitorSeason
+	UnmonitorScope string
+	Notifier       Notifier
+	// Audit Where the reason of every blacklisted release is recorded, the
+	// *arr apis can't keep it, the standard logger if nil
+	Audit *AuditLog
+}
+
+// Fix ...
+func (s BlacklistStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(QuarantinedError); ok {
+		return err
+	}
+	// Timeouts are caused by the filesystem, not by the release
+	if _, ok := err.(TimedOutError); ok {
+		return err
+	}
+	if _, ok := err.(SelfHealedError); ok {
+		return err
+	}
+	// The file is already in the library
+	if _, ok := err.(UnregisteredError); ok {
+		return err
+	}
+	if _, ok := asRejected(err); !ok || temporary(err) {
+		helpers.Logf(ctx, "not blacklisting %s, the release isn't at fault: %s", m.QueueElem.Title, err)
+		return err
+	}
+	// The release of a download shared with other media is blacklisted once,
+	// by its last media and only if none of them could be fixed
+	u := unitOf(ctx)
+	if u != nil && (!u.last() || u.Fixed() > 0) {
+		helpers.Logf(ctx, "not blacklisting %s, its download has other media", m.QueueElem.Title)
+		return err
+	}
+	// A track fixed on its own says nothing about the rest of the album,
+	// blacklisting it would remove the whole album from the queue
+	if u == nil && m.Type == api.TypeMusic {
+		helpers.Logf(ctx, "not blacklisting %s, the rest of its album isn't being fixed", m.QueueElem.Title)
+		return err
+	}
+	blErr := s.API.DeleteQueueItem(ctx, m.QueueElem.ID, api.DeleteQueueOptions{Blocklist: true, KeepInClient: s.KeepInClient})
+	if blErr != nil {
+		helpers.Logf(ctx, "cannot blacklist %s: %s", m.QueueElem.Title, blErr)
+		return err
+	}
+	reason := rejectionReason(err)
+	helpers.Logf(ctx, "release blacklisted (%s): %s", reason, m.QueueElem.Title)
+	auditErr := s.Audit.Record(AuditEntry{
+		Action:     ItemBlacklisted,
+		Instance:   m.Instance,
+		Title:      m.QueueElem.Title,
+		DownloadID: m.QueueElem.DownloadID,
+		Reason:     reason,
+		Message:    err.Error(),
+	})
+	if auditErr != nil {
+		helpers.Logf(ctx, "cannot record why %s was blacklisted: %s", m.QueueElem.Title, auditErr)
+	}
+	if s.State == nil || s.UnmonitorAfter <= 0 {
+		return err
+	}
+	key := blacklistKey(m)
+	item, _ := s.State.Get(key)
+	item.Attempts++
+	saveErr := s.State.SetAttempts(key, ItemBlacklisted, item.Attempts)
+	if saveErr != nil {
+		helpers.Logf(ctx, "cannot save state of %s: %s", m.QueueElem.Title, saveErr)
+	}
+	if item.Attempts < s.UnmonitorAfter {
+		return err
+	}
+	what, unErr := s.unmonitor(ctx, m)
+	if unErr != nil {
+		helpers.Logf(ctx, "cannot unmonitor %s: %s", m.QueueElem.Title, unErr)
+		return err
+	}
+	s.Notifier.Notify("unmonitored "+what, fmt.Sprintf("%s had %d releases blacklisted, it won't be searched anymore",
+		m.QueueElem.Title, item.Attempts))
+	return err
+}
+
+func (s BlacklistStrategy) unmonitor(ctx context.Context, m *api.Media) (what string, err error) {
+	qe := m.QueueElem
+	if m.Type == api.TypeMovie {
+		return "movie", s.API.SetMovieMonitored(ctx, qe.Movie.ID, false)
+	}
+	if m.Type == api.TypeMusic {
+		return "album", s.API.SetAlbumMonitored(ctx, qe.Album.ID, false)
+	}
+	if s.UnmonitorScope == UnmonitorSeason {
+		return "season", s.API.SetSeasonMonitored(ctx, qe.Series.ID, qe.Episode.SeasonNumber, false)
+	}
+	return "episode", s.API.SetEpisodeMonitored(ctx, qe.Episode.ID, false)
+}
+
+// blacklistKey Identify the media regardless of the release
+func blacklistKey(m *api.Media) string {
+	id := m.QueueElem.Episode.ID
+	if m.Type == api.TypeMovie {
+		id = m.QueueElem.Movie.ID
+	}
+	if m.Type == api.TypeMusic {
+		id = m.QueueElem.Album.ID
+	}
+	return fmt.Sprintf("%s:%s:%s:%d", ItemBlacklisted, m.Instance, m.Type, id)
+}
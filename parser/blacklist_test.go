@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"parserr/api"
+	"testing"
+)
+
+// failingStrategy Strategy failing with its error
+type failingStrategy struct{ err error }
+
+func (s failingStrategy) Fix(ctx context.Context, m *api.Media) error {
+	return s.err
+}
+
+// queueAPI API recording the queue items deleted, any other call panics
+type queueAPI struct {
+	api.RRAPI
+	deleted []int
+}
+
+func (a *queueAPI) DeleteQueueItem(ctx context.Context, id int, opts api.DeleteQueueOptions) error {
+	a.deleted = append(a.deleted, id)
+	return nil
+}
+
+func TestBlacklistOnlyRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		blacklist bool
+	}{
+		{"rejected", RejectedError{Reason: ReasonPlaceholder, Err: errors.New("empty")}, true},
+		{"instance busy", api.APIError{StatusCode: http.StatusServiceUnavailable}, false},
+		{"strict mode", errors.New("confidence of a.mkv is 0.40, strict mode requires 0.90"), false},
+		{"timed out", TimedOutError{}, false},
+		{"quarantined", QuarantinedError{}, false},
+		{"not registered", UnregisteredError{Name: "Show", Path: "/tv/Show/a.mkv"}, false},
+		{"wrapped rejection", wrappedError{RejectedError{Reason: ReasonCorruptFile, Err: errors.New("broken")}}, true},
+		{"rejected while busy", RejectedError{Err: api.APIError{StatusCode: http.StatusServiceUnavailable}}, false},
+	}
+	for _, tt := range tests {
+		a := &queueAPI{}
+		s := BlacklistStrategy{Strategy: failingStrategy{tt.err}, API: a}
+		m := &api.Media{}
+		m.QueueElem.ID = 7
+		if err := s.Fix(context.Background(), m); err == nil {
+			t.Errorf("%s: the error was lost", tt.name)
+		}
+		if got := len(a.deleted) > 0; got != tt.blacklist {
+			t.Errorf("%s: blacklisted %v, want %v", tt.name, got, tt.blacklist)
+		}
+	}
+}
+
+func TestBlacklistAlbumOnce(t *testing.T) {
+	a := &queueAPI{}
+	s := BlacklistStrategy{Strategy: failingStrategy{RejectedError{Reason: ReasonCorruptFile, Err: errors.New("broken")}}, API: a}
+	var tracks []*api.Media
+	for i := 0; i < 3; i++ {
+		m := &api.Media{Type: api.TypeMusic}
+		m.QueueElem.ID = 7
+		tracks = append(tracks, m)
+	}
+	s.Fix(context.Background(), tracks[0])
+	if len(a.deleted) != 0 {
+		t.Fatal("the album was blacklisted by a track fixed on its own")
+	}
+	FixMedia(context.Background(), tracks, s, &Report{})
+	if len(a.deleted) != 1 {
+		t.Errorf("album blacklisted %d times, want once", len(a.deleted))
+	}
+}
+
+// wrappedError Error wrapping another one
+type wrappedError struct{ err error }
+
+func (e wrappedError) Error() string { return "wrapped: " + e.err.Error() }
+func (e wrappedError) Unwrap() error { return e.err }
+
+func TestRejectionReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{RejectedError{Reason: ReasonWrongLanguage, Err: errors.New("dubbed")}, ReasonWrongLanguage},
+		{wrappedError{RejectedError{Reason: ReasonParseFailure, Err: errors.New("S01E99")}}, ReasonParseFailure},
+		{RejectedError{Err: errors.New("broken")}, ReasonFixFailed},
+		{errors.New("broken"), ReasonFixFailed},
+	}
+	for _, tt := range tests {
+		if got := rejectionReason(tt.err); got != tt.want {
+			t.Errorf("rejectionReason(%q) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
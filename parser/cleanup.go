@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"parserr/client"
+	"parserr/helpers"
+)
+
+// ClientCleanupStrategy Remove the download from the download client history
+// once the media has been imported, so the *arr doesn't warn about it again.
+// The import of the download itself is confirmed, the media of an upgrade
+// already has a file before it.
+type ClientCleanupStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Client   client.Client
+}
+
+// Fix ...
+func (s ClientCleanupStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil {
+		return err
+	}
+	if u := unitOf(ctx); u != nil && !u.complete() {
+		helpers.Logf(ctx, "keeping %s in %s until the rest of its download is fixed", m.QueueElem.Title, s.Client.GetType())
+		return nil
+	}
+	if !downloadImported(ctx, s.API, m) {
+		helpers.Logf(ctx, "keeping %s in %s, it hasn't been imported yet", m.QueueElem.Title, s.Client.GetType())
+		return nil
+	}
+	cleanErr := s.Client.RemoveFromHistory(m.QueueElem.DownloadID)
+	if cleanErr != nil {
+		helpers.Logf(ctx, "cannot remove %s from %s: %s", m.QueueElem.Title, s.Client.GetType(), cleanErr)
+		return nil
+	}
+	publishMedia(EventItemCleaned, m, nil)
+	return nil
+}
+
+// downloadImported Return true if the instance imported the download of the
+// media: there is an import record of the download for the media, or its
+// queue item is gone. The instance is asked, cached responses would be as
+// old as the queue being fixed.
+func downloadImported(ctx context.Context, a api.RRAPI, m *api.Media) bool {
+	current := api.Uncached(ctx)
+	qe := m.QueueElem
+	if qe.DownloadID != "" {
+		opts := api.HistoryOptions{EventType: api.HistoryEventDownloadFolderImported, DownloadID: qe.DownloadID}
+		history, err := a.GetHistoryFiltered(current, 1, opts)
+		if err != nil {
+			helpers.Logf(ctx, "cannot check if %s has been imported: %s", qe.Title, err)
+		}
+		for _, hr := range history.Records {
+			if hr.DownloadID == qe.DownloadID && importOf(hr, m) {
+				return true
+			}
+		}
+	}
+	queue, err := a.GetQueue(current)
+	if err != nil {
+		helpers.Logf(ctx, "cannot check if %s has been imported: %s", qe.Title, err)
+		return false
+	}
+	for _, queued := range queue {
+		if queued.ID == qe.ID || (qe.DownloadID != "" && queued.DownloadID == qe.DownloadID) {
+			return false
+		}
+	}
+	return true
+}
+
+// importOf Return true if the import record is of the media, records
+// without the id of the episode or movie are of any media of the download
+func importOf(hr api.HistoryRec, m *api.Media) bool {
+	switch m.Type {
+	case api.TypeShow:
+		return hr.Episode.ID == 0 || hr.Episode.ID == m.QueueElem.Episode.ID
+	case api.TypeMovie:
+		return hr.Movie.ID == 0 || hr.Movie.ID == m.QueueElem.Movie.ID
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"testing"
+)
+
+// importingAPI API whose episode has a file, with the import records and
+// the queue given
+type importingAPI struct {
+	api.RRAPI
+	imports []api.HistoryRec
+	queue   []api.QueueElem
+}
+
+func (a importingAPI) GetEpisode(ctx context.Context, id int) (api.Episode, error) {
+	return api.Episode{ID: id, HasFile: true}, nil
+}
+
+func (a importingAPI) GetHistoryFiltered(ctx context.Context, page int, opts api.HistoryOptions) (api.History, error) {
+	return api.History{Page: page, PageSize: 50, Records: a.imports}, nil
+}
+
+func (a importingAPI) GetQueue(ctx context.Context) ([]api.QueueElem, error) {
+	return a.queue, nil
+}
+
+// removingClient Download client recording the downloads removed
+type removingClient struct {
+	removed []string
+}
+
+func (c *removingClient) GetType() string { return "removing" }
+
+func (c *removingClient) RemoveFromHistory(downloadID string) error {
+	c.removed = append(c.removed, downloadID)
+	return nil
+}
+
+func (c *removingClient) SeedingDone(downloadID string) (bool, error) {
+	return true, nil
+}
+
+func TestClientCleanupUpgrade(t *testing.T) {
+	queued := api.QueueElem{ID: 7, DownloadID: "A"}
+	queued.Episode.ID = 3
+	imported := api.HistoryRec{DownloadID: "A", EventType: api.HistoryEventDownloadFolderImported}
+	imported.Episode.ID = 3
+	sibling := imported
+	sibling.Episode.ID = 4
+	tests := []struct {
+		name    string
+		a       importingAPI
+		removed bool
+	}{
+		// the episode has the file the download upgrades
+		{"not imported", importingAPI{queue: []api.QueueElem{queued}}, false},
+		{"other episode imported", importingAPI{imports: []api.HistoryRec{sibling}, queue: []api.QueueElem{queued}}, false},
+		{"imported", importingAPI{imports: []api.HistoryRec{imported}, queue: []api.QueueElem{queued}}, true},
+		{"out of the queue", importingAPI{}, true},
+	}
+	for _, tt := range tests {
+		c := &removingClient{}
+		s := ClientCleanupStrategy{Strategy: loggingStrategy{}, API: tt.a, Client: c}
+		m := &api.Media{Type: api.TypeShow, QueueElem: queued}
+		if err := s.Fix(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(c.removed) > 0; got != tt.removed {
+			t.Errorf("%s: removed from the client %v, want %v", tt.name, got, tt.removed)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"time"
+)
+
+const (
+	// EnvMaxRuntime Max time a run can take, once reached the item being
+	// fixed is finished and the rest are left for the next run
+	EnvMaxRuntime = "PARSERR_MAX_RUNTIME"
+	// ItemPending The item hasn't been fixed because the run deadline was
+	// reached, it will be on the next run
+	ItemPending = "pending"
+)
+
+// DeadlineError Returned for the media left for the next run
+type DeadlineError struct {
+	Name     string
+	Deadline time.Time
+}
+
+func (e DeadlineError) Error() string {
+	return fmt.Sprintf("run deadline of %s reached, %s left for the next run", e.Deadline.Format("15:04:05"), e.Name)
+}
+
+type deadlineKey struct{}
+
+// runDeadline Deadline of a run and the clock telling when it's reached
+type runDeadline struct {
+	Time  time.Time
+	Clock api.Clock
+}
+
+// WithRunDeadline Return a context telling the run to wrap up at the
+// deadline. Unlike a context deadline nothing in flight is cancelled, the
+// item being fixed is finished and only the ones after it are skipped.
+func WithRunDeadline(ctx context.Context, deadline time.Time, clock api.Clock) context.Context {
+	return context.WithValue(ctx, deadlineKey{}, runDeadline{Time: deadline, Clock: clock})
+}
+
+// DeadlineReached Return the error of the media if the deadline of the run
+// has been reached, nil otherwise or if the run has no deadline
+func DeadlineReached(ctx context.Context, name string) error {
+	d, ok := ctx.Value(deadlineKey{}).(runDeadline)
+	if !ok || api.ClockOrReal(d.Clock).Now().Before(d.Time) {
+		return nil
+	}
+	return DeadlineError{Name: name, Deadline: d.Time}
+}
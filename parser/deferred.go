@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"parserr/api"
+	"parserr/client"
+	"parserr/helpers"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvDeferredClean Verify and clean fixed media on the next run instead
+	// of right after fixing it, for every instance without its own setting
+	EnvDeferredClean = "PARSERR_DEFERRED_CLEAN"
+	// EnvSonarrDeferredClean EnvDeferredClean for sonarr
+	EnvSonarrDeferredClean = "SONARR_DEFERRED_CLEAN"
+	// EnvRadarrDeferredClean EnvDeferredClean for radarr
+	EnvRadarrDeferredClean = "RADARR_DEFERRED_CLEAN"
+	// EnvLidarrDeferredClean EnvDeferredClean for lidarr
+	EnvLidarrDeferredClean = "LIDARR_DEFERRED_CLEAN"
+	// EnvCleanSettle Min time between fixing a media and verifying it, so the
+	// *arr has time to import it
+	EnvCleanSettle = "PARSERR_CLEAN_SETTLE"
+	// EnvCleanAttempts Runs a fixed media is verified before giving up on it
+	EnvCleanAttempts = "PARSERR_CLEAN_ATTEMPTS"
+	// DefaultCleanAttempts ...
+	DefaultCleanAttempts = 3
+	// ItemAwaitingClean The item has been fixed and is waiting to be verified
+	// and cleaned on the next run
+	ItemAwaitingClean = "awaiting-clean"
+	// ItemCleaned The item has been verified as imported and cleaned
+	ItemCleaned = "cleaned"
+)
+
+// DeferredCleanStrategy Record the fixed media in the state so it's verified
+// and cleaned by a Cleaner on the next run, once the *arr has imported it
+type DeferredCleanStrategy struct {
+	Strategy FixStrategy
+	State    *State
+}
+
+// Fix ...
+func (s DeferredCleanStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil {
+		return err
+	}
+	err = s.State.Put(cleanKey(m), ItemState{
+		Status:     ItemAwaitingClean,
+		Location:   m.FileLocFinal,
+		DownloadID: m.QueueElem.DownloadID,
+		Title:      m.QueueElem.Title,
+		MediaType:  m.Type,
+		MediaID:    mediaID(m),
+	})
+	if err != nil {
+		helpers.Logf(ctx, "cannot save state of %s, it won't be cleaned: %s", m.QueueElem.Title, err)
+	}
+	return nil
+}
+
+// Cleaner Second phase of the deferred clean, verify the media fixed by
+// previous runs have been imported and remove their downloads from the
+// client. Media not imported after MaxAttempts runs are marked as failed so
+// they are fixed again.
+type Cleaner struct {
+	API   api.RRAPI
+	State *State
+	// Client Download client the downloads are removed from, none if nil
+	Client client.Client
+	// Settle Min time since the media was fixed to verify it
+	Settle time.Duration
+	// MaxAttempts DefaultCleanAttempts if 0
+	MaxAttempts int
+	// Clock Used to know how long ago the media was fixed, RealClock if nil
+	Clock api.Clock
+}
+
+// Clean Verify and clean the media of the api waiting for it, recording
+// the result in the report. A download shared by several media is removed
+// once all of them have been imported.
+func (c Cleaner) Clean(ctx context.Context, r *Report) {
+	maxAttempts := c.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultCleanAttempts
+	}
+	now := api.ClockOrReal(c.Clock).Now()
+	prefix := ItemAwaitingClean + ":" + c.API.GetName() + ":"
+	// waiting Downloads with media not imported yet, the imported ones wait
+	// for them to be cleaned together
+	waiting := make(map[string]bool)
+	imported := make(map[string]ItemState)
+	for key, item := range c.State.WithStatus(ItemAwaitingClean) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if now.Sub(item.Updated) < c.Settle {
+			waiting[item.DownloadID] = true
+			continue
+		}
+		itemCtx := helpers.WithLogTag(ctx, item.logContext())
+		m := item.media(c.API.GetName())
+		if m.HasBeenDetected(itemCtx, c.API) {
+			imported[key] = item
+			continue
+		}
+		waiting[item.DownloadID] = true
+		item.Attempts++
+		if item.Attempts < maxAttempts {
+			helpers.Logf(itemCtx, "%s not imported yet, verifying it again on the next run", item.Title)
+			c.save(key, item)
+			continue
+		}
+		err := fmt.Errorf("not imported after %d runs", item.Attempts)
+		helpers.Logf(itemCtx, "%s %s, fixing it again", item.Title, err)
+		if setErr := c.State.Set(strings.TrimPrefix(key, ItemAwaitingClean+":"), ItemFailed, item.Location); setErr != nil {
+			helpers.Logf(itemCtx, "cannot save state of %s: %s", item.Title, setErr)
+		}
+		c.forget(key)
+		r.Add(item.Title, ItemFailed, err)
+		Events.Publish(Event{Type: EventItemFailed, Instance: c.API.GetName(), Title: item.Title, Err: err})
+	}
+	removed := make(map[string]error)
+	for key, item := range imported {
+		if item.DownloadID != "" && waiting[item.DownloadID] {
+			helpers.Tagf(item.logContext(), "%s imported, waiting for the rest of its download to clean it", item.Title)
+			continue
+		}
+		err := c.clean(key, item, removed)
+		if err != nil {
+			r.Add(item.Title, ItemFailed, err)
+			continue
+		}
+		r.Add(item.Title, ItemCleaned, nil)
+		Events.Publish(Event{Type: EventItemCleaned, Instance: c.API.GetName(), Title: item.Title})
+	}
+}
+
+// clean Remove the download of the imported media from the client, once
+// for all the media of the download, it's tried again on the next run if it
+// fails
+func (c Cleaner) clean(key string, item ItemState, removed map[string]error) error {
+	if c.Client != nil && item.DownloadID != "" {
+		err, done := removed[item.DownloadID]
+		if !done {
+			err = c.Client.RemoveFromHistory(item.DownloadID)
+			removed[item.DownloadID] = err
+		}
+		if err != nil {
+			return fmt.Errorf("cannot remove from %s: %s", c.Client.GetType(), err)
+		}
+	}
+	helpers.Tagf(item.logContext(), "%s imported, cleaned", item.Title)
+	c.forget(key)
+	return nil
+}
+
+func (c Cleaner) save(key string, item ItemState) {
+	if err := c.State.Put(key, item); err != nil {
+		helpers.Tagf(item.logContext(), "cannot save state of %s: %s", item.Title, err)
+	}
+}
+
+func (c Cleaner) forget(key string) {
+	if err := c.State.Delete(key); err != nil {
+		log.Printf("cannot save state: %s", err)
+	}
+}
+
+// media Return the media of the item, with what's needed to know if the
+// *arr has imported it
+func (item ItemState) media(instance string) *api.Media {
+	m := &api.Media{Instance: instance, Type: item.MediaType}
+	m.QueueElem.Title = item.Title
+	switch item.MediaType {
+	case api.TypeMovie:
+		m.QueueElem.Movie.ID = item.MediaID
+	case api.TypeMusic:
+		m.QueueElem.Album.ID = item.MediaID
+	default:
+		m.QueueElem.Episode.ID = item.MediaID
+	}
+	return m
+}
+
+// mediaID Return the id of the episode, movie or album of the media
+func mediaID(m *api.Media) int {
+	switch m.Type {
+	case api.TypeMovie:
+		return m.QueueElem.Movie.ID
+	case api.TypeMusic:
+		return m.QueueElem.Album.ID
+	}
+	return m.QueueElem.Episode.ID
+}
+
+// cleanKey Identify a media waiting to be cleaned in the state
+func cleanKey(m *api.Media) string {
+	return fmt.Sprintf("%s:%s", ItemAwaitingClean, MediaKey(m))
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"parserr/helpers"
+)
+
+// EpisodeCheckStrategy Refuse to fix episodes whose original or final name
+// has season and episode numbers that don't exist in the series, like
+// S05E27 of a season with 22 episodes, the name was parsed wrong and the
+// file would be renamed after the wrong episode
+type EpisodeCheckStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Cache    *RunCache
+}
+
+// Fix Check the numbers of the names against the episodes of the series,
+// scene numbers for series using them, and fix the media if they exist. The
+// media is fixed if the episodes can't be fetched.
+func (s EpisodeCheckStrategy) Fix(ctx context.Context, m *api.Media) error {
+	seriesID := m.QueueElem.Series.ID
+	if m.Type != api.TypeShow || seriesID == 0 {
+		return s.Strategy.Fix(ctx, m)
+	}
+	episodes, err := s.Cache.Episodes(ctx, s.API, seriesID)
+	if err != nil || len(episodes) == 0 {
+		helpers.Logf(ctx, "cannot check episode numbers of %s: %v", m.FilenameOri, err)
+		return s.Strategy.Fix(ctx, m)
+	}
+	series := m.QueueElem.Series.Title
+	if series == "" {
+		series = "the series"
+	}
+	for _, name := range []string{m.FilenameOri, m.FilenameFinal} {
+		season, episode, ok := api.EpisodeNumbers(name)
+		if !ok {
+			continue
+		}
+		if _, found := api.EpisodeByNumbers(episodes, m.QueueElem.Series, season, episode); !found {
+			return RejectedError{Reason: ReasonParseFailure, Err: fmt.Errorf("%s is S%.2dE%.2d, which %s doesn't have, its name was parsed wrong",
+				name, season, episode, series)}
+		}
+	}
+	return s.Strategy.Fix(ctx, m)
+}
+
+// AbsoluteStrategy Convert the absolute number of anime files, like
+// "Show - 123", to the season and episode it is in the series, so packs
+// numbered from the first episode are placed in the right season
+type AbsoluteStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Cache    *RunCache
+}
+
+// Fix Rename the media after the season and episode of its absolute number
+// and fix it. Media without absolute number, or whose number can't be found
+// in the series, are fixed as they are.
+func (s AbsoluteStrategy) Fix(ctx context.Context, m *api.Media) error {
+	absolute, ok := api.AbsoluteNumber(m.FilenameOri)
+	seriesID := m.QueueElem.Series.ID
+	if m.Type != api.TypeShow || !ok || seriesID == 0 {
+		return s.Strategy.Fix(ctx, m)
+	}
+	episodes, err := s.Cache.Episodes(ctx, s.API, seriesID)
+	if err != nil {
+		helpers.Logf(ctx, "cannot convert absolute number of %s: %s", m.FilenameOri, err)
+		return s.Strategy.Fix(ctx, m)
+	}
+	episode, ok := api.EpisodeByAbsolute(episodes, absolute)
+	if !ok {
+		helpers.Logf(ctx, "cannot convert absolute number of %s: episode %d not found", m.FilenameOri, absolute)
+		return s.Strategy.Fix(ctx, m)
+	}
+	final, _ := api.WithSeasonEpisode(m.FilenameOri, m.QueueElem.Series, episode)
+	if episode.ID != m.QueueElem.Episode.ID {
+		helpers.Logf(ctx, "absolute episode %d of %s is S%.2dE%.2d, fixing it as that episode instead of S%.2dE%.2d of the queue",
+			absolute, m.FilenameOri, episode.SeasonNumber, episode.EpisodeNumber,
+			m.QueueElem.Episode.SeasonNumber, m.QueueElem.Episode.EpisodeNumber)
+		m.QueueElem.Episode = episode
+	}
+	if name := final + m.FileExtension; name != m.FilenameFinal {
+		helpers.Logf(ctx, "renaming %s after its absolute episode %d: %s instead of %s", m.FilenameOri, absolute, name, m.FilenameFinal)
+		m.FilenameFinal = name
+	}
+	return s.Strategy.Fix(ctx, m)
+}
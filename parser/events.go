@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"parserr/api"
+	"sync"
+	"time"
+)
+
+// EventType ...
+type EventType string
+
+const (
+	// EventItemDetected A failed download has been found in the queue
+	EventItemDetected EventType = "item-detected"
+	// EventFileMoved The file of a media has been moved or renamed
+	EventFileMoved EventType = "file-moved"
+	// EventItemFixed A media has been fixed without errors
+	EventItemFixed EventType = "item-fixed"
+	// EventItemCleaned The download of an imported media has been cleaned
+	EventItemCleaned EventType = "item-cleaned"
+	// EventItemFailed A media couldn't be fixed or imported
+	EventItemFailed EventType = "item-failed"
+)
+
+// Event Something parserr did, for embedders building their own interfaces
+// or notifications
+type Event struct {
+	Type EventType
+	// Instance Name of the api of the media
+	Instance string
+	Title    string
+	// From Path of the file before being moved
+	From string
+	// To Path of the file after being moved
+	To string
+	// Err Why the media failed
+	Err  error
+	Time time.Time
+}
+
+// Bus Deliver events to its subscribers, synchronously and in the order they
+// subscribed, so subscribers must return quickly. Safe for concurrent use.
+type Bus struct {
+	mu          sync.Mutex
+	next        int
+	subscribers map[int]func(Event)
+	order       []int
+	// Clock Used to date the events, RealClock if nil
+	Clock api.Clock
+}
+
+// Events Bus every event of the package is published to
+var Events = &Bus{}
+
+// Subscribe Call fn with every event published to Events until the returned
+// function is called
+func Subscribe(fn func(Event)) (unsubscribe func()) {
+	return Events.Subscribe(fn)
+}
+
+// Subscribe Call fn with every event published to the bus until the returned
+// function is called
+func (b *Bus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[int]func(Event))
+	}
+	id := b.next
+	b.next++
+	b.subscribers[id] = fn
+	b.order = append(b.order, id)
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+		for i, o := range b.order {
+			if o == id {
+				b.order = append(b.order[:i:i], b.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish Date the event and deliver it to every subscriber
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = api.ClockOrReal(b.Clock).Now()
+	}
+	b.mu.Lock()
+	subscribers := make([]func(Event), 0, len(b.order))
+	for _, id := range b.order {
+		subscribers = append(subscribers, b.subscribers[id])
+	}
+	b.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
+
+// publishMedia Publish an event of the media to Events
+func publishMedia(t EventType, m *api.Media, err error) {
+	e := Event{Type: t, Instance: m.Instance, Title: m.QueueElem.Title, Err: err}
+	if t == EventFileMoved {
+		e.From, e.To = m.FileLocOri, m.FileLocFinal
+	}
+	Events.Publish(e)
+}
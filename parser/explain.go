@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"parserr/client"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Explanation Decisions a run takes about a queue item and why
+type Explanation struct {
+	Steps []string
+	// Media Media a run would fix for the item, none if it would skip it
+	Media []*api.Media
+}
+
+func (e *Explanation) add(format string, args ...interface{}) {
+	e.Steps = append(e.Steps, fmt.Sprintf(format, args...))
+}
+
+func (e Explanation) String() string {
+	return strings.Join(e.Steps, "\n")
+}
+
+// Explain Walk through how FailedMediaIndexed turns the queue element with
+// the id into media: whether it's failed, the history record it's matched
+// with and the file guessed for it, without changing anything. The history
+// is matched for the whole queue like in a run, so the element gets the same
+// record, and the decisions about it are the ones a run logs. found is false
+// if the queue of the instance has no element with the id.
+func Explain(ctx context.Context, a api.RRAPI, queueID int, files client.FileLister, index *HistoryIndex) (e Explanation, found bool, err error) {
+	queue, err := a.GetQueue(ctx)
+	if err != nil {
+		return
+	}
+	var qe api.QueueElem
+	for _, elem := range queue {
+		if elem.ID == queueID {
+			qe, found = elem, true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	e.add("queue item %d of %s: %s", qe.ID, a.GetName(), qe.Title)
+	e.add("status %s, tracked download status %s, download id %s", qe.Status, qe.TrackedDownloadStatus, qe.DownloadID)
+	for _, message := range qe.StatusMessages {
+		e.add("status message: %s", message.Title)
+	}
+	if isNotCompletedOrFailed(qe) {
+		e.add("not fixed: only completed downloads in warning are")
+		return
+	}
+	decide := func(elem api.QueueElem, format string, v ...interface{}) {
+		if elem.ID == qe.ID {
+			e.add(format, v...)
+		}
+	}
+	// only the download of the element is turned into media, its other
+	// elements decide whether it's complete
+	unit := matchHistory(ctx, a, queue, index, decide).only(func(elem api.QueueElem) bool {
+		return elem.ID == qe.ID || qe.DownloadID != "" && elem.DownloadID == qe.DownloadID
+	})
+	for i, elem := range unit.pending {
+		if elem.ID != qe.ID {
+			continue
+		}
+		hr, matched := unit.matches[i]
+		if !matched {
+			break
+		}
+		if grabbed, ok := unit.grabs[i]; ok {
+			e.add("grabbed on %s", grabbed.Format(time.RFC3339))
+		}
+		if warning, ok := unit.warnings[i]; ok {
+			e.add("in warning since %s", warning.Format(time.RFC3339))
+		}
+		if a.GetType() != api.TypeMusic {
+			e.explainCandidates(a, hr, qe, files)
+		}
+	}
+	for _, m := range withDownloadSizes(unit.media(a, files, decide), queue) {
+		if m.QueueElem.ID == qe.ID {
+			e.Media = append(e.Media, m)
+		}
+	}
+	if len(e.Media) == 0 {
+		e.add("not fixed: no media found for it")
+		return e, found, nil
+	}
+	for _, m := range e.Media {
+		e.add("file %s, with confidence %.2f, found in %s", m.FileLocOri, m.Confidence, m.DownloadFolder.Path)
+		e.add("renamed to %s", m.FilenameFinal)
+	}
+	return e, found, nil
+}
+
+// explainCandidates Tell which names could be the file of the element
+func (e *Explanation) explainCandidates(a api.RRAPI, hr api.HistoryRec, qe api.QueueElem, files client.FileLister) {
+	var names []string
+	source := "the status messages"
+	if files != nil {
+		downloadFiles, err := files.Files(qe.DownloadID)
+		if err != nil {
+			e.add("cannot get the files from the download client, guessing them: %s", err)
+		} else {
+			source = "the download client"
+			for _, f := range downloadFiles {
+				names = append(names, filepath.Base(f.Path))
+			}
+		}
+	}
+	if names == nil {
+		for _, message := range qe.StatusMessages {
+			names = append(names, message.Title)
+		}
+	}
+	if a.GetType() == api.TypeShow {
+		season, number := qe.Episode.ReleaseNumbers(qe.Series)
+		e.add("looking for season %d episode %d or absolute episode %d in the files from %s", season, number, qe.Episode.AbsoluteEpisodeNumber, source)
+	} else {
+		e.add("looking for video files from %s", source)
+	}
+	m := api.Media{Type: a.GetType(), HistoryRec: hr, QueueElem: qe}
+	candidates, _ := m.FilenameCandidates(names)
+	for _, name := range names {
+		if containsString(candidates, name) {
+			e.add("candidate: %s", name)
+		} else {
+			e.add("discarded: %s", name)
+		}
+	}
+}
+
+// StrategyChain Return the names of the strategy and every strategy it
+// wraps, from the outermost, following the strategy of the download folder
+// of the media where strategies depend on it
+func StrategyChain(s FixStrategy, m *api.Media) (names []string) {
+	for s != nil {
+		v := reflect.ValueOf(s)
+		for v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		names = append(names, v.Type().Name())
+		if v.Kind() != reflect.Struct {
+			return
+		}
+		if folder, ok := v.Interface().(FolderStrategy); ok {
+			s = folder.For(m)
+			continue
+		}
+		field := v.FieldByName("Strategy")
+		if !field.IsValid() || field.Kind() != reflect.Interface || field.IsNil() {
+			return
+		}
+		s, _ = field.Interface().(FixStrategy)
+	}
+	return
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
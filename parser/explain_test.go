@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// explainAPI Sonarr with a queue and a single page of history
+type explainAPI struct {
+	historyAPI
+	queue []api.QueueElem
+}
+
+func (a explainAPI) GetQueue(ctx context.Context) ([]api.QueueElem, error) { return a.queue, nil }
+func (a explainAPI) GetName() string                                       { return "sonarr" }
+func (a explainAPI) GetType() string                                       { return api.TypeShow }
+func (a explainAPI) FiltersHistory() bool                                  { return false }
+
+func TestExplainMatchesLikeARun(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	var queue []api.QueueElem
+	for i, download := range []string{"A", "B"} {
+		qe := api.QueueElem{ID: i + 1, DownloadID: download, Title: "Show.S01E0" + strconv.Itoa(i+1)}
+		qe.Status, qe.TrackedDownloadStatus = api.StatusCompleted, api.TrackedDownloadStatusWarning
+		qe.Episode.SeasonNumber, qe.Episode.EpisodeNumber = 1, i+1
+		queue = append(queue, qe)
+	}
+	a := explainAPI{queue: queue, historyAPI: historyAPI{records: []api.HistoryRec{
+		record(3, "B", "downloadFailed", 2, now),
+		record(2, "A", api.HistoryEventGrabbed, 1, now.Add(-time.Minute)),
+		record(1, "B", api.HistoryEventGrabbed, 2, now.Add(-2*time.Minute)),
+	}}}
+	e, found, err := Explain(context.Background(), a, 2, nil, nil)
+	if err != nil || !found {
+		t.Fatalf("found %v, error %v", found, err)
+	}
+	for _, want := range []string{
+		"matched by download id with its downloadFailed record of " + now.Format(time.RFC3339),
+		"grabbed on " + now.Add(-2*time.Minute).Format(time.RFC3339),
+		"in warning since " + now.Format(time.RFC3339),
+		"cannot add failed media file",
+		"not fixed",
+	} {
+		if !strings.Contains(e.String(), want) {
+			t.Errorf("explanation doesn't tell %q:\n%s", want, e)
+		}
+	}
+	if strings.Contains(e.String(), queue[0].Title) {
+		t.Errorf("explanation tells about the other item:\n%s", e)
+	}
+}
+
+func TestStrategyChain(t *testing.T) {
+	folder := FolderStrategy{
+		Default:    MaintainPathStrategy{},
+		Strategies: map[string]FixStrategy{"/movies": TimeoutStrategy{Strategy: LibraryStrategy{}}},
+	}
+	s := LockStrategy{Strategy: folder}
+	tests := []struct {
+		folder string
+		want   []string
+	}{
+		{"/movies", []string{"LockStrategy", "FolderStrategy", "TimeoutStrategy", "LibraryStrategy"}},
+		{"/shows", []string{"LockStrategy", "FolderStrategy", "MaintainPathStrategy"}},
+	}
+	for _, tt := range tests {
+		m := &api.Media{}
+		m.DownloadFolder.Path = tt.folder
+		if got := StrategyChain(s, m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.folder, got, tt.want)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	m := &api.Media{FileLocOri: "/downloads/Show.S01E01.mkv", FilenameOri: "Show.S01E01.mkv", FilenameFinal: "Show - S01E01.mkv", FileExtension: ".mkv"}
+	m.QueueElem.Title = "Show.S01E01.mkv"
+	m.DownloadFolder.Path = "/downloads"
+	tests := []struct {
+		s    DestinationStrategy
+		want string
+	}{
+		{MaintainPathStrategy{}, "/downloads/Show.S01E01.mkv/Show - S01E01.mkv"},
+		{ForceImportStrategy{}, "/downloads/Show - S01E01/Show - S01E01.mkv"},
+	}
+	for _, tt := range tests {
+		if got, err := tt.s.Destination(context.Background(), m); err != nil || got != tt.want {
+			t.Errorf("%T: %s, %v, want %s", tt.s, got, err, tt.want)
+		}
+	}
+}
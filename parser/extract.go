@@ -10,8 +10,10 @@ import (
 	"github.com/mholt/archiver"
 )
 
-// ExtractAll search for compressed files and extract them in place
-func ExtractAll(rootDir string) error {
+// ExtractAll search for compressed files and extract them in place.
+// Compressed files are removed after extraction unless the safety level
+// forbids deleting files.
+func ExtractAll(rootDir string, s Safety) error {
 	log.Printf("searching for compressed files on: %s", rootDir)
 	var errors []string
 	var ar archiver.Archiver
@@ -31,6 +33,10 @@ func ExtractAll(rootDir string) error {
 			return
 		}
 		log.Printf("compressed file extracted to: %s", filepath.Dir(path))
+		if !s.AllowDelete() {
+			log.Printf("compressed file kept: %s", file.Name())
+			return nil
+		}
 		err = os.Remove(path)
 		if err != nil {
 			log.Printf("error removing rar: %s", err)
@@ -43,3 +49,27 @@ func ExtractAll(rootDir string) error {
 	}
 	return nil
 }
+
+// ExtractFolder ExtractAll holding the lock of the folder for the instance,
+// so instances sharing a download folder don't extract and remove the same
+// archives at the same time. Folders locked by another instance are skipped,
+// that one is extracting them.
+func ExtractFolder(folder, instance string, s Safety, locks *FileLocks) error {
+	if locks == nil {
+		return ExtractAll(folder, s)
+	}
+	owner, ok, err := locks.Lock(folder, instance)
+	if err != nil {
+		return fmt.Errorf("cannot lock %s: %s", folder, err)
+	}
+	if !ok {
+		log.Printf("skipping extraction on %s, being extracted by %s", folder, owner)
+		return nil
+	}
+	defer func() {
+		if err := locks.Unlock(folder, instance); err != nil {
+			log.Printf("cannot unlock %s: %s", folder, err)
+		}
+	}()
+	return ExtractAll(folder, s)
+}
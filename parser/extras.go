@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"path"
+	"path/filepath"
+)
+
+const (
+	// EnvExtras What to do with the featurettes, trailers and other extras
+	// of the downloads, ExtrasSkip or ExtrasLibrary
+	EnvExtras = "PARSERR_EXTRAS"
+	// ExtrasSkip Leave the extras in the download, they are never taken for
+	// the media
+	ExtrasSkip = "skip"
+	// ExtrasLibrary Move the extras to the extras folders of the series or
+	// movie once the media is fixed, where media servers find them
+	ExtrasLibrary = "library"
+)
+
+// ExtrasStrategy Move the extras of the release to the folders of the
+// series or movie in the library Plex and Jellyfin read them from, the
+// *arrs don't import them. Failing to move them doesn't make the fix fail.
+// Music is skipped.
+type ExtrasStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Mover    Mover
+}
+
+// Fix ...
+func (s ExtrasStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil || m.Type == api.TypeMusic {
+		return err
+	}
+	release := filepath.Dir(m.FileLocOri)
+	if filepath.Clean(release) == filepath.Clean(m.DownloadFolder.Path) {
+		// the file isn't in a folder of its own, whatever is next to it
+		// belongs to other downloads
+		return nil
+	}
+	extras := Extras(release)
+	if len(extras) == 0 {
+		return nil
+	}
+	id := m.QueueElem.Series.ID
+	if m.Type == api.TypeMovie {
+		id = m.QueueElem.Movie.ID
+	}
+	root, err := s.API.GetPath(ctx, id)
+	if err != nil || root == "" {
+		helpers.Logf(ctx, "cannot move the extras of %s, library folder unknown: %v", m.QueueElem.Title, err)
+		return nil
+	}
+	for file, folder := range extras {
+		dir := path.Join(root, folder)
+		if err := ensureDir(s.Mover, dir); err != nil {
+			helpers.Logf(ctx, "cannot move extra %s: %s", file, err)
+			continue
+		}
+		dest := path.Join(dir, filepath.Base(file))
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+		helpers.Logf(ctx, "moving extra from %s to %s", file, dest)
+		if err := s.Mover.Move(file, dest); err != nil {
+			helpers.Logf(ctx, "cannot move extra %s: %s", file, err)
+		}
+	}
+	return nil
+}
+
+// Extras Return the video files of the extras in the folder of a release
+// with the extras folder of the library each belongs in. Samples are left
+// out, they are only a piece of the media.
+func Extras(release string) map[string]string {
+	extras := make(map[string]string)
+	filepath.Walk(release, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !api.IsVideo(p) {
+			return nil
+		}
+		relative, err := filepath.Rel(release, p)
+		if err != nil {
+			return nil
+		}
+		if folder, ok := api.ExtrasFolder(relative); ok && folder != "Samples" {
+			extras[p] = folder
+		}
+		return nil
+	})
+	return extras
+}
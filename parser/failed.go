@@ -1,60 +1,306 @@
 package parser
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"parserr/api"
+	"parserr/client"
+	"sort"
+	"time"
 )
 
-// FailedMedia ...
-func FailedMedia(a api.RRAPI) ([]*api.Media, error) {
-	mediaFiles := make([]*api.Media, 0)
-	queue, err := a.GetQueue()
+// FailedMedia Return the media of every failed queue element sorted by queue
+// id. History is walked a page at a time until the grab of every failed
+// element has been found, or is older than GrabLookback, matching the
+// element with its newest history record, either by download id or, when
+// the download id doesn't match, by the grab event that sent it to the
+// client. The records after the grab tell since when the download is in
+// warning. Instances filtering the history are asked for the records of
+// every download first, so history is only walked for the elements whose
+// grab wasn't found by download id. If files is not nil the download client
+// is asked for the files of every download instead of guessing them from
+// the status messages.
+func FailedMedia(ctx context.Context, a api.RRAPI, files client.FileLister) ([]*api.Media, error) {
+	return FailedMediaIndexed(ctx, a, files, nil)
+}
+
+// FailedMediaIndexed FailedMedia matching the elements with the records of
+// the history index first, if not nil, so only the history newer than the
+// previous run is fetched. The instance is only asked for the records of the
+// elements whose grab isn't in the index.
+func FailedMediaIndexed(ctx context.Context, a api.RRAPI, files client.FileLister, index *HistoryIndex) ([]*api.Media, error) {
+	queue, err := a.GetQueue(ctx)
 	if err != nil {
 		return nil, err
 	}
-	history := api.History{Page: 0, PageSize: 10}
+	decide := logDecisions(a.GetType())
+	match := matchHistory(ctx, a, queue, index, decide)
+	return withDownloadSizes(match.media(a, files, decide), queue), nil
+}
+
+// Decider Told every decision taken about a queue element while turning the
+// queue into media. A run logs them, parserr explain prints the ones about
+// its element, so both describe the same match.
+type Decider func(qe api.QueueElem, format string, v ...interface{})
+
+// logDecisions Decider logging every decision with the context of the element
+func logDecisions(mediaType string) Decider {
+	return func(qe api.QueueElem, format string, v ...interface{}) {
+		logQueuef(qe, mediaType, format, v...)
+	}
+}
+
+// historyMatch Failed elements of the queue, sorted by queue id, and the
+// history records matched with them, by the position of the element
+type historyMatch struct {
+	pending  []api.QueueElem
+	matches  map[int]api.HistoryRec
+	grabs    map[int]time.Time
+	warnings map[int]time.Time
+}
+
+// only Return the match of the pending elements kept
+func (h historyMatch) only(keep func(qe api.QueueElem) bool) historyMatch {
+	kept := historyMatch{
+		matches:  make(map[int]api.HistoryRec),
+		grabs:    make(map[int]time.Time),
+		warnings: make(map[int]time.Time),
+	}
+	for i, qe := range h.pending {
+		if !keep(qe) {
+			continue
+		}
+		j := len(kept.pending)
+		kept.pending = append(kept.pending, qe)
+		if hr, found := h.matches[i]; found {
+			kept.matches[j] = hr
+		}
+		if date, found := h.grabs[i]; found {
+			kept.grabs[j] = date
+		}
+		if date, found := h.warnings[i]; found {
+			kept.warnings[j] = date
+		}
+	}
+	return kept
+}
+
+// media Return the media of the pending elements matched with their
+// history records
+func (h historyMatch) media(a api.RRAPI, files client.FileLister, decide Decider) []*api.Media {
+	return mediaFromHistory(a, files, h.pending, h.matches, h.grabs, h.warnings, decide)
+}
+
+// matchHistory Match the failed elements of the queue with their history
+// records, telling decide every match
+func matchHistory(ctx context.Context, a api.RRAPI, queue []api.QueueElem, index *HistoryIndex, decide Decider) historyMatch {
+	sort.SliceStable(queue, func(i, j int) bool { return queue[i].ID < queue[j].ID })
+	h := historyMatch{
+		matches:  make(map[int]api.HistoryRec),
+		grabs:    make(map[int]time.Time),
+		warnings: make(map[int]time.Time),
+	}
 	for _, qe := range queue {
 		if isNotCompletedOrFailed(qe) {
 			continue
 		}
-		found := false
-		var err error
-		for !found && err == nil {
-			found = false
-			for _, hr := range history.Records {
-				if itsNotTheSame(qe, hr) {
-					continue
-				}
-				found = true
-				newMediaFile, fileErr := api.NewMedia(a, hr, qe)
-				if fileErr == nil {
-					mediaFiles = append(mediaFiles, &newMediaFile)
-					log.Printf("add failed media file correctly: %s", qe.Title)
-				} else {
-					log.Printf("cannot add failed media file: %s", fileErr.Error())
-				}
+		h.pending = append(h.pending, qe)
+	}
+	if len(h.pending) == 0 {
+		return h
+	}
+	pending, matches, grabs, warnings := h.pending, h.matches, h.grabs, h.warnings
+	observe := func(i int, hr api.HistoryRec) bool {
+		qe := pending[i]
+		if itsNotTheSame(qe, hr) && !isSourceGrab(qe, hr) {
+			return false
+		}
+		if _, found := matches[i]; !found {
+			if itsNotTheSame(qe, hr) {
+				decide(qe, "%s correlated with its grab, guid %s from %s", qe.Title, hr.Data.GUID, hr.Data.Indexer)
+			} else {
+				decide(qe, "%s matched by download id with its %s record of %s", qe.Title, hr.EventType, hr.Date.Format(time.RFC3339))
+			}
+			matches[i] = hr
+		}
+		if hr.EventType == api.HistoryEventGrabbed {
+			grabs[i] = hr.Date
+		} else {
+			// walking backwards, the last one seen is the first after the grab
+			warnings[i] = hr.Date
+		}
+		return true
+	}
+	// fetched Records matched while walking the instance, added to the index
+	var fetched []api.HistoryRec
+	unmatched := func(hr api.HistoryRec) bool {
+		for i := range pending {
+			if _, found := grabs[i]; found {
+				continue
+			}
+			if observe(i, hr) {
+				fetched = append(fetched, hr)
 				break
 			}
-			if !found {
-				err = addPageToHistory(a, &history)
+		}
+		return len(grabs) == len(pending) || grabsAgedOut(pending, matches, grabs, hr.Date)
+	}
+	if index != nil {
+		records, err := index.Update(ctx, a, queue)
+		if err != nil {
+			log.Print(err)
+		}
+		for _, hr := range records {
+			if unmatched(hr) {
+				break
 			}
 		}
+		defer func() {
+			if err := index.Add(fetched); err != nil {
+				log.Print(err)
+			}
+		}()
 	}
-	return mediaFiles, nil
+	if len(grabs) == len(pending) {
+		return h
+	}
+	if a.FiltersHistory() {
+		// only the records of every download, once for the elements sharing
+		// it, instead of paging through everything else
+		byDownload := make(map[string][]int)
+		var ids []string
+		for i, qe := range pending {
+			if qe.DownloadID == "" {
+				continue
+			}
+			if _, found := byDownload[qe.DownloadID]; !found {
+				ids = append(ids, qe.DownloadID)
+			}
+			byDownload[qe.DownloadID] = append(byDownload[qe.DownloadID], i)
+		}
+		for _, id := range ids {
+			elems := byDownload[id]
+			opts := api.HistoryOptions{DownloadID: id}
+			err := api.WalkFilteredHistory(ctx, a, opts, func(hr api.HistoryRec) bool {
+				stop := true
+				for _, i := range elems {
+					if _, found := grabs[i]; !found && observe(i, hr) {
+						fetched = append(fetched, hr)
+					}
+					_, found := grabs[i]
+					stop = stop && found
+				}
+				return stop
+			})
+			if err != nil {
+				log.Printf("history of download %s not fully walked: %s", id, err)
+			}
+		}
+	}
+	if len(grabs) == len(pending) {
+		return h
+	}
+	err := api.WalkHistory(ctx, a, unmatched)
+	if err != nil {
+		log.Printf("history not fully walked: %s", err)
+	}
+	return h
 }
 
-func addPageToHistory(a api.RRAPI, h *api.History) error {
-	h.Page = h.Page + 1
-	newHistory, err := a.GetHistory(h.Page)
-	if err != nil {
-		return err
+// GrabLookback How far back from the newest record of a queue element its
+// grab is looked for. Older grabs have aged out of the history, walking it
+// whole on every run wouldn't find them.
+const GrabLookback = 30 * 24 * time.Hour
+
+// grabsAgedOut Return true if every pending element without a grab has been
+// matched with a record newer than the date by more than GrabLookback, the
+// history older than the date can't date them
+func grabsAgedOut(pending []api.QueueElem, matches map[int]api.HistoryRec, grabs map[int]time.Time, date time.Time) bool {
+	for i := range pending {
+		if _, found := grabs[i]; found {
+			continue
+		}
+		match, found := matches[i]
+		if !found || match.Date.Sub(date) < GrabLookback {
+			return false
+		}
 	}
-	if len(newHistory.Records) == 0 {
-		return fmt.Errorf("no more pages in history")
+	return true
+}
+
+// mediaFromHistory Return the media of the pending elements matched with
+// their history records
+func mediaFromHistory(a api.RRAPI, files client.FileLister, pending []api.QueueElem, matches map[int]api.HistoryRec, grabs, warnings map[int]time.Time, decide Decider) []*api.Media {
+	mediaFiles := make([]*api.Media, 0)
+	// downloads with an element that can't be added are skipped whole, so
+	// the media of a download are always fixed and cleaned together
+	incomplete := make(map[string]bool)
+	for i, qe := range pending {
+		hr, found := matches[i]
+		if !found {
+			decide(qe, "cannot add failed media file: no history record for %s", qe.Title)
+			incomplete[qe.DownloadID] = true
+			continue
+		}
+		if a.GetType() == api.TypeMusic {
+			tracks := newTracks(a, hr, qe, files, decide)
+			for _, m := range tracks {
+				m.Grabbed, m.WarningSince = grabs[i], warnings[i]
+			}
+			if len(tracks) == 0 {
+				decide(qe, "cannot add failed media file: no tracks found for %s", qe.Title)
+				incomplete[qe.DownloadID] = true
+			}
+			mediaFiles = append(mediaFiles, tracks...)
+			continue
+		}
+		newMediaFile, fileErr := newMedia(a, hr, qe, files)
+		if fileErr == nil {
+			newMediaFile.Grabbed, newMediaFile.WarningSince = grabs[i], warnings[i]
+			mediaFiles = append(mediaFiles, &newMediaFile)
+			decide(qe, "add failed media file correctly: %s", qe.Title)
+			publishMedia(EventItemDetected, &newMediaFile, nil)
+		} else {
+			decide(qe, "cannot add failed media file: %s", fileErr.Error())
+			incomplete[qe.DownloadID] = true
+		}
+	}
+	return withoutIncomplete(mediaFiles, incomplete, decide)
+}
+
+// withDownloadSizes Set how many media the download of every media has: the
+// media found plus the elements of the download in the queue that haven't
+// failed. Runs fixing some of them, because the rest were filtered out or
+// are still downloading, then know the download isn't done.
+func withDownloadSizes(media []*api.Media, queue []api.QueueElem) []*api.Media {
+	sizes := make(map[string]int)
+	for _, m := range media {
+		if id := m.QueueElem.DownloadID; id != "" {
+			sizes[id]++
+		}
+	}
+	for _, qe := range queue {
+		if _, found := sizes[qe.DownloadID]; found && isNotCompletedOrFailed(qe) {
+			sizes[qe.DownloadID]++
+		}
+	}
+	for _, m := range media {
+		m.DownloadMedia = sizes[m.QueueElem.DownloadID]
+	}
+	return media
+}
+
+// withoutIncomplete Return the media whose download isn't incomplete
+func withoutIncomplete(files []*api.Media, incomplete map[string]bool, decide Decider) []*api.Media {
+	complete := make([]*api.Media, 0, len(files))
+	for _, m := range files {
+		if id := m.QueueElem.DownloadID; id != "" && incomplete[id] {
+			decide(m.QueueElem, "skipping %s, other media of its download can't be fixed yet", m.QueueElem.Title)
+			continue
+		}
+		complete = append(complete, m)
 	}
-	h.Records = append(h.Records, newHistory.Records...)
-	return nil
+	return complete
 }
 
 func isNotCompletedOrFailed(qe api.QueueElem) bool {
@@ -70,3 +316,81 @@ func itsNotTheSame(qe api.QueueElem, hr api.HistoryRec) bool {
 	itsTheSame := sameDownloadID && sameSeason && sameEpisode
 	return !itsTheSame
 }
+
+// isSourceGrab Return true if the history record is the grab of the queue
+// element: same release and media, and same indexer when the queue knows it
+func isSourceGrab(qe api.QueueElem, hr api.HistoryRec) bool {
+	if hr.EventType != api.HistoryEventGrabbed || hr.Data.GUID == "" {
+		return false
+	}
+	if qe.Indexer != "" && qe.Indexer != hr.Data.Indexer {
+		return false
+	}
+	sameRelease := qe.Title == hr.SourceTitle
+	sameMovie := qe.Movie.ID == hr.Movie.ID
+	sameEpisode := qe.Episode.ID == hr.Episode.ID
+	sameAlbum := qe.Album.ID == hr.Album.ID
+	return sameRelease && sameMovie && sameEpisode && sameAlbum
+}
+
+// newMedia Return the media locating its file through the download client if
+// possible, guessing it otherwise
+func newMedia(a api.RRAPI, hr api.HistoryRec, qe api.QueueElem, files client.FileLister) (api.Media, error) {
+	if files == nil {
+		return api.NewMedia(a, hr, qe)
+	}
+	downloadFiles, err := files.Files(qe.DownloadID)
+	if err != nil {
+		logQueuef(qe, a.GetType(), "cannot get files of %s from the download client, guessing them: %s", qe.Title, err)
+		return api.NewMedia(a, hr, qe)
+	}
+	var paths []string
+	for _, f := range downloadFiles {
+		paths = append(paths, f.Path)
+	}
+	return api.NewMediaFromFiles(a, hr, qe, paths)
+}
+
+// newTracks Return a media for every track of a failed album download, an
+// album is downloaded as a single element of the queue
+func newTracks(a api.RRAPI, hr api.HistoryRec, qe api.QueueElem, files client.FileLister, decide Decider) (tracks []*api.Media) {
+	var paths []string
+	if files != nil {
+		downloadFiles, err := files.Files(qe.DownloadID)
+		if err != nil {
+			logQueuef(qe, a.GetType(), "cannot get files of %s from the download client, guessing them: %s", qe.Title, err)
+		}
+		for _, f := range downloadFiles {
+			if api.IsAudio(f.Path) {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	var names []api.StatusMessage
+	for _, message := range qe.StatusMessages {
+		if api.IsAudio(message.Title) {
+			names = append(names, message)
+		}
+	}
+	add := func(m api.Media, err error) {
+		if err != nil {
+			decide(qe, "cannot add failed media file: %s", err.Error())
+			return
+		}
+		tracks = append(tracks, &m)
+		decide(qe, "add failed media file correctly: %s", m.FilenameOri)
+		publishMedia(EventItemDetected, &m, nil)
+	}
+	if len(paths) > 0 {
+		for _, p := range paths {
+			add(api.NewMediaFromFiles(a, hr, qe, []string{p}))
+		}
+		return
+	}
+	for _, name := range names {
+		track := qe
+		track.StatusMessages = []api.StatusMessage{name}
+		add(api.NewMedia(a, hr, track))
+	}
+	return
+}
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"parserr/api"
+	"testing"
+	"time"
+)
+
+func TestGrabsAgedOut(t *testing.T) {
+	now := time.Now()
+	pending := []api.QueueElem{{ID: 1}, {ID: 2}}
+	tests := []struct {
+		name    string
+		matches map[int]api.HistoryRec
+		grabs   map[int]time.Time
+		date    time.Time
+		want    bool
+	}{
+		{"unmatched element", map[int]api.HistoryRec{0: {Date: now}}, map[int]time.Time{}, now.Add(-2 * GrabLookback), false},
+		{"recent records", map[int]api.HistoryRec{0: {Date: now}, 1: {Date: now}}, map[int]time.Time{}, now.Add(-time.Hour), false},
+		{"old records", map[int]api.HistoryRec{0: {Date: now}, 1: {Date: now}}, map[int]time.Time{}, now.Add(-2 * GrabLookback), true},
+		{"grabbed", map[int]api.HistoryRec{0: {Date: now}, 1: {Date: now}}, map[int]time.Time{0: now, 1: now}, now, true},
+	}
+	for _, tt := range tests {
+		if got := grabsAgedOut(pending, tt.matches, tt.grabs, tt.date); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"parserr/api"
+	"strings"
+	"time"
+)
+
+// MediaFilter Select the media of a targeted run, media must match every
+// criteria set. An empty filter selects every media.
+type MediaFilter struct {
+	// Series Title of the series, case insensitive
+	Series string
+	// Movie Title of the movie, case insensitive
+	Movie string
+	// Artist Name of the artist, case insensitive
+	Artist      string
+	QueueIDs    []int
+	DownloadIDs []string
+	// Since Only media grabbed within this duration, media without a known
+	// grab date never match
+	Since time.Duration
+}
+
+// Empty Return true if the filter selects every media
+func (f MediaFilter) Empty() bool {
+	return f.Series == "" && f.Movie == "" && f.Artist == "" &&
+		len(f.QueueIDs) == 0 && len(f.DownloadIDs) == 0 && f.Since <= 0
+}
+
+// Match Return true if the media matches every criteria of the filter
+func (f MediaFilter) Match(m *api.Media, now time.Time) bool {
+	qe := m.QueueElem
+	if f.Series != "" && !strings.EqualFold(qe.Series.Title, f.Series) {
+		return false
+	}
+	if f.Movie != "" && !strings.EqualFold(qe.Movie.Title, f.Movie) {
+		return false
+	}
+	if f.Artist != "" && !strings.EqualFold(qe.Artist.ArtistName, f.Artist) {
+		return false
+	}
+	if len(f.QueueIDs) > 0 && !containsInt(f.QueueIDs, qe.ID) {
+		return false
+	}
+	if len(f.DownloadIDs) > 0 && !containsFold(f.DownloadIDs, qe.DownloadID) {
+		return false
+	}
+	if f.Since > 0 {
+		age := Age(m, now)
+		if age <= 0 || age > f.Since {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter Return the media matching the filter, logging the details of every
+// one so a targeted run shows what is going to be fixed
+func (f MediaFilter) Filter(files []*api.Media, now time.Time) (selected []*api.Media) {
+	if f.Empty() {
+		return files
+	}
+	for _, m := range files {
+		if !f.Match(m, now) {
+			continue
+		}
+		logItemf(m, "selected %s: grabbed %s ago, file %s, fixed as %s",
+			m.QueueElem.Title, Age(m, now).Round(time.Minute), m.FileLocOri, m.FilenameFinal)
+		selected = append(selected, m)
+	}
+	log.Printf("%d of %d failed media selected by %s", len(selected), len(files), f)
+	return
+}
+
+func (f MediaFilter) String() string {
+	var criteria []string
+	if f.Series != "" {
+		criteria = append(criteria, fmt.Sprintf("series %q", f.Series))
+	}
+	if f.Movie != "" {
+		criteria = append(criteria, fmt.Sprintf("movie %q", f.Movie))
+	}
+	if f.Artist != "" {
+		criteria = append(criteria, fmt.Sprintf("artist %q", f.Artist))
+	}
+	if len(f.QueueIDs) > 0 {
+		criteria = append(criteria, fmt.Sprintf("queue ids %v", f.QueueIDs))
+	}
+	if len(f.DownloadIDs) > 0 {
+		criteria = append(criteria, fmt.Sprintf("download ids %v", f.DownloadIDs))
+	}
+	if f.Since > 0 {
+		criteria = append(criteria, fmt.Sprintf("grabbed within %s", f.Since))
+	}
+	if len(criteria) == 0 {
+		return "no filter"
+	}
+	return strings.Join(criteria, ", ")
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold Return true if v is in values ignoring case, download ids
+// are hashes whose case depends on the client
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
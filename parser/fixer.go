@@ -1,18 +1,40 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"parserr/api"
+	"parserr/helpers"
 	"strings"
 )
 
-// FixMedia Try to rename downloaded files to the original torrent name
-func FixMedia(failedMediaFiles []*api.Media, s FixStrategy) error {
+// FixMedia Try to rename downloaded files to the original torrent name.
+// Every file is fixed in isolation, a panic fixing one of them is recovered
+// and recorded in the report so the rest of the files are still processed.
+// Files of the same download are fixed together as a DownloadUnit. Once
+// the deadline of the run is reached the rest of the files are left pending.
+// The lines logged while fixing a file are tagged with it, see withItem.
+func FixMedia(ctx context.Context, failedMediaFiles []*api.Media, s FixStrategy, r *Report) error {
 	var errors []string
-	for _, file := range failedMediaFiles {
-		err := s.Fix(file)
-		if err != nil {
-			errors = append(errors, err.Error())
+	for _, unit := range GroupByDownload(failedMediaFiles) {
+		if unit.Partial() {
+			helpers.Logf(ctx, "fixing %d of the %d media of download %s, it's kept until the rest are fixed", len(unit.Media), unit.Size, unit.DownloadID)
+		} else if len(unit.Media) > 1 {
+			helpers.Logf(ctx, "fixing %d media of download %s together", len(unit.Media), unit.DownloadID)
+		}
+		unitCtx := withUnit(ctx, unit)
+		for _, file := range unit.Media {
+			if err := DeadlineReached(ctx, file.QueueElem.Title); err != nil {
+				r.AddMedia(file, ItemPending, err)
+				continue
+			}
+			status, err := fixIsolated(withItem(unitCtx, file), file, s)
+			unit.record(status == ItemFixed || status == ItemSelfHealed)
+			r.AddMedia(file, status, err)
+			publishFix(file, status, err)
+			if err != nil && status != ItemQuarantined && status != ItemSelfHealed && status != ItemLocked {
+				errors = append(errors, err.Error())
+			}
 		}
 	}
 	if len(errors) == 0 {
@@ -20,3 +42,47 @@ func FixMedia(failedMediaFiles []*api.Media, s FixStrategy) error {
 	}
 	return fmt.Errorf("%s", strings.Join(errors, ", "))
 }
+
+// fixIsolated Fix a single file recovering from any panic
+func fixIsolated(ctx context.Context, file *api.Media, s FixStrategy) (status string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			helpers.Logf(ctx, "recovered from panic fixing %s: %v", file.QueueElem.Title, p)
+			status = ItemPanicked
+			err = fmt.Errorf("panic fixing %s: %v", file.QueueElem.Title, p)
+		}
+	}()
+	err = s.Fix(ctx, file)
+	if _, ok := err.(QuarantinedError); ok {
+		return ItemQuarantined, err
+	}
+	if _, ok := err.(TimedOutError); ok {
+		return ItemTimedOut, err
+	}
+	if _, ok := err.(SelfHealedError); ok {
+		helpers.Logf(ctx, "skipping, %s", err)
+		return ItemSelfHealed, err
+	}
+	if _, ok := err.(LockedError); ok {
+		helpers.Logf(ctx, "skipping, %s", err)
+		return ItemLocked, err
+	}
+	if err != nil {
+		return ItemFailed, err
+	}
+	return ItemFixed, nil
+}
+
+// publishFix Publish the events of the result of fixing the media
+func publishFix(m *api.Media, status string, err error) {
+	switch status {
+	case ItemFixed:
+		if m.FileLocFinal != "" && m.FileLocFinal != m.FileLocOri {
+			publishMedia(EventFileMoved, m, nil)
+		}
+		publishMedia(EventItemFixed, m, nil)
+	case ItemSelfHealed, ItemLocked:
+	default:
+		publishMedia(EventItemFailed, m, err)
+	}
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"parserr/helpers"
+	"strings"
+)
+
+const (
+	// EnvHealthCheck What to do when the instance is unhealthy, HealthSkip,
+	// HealthAlert or HealthOff
+	EnvHealthCheck = "PARSERR_HEALTH_CHECK"
+	// HealthSkip Skip the instance and notify why, the default
+	HealthSkip = "skip"
+	// HealthAlert Notify why but fix the instance anyway
+	HealthAlert = "alert"
+	// HealthOff Don't check the health of the instances
+	HealthOff = "off"
+)
+
+// blockingHealthSources Health checks failing when the instance can't import
+// any download whatever their files are, so the warnings of its queue aren't
+// fixable by renaming them. Checks about one root folder, mount or download
+// client of several don't stop the rest from importing.
+var blockingHealthSources = map[string]bool{
+	"DownloadClientCheck":  true,
+	"ImportMechanismCheck": true,
+}
+
+// HealthModes Values of EnvHealthCheck
+var HealthModes = []string{HealthSkip, HealthAlert, HealthOff}
+
+// UnhealthyError Returned by CheckHealth when the instance can't import
+// downloads on its own
+type UnhealthyError struct {
+	Instance string
+	Checks   []api.HealthCheck
+}
+
+func (e UnhealthyError) Error() string {
+	var messages []string
+	for _, c := range e.Checks {
+		messages = append(messages, c.Message)
+	}
+	return fmt.Sprintf("%s is unhealthy: %s", e.Instance, strings.Join(messages, ", "))
+}
+
+// CheckHealth Return an UnhealthyError if the instance reports a problem
+// that keeps it from importing downloads, notifying about it. Instances
+// whose health can't be got are considered healthy.
+func CheckHealth(ctx context.Context, a api.RRAPI, n Notifier) error {
+	checks, err := a.GetHealth(ctx)
+	if err != nil {
+		helpers.Logf(ctx, "cannot get health of %s: %s", a.GetName(), err)
+		return nil
+	}
+	unhealthy := UnhealthyError{Instance: a.GetName()}
+	for _, c := range checks {
+		if blockingHealthSources[c.Source] && (c.Type == api.HealthWarning || c.Type == api.HealthError) {
+			unhealthy.Checks = append(unhealthy.Checks, c)
+		}
+	}
+	if len(unhealthy.Checks) == 0 {
+		return nil
+	}
+	if n != nil {
+		n.Notify(fmt.Sprintf("%s is unhealthy", a.GetName()), unhealthy.Error())
+	}
+	return unhealthy
+}
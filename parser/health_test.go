@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"testing"
+)
+
+// healthAPI API reporting the health checks
+type healthAPI struct {
+	api.RRAPI
+	checks []api.HealthCheck
+}
+
+func (a healthAPI) GetName() string { return "sonarr" }
+
+func (a healthAPI) GetHealth(ctx context.Context) ([]api.HealthCheck, error) {
+	return a.checks, nil
+}
+
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		source    string
+		unhealthy bool
+	}{
+		{"DownloadClientCheck", true},
+		{"ImportMechanismCheck", true},
+		{"DownloadClientStatusCheck", false},
+		{"RootFolderCheck", false},
+		{"MountCheck", false},
+		{"RemotePathMappingCheck", false},
+	}
+	for _, tt := range tests {
+		a := healthAPI{checks: []api.HealthCheck{{Source: tt.source, Type: api.HealthWarning, Message: "failing"}}}
+		err := CheckHealth(context.Background(), a, nil)
+		if _, ok := err.(UnhealthyError); ok != tt.unhealthy {
+			t.Errorf("%s: %v, want unhealthy %v", tt.source, err, tt.unhealthy)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"parserr/api"
+	"parserr/store"
+	"sort"
+	"time"
+)
+
+const (
+	// EnvIncrementalHistory Keep the grabs seen by every run in the state
+	// store, so later runs only fetch the newer records
+	EnvIncrementalHistory = "PARSERR_INCREMENTAL_HISTORY"
+	// EnvHistoryRetention Age of the oldest history walked and kept
+	EnvHistoryRetention = "PARSERR_HISTORY_RETENTION"
+	// DefaultHistoryRetention ...
+	DefaultHistoryRetention = 30 * 24 * time.Hour
+)
+
+// historyCursor History of an instance kept between runs. LastID and
+// LastDate are the newest record seen, where the next walk stops. Downloads
+// keeps, by download id and newest first, only the records FailedMedia
+// matches queue elements with, see grabMatches.
+type historyCursor struct {
+	LastID    int
+	LastDate  time.Time
+	Downloads map[string][]api.HistoryRec
+	// Records Every record, kept by older versions, moved to Downloads
+	Records []api.HistoryRec `json:",omitempty"`
+}
+
+// HistoryIndex Grab matches of the downloads of an instance seen by previous
+// runs, kept in the store. Every update only walks the records newer than
+// the last one seen, the first one walks back Retention. Downloads are
+// dropped once they are out of the queue and their records are older than
+// Retention. The grabs of older downloads are looked up in the instance and
+// added, so they are only walked once.
+type HistoryIndex struct {
+	Store     store.Store
+	Instance  string
+	Retention time.Duration
+	// ReadOnly The index is updated in memory but never saved
+	ReadOnly bool
+	// Clock Used to age the records, RealClock if nil
+	Clock api.Clock
+}
+
+// NewHistoryIndex Return the index of the instance kept in the store
+func NewHistoryIndex(st store.Store, instance string, retention time.Duration) *HistoryIndex {
+	if retention <= 0 {
+		retention = DefaultHistoryRetention
+	}
+	return &HistoryIndex{Store: st, Instance: instance, Retention: retention}
+}
+
+// Update Fetch the records newer than the last one seen, save their grab
+// matches and return every record kept, newest first. The downloads of the
+// queue are kept however old they are.
+func (h *HistoryIndex) Update(ctx context.Context, a api.RRAPI, queue []api.QueueElem) ([]api.HistoryRec, error) {
+	cursor, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+	oldest := api.ClockOrReal(h.Clock).Now().Add(-h.Retention)
+	var newer []api.HistoryRec
+	err = api.WalkHistory(ctx, a, func(hr api.HistoryRec) bool {
+		if cursor.seen(hr) || hr.Date.Before(oldest) {
+			return true
+		}
+		newer = append(newer, hr)
+		return false
+	})
+	if err != nil {
+		// nothing is saved, the cursor would skip the records not walked
+		return nil, fmt.Errorf("cannot update history index of %s: %s", h.Instance, err)
+	}
+	changed := cursor.add(newer)
+	queued := make(map[string]bool)
+	for _, qe := range queue {
+		queued[qe.DownloadID] = true
+	}
+	for id, records := range cursor.Downloads {
+		if !queued[id] && records[0].Date.Before(oldest) {
+			delete(cursor.Downloads, id)
+			changed = true
+		}
+	}
+	if len(newer) > 0 {
+		cursor.LastID, cursor.LastDate = newer[0].ID, newer[0].Date
+	}
+	if changed {
+		err = h.save(cursor)
+	}
+	return cursor.records(), err
+}
+
+// Add Keep the records of downloads looked up in the instance, so later
+// runs find them in the index
+func (h *HistoryIndex) Add(records []api.HistoryRec) error {
+	cursor, err := h.load()
+	if err != nil {
+		return err
+	}
+	if !cursor.add(records) {
+		return nil
+	}
+	return h.save(cursor)
+}
+
+// add Keep the grab matches of the records, return true if any is new
+func (c *historyCursor) add(records []api.HistoryRec) (changed bool) {
+	if c.Downloads == nil {
+		c.Downloads = make(map[string][]api.HistoryRec)
+	}
+	byDownload := make(map[string][]api.HistoryRec)
+	for _, hr := range records {
+		if hr.DownloadID != "" {
+			byDownload[hr.DownloadID] = append(byDownload[hr.DownloadID], hr)
+		}
+	}
+	for id, added := range byDownload {
+		kept := c.Downloads[id]
+		merged := kept
+		for _, hr := range added {
+			if !containsRecord(merged, hr) {
+				merged = append(merged, hr)
+			}
+		}
+		if len(merged) == len(kept) {
+			continue
+		}
+		sortNewestFirst(merged)
+		c.Downloads[id] = grabMatches(merged)
+		changed = true
+	}
+	return
+}
+
+// records Return the records of every download kept, newest first
+func (c historyCursor) records() []api.HistoryRec {
+	var records []api.HistoryRec
+	for _, kept := range c.Downloads {
+		records = append(records, kept...)
+	}
+	sortNewestFirst(records)
+	return records
+}
+
+// grabMatches Return the records of a download FailedMedia needs, newest
+// first: for every media of the download its newest record, its grabs and
+// the record right after every grab, which dates the warning
+func grabMatches(records []api.HistoryRec) []api.HistoryRec {
+	keep := make([]bool, len(records))
+	newest := make(map[string]bool)
+	after := make(map[string]int)
+	for i, hr := range records {
+		media := fmt.Sprintf("%d:%d", hr.Episode.SeasonNumber, hr.Episode.EpisodeNumber)
+		if !newest[media] {
+			newest[media] = true
+			keep[i] = true
+		}
+		if hr.EventType != api.HistoryEventGrabbed {
+			after[media] = i
+			continue
+		}
+		keep[i] = true
+		if j, ok := after[media]; ok {
+			keep[j] = true
+			delete(after, media)
+		}
+	}
+	var matches []api.HistoryRec
+	for i, hr := range records {
+		if keep[i] {
+			matches = append(matches, hr)
+		}
+	}
+	return matches
+}
+
+// containsRecord Return true if the record is one of the records
+func containsRecord(records []api.HistoryRec, hr api.HistoryRec) bool {
+	for _, r := range records {
+		if r.ID == hr.ID && r.Date.Equal(hr.Date) && r.EventType == hr.EventType {
+			return true
+		}
+	}
+	return false
+}
+
+// sortNewestFirst Sort the records like the history of the instance
+func sortNewestFirst(records []api.HistoryRec) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Date.Equal(records[j].Date) {
+			return records[i].Date.After(records[j].Date)
+		}
+		return records[i].ID > records[j].ID
+	})
+}
+
+// seen Return true if the record isn't newer than the last one seen.
+// Instances without record ids are compared by date.
+func (c historyCursor) seen(hr api.HistoryRec) bool {
+	if c.LastID != 0 && hr.ID != 0 {
+		return hr.ID <= c.LastID
+	}
+	return !c.LastDate.IsZero() && !hr.Date.After(c.LastDate)
+}
+
+func (h *HistoryIndex) load() (cursor historyCursor, err error) {
+	j, ok, err := h.Store.Get(store.BucketHistory, h.Instance)
+	if err != nil || !ok {
+		return
+	}
+	err = json.Unmarshal(j, &cursor)
+	if err != nil {
+		err = fmt.Errorf("corrupted history index of %s: %s", h.Instance, err)
+		return
+	}
+	if len(cursor.Records) > 0 {
+		cursor.add(cursor.Records)
+		cursor.Records = nil
+	}
+	return
+}
+
+func (h *HistoryIndex) save(cursor historyCursor) error {
+	if h.ReadOnly {
+		return nil
+	}
+	j, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	return h.Store.Put(store.BucketHistory, h.Instance, j)
+}
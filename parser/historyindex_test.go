@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"parserr/store"
+	"testing"
+	"time"
+)
+
+// historyAPI API with a single page of history, newest first
+type historyAPI struct {
+	api.RRAPI
+	records []api.HistoryRec
+}
+
+func (a historyAPI) GetHistoryFiltered(ctx context.Context, page int, opts api.HistoryOptions) (api.History, error) {
+	if page > 1 {
+		return api.History{Page: page, PageSize: len(a.records), TotalRecords: len(a.records)}, nil
+	}
+	return api.History{Page: page, PageSize: len(a.records), TotalRecords: len(a.records), Records: a.records}, nil
+}
+
+// record History record of the episode of the download
+func record(id int, download, event string, episode int, date time.Time) api.HistoryRec {
+	hr := api.HistoryRec{ID: id, DownloadID: download, EventType: event, Date: date}
+	hr.Episode.SeasonNumber, hr.Episode.EpisodeNumber = 1, episode
+	return hr
+}
+
+func TestGrabMatches(t *testing.T) {
+	now := time.Now()
+	records := []api.HistoryRec{
+		record(6, "A", "downloadFailed", 1, now),
+		record(5, "A", "downloadFailed", 1, now.Add(-time.Minute)),
+		record(4, "A", "downloadFailed", 1, now.Add(-2*time.Minute)),
+		record(3, "A", api.HistoryEventGrabbed, 1, now.Add(-3*time.Minute)),
+		record(2, "A", api.HistoryEventGrabbed, 2, now.Add(-3*time.Minute)),
+		record(1, "A", "downloadFailed", 1, now.Add(-4*time.Minute)),
+	}
+	var ids []int
+	for _, hr := range grabMatches(records) {
+		ids = append(ids, hr.ID)
+	}
+	want := []int{6, 4, 3, 2}
+	if len(ids) != len(want) {
+		t.Fatalf("kept %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("kept %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestHistoryIndex(t *testing.T) {
+	now := time.Now()
+	clock := &api.FakeClock{Time: now}
+	st := store.NewMemory()
+	index := NewHistoryIndex(st, "sonarr", time.Hour)
+	index.Clock = clock
+	a := historyAPI{records: []api.HistoryRec{
+		record(2, "NEW", api.HistoryEventGrabbed, 1, now.Add(-time.Minute)),
+		record(1, "QUEUED", api.HistoryEventGrabbed, 2, now.Add(-30*time.Minute)),
+	}}
+	queue := []api.QueueElem{{DownloadID: "QUEUED"}}
+	index.ReadOnly = true
+	if _, err := index.Update(context.Background(), a, queue); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := st.Get(store.BucketHistory, "sonarr"); ok {
+		t.Fatal("a read-only index was saved")
+	}
+	index.ReadOnly = false
+	if _, err := index.Update(context.Background(), a, queue); err != nil {
+		t.Fatal(err)
+	}
+	old := record(0, "OLD", api.HistoryEventGrabbed, 3, now.Add(-48*time.Hour))
+	if err := index.Add([]api.HistoryRec{old}); err != nil {
+		t.Fatal(err)
+	}
+	// two hours later, only the queued download and the one just looked up
+	// are kept
+	clock.Sleep(2 * time.Hour)
+	queue = append(queue, api.QueueElem{DownloadID: "OLD"})
+	records, err := index.Update(context.Background(), a, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := make(map[string]bool)
+	for _, hr := range records {
+		kept[hr.DownloadID] = true
+	}
+	if len(kept) != 2 || !kept["QUEUED"] || !kept["OLD"] {
+		t.Errorf("kept the downloads %v, want QUEUED and OLD", kept)
+	}
+}
+
+func TestHistoryCursorSeen(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		cursor historyCursor
+		record api.HistoryRec
+		want   bool
+	}{
+		{historyCursor{}, record(1, "A", api.HistoryEventGrabbed, 1, now), false},
+		{historyCursor{LastID: 5, LastDate: now}, record(5, "A", api.HistoryEventGrabbed, 1, now), true},
+		{historyCursor{LastID: 5, LastDate: now}, record(6, "A", api.HistoryEventGrabbed, 1, now.Add(-time.Hour)), false},
+		{historyCursor{LastDate: now}, record(0, "A", api.HistoryEventGrabbed, 1, now), true},
+		{historyCursor{LastDate: now}, record(0, "A", api.HistoryEventGrabbed, 1, now.Add(time.Second)), false},
+		{historyCursor{LastID: 5, LastDate: now}, record(0, "A", api.HistoryEventGrabbed, 1, now.Add(-time.Second)), true},
+	}
+	for i, tt := range tests {
+		if got := tt.cursor.seen(tt.record); got != tt.want {
+			t.Errorf("case %d: seen = %v, want %v", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"parserr/store"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ItemLocked The file of the item is being fixed by another instance
+	ItemLocked = "locked"
+	// DefaultLockTTL Time after which the lock of a run that didn't release
+	// it, because it crashed, can be taken
+	DefaultLockTTL = time.Hour
+)
+
+// LockedError Returned when another instance is fixing the file of the media
+type LockedError struct {
+	Name  string
+	Owner string
+}
+
+func (e LockedError) Error() string {
+	return fmt.Sprintf("%s is being fixed by %s", e.Name, e.Owner)
+}
+
+// fileLock Lock of a file kept in the store
+type fileLock struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileLocks Locks of the files being fixed, kept in the state store so
+// instances sharing a download folder, in the same or in different parserr
+// processes sharing the store, don't fix the same file at the same time
+type FileLocks struct {
+	Store store.Store
+	// Process Identifies this parserr among the ones sharing the store
+	Process string
+	// TTL DefaultLockTTL if 0
+	TTL time.Duration
+	// Clock Used to expire the locks, RealClock if nil
+	Clock api.Clock
+}
+
+// NewFileLocks Return the locks of the store for this process
+func NewFileLocks(st store.Store) *FileLocks {
+	host, _ := os.Hostname()
+	return &FileLocks{Store: st, Process: fmt.Sprintf("%s:%d", host, os.Getpid())}
+}
+
+// owner Return the owner of the locks taken for the instance
+func (l *FileLocks) owner(instance string) string {
+	return instance + "@" + l.Process
+}
+
+// Lock Lock the file for the instance, ok is false if it's locked by another
+// one, who is returned. Expired locks are taken over atomically, so only one
+// of the processes finding it expired takes it.
+func (l *FileLocks) Lock(file, instance string) (owner string, ok bool, err error) {
+	ttl := l.TTL
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	now := api.ClockOrReal(l.Clock).Now()
+	value, err := json.Marshal(fileLock{Owner: l.owner(instance), Expires: now.Add(ttl)})
+	if err != nil {
+		return "", false, err
+	}
+	key := filepath.Clean(file)
+	for attempt := 0; attempt < 3; attempt++ {
+		existing, created, err := l.Store.Create(store.BucketLocks, key, value)
+		if err != nil || created {
+			return l.owner(instance), created, err
+		}
+		var lock fileLock
+		if err = json.Unmarshal(existing, &lock); err == nil && now.Before(lock.Expires) {
+			return lock.Owner, lock.Owner == l.owner(instance), nil
+		}
+		swapped, err := l.Store.Swap(store.BucketLocks, key, existing, value)
+		if err != nil {
+			return "", false, err
+		}
+		if swapped {
+			log.Printf("took over expired lock of %s", file)
+			return l.owner(instance), true, nil
+		}
+		// released or taken over by another process meanwhile
+	}
+	return "", false, fmt.Errorf("cannot lock %s", file)
+}
+
+// Unlock Release the lock of the file taken for the instance. Locks taken
+// over by others, after this one expired, are left alone.
+func (l *FileLocks) Unlock(file, instance string) error {
+	key := filepath.Clean(file)
+	existing, ok, err := l.Store.Get(store.BucketLocks, key)
+	if err != nil || !ok {
+		return err
+	}
+	var lock fileLock
+	if err = json.Unmarshal(existing, &lock); err == nil && lock.Owner != l.owner(instance) {
+		log.Printf("lock of %s was taken over by %s, not releasing it", file, lock.Owner)
+		return nil
+	}
+	_, err = l.Store.DeleteIf(store.BucketLocks, key, existing)
+	return err
+}
+
+// LockStrategy Lock the file of the media while fixing it, media whose file
+// is locked by another instance are skipped
+type LockStrategy struct {
+	Strategy FixStrategy
+	Locks    *FileLocks
+}
+
+// Fix ...
+func (s LockStrategy) Fix(ctx context.Context, m *api.Media) error {
+	owner, ok, err := s.Locks.Lock(m.FileLocOri, m.Instance)
+	if err != nil {
+		return fmt.Errorf("cannot lock %s: %s", m.FileLocOri, err)
+	}
+	if !ok {
+		return LockedError{Name: m.QueueElem.Title, Owner: owner}
+	}
+	defer func() {
+		if err := s.Locks.Unlock(m.FileLocOri, m.Instance); err != nil {
+			helpers.Logf(ctx, "cannot unlock %s: %s", m.FileLocOri, err)
+		}
+	}()
+	return s.Strategy.Fix(ctx, m)
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"parserr/store"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLocksTakeOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parserr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileStore, err := store.OpenFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]store.Store{"memory": store.NewMemory(), "json": fileStore}
+	for name, st := range stores {
+		clock := &api.FakeClock{Time: time.Now()}
+		a := &FileLocks{Store: st, Process: "a", Clock: clock}
+		b := &FileLocks{Store: st, Process: "b", Clock: clock}
+		if _, ok, err := a.Lock("/downloads/file.mkv", "sonarr"); !ok || err != nil {
+			t.Fatalf("%s: a didn't lock: %v", name, err)
+		}
+		if owner, ok, _ := b.Lock("/downloads/file.mkv", "sonarr"); ok || owner != "sonarr@a" {
+			t.Errorf("%s: b locked a file locked by a, owner %s", name, owner)
+		}
+		clock.Sleep(DefaultLockTTL + time.Minute)
+		if _, ok, err := b.Lock("/downloads/file.mkv", "sonarr"); !ok || err != nil {
+			t.Fatalf("%s: b didn't take over the expired lock: %v", name, err)
+		}
+		// a finishes late, the lock is b's now
+		if err := a.Unlock("/downloads/file.mkv", "sonarr"); err != nil {
+			t.Fatal(err)
+		}
+		if owner, ok, _ := a.Lock("/downloads/file.mkv", "sonarr"); ok || owner != "sonarr@b" {
+			t.Errorf("%s: a released the lock taken over by b, owner %s", name, owner)
+		}
+		if err := b.Unlock("/downloads/file.mkv", "sonarr"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := a.Lock("/downloads/file.mkv", "sonarr"); !ok {
+			t.Errorf("%s: b didn't release its lock", name)
+		}
+	}
+}
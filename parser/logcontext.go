@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"parserr/helpers"
+	"strings"
+)
+
+// withItem Return a context whose log lines end with the context of the
+// media, like queue=12 download=ABC series="Show" episode=S01E02, so
+// grepping an id tells everything done to it. The tag travels with the
+// context, items fixed at the same time are still told apart.
+func withItem(ctx context.Context, m *api.Media) context.Context {
+	return helpers.WithLogTag(ctx, ItemContext(m))
+}
+
+// logItemf Log a line about the media ending with its context, for code
+// without the context of the item
+func logItemf(m *api.Media, format string, v ...interface{}) {
+	helpers.Tagf(ItemContext(m), format, v...)
+}
+
+// logQueuef Log a line about the queue item of an instance of the type
+// ending with its context, for items not yet turned into media
+func logQueuef(qe api.QueueElem, mediaType, format string, v ...interface{}) {
+	helpers.Tagf(QueueContext(qe, mediaType), format, v...)
+}
+
+// ItemContext Describe the queue item of the media as key=value pairs
+func ItemContext(m *api.Media) string {
+	return QueueContext(m.QueueElem, m.Type)
+}
+
+// QueueContext Describe the queue item of an instance of the type as
+// key=value pairs
+func QueueContext(qe api.QueueElem, mediaType string) string {
+	fields := []string{fmt.Sprintf("queue=%d", qe.ID)}
+	if qe.DownloadID != "" {
+		fields = append(fields, "download="+qe.DownloadID)
+	}
+	switch mediaType {
+	case api.TypeMovie:
+		fields = append(fields, fmt.Sprintf("movie=%q", qe.Movie.Title))
+	case api.TypeMusic:
+		fields = append(fields, fmt.Sprintf("artist=%q", qe.Artist.ArtistName), fmt.Sprintf("album=%q", qe.Album.Title))
+	case api.TypeShow:
+		fields = append(fields, fmt.Sprintf("series=%q", qe.Series.Title),
+			fmt.Sprintf("episode=S%.2dE%.2d", qe.Episode.SeasonNumber, qe.Episode.EpisodeNumber))
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// loggingStrategy Strategy logging the title of the media it fixes
+type loggingStrategy struct{}
+
+func (s loggingStrategy) Fix(ctx context.Context, m *api.Media) error {
+	helpers.Logf(ctx, "fixing %s", m.QueueElem.Title)
+	return nil
+}
+
+func TestFixMediaTagsLines(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+	var media []*api.Media
+	for i, title := range []string{"A.S01E01", "B.S01E02"} {
+		m := &api.Media{Type: api.TypeShow}
+		m.QueueElem.ID = i + 1
+		m.QueueElem.Title = title
+		m.QueueElem.DownloadID = title
+		media = append(media, m)
+	}
+	// instances fixed at the same time
+	var wg sync.WaitGroup
+	for _, m := range media {
+		wg.Add(1)
+		go func(m *api.Media) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				FixMedia(context.Background(), []*api.Media{m}, loggingStrategy{}, &Report{})
+			}
+		}(m)
+	}
+	wg.Wait()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 40 {
+		t.Fatalf("%d lines logged, want 40", len(lines))
+	}
+	for _, line := range lines {
+		for _, m := range media {
+			if strings.Contains(line, "fixing "+m.QueueElem.Title) && !strings.HasSuffix(line, " "+ItemContext(m)) {
+				t.Errorf("line %q not tagged with %s", line, ItemContext(m))
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+)
+
+// MissingItem Media whose download has been removed from the queue without
+// being imported, it has to be searched again
+type MissingItem struct {
+	Title string `json:"title"`
+	// Link Page of the series or movie in the web interface
+	Link string `json:"link,omitempty"`
+}
+
+func (i MissingItem) String() string {
+	return fmt.Sprintf("%s: %s", i.Title, i.Link)
+}
+
+// Missing Return the media that left the queue during the run and still
+// have no file
+func Missing(ctx context.Context, a api.RRAPI, files []*api.Media) (missing []MissingItem, err error) {
+	if len(files) == 0 {
+		return
+	}
+	queue, err := a.GetQueue(ctx)
+	if err != nil {
+		return
+	}
+	queued := make(map[int]bool)
+	for _, qe := range queue {
+		queued[qe.ID] = true
+	}
+	for _, m := range files {
+		if queued[m.QueueElem.ID] || m.HasBeenDetected(ctx, a) {
+			continue
+		}
+		missing = append(missing, MissingItem{Title: m.QueueElem.Title, Link: webLink(a, m)})
+	}
+	return
+}
+
+// webLink Return the page of the series, movie or artist of the media
+func webLink(a api.RRAPI, m *api.Media) string {
+	if m.Type == api.TypeMusic {
+		if m.QueueElem.Artist.ForeignArtistID == "" {
+			return a.GetWebURL("/")
+		}
+		return a.GetWebURL("/artist/" + m.QueueElem.Artist.ForeignArtistID)
+	}
+	if m.Type == api.TypeMovie {
+		if m.QueueElem.Movie.TitleSlug == "" {
+			return a.GetWebURL("/")
+		}
+		return a.GetWebURL("/movies/" + m.QueueElem.Movie.TitleSlug)
+	}
+	if m.QueueElem.Series.TitleSlug == "" {
+		return a.GetWebURL("/")
+	}
+	return a.GetWebURL("/series/" + m.QueueElem.Series.TitleSlug)
+}
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"parserr/helpers"
 )
 
 // Mover Mover file from path to path.
@@ -11,6 +14,19 @@ type Mover interface {
 	Mkdir(path string) error
 }
 
+// ensureDir Create the folder with the mover unless it already exists,
+// returning an error if it can't be created
+func ensureDir(m Mover, dir string) error {
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return nil
+	}
+	err := m.Mkdir(dir)
+	if err != nil {
+		return fmt.Errorf("cannot create folder %s: %s", dir, err)
+	}
+	return nil
+}
+
 // BasicMover ...
 type BasicMover struct{}
 
@@ -30,6 +46,7 @@ type FakeMover struct{}
 // Move ...
 func (m FakeMover) Move(from, to string) error {
 	log.Printf("fake moving\n\tfrom: %s\n\tto:   %s", from, to)
+	helpers.Planned(helpers.ActionMove, "move %s to %s", from, to)
 	return nil
 }
 
@@ -38,3 +55,38 @@ func (m FakeMover) Mkdir(path string) error {
 	log.Printf("fake mkdir: %s", path)
 	return nil
 }
+
+// CopyMover Copy files instead of moving them, leaving the originals for the
+// download client to keep seeding
+type CopyMover struct {
+	Mover Mover
+}
+
+// Move Copy the file keeping its mode
+func (m CopyMover) Move(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	return dst.Close()
+}
+
+// Mkdir ...
+func (m CopyMover) Mkdir(path string) error {
+	return m.Mover.Mkdir(path)
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"parserr/api"
+	"parserr/helpers"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnvNFO Write a Kodi .nfo file next to every imported media
+const EnvNFO = "PARSERR_NFO"
+
+// uniqueID Id of the media in an online database
+type uniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// episodeNFO Kodi episode metadata
+type episodeNFO struct {
+	XMLName   xml.Name   `xml:"episodedetails"`
+	Title     string     `xml:"title"`
+	ShowTitle string     `xml:"showtitle"`
+	Season    int        `xml:"season"`
+	Episode   int        `xml:"episode"`
+	Plot      string     `xml:"plot,omitempty"`
+	Aired     string     `xml:"aired,omitempty"`
+	UniqueIDs []uniqueID `xml:"uniqueid"`
+}
+
+// movieNFO Kodi movie metadata
+type movieNFO struct {
+	XMLName   xml.Name   `xml:"movie"`
+	Title     string     `xml:"title"`
+	Year      int        `xml:"year,omitempty"`
+	Plot      string     `xml:"plot,omitempty"`
+	UniqueIDs []uniqueID `xml:"uniqueid"`
+}
+
+// NFOStrategy Write a Kodi .nfo file with the metadata of the instance next
+// to the media in the library once it's been fixed, see ImportedFile, for
+// media centers that only read local metadata. Failing to write it doesn't
+// make the fix fail. Music is skipped, Kodi reads its metadata from the tags
+// of the files.
+type NFOStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+}
+
+// Fix ...
+func (s NFOStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil || m.Type == api.TypeMusic {
+		return err
+	}
+	file, err := ImportedFile(ctx, s.API, m)
+	if err != nil {
+		helpers.Logf(ctx, "cannot write nfo of %s: %s", m.QueueElem.Title, err)
+		return nil
+	}
+	content, err := s.nfo(ctx, m)
+	if err != nil {
+		helpers.Logf(ctx, "cannot write nfo of %s: %s", m.QueueElem.Title, err)
+		return nil
+	}
+	nfo := strings.TrimSuffix(file, filepath.Ext(file)) + ".nfo"
+	err = ioutil.WriteFile(nfo, append([]byte(xml.Header), content...), 0664)
+	if err != nil {
+		helpers.Logf(ctx, "cannot write nfo of %s: %s", m.QueueElem.Title, err)
+		return nil
+	}
+	helpers.Logf(ctx, "nfo written: %s", nfo)
+	return nil
+}
+
+// nfo Return the metadata of the media as a Kodi nfo
+func (s NFOStrategy) nfo(ctx context.Context, m *api.Media) ([]byte, error) {
+	if m.Type == api.TypeMovie {
+		movie, err := s.API.GetMovie(ctx, m.QueueElem.Movie.ID)
+		if err != nil {
+			return nil, err
+		}
+		nfo := movieNFO{Title: movie.Title, Year: movie.Year, Plot: movie.Overview}
+		if movie.TmdbID != 0 {
+			nfo.UniqueIDs = append(nfo.UniqueIDs, uniqueID{Type: "tmdb", Default: true, Value: strconv.Itoa(movie.TmdbID)})
+		}
+		if movie.ImdbID != "" {
+			nfo.UniqueIDs = append(nfo.UniqueIDs, uniqueID{Type: "imdb", Value: movie.ImdbID})
+		}
+		return xml.MarshalIndent(nfo, "", "  ")
+	}
+	episode, err := s.API.GetEpisode(ctx, m.QueueElem.Episode.ID)
+	if err != nil {
+		return nil, err
+	}
+	series, err := s.API.GetSeriesByID(ctx, m.QueueElem.Series.ID)
+	if err != nil {
+		return nil, err
+	}
+	nfo := episodeNFO{
+		Title:     episode.Title,
+		ShowTitle: series.Title,
+		Season:    episode.SeasonNumber,
+		Episode:   episode.EpisodeNumber,
+		Plot:      episode.Overview,
+		Aired:     episode.AirDate,
+	}
+	if episode.TvDbEpisodeID != 0 {
+		nfo.UniqueIDs = append(nfo.UniqueIDs, uniqueID{Type: "tvdb", Default: true, Value: strconv.Itoa(episode.TvDbEpisodeID)})
+	}
+	return xml.MarshalIndent(nfo, "", "  ")
+}
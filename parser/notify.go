@@ -0,0 +1,16 @@
+package parser
+
+import "log"
+
+// Notifier Tell the user about something that requires their attention
+type Notifier interface {
+	Notify(title, message string)
+}
+
+// LogNotifier Notifier writing to the standard logger
+type LogNotifier struct{}
+
+// Notify ...
+func (n LogNotifier) Notify(title, message string) {
+	log.Printf("notification: %s: %s", title, message)
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"parserr/api"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// EnvMediaManagement Apply the permissions and recycle bin of the media
+// management settings of the instance
+const EnvMediaManagement = "PARSERR_MEDIA_MANAGEMENT"
+
+// MediaManagementMover Mover that behaves like the *arr importing files,
+// applying its permissions and recycling replaced files
+type MediaManagementMover struct {
+	Mover  Mover
+	Config api.MediaManagementConfig
+}
+
+// Move Recycle the destination if it exists, move the file and set its
+// permissions
+func (m MediaManagementMover) Move(from, to string) error {
+	if _, err := os.Stat(to); err == nil && m.Config.RecycleBin != "" {
+		recycled := path.Join(m.Config.RecycleBin, filepath.Base(to))
+		log.Printf("recycling %s to %s", to, recycled)
+		err = m.Mover.Move(to, recycled)
+		if err != nil {
+			return fmt.Errorf("cannot recycle %s: %s", to, err)
+		}
+	}
+	err := m.Mover.Move(from, to)
+	if err != nil {
+		return err
+	}
+	m.setPermissions(to, m.Config.FileChmod)
+	return nil
+}
+
+// Mkdir Create the folder and set its permissions
+func (m MediaManagementMover) Mkdir(path string) error {
+	err := m.Mover.Mkdir(path)
+	if err != nil {
+		return err
+	}
+	m.setPermissions(path, m.Config.FolderChmod)
+	return nil
+}
+
+// setPermissions Apply the mode and owner of the config, errors are logged
+// as the file has already been moved
+func (m MediaManagementMover) setPermissions(path, chmod string) {
+	if !m.Config.SetPermissions {
+		return
+	}
+	if chmod != "" {
+		mode, err := strconv.ParseUint(chmod, 8, 32)
+		if err != nil {
+			log.Printf("invalid chmod %q: %s", chmod, err)
+		} else if err = os.Chmod(path, os.FileMode(mode)); err != nil {
+			log.Printf("cannot chmod %s: %s", path, err)
+		}
+	}
+	if m.Config.ChownUser == "" && m.Config.ChownGroup == "" {
+		return
+	}
+	uid, gid, err := owner(m.Config.ChownUser, m.Config.ChownGroup)
+	if err != nil {
+		log.Printf("cannot chown %s: %s", path, err)
+		return
+	}
+	if err = os.Chown(path, uid, gid); err != nil {
+		log.Printf("cannot chown %s: %s", path, err)
+	}
+}
+
+// owner Return the ids of the user and group, given by name or id, -1 for
+// the empty ones so they are not changed
+func owner(userName, groupName string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if userName != "" {
+		uid, err = strconv.Atoi(userName)
+		if err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return 0, 0, err
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if groupName != "" {
+		gid, err = strconv.Atoi(groupName)
+		if err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return 0, 0, err
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"parserr/api"
+)
+
+const (
+	// EnvMinFileSize Files smaller than this size, like 100KB, are taken for
+	// placeholders, 0 disables the check
+	EnvMinFileSize = "PARSERR_MIN_FILE_SIZE"
+	// DefaultMinFileSize ...
+	DefaultMinFileSize = "100KB"
+	// placeholderHead Bytes read from the start of the files, media files
+	// always start with a header
+	placeholderHead = 4096
+)
+
+// PlaceholderCheck Return a validator refusing zero-byte and tiny files,
+// left by failed transfers, and files whose start is empty, like
+// preallocated files never written and stubs of cloud mounts. They are
+// rejected so the release is blacklisted instead of imported empty. Files
+// that can't be read, like on a flaky mount, fail with a plain error, the
+// release isn't at fault.
+func PlaceholderCheck(minSize int64) Validator {
+	return func(m *api.Media) error {
+		info, err := os.Stat(m.FileLocOri)
+		if err != nil {
+			return fmt.Errorf("cannot check %s is not a placeholder: %s", m.FilenameOri, err)
+		}
+		if info.Size() < minSize {
+			return RejectedError{Reason: ReasonPlaceholder, Err: fmt.Errorf("%s is %d bytes, it's a placeholder of a failed transfer", m.FilenameOri, info.Size())}
+		}
+		f, err := os.Open(m.FileLocOri)
+		if err != nil {
+			return fmt.Errorf("cannot check %s is not a placeholder: %s", m.FilenameOri, err)
+		}
+		defer f.Close()
+		head := make([]byte, placeholderHead)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return fmt.Errorf("cannot check %s is not a placeholder: %s", m.FilenameOri, err)
+		}
+		if n > 0 && bytes.Count(head[:n], []byte{0}) == n {
+			return RejectedError{Reason: ReasonPlaceholder, Err: fmt.Errorf("%s starts empty, it's a placeholder never written", m.FilenameOri)}
+		}
+		return nil
+	}
+}
+
+// ParseMinFileSize Parse the value of EnvMinFileSize, DefaultMinFileSize if
+// empty
+func ParseMinFileSize(value string) (int64, error) {
+	if value == "" {
+		value = DefaultMinFileSize
+	}
+	if value == "0" {
+		return 0, nil
+	}
+	size, err := parseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", EnvMinFileSize, err)
+	}
+	return size, nil
+}
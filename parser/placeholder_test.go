@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlaceholderCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parserr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	header := make([]byte, 2048)
+	copy(header, "\x1aE\xdf\xa3matroska")
+	files := map[string][]byte{
+		"empty.mkv":  nil,
+		"tiny.mkv":   []byte("abc"),
+		"zeros.mkv":  make([]byte, 2048),
+		"header.mkv": header,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		file     string
+		rejected bool
+		fails    bool
+	}{
+		{"empty.mkv", true, true},
+		{"tiny.mkv", true, true},
+		{"zeros.mkv", true, true},
+		{"header.mkv", false, false},
+		{"missing.mkv", false, true},
+	}
+	check := PlaceholderCheck(1024)
+	for _, tt := range tests {
+		m := &api.Media{FileLocOri: filepath.Join(dir, tt.file), FilenameOri: tt.file}
+		err := check(m)
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: error %v, want error %v", tt.file, err, tt.fails)
+		}
+		if _, ok := err.(RejectedError); ok != tt.rejected {
+			t.Errorf("%s: rejected %v, want %v", tt.file, ok, tt.rejected)
+		}
+	}
+}
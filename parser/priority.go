@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"parserr/api"
+	"sort"
+	"time"
+)
+
+const (
+	// EnvAiringWindow Media airing within this duration of now are fixed first
+	EnvAiringWindow = "PARSERR_AIRING_WINDOW"
+	// EnvWarningGrace Media in warning for less than this are left to the
+	// *arr, which may still import them
+	EnvWarningGrace = "PARSERR_WARNING_GRACE"
+	// EnvEscalateAfter Media in warning for longer than this are fixed
+	// before any other
+	EnvEscalateAfter = "PARSERR_ESCALATE_AFTER"
+)
+
+// Age How long the media has been stuck since it was grabbed, or since its
+// newest history record if the grab isn't known
+func Age(m *api.Media, now time.Time) time.Duration {
+	grabbed := m.Grabbed
+	if grabbed.IsZero() {
+		grabbed = m.HistoryRec.Date
+	}
+	if grabbed.IsZero() {
+		return 0
+	}
+	return now.Sub(grabbed)
+}
+
+// WarningAge How long the media has been in warning, 0 if unknown
+func WarningAge(m *api.Media, now time.Time) time.Duration {
+	if m.WarningSince.IsZero() {
+		return 0
+	}
+	return now.Sub(m.WarningSince)
+}
+
+// Escalated Return true if the media has been in warning for longer than
+// after, a zero after disables it
+func Escalated(m *api.Media, now time.Time, after time.Duration) bool {
+	return after > 0 && WarningAge(m, now) > after
+}
+
+// SettledWarnings Return the media whose warning is older than the grace
+// period, media whose warning age is unknown are always returned
+func SettledWarnings(files []*api.Media, now time.Time, grace time.Duration) (settled []*api.Media) {
+	for _, m := range files {
+		if age := WarningAge(m, now); grace > 0 && age > 0 && age < grace {
+			logItemf(m, "%s in warning for only %s, leaving it to %s", m.QueueElem.Title, age.Round(time.Minute), m.Instance)
+			continue
+		}
+		settled = append(settled, m)
+	}
+	return
+}
+
+// AirsWithin Return true if the episode of the media airs, or aired, within
+// the window around now
+func AirsWithin(m *api.Media, now time.Time, window time.Duration) bool {
+	airDate := m.QueueElem.Episode.AirDateUtc
+	if window <= 0 || airDate.IsZero() {
+		return false
+	}
+	d := airDate.Sub(now)
+	return d <= window && d >= -window
+}
+
+// Prioritize Sort the media so the oldest ones are fixed first. Media in
+// warning for longer than escalateAfter go before any other, followed by
+// media airing within the window. A zero duration disables either.
+func Prioritize(files []*api.Media, now time.Time, airingWindow, escalateAfter time.Duration) {
+	sort.SliceStable(files, func(i, j int) bool {
+		escalatedI := Escalated(files[i], now, escalateAfter)
+		escalatedJ := Escalated(files[j], now, escalateAfter)
+		if escalatedI != escalatedJ {
+			return escalatedI
+		}
+		airsI := AirsWithin(files[i], now, airingWindow)
+		airsJ := AirsWithin(files[j], now, airingWindow)
+		if airsI != airsJ {
+			return airsI
+		}
+		return Age(files[i], now) > Age(files[j], now)
+	})
+}
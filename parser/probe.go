@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"parserr/api"
+	"parserr/helpers"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvProbe Probe the languages of the audio and subtitle tracks of the files
+	EnvProbe = "PARSERR_PROBE"
+	// EnvFFProbe Path of the ffprobe binary
+	EnvFFProbe = "PARSERR_FFPROBE"
+	// EnvAudioLanguages Comma separated list of the audio languages wanted,
+	// like eng,spa, files with none of them are rejected
+	EnvAudioLanguages = "PARSERR_AUDIO_LANGUAGES"
+	// DefaultFFProbe ...
+	DefaultFFProbe = "ffprobe"
+	// ExtraRuntimeRatio Files shorter than the runtime of their episode or
+	// movie divided by it are taken for extras
+	ExtraRuntimeRatio = 3
+)
+
+// Prober Read the audio and subtitle languages of a file using ffprobe
+type Prober struct {
+	FFProbe string
+}
+
+// Probe Return the languages of the audio and subtitle tracks of the file,
+// "und" for tracks without language
+func (p Prober) Probe(file string) (audio, subtitles []string, err error) {
+	out, err := exec.Command(p.FFProbe, "-v", "quiet", "-print_format", "json", "-show_streams", file).Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot probe %s: %s", file, err)
+	}
+	var result struct {
+		Streams []struct {
+			CodecType string            `json:"codec_type"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	err = json.Unmarshal(out, &result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot probe %s: %s", file, err)
+	}
+	for _, s := range result.Streams {
+		language := s.Tags["language"]
+		if language == "" {
+			language = "und"
+		}
+		switch s.CodecType {
+		case "audio":
+			audio = append(audio, language)
+		case "subtitle":
+			subtitles = append(subtitles, language)
+		}
+	}
+	return
+}
+
+// Duration Return how long the file lasts
+func (p Prober) Duration(file string) (time.Duration, error) {
+	out, err := exec.Command(p.FFProbe, "-v", "quiet", "-print_format", "json", "-show_format", file).Output()
+	if err != nil {
+		return 0, fmt.Errorf("cannot probe %s: %s", file, err)
+	}
+	var result struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	err = json.Unmarshal(out, &result)
+	if err != nil {
+		return 0, fmt.Errorf("cannot probe %s: %s", file, err)
+	}
+	seconds, err := strconv.ParseFloat(result.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read the duration of %s: %s", file, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ProbeStrategy Probe the file before fixing it and notify its languages
+// once fixed, so a wrong dub is noticed right away. Files much shorter than
+// their episode or movie are refused, they are extras mistaken for it.
+type ProbeStrategy struct {
+	Strategy FixStrategy
+	Prober   Prober
+	Notifier Notifier
+	// AudioLanguages Files whose audio tracks have none of these languages
+	// are rejected, any language is accepted if empty
+	AudioLanguages []string
+}
+
+// Fix ...
+func (s ProbeStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.checkRuntime(ctx, m)
+	if err != nil {
+		return err
+	}
+	audio, subtitles, err := s.Prober.Probe(m.FileLocOri)
+	if err != nil {
+		return s.Strategy.Fix(ctx, m)
+	}
+	m.AudioLanguages, m.SubtitleLanguages = audio, subtitles
+	err = s.checkLanguages(m)
+	if err != nil {
+		return err
+	}
+	err = s.Strategy.Fix(ctx, m)
+	if err != nil {
+		return err
+	}
+	s.Notifier.Notify("fixed "+m.QueueElem.Title, Languages(m))
+	return nil
+}
+
+// checkRuntime Return an error if the file is too short to be the episode
+// or movie, files of media with unknown runtime aren't checked
+func (s ProbeStrategy) checkRuntime(ctx context.Context, m *api.Media) error {
+	runtime := Runtime(m)
+	if runtime == 0 {
+		return nil
+	}
+	duration, err := s.Prober.Duration(m.FileLocOri)
+	if err != nil {
+		helpers.Logf(ctx, "cannot check the runtime of %s: %s", m.QueueElem.Title, err)
+		return nil
+	}
+	if duration < runtime/ExtraRuntimeRatio {
+		return RejectedError{Reason: ReasonCorruptFile, Err: fmt.Errorf("%s lasts %s while %s lasts %s, it looks like an extra",
+			m.FileLocOri, duration.Round(time.Second), m.QueueElem.Title, runtime)}
+	}
+	return nil
+}
+
+// checkLanguages Return an error if no audio track of the file has a wanted
+// language, files whose tracks don't tell their language aren't checked
+func (s ProbeStrategy) checkLanguages(m *api.Media) error {
+	if len(s.AudioLanguages) == 0 {
+		return nil
+	}
+	known := false
+	for _, language := range m.AudioLanguages {
+		if language == "und" {
+			continue
+		}
+		known = true
+		for _, wanted := range s.AudioLanguages {
+			if strings.EqualFold(language, wanted) {
+				return nil
+			}
+		}
+	}
+	if !known {
+		return nil
+	}
+	return RejectedError{Reason: ReasonWrongLanguage, Err: fmt.Errorf("%s has audio in %s, none of %s",
+		m.FileLocOri, strings.Join(m.AudioLanguages, ", "), strings.Join(s.AudioLanguages, ", "))}
+}
+
+// ParseLanguages Parse a comma separated list of languages
+func ParseLanguages(value string) (languages []string) {
+	for _, language := range strings.Split(value, ",") {
+		language = strings.TrimSpace(language)
+		if language != "" {
+			languages = append(languages, language)
+		}
+	}
+	return
+}
+
+// Runtime Return how long the episode or movie of the media lasts, 0 if
+// the instance doesn't know
+func Runtime(m *api.Media) time.Duration {
+	switch m.Type {
+	case api.TypeMovie:
+		return time.Duration(m.QueueElem.Movie.Runtime) * time.Minute
+	case api.TypeShow:
+		return time.Duration(m.QueueElem.Series.Runtime) * time.Minute
+	}
+	return 0
+}
+
+// Languages Describe the languages of the tracks of the media
+func Languages(m *api.Media) string {
+	subtitles := strings.Join(m.SubtitleLanguages, ", ")
+	if subtitles == "" {
+		subtitles = "none"
+	}
+	return fmt.Sprintf("audio: %s, subtitles: %s", strings.Join(m.AudioLanguages, ", "), subtitles)
+}
@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"parserr/api"
+	"testing"
+)
+
+func TestCheckLanguages(t *testing.T) {
+	tests := []struct {
+		wanted   []string
+		audio    []string
+		rejected bool
+	}{
+		{nil, []string{"ita"}, false},
+		{[]string{"eng", "spa"}, []string{"ita", "spa"}, false},
+		{[]string{"eng"}, []string{"ENG"}, false},
+		{[]string{"eng"}, []string{"ita", "fre"}, true},
+		{[]string{"eng"}, []string{"und"}, false},
+		{[]string{"eng"}, nil, false},
+	}
+	for _, tt := range tests {
+		m := &api.Media{AudioLanguages: tt.audio}
+		err := ProbeStrategy{AudioLanguages: tt.wanted}.checkLanguages(m)
+		rejected, ok := asRejected(err)
+		if ok != tt.rejected || (ok && rejected.Reason != ReasonWrongLanguage) {
+			t.Errorf("wanted %v, audio %v: got %v, want rejected %v", tt.wanted, tt.audio, err, tt.rejected)
+		}
+	}
+}
+
+func TestParseLanguages(t *testing.T) {
+	got := ParseLanguages(" eng, spa,,")
+	if len(got) != 2 || got[0] != "eng" || got[1] != "spa" {
+		t.Errorf("ParseLanguages = %v, want [eng spa]", got)
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"parserr/helpers"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// EnvAnnotateQuality Add the quality of the release to destination names
+// without one
+const EnvAnnotateQuality = "PARSERR_ANNOTATE_QUALITY"
+
+// qualityRegex Resolutions and sources *arrs parse qualities from
+var qualityRegex = regexp.MustCompile(`(?i)\b([0-9]{3,4}p|sdtv|hdtv|web-?dl|web-?rip|bluray|blu-ray|bdrip|brrip|dvdrip|dvd|remux)\b`)
+
+// QualityStrategy Add the quality the instance grabbed the release as to
+// destination names that don't tell it, like Show S01E01.mkv, so the
+// instance imports the file with its quality instead of an unknown one.
+// Qualities out of the quality profile of the series or movie are reported,
+// the instance may refuse them. Music is skipped.
+type QualityStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Cache    *RunCache
+}
+
+// Fix ...
+func (s QualityStrategy) Fix(ctx context.Context, m *api.Media) error {
+	quality := m.QueueElem.Quality.EpisodeQuality.Name
+	if m.Type == api.TypeMusic || quality == "" || strings.EqualFold(quality, "unknown") {
+		return s.Strategy.Fix(ctx, m)
+	}
+	s.checkProfile(ctx, m, quality)
+	ext := filepath.Ext(m.FilenameFinal)
+	name := strings.TrimSuffix(m.FilenameFinal, ext)
+	if !qualityRegex.MatchString(name) {
+		m.FilenameFinal = name + "." + strings.Replace(quality, " ", ".", -1) + ext
+		helpers.Logf(ctx, "quality %s added to the name: %s", quality, m.FilenameFinal)
+	}
+	return s.Strategy.Fix(ctx, m)
+}
+
+// checkProfile Log if the quality isn't allowed by the quality profile of
+// the media
+func (s QualityStrategy) checkProfile(ctx context.Context, m *api.Media, quality string) {
+	id := m.QualityProfileID()
+	if id == 0 {
+		return
+	}
+	profiles, err := s.Cache.QualityProfiles(ctx, s.API)
+	if err != nil {
+		helpers.Logf(ctx, "cannot get quality profiles: %s", err)
+		return
+	}
+	profile, ok := api.QualityProfileOf(profiles, id)
+	if ok && !profile.Allows(quality) {
+		helpers.Logf(ctx, "quality %s of %s is not allowed by quality profile %s, %s may refuse it", quality, m.QueueElem.Title, profile.Name, s.API.GetName())
+	}
+}
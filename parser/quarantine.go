@@ -0,0 +1,260 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"parserr/store"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvQuarantineFolder Folder where suspicious files are moved to
+	EnvQuarantineFolder = "PARSERR_QUARANTINE_FOLDER"
+	// ItemQuarantined The item didn't pass validation and has been quarantined
+	ItemQuarantined = "quarantined"
+	// sidecarExtension Extension of the files describing quarantined files
+	sidecarExtension = ".json"
+)
+
+// Validator Return an error if the media shouldn't be fixed
+type Validator func(m *api.Media) error
+
+// QuarantinedError Returned when a file has been quarantined instead of fixed
+type QuarantinedError struct {
+	Name   string
+	Reason string
+}
+
+func (e QuarantinedError) Error() string {
+	return fmt.Sprintf("%s quarantined: %s", e.Name, e.Reason)
+}
+
+// QuarantineEntry Sidecar describing why a file has been quarantined
+type QuarantineEntry struct {
+	Name     string    `json:"name"`
+	Reason   string    `json:"reason"`
+	Date     time.Time `json:"date"`
+	APIType  string    `json:"apiType"`
+	Instance string    `json:"instance"`
+	Media    api.Media `json:"media"`
+}
+
+// Quarantine Folder holding files that couldn't be safely imported
+type Quarantine struct {
+	Dir   string
+	Mover Mover
+	// Clock Used to date the entries, RealClock if nil
+	Clock api.Clock
+	// Store Where the entries are kept, sidecars next to the files if nil
+	Store store.Store
+	// ReadOnly Files are neither quarantined, promoted nor deleted, what
+	// would be done is logged
+	ReadOnly bool
+}
+
+// wouldDo Return true and log the action if the quarantine is read-only, in
+// which case it must not be done
+func (q Quarantine) wouldDo(kind, format string, args ...interface{}) bool {
+	if !q.ReadOnly {
+		return false
+	}
+	log.Printf("read-only, would "+format, args...)
+	helpers.Planned(kind, format, args...)
+	return true
+}
+
+// Add Move the media file to quarantine and write a sidecar with the reason
+func (q Quarantine) Add(m *api.Media, reason string) error {
+	name := filepath.Base(m.FileLocOri)
+	dest := path.Join(q.Dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s is already in quarantine", name)
+	}
+	if q.wouldDo(helpers.ActionMove, "quarantine %s: %s", m.FileLocOri, reason) {
+		return nil
+	}
+	entry := QuarantineEntry{
+		Name:     name,
+		Reason:   reason,
+		Date:     api.ClockOrReal(q.Clock).Now(),
+		APIType:  m.Type,
+		Instance: m.Instance,
+		Media:    *m,
+	}
+	j, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = q.Mover.Move(m.FileLocOri, dest)
+	if err != nil {
+		return err
+	}
+	m.FileLocFinal = dest
+	logItemf(m, "file quarantined: %s (%s)", dest, reason)
+	if q.Store != nil {
+		return q.Store.Put(store.BucketQuarantine, name, j)
+	}
+	return ioutil.WriteFile(dest+sidecarExtension, j, 0664)
+}
+
+// List Return every quarantined file
+func (q Quarantine) List() (entries []QuarantineEntry, err error) {
+	if q.Store != nil {
+		return q.listStore()
+	}
+	files, err := ioutil.ReadDir(q.Dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), sidecarExtension) {
+			continue
+		}
+		entry, err := q.Get(strings.TrimSuffix(f.Name(), sidecarExtension))
+		if err != nil {
+			log.Printf("cannot read quarantine entry %s: %s", f.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// listStore Return every entry of the store sorted by name
+func (q Quarantine) listStore() (entries []QuarantineEntry, err error) {
+	values, err := q.Store.All(store.BucketQuarantine)
+	if err != nil {
+		return
+	}
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var entry QuarantineEntry
+		err := json.Unmarshal(values[name], &entry)
+		if err != nil {
+			log.Printf("cannot read quarantine entry %s: %s", name, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Get Return the entry of a quarantined file
+func (q Quarantine) Get(name string) (entry QuarantineEntry, err error) {
+	var j []byte
+	if q.Store != nil {
+		var ok bool
+		j, ok, err = q.Store.Get(store.BucketQuarantine, name)
+		if err == nil && !ok {
+			err = fmt.Errorf("%s is not in quarantine", name)
+		}
+	} else {
+		j, err = ioutil.ReadFile(path.Join(q.Dir, name+sidecarExtension))
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(j, &entry)
+	return
+}
+
+// deleteEntry Remove the entry of a quarantined file
+func (q Quarantine) deleteEntry(name string) error {
+	if q.Store != nil {
+		return q.Store.Delete(store.BucketQuarantine, name)
+	}
+	return os.Remove(path.Join(q.Dir, name+sidecarExtension))
+}
+
+// Promote Move a quarantined file back to its original location and fix it
+// with the given strategy, skipping the validation that sent it to quarantine
+func (q Quarantine) Promote(ctx context.Context, name string, s FixStrategy) error {
+	entry, err := q.Get(name)
+	if err != nil {
+		return err
+	}
+	m := entry.Media
+	if q.wouldDo(helpers.ActionMove, "promote %s from quarantine, moving it to %s and fixing it", name, m.FileLocOri) {
+		return nil
+	}
+	err = q.Mover.Move(path.Join(q.Dir, name), m.FileLocOri)
+	if err != nil {
+		return err
+	}
+	m.FileLocFinal = m.FileLocOri
+	m.Confidence = 1
+	helpers.Logf(ctx, "promoting %s from quarantine", name)
+	err = s.Fix(withItem(ctx, &m), &m)
+	if err != nil {
+		return err
+	}
+	return q.deleteEntry(name)
+}
+
+// Delete Remove a quarantined file and its sidecar
+func (q Quarantine) Delete(name string) error {
+	if q.wouldDo(helpers.ActionDelete, "delete %s from quarantine", name) {
+		return nil
+	}
+	err := os.Remove(path.Join(q.Dir, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return q.deleteEntry(name)
+}
+
+// QuarantineStrategy Move files that don't pass validation to quarantine
+// instead of fixing them
+type QuarantineStrategy struct {
+	Strategy   FixStrategy
+	Quarantine Quarantine
+	Validators []Validator
+}
+
+// Fix Quarantine the media if any validator fails, fix it otherwise
+func (s QuarantineStrategy) Fix(ctx context.Context, m *api.Media) error {
+	for _, validate := range s.Validators {
+		err := validate(m)
+		if err == nil {
+			continue
+		}
+		qErr := s.Quarantine.Add(m, err.Error())
+		if qErr != nil {
+			return fmt.Errorf("cannot quarantine %s: %s", m.FilenameOri, qErr)
+		}
+		return QuarantinedError{Name: filepath.Base(m.FileLocOri), Reason: err.Error()}
+	}
+	return s.Strategy.Fix(ctx, m)
+}
+
+// ValidateStrategy Refuse to fix files that don't pass validation, for when
+// there is no quarantine to send them to
+type ValidateStrategy struct {
+	Strategy   FixStrategy
+	Validators []Validator
+}
+
+// Fix Return the error of the first failing validator, fix the media otherwise
+func (s ValidateStrategy) Fix(ctx context.Context, m *api.Media) error {
+	for _, validate := range s.Validators {
+		err := validate(m)
+		if err != nil {
+			return err
+		}
+	}
+	return s.Strategy.Fix(ctx, m)
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"path"
+	"testing"
+)
+
+func TestQuarantineReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "a.mkv")
+	if err = ioutil.WriteFile(file, []byte("video"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	q := Quarantine{Dir: path.Join(dir, "quarantine"), Mover: BasicMover{}}
+	if err = os.Mkdir(q.Dir, 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.Add(&api.Media{FileLocOri: file}, "too small"); err != nil {
+		t.Fatal(err)
+	}
+	q.ReadOnly = true
+	if err = q.Promote(context.Background(), "a.mkv", failingStrategy{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.Delete("a.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = q.Get("a.mkv"); err != nil {
+		t.Errorf("entry lost in read-only mode: %s", err)
+	}
+	if _, err = os.Stat(path.Join(q.Dir, "a.mkv")); err != nil {
+		t.Errorf("file moved in read-only mode: %s", err)
+	}
+}
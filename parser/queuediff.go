@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"parserr/api"
+	"strings"
+	"time"
+)
+
+const (
+	// MinFailedRetry Wait before evaluating again an item whose fix failed
+	MinFailedRetry = 5 * time.Minute
+	// MaxFailedRetry Longest wait between evaluations of an item that keeps
+	// failing, the wait doubles with every failure
+	MaxFailedRetry = 6 * time.Hour
+)
+
+// QueueTracker Remember the broken queue items between cycles of the watch
+// mode so only new or changed ones are evaluated again. Items whose fix
+// failed are evaluated again after a backoff even if they haven't changed.
+type QueueTracker struct {
+	last     map[int]string
+	failures map[int]trackedFailure
+	// Clock Used to time the retries, RealClock if nil
+	Clock api.Clock
+}
+
+// trackedFailure Failed fixes of an unchanged item and when it's retried
+type trackedFailure struct {
+	count   int
+	retryAt time.Time
+}
+
+// NewQueueTracker ...
+func NewQueueTracker() *QueueTracker {
+	return &QueueTracker{}
+}
+
+// QueueDelta Changes of the broken queue items since the last cycle
+type QueueDelta struct {
+	New      int
+	Changed  int
+	Resolved int
+	// Retried Unchanged items whose fix failed, evaluated again
+	Retried int
+}
+
+func (d QueueDelta) String() string {
+	return fmt.Sprintf("%d new warnings, %d changed, %d resolved, %d failed retried since last cycle", d.New, d.Changed, d.Resolved, d.Retried)
+}
+
+// Track Return the media whose queue item is new or has changed since the
+// last call, or whose failed fix is due to be retried. Every media is
+// returned the first time.
+func (t *QueueTracker) Track(files []*api.Media) (changed []*api.Media, delta QueueDelta) {
+	now := api.ClockOrReal(t.Clock).Now()
+	current := make(map[int]string)
+	for _, m := range files {
+		id := m.QueueElem.ID
+		signature := queueSignature(m.QueueElem)
+		current[id] = signature
+		last, ok := t.last[id]
+		switch {
+		case t.last == nil:
+			// First cycle, everything is evaluated
+		case !ok:
+			delta.New++
+		case last != signature:
+			delta.Changed++
+			delete(t.failures, id)
+		case t.retryDue(id, now):
+			// retried once, again after the next failure
+			f := t.failures[id]
+			f.retryAt = now.Add(MaxFailedRetry)
+			t.failures[id] = f
+			delta.Retried++
+		default:
+			continue
+		}
+		changed = append(changed, m)
+	}
+	for id := range t.last {
+		if _, ok := current[id]; !ok {
+			delta.Resolved++
+			delete(t.failures, id)
+		}
+	}
+	if t.last != nil {
+		log.Print(delta)
+	}
+	t.last = current
+	return
+}
+
+// Forget Evaluate the queue item of the media again on the next call, like
+// if it were new
+func (t *QueueTracker) Forget(m *api.Media) {
+	delete(t.last, m.QueueElem.ID)
+}
+
+// Failed Evaluate the queue item of the media again after a backoff, its fix
+// failed and it may not change
+func (t *QueueTracker) Failed(m *api.Media) {
+	if t.failures == nil {
+		t.failures = make(map[int]trackedFailure)
+	}
+	f := t.failures[m.QueueElem.ID]
+	f.count++
+	wait := MinFailedRetry
+	for i := 1; i < f.count && wait < MaxFailedRetry; i++ {
+		wait *= 2
+	}
+	if wait > MaxFailedRetry {
+		wait = MaxFailedRetry
+	}
+	f.retryAt = api.ClockOrReal(t.Clock).Now().Add(wait)
+	t.failures[m.QueueElem.ID] = f
+}
+
+// retryDue Return true if the item failed and its retry is due
+func (t *QueueTracker) retryDue(id int, now time.Time) bool {
+	f, ok := t.failures[id]
+	return ok && !now.Before(f.retryAt)
+}
+
+// queueSignature Summary of what makes a queue item change
+func queueSignature(qe api.QueueElem) string {
+	var messages []string
+	for _, m := range qe.StatusMessages {
+		messages = append(messages, m.Title)
+	}
+	return strings.Join(append([]string{qe.Status, qe.TrackedDownloadStatus}, messages...), "|")
+}
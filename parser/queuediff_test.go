@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"parserr/api"
+	"testing"
+	"time"
+)
+
+func TestQueueTrackerRetriesFailed(t *testing.T) {
+	clock := &api.FakeClock{Time: time.Now()}
+	tracker := &QueueTracker{Clock: clock}
+	m := &api.Media{}
+	m.QueueElem.ID = 1
+	files := []*api.Media{m}
+	if changed, _ := tracker.Track(files); len(changed) != 1 {
+		t.Fatal("the first cycle doesn't evaluate the item")
+	}
+	tracker.Failed(m)
+	if changed, _ := tracker.Track(files); len(changed) != 0 {
+		t.Fatal("the failed item is retried before the backoff")
+	}
+	clock.Sleep(MinFailedRetry)
+	changed, delta := tracker.Track(files)
+	if len(changed) != 1 || delta.Retried != 1 {
+		t.Fatalf("the failed item isn't retried after the backoff: %s", delta)
+	}
+	tracker.Failed(m)
+	clock.Sleep(MinFailedRetry)
+	if changed, _ := tracker.Track(files); len(changed) != 0 {
+		t.Fatal("the second backoff isn't longer")
+	}
+	clock.Sleep(MinFailedRetry)
+	if changed, _ := tracker.Track(files); len(changed) != 1 {
+		t.Fatal("the failed item isn't retried after the second backoff")
+	}
+}
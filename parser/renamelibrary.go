@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"path/filepath"
+	"strings"
+)
+
+// isMedia Return true if the file is a video or a music track
+func isMedia(file string) bool {
+	return api.IsVideo(file) || api.IsAudio(file)
+}
+
+// RenameStep Move of a file of an unorganized library to where it belongs
+type RenameStep struct {
+	API   api.RRAPI
+	Media api.Media
+	// Dest Path where the file belongs, empty if there is an error
+	Dest string
+	Err  error
+}
+
+// PlanLibraryRename Walk the folder matching every media file with the series,
+// movies and albums of the apis, returning where each one must be moved. Files that
+// are already where they belong are left out.
+func PlanLibraryRename(ctx context.Context, apis []api.RRAPI, dir string, transliterate bool) (plan []RenameStep, err error) {
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isMedia(file) {
+			return nil
+		}
+		step := PlanRename(ctx, apis, file, transliterate)
+		if step.Err == nil && step.Dest == file {
+			return nil
+		}
+		plan = append(plan, step)
+		return ctx.Err()
+	})
+	return
+}
+
+// PlanRename Match the file with the first api that knows its series, movie
+// or album and find where it belongs, tracks are named after the one they match
+func PlanRename(ctx context.Context, apis []api.RRAPI, file string, transliterate bool) (step RenameStep) {
+	step.Media.FileLocOri = file
+	title := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for _, a := range apis {
+		p, err := a.Parse(ctx, title)
+		if err != nil {
+			step.Err = fmt.Errorf("cannot match with %s: %s", a.GetName(), err)
+			continue
+		}
+		if !p.Matched() {
+			continue
+		}
+		step.API = a
+		step.Media, step.Err = api.NewManualMedia(a, p, file)
+		if step.Err != nil {
+			return
+		}
+		if step.Media.Type == api.TypeMusic {
+			if step.Err = RenameTrack(ctx, a, &step.Media); step.Err != nil {
+				return
+			}
+		}
+		if transliterate {
+			step.Media.FilenameFinal = helpers.Transliterate(step.Media.FilenameFinal)
+		}
+		step.Dest, step.Err = LibraryStrategy{API: a}.Destination(ctx, &step.Media)
+		return
+	}
+	if step.Err == nil {
+		step.Err = fmt.Errorf("does not match any series, movie or album")
+	}
+	return
+}
+
+// ApplyRename Move every file of the plan where it belongs with the strategy
+// of its api, returning how many were moved. Steps with an error are skipped
+// and files that can't be moved are logged.
+func ApplyRename(ctx context.Context, plan []RenameStep, strategyOf func(api.RRAPI) FixStrategy) (moved, failed int) {
+	for i := range plan {
+		step := &plan[i]
+		if step.Err != nil {
+			continue
+		}
+		err := strategyOf(step.API).Fix(ctx, &step.Media)
+		if err != nil {
+			log.Printf("cannot move %s: %s", step.Media.FileLocOri, err)
+			failed++
+			continue
+		}
+		moved++
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"parserr/api"
+	"testing"
+)
+
+func TestIsMedia(t *testing.T) {
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"/lib/Show S01E01.mkv", true},
+		{"/lib/Movie (2020).MP4", true},
+		{"/lib/Artist/01 Track.flac", true},
+		{"/lib/Show S01E01.nfo", false},
+		{"/lib/cover.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := isMedia(tt.file); got != tt.want {
+			t.Errorf("isMedia(%q) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRename(t *testing.T) {
+	sonarr, radarr := namedAPI{name: "sonarr"}, namedAPI{name: "radarr"}
+	plan := []RenameStep{
+		{API: sonarr, Media: api.Media{FileLocOri: "/lib/a.mkv"}},
+		{Media: api.Media{FileLocOri: "/lib/unknown.mkv"}, Err: errors.New("does not match")},
+		{API: radarr, Media: api.Media{FileLocOri: "/lib/b.mkv"}},
+	}
+	var used []string
+	moved, failed := ApplyRename(context.Background(), plan, func(a api.RRAPI) FixStrategy {
+		used = append(used, a.GetName())
+		if a.GetName() == "radarr" {
+			return failingStrategy{errors.New("disk full")}
+		}
+		return loggingStrategy{}
+	})
+	if moved != 1 || failed != 1 {
+		t.Errorf("%d moved and %d failed, want 1 and 1", moved, failed)
+	}
+	if len(used) != 2 || used[0] != "sonarr" || used[1] != "radarr" {
+		t.Errorf("strategies of %v used, want the ones of sonarr and radarr", used)
+	}
+}
+
+// namedAPI API only knowing its name
+type namedAPI struct {
+	api.RRAPI
+	name string
+}
+
+func (a namedAPI) GetName() string { return a.name }
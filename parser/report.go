@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvReportFile File every run report is appended to, one JSON object
+	// per line as described by `parserr report schema`
+	EnvReportFile = "PARSERR_REPORT_FILE"
+	// ItemFixed The item has been fixed without errors
+	ItemFixed = "fixed"
+	// ItemFailed The item couldn't be fixed
+	ItemFailed = "failed"
+	// ItemPanicked Fixing the item caused a panic that has been recovered
+	ItemPanicked = "panicked"
+)
+
+// ItemReport Result of processing a single media item
+type ItemReport struct {
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// QueueID Id of the queue item, 0 if unknown
+	QueueID int `json:"queueId,omitempty"`
+	// DownloadID Id of the download in the download client, if known
+	DownloadID string `json:"downloadId,omitempty"`
+	// Stuck How long the item has been waiting since it was grabbed
+	Stuck time.Duration `json:"stuck,omitempty"`
+	// Warning How long the item has been in warning, 0 if unknown
+	Warning time.Duration `json:"warning,omitempty"`
+	// Languages Languages of the tracks, if the file has been probed
+	Languages string `json:"languages,omitempty"`
+	// Verification Whether the media server shows the item, if checked
+	Verification string `json:"verification,omitempty"`
+}
+
+func (i ItemReport) String() string {
+	title := i.Title
+	switch {
+	case i.Stuck > 0 && i.Warning > 0:
+		title = fmt.Sprintf("%s (stuck for %.0f hours, in warning for %.0f hours)", i.Title, i.Stuck.Hours(), i.Warning.Hours())
+	case i.Stuck > 0:
+		title = fmt.Sprintf("%s (stuck for %.0f hours)", i.Title, i.Stuck.Hours())
+	}
+	if i.Languages != "" {
+		title = fmt.Sprintf("%s [%s]", title, i.Languages)
+	}
+	if i.Verification != "" {
+		title = fmt.Sprintf("%s (%s)", title, i.Verification)
+	}
+	if i.Error == "" {
+		return fmt.Sprintf("[%s] %s", i.Status, title)
+	}
+	return fmt.Sprintf("[%s] %s: %s", i.Status, title, i.Error)
+}
+
+// Report Summary of every item processed during a run
+type Report struct {
+	// Time When the run finished, set when the report is written
+	Time time.Time `json:"time"`
+	// Instance Name of the api the report belongs to
+	Instance string       `json:"instance"`
+	Items    []ItemReport `json:"items"`
+	Library  *LibraryDiff `json:"library,omitempty"`
+	// Missing Media removed from the queue that still have to be searched
+	Missing []MissingItem `json:"missing,omitempty"`
+	// Clock Used to know how long items have been stuck, RealClock if nil
+	Clock api.Clock `json:"-"`
+}
+
+// Add Record the result of an item
+func (r *Report) Add(title, status string, err error) {
+	item := ItemReport{Title: title, Status: status}
+	if err != nil {
+		item.Error = err.Error()
+	}
+	r.Items = append(r.Items, item)
+}
+
+// AddMedia Record the result of a media
+func (r *Report) AddMedia(m *api.Media, status string, err error) {
+	r.Add(m.QueueElem.Title, status, err)
+	item := &r.Items[len(r.Items)-1]
+	item.QueueID, item.DownloadID = m.QueueElem.ID, m.QueueElem.DownloadID
+	now := api.ClockOrReal(r.Clock).Now()
+	item.Stuck = Age(m, now)
+	item.Warning = WarningAge(m, now)
+	if len(m.AudioLanguages) > 0 || len(m.SubtitleLanguages) > 0 {
+		item.Languages = Languages(m)
+	}
+	item.Verification = m.Verification
+}
+
+// Count Return how many items ended with the given status
+func (r Report) Count(status string) (n int) {
+	for _, i := range r.Items {
+		if i.Status == status {
+			n++
+		}
+	}
+	return
+}
+
+func (r Report) String() string {
+	lines := []string{fmt.Sprintf("run report of %s: %d fixed, %d self-healed, %d failed, %d quarantined, %d timed out, %d panicked, %d locked, %d cleaned",
+		r.Instance, r.Count(ItemFixed), r.Count(ItemSelfHealed), r.Count(ItemFailed), r.Count(ItemQuarantined),
+		r.Count(ItemTimedOut), r.Count(ItemPanicked), r.Count(ItemLocked), r.Count(ItemCleaned))}
+	if pending := r.Count(ItemPending); pending > 0 {
+		lines[0] += fmt.Sprintf(", partially completed with %d pending for the next run", pending)
+	}
+	for _, i := range r.Items {
+		lines = append(lines, "\t"+i.String())
+	}
+	if len(r.Missing) > 0 {
+		lines = append(lines, fmt.Sprintf("%d still missing, search them manually:", len(r.Missing)))
+		for _, m := range r.Missing {
+			lines = append(lines, "\t"+m.String())
+		}
+	}
+	if r.Library != nil {
+		lines = append(lines, r.Library.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Log Print the report using the standard logger
+func (r Report) Log() {
+	log.Print(r)
+}
+
+// reportFileMu Instances running in parallel append to the same file
+var reportFileMu sync.Mutex
+
+// Append Write the report as a JSON line at the end of the file, dating it
+// if it has no time
+func (r *Report) Append(path string) error {
+	if r.Time.IsZero() {
+		r.Time = api.ClockOrReal(r.Clock).Now()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	reportFileMu.Lock()
+	defer reportFileMu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
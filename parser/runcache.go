@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"strconv"
+	"sync"
+)
+
+// RunCache Lists the strategies fetch from the instance, reused for the rest
+// of the run so the media of a pack don't fetch them once each. Failed
+// requests aren't cached. Safe for concurrent use, not used if nil.
+type RunCache struct {
+	episodes map[int][]api.Episode
+	tags     map[string]api.Tag
+	tagged   map[string]bool
+	profiles []api.QualityProfile
+	queue    []api.QueueElem
+	mutex    sync.Mutex
+}
+
+// NewRunCache ...
+func NewRunCache() *RunCache {
+	return &RunCache{
+		episodes: make(map[int][]api.Episode),
+		tags:     make(map[string]api.Tag),
+		tagged:   make(map[string]bool),
+	}
+}
+
+// Episodes Return the episodes of the series, fetched once per run
+func (c *RunCache) Episodes(ctx context.Context, a api.RRAPI, seriesID int) ([]api.Episode, error) {
+	if c == nil {
+		return a.GetEpisodesBySeries(ctx, seriesID)
+	}
+	c.mutex.Lock()
+	episodes, ok := c.episodes[seriesID]
+	c.mutex.Unlock()
+	if ok {
+		return episodes, nil
+	}
+	episodes, err := a.GetEpisodesBySeries(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.episodes[seriesID] = episodes
+	return episodes, nil
+}
+
+// Tag Return the tag of the instance with the label, looked up or created
+// once per run
+func (c *RunCache) Tag(ctx context.Context, a api.RRAPI, label string) (api.Tag, error) {
+	if c == nil {
+		return api.EnsureTag(ctx, a, label)
+	}
+	c.mutex.Lock()
+	tag, ok := c.tags[label]
+	c.mutex.Unlock()
+	if ok {
+		return tag, nil
+	}
+	tag, err := api.EnsureTag(ctx, a, label)
+	if err != nil {
+		return tag, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tags[label] = tag
+	return tag, nil
+}
+
+// ApplyTag Give the tag to the series, movie or artist with the id, once
+// per run
+func (c *RunCache) ApplyTag(ctx context.Context, a api.RRAPI, id, tagID int) error {
+	if c == nil {
+		return a.ApplyTag(ctx, id, tagID)
+	}
+	key := strconv.Itoa(id) + ":" + strconv.Itoa(tagID)
+	c.mutex.Lock()
+	done := c.tagged[key]
+	c.mutex.Unlock()
+	if done {
+		return nil
+	}
+	err := a.ApplyTag(ctx, id, tagID)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tagged[key] = true
+	return nil
+}
+
+// QualityProfiles Return the quality profiles of the instance, fetched once
+// per run
+func (c *RunCache) QualityProfiles(ctx context.Context, a api.RRAPI) ([]api.QualityProfile, error) {
+	if c == nil {
+		return a.GetQualityProfiles(ctx)
+	}
+	c.mutex.Lock()
+	profiles := c.profiles
+	c.mutex.Unlock()
+	if profiles != nil {
+		return profiles, nil
+	}
+	profiles, err := a.GetQualityProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		profiles = []api.QualityProfile{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.profiles = profiles
+	return profiles, nil
+}
+
+// Queue Return the queue of the instance, asked once per run without the
+// response cache, so it's newer than the queue being fixed
+func (c *RunCache) Queue(ctx context.Context, a api.RRAPI) ([]api.QueueElem, error) {
+	if c == nil {
+		return a.GetQueue(api.Uncached(ctx))
+	}
+	c.mutex.Lock()
+	queue := c.queue
+	c.mutex.Unlock()
+	if queue != nil {
+		return queue, nil
+	}
+	queue, err := a.GetQueue(api.Uncached(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if queue == nil {
+		queue = []api.QueueElem{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.queue = queue
+	return queue, nil
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"testing"
+)
+
+// countingAPI API counting the lists fetched and the tags applied
+type countingAPI struct {
+	api.RRAPI
+	episodes, tags, applied, profiles, queues int
+}
+
+func (a *countingAPI) GetEpisodesBySeries(ctx context.Context, seriesID int) ([]api.Episode, error) {
+	a.episodes++
+	return []api.Episode{{ID: 1, SeasonNumber: 1, EpisodeNumber: 1}}, nil
+}
+
+func (a *countingAPI) GetTags(ctx context.Context) ([]api.Tag, error) {
+	a.tags++
+	return []api.Tag{{ID: 3, Label: "parserr"}}, nil
+}
+
+func (a *countingAPI) ApplyTag(ctx context.Context, id, tagID int) error {
+	a.applied++
+	return nil
+}
+
+func (a *countingAPI) GetQualityProfiles(ctx context.Context) ([]api.QualityProfile, error) {
+	a.profiles++
+	return []api.QualityProfile{}, nil
+}
+
+func (a *countingAPI) GetQueue(ctx context.Context) (queue []api.QueueElem, err error) {
+	a.queues++
+	for i := 1; i <= 20; i++ {
+		queue = append(queue, api.QueueElem{ID: i})
+	}
+	return
+}
+
+func (a *countingAPI) GetEpisode(ctx context.Context, id int) (api.Episode, error) {
+	return api.Episode{ID: id}, nil
+}
+
+func (a *countingAPI) GetName() string { return "sonarr" }
+
+func TestRunCache(t *testing.T) {
+	a := &countingAPI{}
+	cache := NewRunCache()
+	var s FixStrategy = loggingStrategy{}
+	s = EpisodeCheckStrategy{Strategy: s, API: a, Cache: cache}
+	s = AbsoluteStrategy{Strategy: s, API: a, Cache: cache}
+	s = QualityStrategy{Strategy: s, API: a, Cache: cache}
+	s = TagStrategy{Strategy: s, API: a, Label: "parserr", Cache: cache}
+	s = SelfHealStrategy{Strategy: s, API: a, Cache: cache}
+	for i := 1; i <= 20; i++ {
+		m := &api.Media{Type: api.TypeShow, FilenameOri: "Show - 001.mkv", FilenameFinal: "Show - S01E01.mkv", FileExtension: ".mkv"}
+		m.QueueElem.ID = i
+		m.QueueElem.Series.ID = 2
+		m.QueueElem.Series.QualityProfileID = 4
+		m.QueueElem.Quality.EpisodeQuality.Name = "HDTV-720p"
+		if err := s.Fix(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.episodes != 1 || a.tags != 1 || a.applied != 1 || a.profiles != 1 || a.queues != 1 {
+		t.Errorf("fetched episodes %d, tags %d, profiles %d and the queue %d times and tagged %d times, want once each",
+			a.episodes, a.tags, a.profiles, a.queues, a.applied)
+	}
+}
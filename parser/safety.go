@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"parserr/api"
+)
+
+const (
+	// EnvSafety Environment variable to select the safety level
+	EnvSafety = "PARSERR_SAFETY"
+	// SafetyStrict Never overwrite, never delete, only act on files we are
+	// sure about and verify every operation
+	SafetyStrict = "strict"
+	// SafetyPermissive Best-effort behavior
+	SafetyPermissive = "permissive"
+	// StrictMinConfidence Minimum confidence needed to fix a file in strict mode
+	StrictMinConfidence = 1.0
+	// EnvCreateLibraryFolders Create the folder of a series or movie that
+	// doesn't exist yet instead of failing when moving into it
+	EnvCreateLibraryFolders = "PARSERR_CREATE_LIBRARY_FOLDERS"
+	// EnvDeleteSuperseded Delete the file an episode or movie had before the
+	// fixed one was moved to the library and registered instead
+	EnvDeleteSuperseded = "PARSERR_DELETE_SUPERSEDED"
+)
+
+// Safety Global safety level of a run
+type Safety struct {
+	Level string
+	// CreateLibraryFolders Create missing series and movie folders
+	CreateLibraryFolders bool
+	// DeleteSuperseded Delete episode and movie files replaced by fixed
+	// ones, never in strict mode
+	DeleteSuperseded bool
+}
+
+// NewSafety Return the safety level with the given name, an empty name
+// means permissive
+func NewSafety(level string) (Safety, error) {
+	switch level {
+	case "", SafetyPermissive:
+		return Safety{Level: SafetyPermissive}, nil
+	case SafetyStrict:
+		return Safety{Level: SafetyStrict}, nil
+	}
+	return Safety{}, fmt.Errorf("unknown safety level %q, use %q or %q", level, SafetyStrict, SafetyPermissive)
+}
+
+// IsStrict ...
+func (s Safety) IsStrict() bool {
+	return s.Level == SafetyStrict
+}
+
+// AllowOverwrite Return true if existing files can be replaced
+func (s Safety) AllowOverwrite() bool {
+	return !s.IsStrict()
+}
+
+// AllowDelete Return true if files can be removed from disk
+func (s Safety) AllowDelete() bool {
+	return !s.IsStrict()
+}
+
+// MinConfidence Minimum confidence a media needs to be fixed
+func (s Safety) MinConfidence() float64 {
+	if s.IsStrict() {
+		return StrictMinConfidence
+	}
+	return 0
+}
+
+// Verify Return true if every operation must be verified
+func (s Safety) Verify() bool {
+	return s.IsStrict()
+}
+
+// Check Return an error if the media is not allowed to be fixed
+func (s Safety) Check(m *api.Media) error {
+	if m.Confidence < s.MinConfidence() {
+		return fmt.Errorf("confidence of %s is %.2f, %s mode requires %.2f",
+			m.FilenameOri, m.Confidence, s.Level, s.MinConfidence())
+	}
+	return nil
+}
+
+// Mover Wrap the mover so it honors the safety level
+func (s Safety) Mover(m Mover) Mover {
+	if s.AllowOverwrite() {
+		return m
+	}
+	return NoOverwriteMover{Mover: m}
+}
+
+// VerifyExists Return an error if the file doesn't exist, only when
+// verification is required
+func (s Safety) VerifyExists(path string) error {
+	if !s.Verify() {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("verification failed, %s not found: %s", path, err)
+	}
+	return nil
+}
+
+// NoOverwriteMover Mover that refuses to replace existing files
+type NoOverwriteMover struct {
+	Mover Mover
+}
+
+// Move ...
+func (m NoOverwriteMover) Move(from, to string) error {
+	if _, err := os.Stat(to); err == nil {
+		return fmt.Errorf("refusing to overwrite %s", to)
+	}
+	return m.Mover.Move(from, to)
+}
+
+// Mkdir ...
+func (m NoOverwriteMover) Mkdir(path string) error {
+	return m.Mover.Mkdir(path)
+}
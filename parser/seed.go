@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/client"
+	"parserr/helpers"
+	"path"
+	"strings"
+)
+
+const (
+	// EnvCopyUntilSeeded Copy the files instead of moving them and delete the
+	// originals once the download client has finished seeding them
+	EnvCopyUntilSeeded = "PARSERR_COPY_UNTIL_SEEDED"
+	// ItemSeeding The item has been fixed using a copy, the original is kept
+	// until the download client finishes seeding it
+	ItemSeeding = "seeding"
+)
+
+// SeedStrategy Record the originals of the fixed media, which must be fixed
+// using a CopyMover, so they are deleted once seeded. Copies left in the
+// download folder, renamed in place for the instance to import them, are
+// deleted with them.
+type SeedStrategy struct {
+	Strategy FixStrategy
+	State    *State
+}
+
+// Fix ...
+func (s SeedStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil {
+		return err
+	}
+	item := ItemState{
+		Status:     ItemSeeding,
+		Location:   m.FileLocOri,
+		DownloadID: m.QueueElem.DownloadID,
+		Title:      m.QueueElem.Title,
+		MediaType:  m.Type,
+		MediaID:    mediaID(m),
+	}
+	if m.FileLocFinal != m.FileLocOri && inFolder(m.FileLocFinal, m.DownloadFolder.Path) {
+		item.Copy = m.FileLocFinal
+	}
+	err = s.State.Put(seedKey(m), item)
+	if err != nil {
+		helpers.Logf(ctx, "cannot save state of %s, its original won't be deleted: %s", m.QueueElem.Title, err)
+	}
+	return nil
+}
+
+// CleanSeeded Delete the originals of the media of the api fixed by
+// SeedStrategy whose download has finished seeding, removing the download
+// from the client too if cleanup is enabled. Copies left in the download
+// folder are deleted too once the api has imported them, until then the
+// item waits. With a read-only state the deletions are planned.
+func CleanSeeded(ctx context.Context, a api.RRAPI, state *State, c client.Client, cleanup bool, safety Safety) {
+	seeder, ok := c.(client.Seeder)
+	if !ok {
+		log.Printf("%s can't tell when seeding finishes, originals are kept", c.GetType())
+		return
+	}
+	prefix := ItemSeeding + ":" + a.GetName() + ":"
+	removed := make(map[string]bool)
+	for key, item := range state.WithStatus(ItemSeeding) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		tag := item.logContext()
+		done, err := seeder.SeedingDone(item.DownloadID)
+		if err != nil {
+			helpers.Tagf(tag, "cannot check seeding of %s: %s", item.Location, err)
+			continue
+		}
+		if !done {
+			continue
+		}
+		if !safety.AllowDelete() {
+			helpers.Tagf(tag, "seeding finished, %s mode doesn't allow deleting %s", safety.Level, item.Location)
+			continue
+		}
+		itemCtx := helpers.WithLogTag(ctx, tag)
+		if item.Copy != "" && !item.media(a.GetName()).HasBeenDetected(itemCtx, a) {
+			helpers.Tagf(tag, "seeding finished, waiting for %s to import %s", a.GetName(), item.Copy)
+			continue
+		}
+		if !removeSeeded(item.Location, state.ReadOnly, tag) {
+			continue
+		}
+		if item.Copy != "" && !removeSeeded(item.Copy, state.ReadOnly, tag) {
+			// the original is gone, the copy is deleted on the next run
+			item.Location = item.Copy
+			item.Copy = ""
+			if err = state.Put(key, item); err != nil {
+				helpers.Tagf(tag, "cannot save state: %s", err)
+			}
+			continue
+		}
+		Events.Publish(Event{Type: EventItemCleaned, Title: item.Title, From: item.Location})
+		if cleanup && !removed[item.DownloadID] {
+			// downloads with several media are removed once
+			removed[item.DownloadID] = true
+			err = c.RemoveFromHistory(item.DownloadID)
+			if err != nil {
+				helpers.Tagf(tag, "cannot remove %s from %s: %s", item.DownloadID, c.GetType(), err)
+			}
+		}
+		err = state.Delete(key)
+		if err != nil {
+			helpers.Tagf(tag, "cannot save state: %s", err)
+		}
+	}
+}
+
+// removeSeeded Delete a seeded file, or plan it if read-only, returning
+// false if it couldn't be deleted
+func removeSeeded(file string, readOnly bool, tag string) bool {
+	if readOnly {
+		helpers.Tagf(tag, "read-only, would delete seeded %s", file)
+		helpers.Planned(helpers.ActionDelete, "delete seeded %s", file)
+		return true
+	}
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		helpers.Tagf(tag, "cannot delete seeded %s: %s", file, err)
+		return false
+	}
+	helpers.Tagf(tag, "seeding finished, deleted: %s", file)
+	return true
+}
+
+// inFolder Return true if the path is inside the folder
+func inFolder(p, folder string) bool {
+	if folder == "" {
+		return false
+	}
+	return strings.HasPrefix(path.Clean(p), strings.TrimSuffix(path.Clean(folder), "/")+"/")
+}
+
+// seedKey Identify the original of a media in the state
+func seedKey(m *api.Media) string {
+	return fmt.Sprintf("%s:%s", ItemSeeding, MediaKey(m))
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"parserr/store"
+	"path"
+	"testing"
+)
+
+// seededClient Download client whose downloads have all finished seeding
+type seededClient struct{}
+
+func (c seededClient) GetType() string                           { return "seeded" }
+func (c seededClient) RemoveFromHistory(downloadID string) error { return nil }
+func (c seededClient) SeedingDone(downloadID string) (bool, error) {
+	return true, nil
+}
+
+// importAPI API whose episodes have been imported if imported is true
+type importAPI struct {
+	api.RRAPI
+	imported bool
+}
+
+func (a importAPI) GetName() string { return "sonarr" }
+
+func (a importAPI) GetEpisode(ctx context.Context, id int) (api.Episode, error) {
+	return api.Episode{HasFile: a.imported}, nil
+}
+
+func TestCleanSeededCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	original, copied := path.Join(dir, "a.mkv"), path.Join(dir, "Show S01E01.mkv")
+	for _, f := range []string{original, copied} {
+		if err = ioutil.WriteFile(f, []byte("video"), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &api.Media{Instance: "sonarr", Type: api.TypeShow, FileLocOri: original, FileLocFinal: copied}
+	m.DownloadFolder.Path = dir
+	m.QueueElem.DownloadID = "ABC"
+	state, err := NewState(store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := SeedStrategy{Strategy: failingStrategy{}, State: state}
+	if err = s.Fix(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	safety := Safety{Level: SafetyPermissive}
+	CleanSeeded(context.Background(), importAPI{}, state, seededClient{}, false, safety)
+	if _, err = os.Stat(original); err != nil {
+		t.Error("the original was deleted before the copy was imported")
+	}
+	CleanSeeded(context.Background(), importAPI{imported: true}, state, seededClient{}, false, safety)
+	for _, f := range []string{original, copied} {
+		if _, err = os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s not deleted once seeded and imported", f)
+		}
+	}
+	if len(state.WithStatus(ItemSeeding)) != 0 {
+		t.Error("the item is still seeding")
+	}
+}
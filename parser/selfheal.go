@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"parserr/api"
+)
+
+// ItemSelfHealed The *arr imported the item by itself before it was fixed
+const ItemSelfHealed = "self-healed"
+
+// SelfHealedError Returned when the *arr imported the media by itself
+// between loading the queue and fixing it
+type SelfHealedError struct {
+	Name   string
+	Reason string
+}
+
+func (e SelfHealedError) Error() string {
+	return fmt.Sprintf("%s already imported, %s", e.Name, e.Reason)
+}
+
+// SelfHealStrategy Check again right before fixing the media that the *arr
+// hasn't imported it in the meantime
+type SelfHealStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	// Cache Shares the queue between the media of the run, it's asked
+	// for every media if nil
+	Cache *RunCache
+}
+
+// Fix Skip the media if it's not in the queue anymore or its file is gone
+// and the *arr has it, fix it otherwise. The instance is asked, cached
+// responses would be as old as the queue being fixed.
+func (s SelfHealStrategy) Fix(ctx context.Context, m *api.Media) error {
+	current := api.Uncached(ctx)
+	queue, err := s.Cache.Queue(ctx, s.API)
+	if err != nil {
+		return fmt.Errorf("cannot check queue before fixing %s: %s", m.QueueElem.Title, err)
+	}
+	queued := false
+	for _, qe := range queue {
+		if qe.ID == m.QueueElem.ID {
+			queued = true
+			break
+		}
+	}
+	if !queued {
+		return SelfHealedError{Name: m.QueueElem.Title, Reason: "it left the queue"}
+	}
+	if _, err := os.Stat(m.FileLocOri); os.IsNotExist(err) && m.HasBeenDetected(current, s.API) {
+		return SelfHealedError{Name: m.QueueElem.Title, Reason: "its file has been moved"}
+	}
+	return s.Strategy.Fix(ctx, m)
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"parserr/api"
+	"strconv"
+	"strings"
+)
+
+// EnvSizeLimits Comma separated list of quality=min-max, like 1080p=500MB-8GB
+const EnvSizeLimits = "PARSERR_SIZE_LIMITS"
+
+// sizeUnits Multipliers of the supported size suffixes
+var sizeUnits = []struct {
+	Suffix string
+	Bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// SizeLimit Expected size of the files of a quality, a zero bound is not checked
+type SizeLimit struct {
+	Quality string
+	Min     int64
+	Max     int64
+}
+
+// SizeLimits Expected sizes by quality, the first one whose quality is
+// contained in the quality name of the media applies
+type SizeLimits []SizeLimit
+
+// ParseSizeLimits Parse a list like 1080p=500MB-8GB,2160p=4GB-
+func ParseSizeLimits(value string) (limits SizeLimits, err error) {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		bounds := strings.SplitN(parts[len(parts)-1], "-", 2)
+		if len(parts) != 2 || len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid size limit %q, use quality=min-max", entry)
+		}
+		limit := SizeLimit{Quality: strings.ToLower(strings.TrimSpace(parts[0]))}
+		limit.Min, err = parseSize(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid size limit %q: %s", entry, err)
+		}
+		limit.Max, err = parseSize(bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid size limit %q: %s", entry, err)
+		}
+		if limit.Max > 0 && limit.Min > limit.Max {
+			return nil, fmt.Errorf("invalid size limit %q, min is greater than max", entry)
+		}
+		limits = append(limits, limit)
+	}
+	return
+}
+
+// parseSize Parse a size like 500MB, an empty size is 0
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	for _, unit := range sizeUnits {
+		if !strings.HasSuffix(value, unit.Suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.Suffix)), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid size %q", value)
+		}
+		return int64(n * float64(unit.Bytes)), nil
+	}
+	return 0, fmt.Errorf("invalid size %q, use a unit like MB or GB", value)
+}
+
+// Check Return an error if the file of the media is out of the expected size
+// of its quality
+func (l SizeLimits) Check(m *api.Media) error {
+	quality := strings.ToLower(m.QueueElem.Quality.EpisodeQuality.Name)
+	for _, limit := range l {
+		if !strings.Contains(quality, limit.Quality) {
+			continue
+		}
+		info, err := os.Stat(m.FileLocOri)
+		if err != nil {
+			return err
+		}
+		if limit.Min > 0 && info.Size() < limit.Min {
+			return RejectedError{Reason: ReasonCorruptFile, Err: fmt.Errorf("%s is %d MB, %s should be at least %d MB",
+				m.FilenameOri, info.Size()>>20, quality, limit.Min>>20)}
+		}
+		if limit.Max > 0 && info.Size() > limit.Max {
+			return RejectedError{Reason: ReasonCorruptFile, Err: fmt.Errorf("%s is %d MB, %s should be at most %d MB",
+				m.FilenameOri, info.Size()>>20, quality, limit.Max>>20)}
+		}
+		return nil
+	}
+	return nil
+}
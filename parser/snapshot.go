@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"parserr/api"
+	"sort"
+	"strings"
+)
+
+// LibrarySnapshot State of the library at some point of a run
+type LibrarySnapshot struct {
+	Queue []api.QueueElem
+	// HasFile Whether the media of every tracked item has a file, by queue
+	// id, as the items of a season pack share their title
+	HasFile map[int]bool
+	// Names Episode or movie of every tracked item, by queue id
+	Names map[int]string
+	// DiskUsage Size of the files of the tracked items left in the download
+	// folders
+	DiskUsage int64
+}
+
+// TakeSnapshot Save the state of the queue and of the tracked media, the
+// failed items being fixed, whether they have a file and the size of their
+// files in the download folders
+func TakeSnapshot(ctx context.Context, a api.RRAPI, tracked []*api.Media) (s LibrarySnapshot, err error) {
+	s.Queue, err = a.GetQueue(ctx)
+	if err != nil {
+		return
+	}
+	s.HasFile = make(map[int]bool)
+	s.Names = make(map[int]string)
+	for _, m := range tracked {
+		id := m.QueueElem.ID
+		s.HasFile[id] = m.HasBeenDetected(ctx, a)
+		s.Names[id] = mediaName(m)
+		if info, err := os.Stat(m.FileLocOri); err == nil {
+			s.DiskUsage += info.Size()
+		}
+	}
+	return
+}
+
+// mediaName Return the episode, movie or album of the media
+func mediaName(m *api.Media) string {
+	qe := m.QueueElem
+	switch m.Type {
+	case api.TypeShow:
+		return fmt.Sprintf("%s S%.2dE%.2d", qe.Series.Title, qe.Episode.SeasonNumber, qe.Episode.EpisodeNumber)
+	case api.TypeMovie:
+		return qe.Movie.Title
+	case api.TypeMusic:
+		return qe.Artist.ArtistName + " - " + qe.Album.Title
+	}
+	return qe.Title
+}
+
+// LibraryDiff What changed in the library between two snapshots
+type LibraryDiff struct {
+	GainedFile     []string `json:"gainedFile"`
+	QueueDelta     int      `json:"queueDelta"`
+	DiskUsageDelta int64    `json:"diskUsageDelta"`
+}
+
+// Diff Compare two snapshots, after must track the media of before
+func Diff(before, after LibrarySnapshot) (d LibraryDiff) {
+	gained := make(map[string]bool)
+	for id, hadFile := range before.HasFile {
+		if !hadFile && after.HasFile[id] && !gained[before.Names[id]] {
+			gained[before.Names[id]] = true
+			d.GainedFile = append(d.GainedFile, before.Names[id])
+		}
+	}
+	sort.Strings(d.GainedFile)
+	d.QueueDelta = len(after.Queue) - len(before.Queue)
+	d.DiskUsageDelta = after.DiskUsage - before.DiskUsage
+	return
+}
+
+func (d LibraryDiff) String() string {
+	lines := []string{fmt.Sprintf("library changes: %d gained a file, queue %+d, files in download folders %+d bytes",
+		len(d.GainedFile), d.QueueDelta, d.DiskUsageDelta)}
+	for _, title := range d.GainedFile {
+		lines = append(lines, "\t[gained file] "+title)
+	}
+	return strings.Join(lines, "\n")
+}
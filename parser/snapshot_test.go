@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"parserr/api"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSeasonPack(t *testing.T) {
+	// the items of a season pack share their title
+	names := map[int]string{1: "Show S01E01", 2: "Show S01E02", 3: "Show S01E03"}
+	before := LibrarySnapshot{HasFile: map[int]bool{1: false, 2: false, 3: true}, Names: names, DiskUsage: 300}
+	after := LibrarySnapshot{HasFile: map[int]bool{1: true, 2: false, 3: true}, Names: names, DiskUsage: 200}
+	before.Queue = make([]api.QueueElem, 3)
+	after.Queue = make([]api.QueueElem, 2)
+	d := Diff(before, after)
+	want := LibraryDiff{GainedFile: []string{"Show S01E01"}, QueueDelta: -1, DiskUsageDelta: -100}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Diff = %+v, want %+v", d, want)
+	}
+}
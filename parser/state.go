@@ -0,0 +1,419 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"parserr/store"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvStateFile File where the state of the processed items is kept
+	EnvStateFile = "PARSERR_STATE_FILE"
+	// ItemInProgress The item started being fixed, if a run finds an item in
+	// this state the previous run was interrupted while fixing it
+	ItemInProgress = "in-progress"
+)
+
+// ItemState Last known state of an item
+type ItemState struct {
+	Status   string    `json:"status"`
+	Location string    `json:"location"`
+	Updated  time.Time `json:"updated"`
+	// Attempts Times the action of the status has been done
+	Attempts int `json:"attempts,omitempty"`
+	// DownloadID Download of the item in the download client
+	DownloadID string `json:"downloadId,omitempty"`
+	// Title Title of the download, for items handled by a later run
+	Title string `json:"title,omitempty"`
+	// MediaType Type of the media, for items handled by a later run
+	MediaType string `json:"mediaType,omitempty"`
+	// MediaID Episode, movie or album of the item
+	MediaID int `json:"mediaId,omitempty"`
+	// Copy Copy of the file left in the download folder by a seeded item,
+	// deleted with the original
+	Copy string `json:"copy,omitempty"`
+	// Moves Moves done and folders created while fixing the item, undone
+	// by Recover if the run is interrupted
+	Moves []ItemMove `json:"moves,omitempty"`
+}
+
+// ItemMove Move of the file of an item, or folder created for it if From is
+// empty
+type ItemMove struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// logContext Describe the item as key=value pairs, like ItemContext, for
+// the items handled by a later run, which don't keep their queue item
+func (item ItemState) logContext() string {
+	var fields []string
+	if item.DownloadID != "" {
+		fields = append(fields, "download="+item.DownloadID)
+	}
+	if item.Title != "" {
+		fields = append(fields, fmt.Sprintf("title=%q", item.Title))
+	}
+	return strings.Join(fields, " ")
+}
+
+// State Persistent record of the items processed by previous runs, kept in
+// a store.Store. Safe for concurrent use.
+type State struct {
+	Store store.Store
+	Items map[string]ItemState
+	// Clock Used to date the items, RealClock if nil
+	Clock api.Clock
+	// ReadOnly Changes are only kept in memory, see View
+	ReadOnly bool
+	mu       sync.Mutex
+}
+
+// LoadState Read the state from a JSON file, a missing file is an empty state
+func LoadState(path string) (*State, error) {
+	st, err := store.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewState(st)
+}
+
+// NewState Read the state kept in the store
+func NewState(st store.Store) (*State, error) {
+	values, err := st.All(store.BucketState)
+	if err != nil {
+		return nil, err
+	}
+	s := &State{Store: st, Items: make(map[string]ItemState, len(values))}
+	for key, value := range values {
+		var item ItemState
+		err = json.Unmarshal(value, &item)
+		if err != nil {
+			return nil, fmt.Errorf("corrupted state of %s: %s", key, err)
+		}
+		s.Items[key] = item
+	}
+	return s, nil
+}
+
+// View Return a copy of the state whose changes aren't saved, so read-only
+// runs go through the same steps as the rest without modifying it
+func (s *State) View() *State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make(map[string]ItemState, len(s.Items))
+	for key, item := range s.Items {
+		items[key] = item
+	}
+	return &State{Store: store.ReadOnly(s.Store), Items: items, Clock: s.Clock, ReadOnly: true}
+}
+
+// Save Write every item to the store
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, item := range s.Items {
+		err := s.put(key, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put Write the item to the store
+func (s *State) put(key string, item ItemState) error {
+	j, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return s.Store.Put(store.BucketState, key, j)
+}
+
+// Get ...
+func (s *State) Get(key string) (item ItemState, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok = s.Items[key]
+	return
+}
+
+// Set Update the state of an item and save it
+func (s *State) Set(key, status, location string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Items[key] = ItemState{
+		Status:   status,
+		Location: location,
+		Updated:  api.ClockOrReal(s.Clock).Now(),
+	}
+	return s.put(key, s.Items[key])
+}
+
+// SetAttempts Update the status and attempts of an item and save it
+func (s *State) SetAttempts(key, status string, attempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Items[key] = ItemState{
+		Status:   status,
+		Attempts: attempts,
+		Updated:  api.ClockOrReal(s.Clock).Now(),
+	}
+	return s.put(key, s.Items[key])
+}
+
+// Put Replace the state of an item, dating it, and save it
+func (s *State) Put(key string, item ItemState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item.Updated = api.ClockOrReal(s.Clock).Now()
+	s.Items[key] = item
+	return s.put(key, item)
+}
+
+// Delete Forget an item and save the state
+func (s *State) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Items, key)
+	return s.Store.Delete(store.BucketState, key)
+}
+
+// WithStatus Return a copy of the items with the given status
+func (s *State) WithStatus(status string) map[string]ItemState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make(map[string]ItemState)
+	for key, item := range s.Items {
+		if item.Status == status {
+			items[key] = item
+		}
+	}
+	return items
+}
+
+// Recover Undo the half done moves of items interrupted in a previous run so
+// they can be fixed again. The moves journaled by JournalMover are undone
+// from the last one, putting back every file still where it was moved and
+// removing the folders created if they are empty. Without a journal only the
+// file left aside by maintain-path is restored.
+func (s *State) Recover(m Mover) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, item := range s.Items {
+		if item.Status != ItemInProgress {
+			continue
+		}
+		log.Printf("previous run was interrupted while fixing %s", key)
+		if !undoMoves(item.Moves, m) {
+			continue
+		}
+		tmpPath := item.Location + ".tmp"
+		if _, err := os.Stat(item.Location); os.IsNotExist(err) {
+			if _, err := os.Stat(tmpPath); err == nil {
+				log.Printf("restoring %s", item.Location)
+				err = m.Move(tmpPath, item.Location)
+				if err != nil {
+					log.Printf("cannot restore %s: %s", item.Location, err)
+					continue
+				}
+			}
+		}
+		delete(s.Items, key)
+		err := s.Store.Delete(store.BucketState, key)
+		if err != nil {
+			log.Printf("cannot save state: %s", err)
+		}
+	}
+}
+
+// undoMoves Undo the moves from the last one, return false if a file can't
+// be put back
+func undoMoves(moves []ItemMove, m Mover) bool {
+	for i := len(moves) - 1; i >= 0; i-- {
+		move := moves[i]
+		if move.From == "" {
+			// only removed if empty, the files in it are put back first
+			if err := os.Remove(move.To); err == nil {
+				log.Printf("removed folder %s", move.To)
+			}
+			continue
+		}
+		if _, err := os.Stat(move.To); err != nil {
+			continue
+		}
+		if _, err := os.Stat(move.From); err == nil {
+			// copied, the original is still there
+			continue
+		}
+		log.Printf("restoring %s from %s", move.From, move.To)
+		if err := m.Move(move.To, move.From); err != nil {
+			log.Printf("cannot restore %s: %s", move.From, err)
+			return false
+		}
+	}
+	return true
+}
+
+// journal Record the move in the item in progress whose file it moves, a
+// folder in the item it's named after or the only one in progress
+func (s *State) journal(move ItemMove) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var key string
+	var inProgress []string
+	for k, item := range s.Items {
+		if item.Status != ItemInProgress {
+			continue
+		}
+		inProgress = append(inProgress, k)
+		if move.From == "" {
+			if move.To == item.Location {
+				key = k
+			}
+			continue
+		}
+		if move.From == item.current() {
+			key = k
+		}
+	}
+	if key == "" && move.From == "" && len(inProgress) == 1 {
+		// folders of the library aren't named after the file
+		key = inProgress[0]
+	}
+	if key == "" {
+		return
+	}
+	item := s.Items[key]
+	item.Moves = append(item.Moves, move)
+	s.Items[key] = item
+	err := s.put(key, item)
+	if err != nil {
+		log.Printf("cannot save state: %s", err)
+	}
+}
+
+// current Return where the file of the item is after its journaled moves
+func (item ItemState) current() string {
+	for i := len(item.Moves) - 1; i >= 0; i-- {
+		if item.Moves[i].From != "" {
+			return item.Moves[i].To
+		}
+	}
+	return item.Location
+}
+
+// JournalMover Mover recording in the state the moves done while fixing the
+// items in progress, so Recover can undo them if the run is interrupted.
+// Moves are recorded before being done, folders once created.
+type JournalMover struct {
+	Mover Mover
+	State *State
+}
+
+// Move ...
+func (m JournalMover) Move(from, to string) error {
+	m.State.journal(ItemMove{From: from, To: to})
+	return m.Mover.Move(from, to)
+}
+
+// Mkdir ...
+func (m JournalMover) Mkdir(path string) error {
+	err := m.Mover.Mkdir(path)
+	if err == nil {
+		m.State.journal(ItemMove{To: path})
+	}
+	return err
+}
+
+// Pending Return the files that haven't been fixed yet
+func (s *State) Pending(files []*api.Media) (pending []*api.Media) {
+	for _, m := range files {
+		item, ok := s.Get(MediaKey(m))
+		if ok && (item.Status == ItemFixed || item.Status == ItemSelfHealed) {
+			logItemf(m, "already fixed, skipping: %s", m.QueueElem.Title)
+			continue
+		}
+		pending = append(pending, m)
+	}
+	return
+}
+
+// Summary Describe how many items there are by status and which ones are
+// being fixed right now
+func (s *State) Summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int)
+	var inProgress []string
+	for key, item := range s.Items {
+		counts[item.Status]++
+		if item.Status == ItemInProgress {
+			inProgress = append(inProgress, fmt.Sprintf("\t%s: %s since %s",
+				key, item.Location, item.Updated.Format("2006-01-02 15:04:05")))
+		}
+	}
+	var statuses []string
+	for status, n := range counts {
+		statuses = append(statuses, fmt.Sprintf("%d %s", n, status))
+	}
+	sort.Strings(statuses)
+	sort.Strings(inProgress)
+	summary := fmt.Sprintf("state of %d items", len(s.Items))
+	if len(statuses) > 0 {
+		summary += ": " + strings.Join(statuses, ", ")
+	}
+	lines := []string{summary}
+	return strings.Join(append(lines, inProgress...), "\n")
+}
+
+// MediaKey Identify a media between runs, namespaced by instance so
+// instances sharing a state file don't collide. Tracks of an album share
+// the download, they are told apart by their file.
+func MediaKey(m *api.Media) string {
+	if m.Type == api.TypeMusic {
+		return fmt.Sprintf("%s:%s:%s:%s", m.Instance, m.Type, m.QueueElem.DownloadID, m.FilenameOri)
+	}
+	ep := m.QueueElem.Episode
+	return fmt.Sprintf("%s:%s:%s:%d:%d", m.Instance, m.Type, m.QueueElem.DownloadID, ep.SeasonNumber, ep.EpisodeNumber)
+}
+
+// StatefulStrategy Record in the state the progress of every fix
+type StatefulStrategy struct {
+	Strategy FixStrategy
+	State    *State
+}
+
+// Fix Mark the media as in progress, fix it and record the result
+func (s StatefulStrategy) Fix(ctx context.Context, m *api.Media) error {
+	key := MediaKey(m)
+	err := s.State.Set(key, ItemInProgress, m.FileLocOri)
+	if err != nil {
+		return fmt.Errorf("cannot save state of %s: %s", m.QueueElem.Title, err)
+	}
+	fixErr := s.Strategy.Fix(ctx, m)
+	status := ItemFixed
+	if _, ok := fixErr.(QuarantinedError); ok {
+		status = ItemQuarantined
+	} else if _, ok := fixErr.(TimedOutError); ok {
+		status = ItemTimedOut
+	} else if _, ok := fixErr.(SelfHealedError); ok {
+		status = ItemSelfHealed
+	} else if fixErr != nil {
+		status = ItemFailed
+	}
+	err = s.State.Set(key, status, m.FileLocFinal)
+	if err != nil {
+		helpers.Logf(ctx, "cannot save state of %s: %s", m.QueueElem.Title, err)
+	}
+	return fixErr
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"parserr/store"
+	"path"
+	"testing"
+)
+
+func TestStateView(t *testing.T) {
+	st := store.NewMemory()
+	state, err := NewState(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = state.Set("sonarr:show:A:1:1", ItemFixed, "/tv/a.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	view := state.View()
+	if err = view.Set("sonarr:show:B:1:2", ItemFixed, "/tv/b.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if err = view.Delete("sonarr:show:A:1:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := view.Get("sonarr:show:B:1:2"); !ok {
+		t.Error("the view doesn't keep its changes in memory")
+	}
+	if _, ok := state.Get("sonarr:show:B:1:2"); ok {
+		t.Error("the view changed the state")
+	}
+	saved, err := NewState(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.Get("sonarr:show:A:1:1"); !ok {
+		t.Error("the view deleted a saved item")
+	}
+	if _, ok := saved.Get("sonarr:show:B:1:2"); ok {
+		t.Error("the view saved an item")
+	}
+}
+
+func TestRecoverJournaledMoves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	orig := path.Join(dir, "Show.S01E01.mkv")
+	season := path.Join(dir, "tv", "Season 01")
+	if err = os.Mkdir(path.Join(dir, "tv"), 0775); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		moves func(m Mover) error
+	}{
+		{"maintain-path in its own folder", func(m Mover) error {
+			_, err := moveFileToFolderWithSameName(context.Background(), orig, m)
+			return err
+		}},
+		{"maintain-path renamed", func(m Mover) error {
+			dest, err := moveFileToFolderWithSameName(context.Background(), orig, m)
+			if err != nil {
+				return err
+			}
+			return m.Move(dest, path.Join(orig, "Show - S01E01.mkv"))
+		}},
+		{"move-to-library", func(m Mover) error {
+			if err := m.Mkdir(season); err != nil {
+				return err
+			}
+			return m.Move(orig, path.Join(season, "Show - S01E01.mkv"))
+		}},
+	}
+	for _, tt := range tests {
+		if err = ioutil.WriteFile(orig, []byte("video"), 0664); err != nil {
+			t.Fatal(err)
+		}
+		state, err := NewState(store.NewMemory())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = state.Set("sonarr:show:A:1:1", ItemInProgress, orig); err != nil {
+			t.Fatal(err)
+		}
+		if err = tt.moves(JournalMover{Mover: BasicMover{}, State: state}); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		// interrupted here, the next run recovers it
+		state, err = NewState(state.Store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.Recover(BasicMover{})
+		if info, err := os.Stat(orig); err != nil || info.IsDir() {
+			t.Errorf("%s: %s not restored: %v", tt.name, orig, err)
+		}
+		if _, err := os.Stat(season); !os.IsNotExist(err) {
+			t.Errorf("%s: folder %s not removed", tt.name, season)
+		}
+		if _, ok := state.Get("sonarr:show:A:1:1"); ok {
+			t.Errorf("%s: recovered item still in progress", tt.name)
+		}
+		os.RemoveAll(orig)
+	}
+}
@@ -1,68 +1,182 @@
 package parser
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"parserr/api"
+	"parserr/helpers"
 	"path"
 	"path/filepath"
+	"strings"
 )
 
 // FixStrategy ...
 type FixStrategy interface {
-	Fix(m *api.Media) error
+	Fix(ctx context.Context, m *api.Media) error
 }
 
 // MaintainPathStrategy Rename file in place if its inside a folder or
 // create a folder with the name of the file and move it to that folder
 type MaintainPathStrategy struct {
-	API   api.RRAPI
-	Mover Mover
+	API    api.RRAPI
+	Mover  Mover
+	Safety Safety
 }
 
 // ForceImportStrategy Move file
 type ForceImportStrategy struct {
-	API   api.RRAPI
-	Mover Mover
+	API    api.RRAPI
+	Mover  Mover
+	Safety Safety
 }
 
-// StrategyFactory Return the fix strategy depending on the api
-func StrategyFactory(a api.RRAPI, m Mover) FixStrategy {
-	if a.GetType() == api.TypeMovie {
-		return MaintainPathStrategy{
-			API:   a,
-			Mover: m,
+// LibraryStrategy Move the file straight into the library, inside the season
+// folder the *arr would use, and rescan the series / movie
+type LibraryStrategy struct {
+	API    api.RRAPI
+	Mover  Mover
+	Safety Safety
+}
+
+const (
+	// StrategyMaintainPath Name of MaintainPathStrategy
+	StrategyMaintainPath = "maintain-path"
+	// StrategyForceImport Name of ForceImportStrategy
+	StrategyForceImport = "force-import"
+	// StrategyMoveToLibrary Name of LibraryStrategy
+	StrategyMoveToLibrary = "move-to-library"
+)
+
+// StrategyNames Names of the strategies NewStrategy creates
+var StrategyNames = []string{StrategyMaintainPath, StrategyForceImport, StrategyMoveToLibrary}
+
+// SplitFolderStrategy Split a download folder followed by =strategy into
+// both, strategy is empty if it has none. Only a known strategy is split
+// off, the folder itself can contain "=".
+func SplitFolderStrategy(entry string) (folder, strategy string) {
+	for _, name := range StrategyNames {
+		if strings.HasSuffix(entry, "="+name) {
+			return strings.TrimSuffix(entry, "="+name), name
+		}
+	}
+	return entry, ""
+}
+
+// StrategyFactory Return the fix strategy depending on the api, download
+// folders with their own strategy use it instead of the api one
+func StrategyFactory(a api.RRAPI, m Mover, safety Safety) FixStrategy {
+	m = safety.Mover(m)
+	defaultName := DefaultStrategy(a)
+	def, _ := NewStrategy(defaultName, a, m, safety)
+	folderStrategy := FolderStrategy{Default: def, Strategies: map[string]FixStrategy{}}
+	for _, folder := range a.GetDownloadFolders() {
+		if folder.Strategy == "" {
+			continue
+		}
+		s, err := NewStrategy(folder.Strategy, a, m, safety)
+		if err != nil {
+			log.Printf("%s, using %s for %s", err, defaultName, folder.Path)
+			continue
 		}
+		folderStrategy.Strategies[folder.Path] = s
+	}
+	if len(folderStrategy.Strategies) == 0 {
+		return def
+	}
+	return folderStrategy
+}
+
+// DefaultStrategy Return the name of the strategy used by the download
+// folders of the api without their own one
+func DefaultStrategy(a api.RRAPI) string {
+	if a.GetType() == api.TypeMovie || a.GetType() == api.TypeMusic {
+		return StrategyMaintainPath
 	}
-	return ForceImportStrategy{
-		API:   a,
-		Mover: m,
+	return StrategyForceImport
+}
+
+// NewStrategy Return the strategy with the given name
+func NewStrategy(name string, a api.RRAPI, m Mover, safety Safety) (FixStrategy, error) {
+	switch name {
+	case StrategyMaintainPath:
+		return MaintainPathStrategy{API: a, Mover: m, Safety: safety}, nil
+	case StrategyForceImport:
+		return ForceImportStrategy{API: a, Mover: m, Safety: safety}, nil
+	case StrategyMoveToLibrary:
+		return LibraryStrategy{API: a, Mover: m, Safety: safety}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}
+
+// FolderStrategy Fix every media with the strategy of the download folder
+// where it has been found
+type FolderStrategy struct {
+	Default    FixStrategy
+	Strategies map[string]FixStrategy
+}
+
+// Fix ...
+func (s FolderStrategy) Fix(ctx context.Context, m *api.Media) error {
+	return s.For(m).Fix(ctx, m)
+}
+
+// For Return the strategy fixing the media, the default one if its download
+// folder has none
+func (s FolderStrategy) For(m *api.Media) FixStrategy {
+	if strategy, ok := s.Strategies[m.DownloadFolder.Path]; ok {
+		return strategy
 	}
+	return s.Default
+}
+
+// DestinationStrategy Strategy telling where it would move the file of a
+// media, without moving it
+type DestinationStrategy interface {
+	Destination(ctx context.Context, m *api.Media) (string, error)
 }
 
 // Fix Rename file in place if its inside a folder or
 // create a folder with the name of the file and move it to that folder
-func (s MaintainPathStrategy) Fix(m *api.Media) (err error) {
-	err = s.move(m)
+func (s MaintainPathStrategy) Fix(ctx context.Context, m *api.Media) (err error) {
+	err = s.Safety.Check(m)
 	if err != nil {
 		return
 	}
-	return nil
+	err = s.move(ctx, m)
+	if err != nil {
+		return
+	}
+	return s.Safety.VerifyExists(m.FileLocFinal)
 }
 
-func (s MaintainPathStrategy) move(m *api.Media) (err error) {
-	log.Printf("fixing: %s", m.FilenameOri)
+// Destination Return the path the file is renamed to, in a folder with its
+// own name if it isn't in one
+func (s MaintainPathStrategy) Destination(ctx context.Context, m *api.Media) (string, error) {
+	dir := filepath.Dir(m.FileLocOri)
+	if s.isOnRoot(m) {
+		dir = m.FileLocOri
+	}
+	return path.Join(dir, m.FilenameFinal), nil
+}
+
+func (s MaintainPathStrategy) isOnRoot(m *api.Media) bool {
+	return m.QueueElem.Title == m.FilenameOri
+}
+
+func (s MaintainPathStrategy) move(ctx context.Context, m *api.Media) (err error) {
+	helpers.Logf(ctx, "fixing: %s", m.FilenameOri)
 	fileLocation := m.FileLocOri
-	fileIsOnRoot := m.QueueElem.Title == m.FilenameOri
-	if fileIsOnRoot {
-		fileLocation, err = moveFileToFolderWithSameName(m.FileLocOri, s.Mover)
+	if s.isOnRoot(m) {
+		fileLocation, err = moveFileToFolderWithSameName(ctx, m.FileLocOri, s.Mover)
 		if err != nil {
-			log.Printf("cannot move file to a folder: %s", err.Error())
+			helpers.Logf(ctx, "cannot move file to a folder: %s", err.Error())
 			return err
 		}
 	}
-	newFileLocation := path.Join(filepath.Dir(fileLocation), m.FilenameFinal)
-	log.Printf("moving from %s to %s", fileLocation, newFileLocation)
+	newFileLocation, _ := s.Destination(ctx, m)
+	helpers.Logf(ctx, "moving from %s to %s", fileLocation, newFileLocation)
 	err = s.Mover.Move(fileLocation, newFileLocation)
 	if err != nil {
 		return err
@@ -71,8 +185,8 @@ func (s MaintainPathStrategy) move(m *api.Media) (err error) {
 	return nil
 }
 
-func moveFileToFolderWithSameName(fileLocation string, m Mover) (dest string, err error) {
-	log.Printf("moving file to a folder with its own name")
+func moveFileToFolderWithSameName(ctx context.Context, fileLocation string, m Mover) (dest string, err error) {
+	helpers.Logf(ctx, "moving file to a folder with its own name")
 	tmpPath := fileLocation + ".tmp"
 	err = m.Move(fileLocation, tmpPath)
 	if err != nil {
@@ -93,41 +207,358 @@ func moveFileToFolderWithSameName(fileLocation string, m Mover) (dest string, er
 
 // Fix Rename file in place if its inside a folder or
 // create a folder with the name of the file and move it to that folder
-func (s ForceImportStrategy) Fix(m *api.Media) (err error) {
-	log.Printf("move to own folder strategy: %s", m.FilenameOri)
-	err = s.moveToFolder(m)
+func (s ForceImportStrategy) Fix(ctx context.Context, m *api.Media) (err error) {
+	helpers.Logf(ctx, "move to own folder strategy: %s", m.FilenameOri)
+	err = s.Safety.Check(m)
+	if err != nil {
+		return
+	}
+	err = s.moveToFolder(ctx, m)
 	if err != nil {
 		return
 	}
 	newDir := filepath.Dir(m.FileLocFinal)
-	s.orderToImportFiles(newDir)
-	if _, err := os.Stat(newDir); err == nil {
-		log.Printf("file not imported correctly: %s", m.FileLocFinal)
+	scanErr := s.orderToImportFiles(ctx, newDir)
+	if _, statErr := os.Stat(newDir); statErr == nil {
+		// The instance leaves the folder if it didn't import the file, the
+		// item must be fixed again
+		helpers.Logf(ctx, "file not imported correctly: %s", m.FileLocFinal)
+		helpers.Logf(ctx, "moving file back from: %s to: %s", m.FileLocFinal, m.FileLocOri)
 		err = s.Mover.Move(m.FileLocFinal, m.FileLocOri)
-		log.Printf("moving file back from: %s to: %s", m.FileLocFinal, m.FileLocOri)
-		os.Remove(newDir)
+		if err != nil {
+			return fmt.Errorf("%s not imported, cannot move it back from %s: %s", m.QueueElem.Title, m.FileLocFinal, err)
+		}
+		if s.Safety.AllowDelete() {
+			os.Remove(newDir)
+		}
 		m.FileLocFinal = m.FileLocOri
+		if scanErr != nil {
+			return fmt.Errorf("cannot import %s: %s", m.QueueElem.Title, scanErr)
+		}
+		return fmt.Errorf("%s has not been imported from %s", m.QueueElem.Title, newDir)
+	}
+	if scanErr != nil {
+		helpers.Logf(ctx, "import of %s failed but its folder is gone: %s", m.QueueElem.Title, scanErr)
+	}
+	if s.Safety.Verify() && !m.HasBeenDetected(ctx, s.API) {
+		return fmt.Errorf("verification failed, %s has not been imported", m.QueueElem.Title)
 	}
 	return nil
 }
 
-func (s ForceImportStrategy) moveToFolder(m *api.Media) (err error) {
-	destDir := path.Join(s.API.GetDownloadFolder(), m.FilenameFinal)
-	destFile := path.Join(destDir, destDir+m.FileExtension)
-	s.Mover.Mkdir(destDir)
+// Destination Return the path of the file in a folder with its name in the
+// download folder, where the instance is told to import it from
+func (s ForceImportStrategy) Destination(ctx context.Context, m *api.Media) (string, error) {
+	return s.destination(m), nil
+}
+
+func (s ForceImportStrategy) destination(m *api.Media) string {
+	downloadFolder := m.DownloadFolder.Path
+	if downloadFolder == "" {
+		downloadFolder = s.API.GetDownloadFolder()
+	}
+	destDir := path.Join(downloadFolder, strings.TrimSuffix(m.FilenameFinal, m.FileExtension))
+	return path.Join(destDir, m.FilenameFinal)
+}
+
+func (s ForceImportStrategy) moveToFolder(ctx context.Context, m *api.Media) (err error) {
+	destFile := s.destination(m)
+	err = ensureDir(s.Mover, filepath.Dir(destFile))
+	if err != nil {
+		helpers.Logf(ctx, "cannot move file: %s", err.Error())
+		return
+	}
 	err = s.Mover.Move(m.FileLocOri, destFile)
 	if err != nil {
-		log.Printf("cannot move file: %s", err.Error())
+		helpers.Logf(ctx, "cannot move file: %s", err.Error())
 		return
 	}
 	m.FileLocFinal = destFile
-	log.Printf("file moved, new destination: %s", m.FileLocFinal)
+	helpers.Logf(ctx, "file moved, new destination: %s", m.FileLocFinal)
 	return
 }
 
-func (s ForceImportStrategy) orderToImportFiles(path string) (err error) {
-	log.Printf("forcing to import files from: %s", path)
+func (s ForceImportStrategy) orderToImportFiles(ctx context.Context, path string) (err error) {
+	helpers.Logf(ctx, "forcing to import files from: %s", path)
 	command := s.API.DownloadScan(path)
-	_, err = s.API.ExecuteCommandAndWait(command, api.DefaultRetries)
+	_, err = s.API.ExecuteCommandAndWait(ctx, command, api.DefaultRetries)
 	return
 }
+
+// Fix Move the file to the folder of its series season or movie and rescan it
+func (s LibraryStrategy) Fix(ctx context.Context, m *api.Media) (err error) {
+	helpers.Logf(ctx, "move to library strategy: %s", m.FilenameOri)
+	err = s.Safety.Check(m)
+	if err != nil {
+		return
+	}
+	root, err := s.root(ctx, m)
+	if err != nil {
+		return fmt.Errorf("cannot find library folder of %s: %s", m.QueueElem.Title, err)
+	}
+	err = s.checkRootFolder(ctx, root, m)
+	if err != nil {
+		return
+	}
+	err = s.checkRoot(ctx, root)
+	if err != nil {
+		return
+	}
+	dir, err := s.destination(ctx, m)
+	if err != nil {
+		return fmt.Errorf("cannot find library folder of %s: %s", m.QueueElem.Title, err)
+	}
+	if dir != root {
+		err = ensureDir(s.Mover, dir)
+		if err != nil {
+			return
+		}
+	}
+	var previous api.MediaFile
+	if m.Type != api.TypeMusic {
+		previous, err = s.currentFile(ctx, m)
+		if err != nil {
+			return fmt.Errorf("cannot get library file of %s: %s", m.QueueElem.Title, err)
+		}
+	}
+	dest := path.Join(dir, m.FilenameFinal)
+	helpers.Logf(ctx, "moving from %s to %s", m.FileLocOri, dest)
+	err = s.Mover.Move(m.FileLocOri, dest)
+	if err != nil {
+		return
+	}
+	m.FileLocFinal = dest
+	err = s.Safety.VerifyExists(m.FileLocFinal)
+	if err != nil {
+		return
+	}
+	command := s.API.ScanCommand()
+	if m.Type == api.TypeMovie {
+		command.MovieID = m.QueueElem.Movie.ID
+	} else if m.Type == api.TypeMusic {
+		command.ArtistID = m.QueueElem.Artist.ID
+	} else {
+		command.SeriesID = m.QueueElem.Series.ID
+	}
+	_, err = s.API.ExecuteCommandAndWait(ctx, command, api.DefaultRetries)
+	if err != nil || m.Type == api.TypeMusic {
+		return
+	}
+	return s.registered(ctx, m, previous.ID)
+}
+
+// currentFile Return the file the instance has for the episode or movie of
+// the media, with id 0 if it has none
+func (s LibraryStrategy) currentFile(ctx context.Context, m *api.Media) (api.MediaFile, error) {
+	if m.Type == api.TypeMovie {
+		movie, err := s.API.GetMovie(ctx, m.QueueElem.Movie.ID)
+		if err != nil || !movie.HasFile || movie.MovieFile.Path != "" {
+			return movie.MovieFile, err
+		}
+		// old versions leave the file out of the movie
+		files, err := s.API.GetMovieFiles(ctx, movie.ID)
+		if err != nil || len(files) == 0 {
+			return api.MediaFile{}, err
+		}
+		if files[0].Path == "" {
+			files[0].Path = path.Join(movie.Path, files[0].RelativePath)
+		}
+		return files[0], nil
+	}
+	episode, err := s.API.GetEpisode(ctx, m.QueueElem.Episode.ID)
+	if err != nil || episode.EpisodeFileID == 0 {
+		return api.MediaFile{}, err
+	}
+	return s.API.GetEpisodeFile(ctx, episode.EpisodeFileID)
+}
+
+// UnregisteredError Returned when the file has been moved to the library
+// but the instance doesn't have it as the file of its episode or movie. The
+// release isn't at fault, its file is already in the library.
+type UnregisteredError struct {
+	Name string
+	Path string
+	Err  error
+}
+
+func (e UnregisteredError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("cannot verify %s has been registered: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf("verification failed, the rescan didn't register %s as the file of %s", e.Path, e.Name)
+}
+
+// registered Return an UnregisteredError if the rescan didn't register the
+// file of the media as the file of its episode or movie. If superseded files
+// are deleted, the file it had before and any other file of the movie are.
+func (s LibraryStrategy) registered(ctx context.Context, m *api.Media, previousFileID int) error {
+	if _, err := os.Stat(m.FileLocFinal); os.IsNotExist(err) {
+		// nothing has been moved in read-only mode
+		return nil
+	}
+	file, err := s.currentFile(ctx, m)
+	if err != nil {
+		return UnregisteredError{Name: m.QueueElem.Title, Path: m.FileLocFinal, Err: err}
+	}
+	if file.ID == 0 || path.Clean(file.Path) != path.Clean(m.FileLocFinal) {
+		return UnregisteredError{Name: m.QueueElem.Title, Path: m.FileLocFinal}
+	}
+	if !s.Safety.DeleteSuperseded || !s.Safety.AllowDelete() {
+		return nil
+	}
+	superseded := make(map[int]bool)
+	if previousFileID != 0 {
+		superseded[previousFileID] = true
+	}
+	if m.Type == api.TypeMovie {
+		files, err := s.API.GetMovieFiles(ctx, m.QueueElem.Movie.ID)
+		if err != nil {
+			helpers.Logf(ctx, "cannot look for duplicated files of %s: %s", m.QueueElem.Title, err)
+		}
+		for _, f := range files {
+			superseded[f.ID] = true
+		}
+	}
+	delete(superseded, file.ID)
+	for id := range superseded {
+		if !s.superseded(ctx, m, id) {
+			helpers.Logf(ctx, "not deleting %s file %d, it's no longer superseded by %s", m.Type, id, m.FileLocFinal)
+			continue
+		}
+		helpers.Logf(ctx, "deleting %s file %d superseded by %s", m.Type, id, m.FileLocFinal)
+		if m.Type == api.TypeMovie {
+			err = s.API.DeleteMovieFile(ctx, id)
+		} else {
+			err = s.API.DeleteEpisodeFile(ctx, id)
+		}
+		if err != nil {
+			helpers.Logf(ctx, "cannot delete superseded %s file %d: %s", m.Type, id, err)
+		}
+	}
+	return nil
+}
+
+// superseded Return true if the file with the id is still a file of the
+// media other than its registered one, read again right before deleting it
+// since the instance may have replaced or deleted it in the meantime
+func (s LibraryStrategy) superseded(ctx context.Context, m *api.Media, id int) bool {
+	current, err := s.currentFile(ctx, m)
+	if err != nil || current.ID == id || path.Clean(current.Path) != path.Clean(m.FileLocFinal) {
+		return false
+	}
+	if m.Type == api.TypeMovie {
+		files, err := s.API.GetMovieFiles(ctx, m.QueueElem.Movie.ID)
+		if err != nil {
+			return false
+		}
+		for _, f := range files {
+			if f.ID == id {
+				return path.Clean(f.Path) != path.Clean(m.FileLocFinal)
+			}
+		}
+		return false
+	}
+	file, err := s.API.GetEpisodeFile(ctx, id)
+	return err == nil && file.ID == id && path.Clean(file.Path) != path.Clean(m.FileLocFinal)
+}
+
+// root Return the folder of the series, movie or artist in the library
+func (s LibraryStrategy) root(ctx context.Context, m *api.Media) (root string, err error) {
+	if m.Type == api.TypeMovie {
+		root, err = s.API.GetPath(ctx, m.QueueElem.Movie.ID)
+	} else if m.Type == api.TypeMusic {
+		root, err = s.API.GetPath(ctx, m.QueueElem.Artist.ID)
+	} else {
+		root, err = s.API.GetPath(ctx, m.QueueElem.Series.ID)
+	}
+	if err == nil && root == "" {
+		err = fmt.Errorf("it has no path")
+	}
+	return
+}
+
+// DiskSpaceReserve Part of the disks kept free by LibraryStrategy, 1 of
+// every DiskSpaceReserve bytes
+const DiskSpaceReserve = 100
+
+// checkRootFolder Return an error if the folder of the series, movie or
+// artist isn't in a root folder of the instance or moving the file there
+// would fill its disk. Root folders that can't be listed aren't checked.
+func (s LibraryStrategy) checkRootFolder(ctx context.Context, root string, m *api.Media) error {
+	folders, err := s.API.GetRootFolders(ctx)
+	if err != nil {
+		helpers.Logf(ctx, "cannot get root folders, not checking free space: %s", err)
+		return nil
+	}
+	folder, ok := api.RootFolderOf(folders, root)
+	if !ok {
+		return fmt.Errorf("library folder %s is not in any root folder of %s", root, s.API.GetName())
+	}
+	info, err := os.Stat(m.FileLocOri)
+	if err != nil {
+		return err
+	}
+	// the disk tells how much space must be kept, the root folder only
+	// knows the free space, which is unknown when it is zero
+	free, reserve := folder.FreeSpace, int64(0)
+	disks, err := s.API.GetDiskSpace(ctx)
+	if err != nil {
+		helpers.Logf(ctx, "cannot get disk space, using the free space of the root folder: %s", err)
+	} else if disk, ok := api.DiskSpaceOf(disks, root); ok {
+		free, reserve = disk.FreeSpace, disk.TotalSpace/DiskSpaceReserve
+	}
+	if free > 0 && info.Size() > free-reserve {
+		return fmt.Errorf("not enough free space in %s for %s, %d bytes needed and %d free keeping %d", folder.Path, m.FileLocOri, info.Size(), free, reserve)
+	}
+	return nil
+}
+
+// checkRoot Return an error if the folder of the series or movie doesn't
+// exist, the *arr only creates it on the first import, unless missing
+// folders can be created
+func (s LibraryStrategy) checkRoot(ctx context.Context, root string) error {
+	info, err := os.Stat(root)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("library folder %s is not a folder", root)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("cannot access library folder %s: %s", root, err)
+	}
+	if !s.Safety.CreateLibraryFolders {
+		return fmt.Errorf("library folder %s does not exist, set %s to create it", root, EnvCreateLibraryFolders)
+	}
+	helpers.Logf(ctx, "creating library folder %s", root)
+	err = s.Mover.Mkdir(root)
+	if err != nil {
+		return fmt.Errorf("cannot create library folder %s: %s", root, err)
+	}
+	return nil
+}
+
+// Destination Return the path where the file belongs in the library
+func (s LibraryStrategy) Destination(ctx context.Context, m *api.Media) (string, error) {
+	dir, err := s.destination(ctx, m)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, m.FilenameFinal), nil
+}
+
+// destination Return the folder where the file belongs in the library,
+// tracks go to a folder of the album inside the one of the artist
+func (s LibraryStrategy) destination(ctx context.Context, m *api.Media) (string, error) {
+	if m.Type == api.TypeMovie {
+		return s.API.GetPath(ctx, m.QueueElem.Movie.ID)
+	}
+	if m.Type == api.TypeMusic {
+		root, err := s.API.GetPath(ctx, m.QueueElem.Artist.ID)
+		if err != nil {
+			return "", err
+		}
+		return path.Join(root, strings.Replace(m.QueueElem.Album.Title, "/", "-", -1)), nil
+	}
+	return s.API.SeasonPath(ctx, m.QueueElem.Series.ID, m.QueueElem.Episode.SeasonNumber)
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"parserr/api"
+	"parserr/store"
+	"path"
+	"testing"
+)
+
+// supersededAPI API whose episode has the file with id 2, and the file with
+// id 1 if it still exists
+type supersededAPI struct {
+	api.RRAPI
+	file    string
+	exists  bool
+	deleted []int
+}
+
+func (a *supersededAPI) GetEpisode(ctx context.Context, id int) (api.Episode, error) {
+	return api.Episode{EpisodeFileID: 2}, nil
+}
+
+func (a *supersededAPI) GetEpisodeFile(ctx context.Context, id int) (api.MediaFile, error) {
+	if id == 2 {
+		return api.MediaFile{ID: 2, Path: a.file}, nil
+	}
+	if !a.exists {
+		return api.MediaFile{}, api.APIError{StatusCode: http.StatusNotFound}
+	}
+	return api.MediaFile{ID: id, Path: "/tv/Show/old.mkv"}, nil
+}
+
+func (a *supersededAPI) DeleteEpisodeFile(ctx context.Context, id int) error {
+	a.deleted = append(a.deleted, id)
+	return nil
+}
+
+func TestRegisteredDeletesOnlySuperseded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "Show S01E01.mkv")
+	if err = ioutil.WriteFile(file, []byte("video"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	for _, exists := range []bool{true, false} {
+		a := &supersededAPI{file: file, exists: exists}
+		s := LibraryStrategy{API: a, Safety: Safety{DeleteSuperseded: true}}
+		m := &api.Media{Type: api.TypeShow, FileLocFinal: file}
+		if err := s.registered(context.Background(), m, 1); err != nil {
+			t.Fatal(err)
+		}
+		if deleted := len(a.deleted) > 0; deleted != exists {
+			t.Errorf("file existing %v: deleted %v", exists, a.deleted)
+		}
+	}
+	a := &supersededAPI{file: path.Join(dir, "other.mkv")}
+	m := &api.Media{Type: api.TypeShow, FileLocFinal: file}
+	err = LibraryStrategy{API: a}.registered(context.Background(), m, 1)
+	if _, ok := err.(UnregisteredError); !ok {
+		t.Errorf("error %v, want an UnregisteredError", err)
+	}
+}
+
+// failingMover Mover failing to create folders, moving nothing
+type failingMover struct{ moved int }
+
+func (m *failingMover) Move(from, to string) error {
+	m.moved++
+	return nil
+}
+
+func (m *failingMover) Mkdir(path string) error {
+	return os.ErrPermission
+}
+
+func TestEnsureDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ensure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "file")
+	if err = ioutil.WriteFile(file, nil, 0664); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		dir   string
+		mover Mover
+		fails bool
+	}{
+		{"existing", dir, &failingMover{}, false},
+		{"created", path.Join(dir, "new"), BasicMover{}, false},
+		{"cannot create", path.Join(dir, "denied"), &failingMover{}, true},
+		{"file in the way", file, BasicMover{}, true},
+	}
+	for _, tt := range tests {
+		if err := ensureDir(tt.mover, tt.dir); (err != nil) != tt.fails {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.fails)
+		}
+	}
+}
+
+func TestForceImportFolderNotCreated(t *testing.T) {
+	mover := &failingMover{}
+	s := ForceImportStrategy{Mover: mover}
+	m := &api.Media{FileLocOri: "/downloads/a.mkv", FilenameFinal: "Show - S01E01.mkv", FileExtension: ".mkv"}
+	m.DownloadFolder.Path = "/downloads"
+	if err := s.moveToFolder(context.Background(), m); err == nil {
+		t.Error("the folder wasn't created but the file was moved")
+	}
+	if mover.moved != 0 {
+		t.Errorf("%d files moved, want none", mover.moved)
+	}
+}
+
+// ignoringAPI API accepting every scan without importing anything
+type ignoringAPI struct {
+	api.RRAPI
+	scans int
+}
+
+func (a *ignoringAPI) DownloadScan(path string) api.CommandBody {
+	return api.CommandBody{}
+}
+
+func (a *ignoringAPI) ExecuteCommandAndWait(ctx context.Context, c api.CommandBody, retries int) (api.CommandStatus, error) {
+	a.scans++
+	return api.CommandStatus{}, nil
+}
+
+func TestForceImportNotImportedNotFixed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forceimport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "a.mkv")
+	if err = ioutil.WriteFile(file, nil, 0664); err != nil {
+		t.Fatal(err)
+	}
+	state, err := NewState(store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &ignoringAPI{}
+	s := StatefulStrategy{Strategy: ForceImportStrategy{API: a, Mover: BasicMover{}}, State: state}
+	m := &api.Media{Instance: "sonarr", Type: api.TypeShow, FileLocOri: file, FilenameFinal: "Show - S01E01.mkv", FileExtension: ".mkv"}
+	m.DownloadFolder.Path = dir
+	if err = s.Fix(context.Background(), m); err == nil {
+		t.Error("the file wasn't imported but the fix succeeded")
+	}
+	if _, err = os.Stat(file); err != nil {
+		t.Errorf("the file wasn't moved back: %s", err)
+	}
+	if item, _ := state.Get(MediaKey(m)); item.Status != ItemFailed {
+		t.Errorf("item %s, want %s", item.Status, ItemFailed)
+	}
+	if len(state.Pending([]*api.Media{m})) != 1 {
+		t.Error("the item isn't fixed again on the next run")
+	}
+}
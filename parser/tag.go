@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"parserr/helpers"
+)
+
+// EnvTag Label of the tag given to the series, movies and artists parserr
+// fixes, disabled if empty
+const EnvTag = "PARSERR_TAG"
+
+// TagStrategy Tag the series, movie or artist of the media once it's been
+// fixed, so what parserr has touched can be found in the instance. Failing
+// to tag it doesn't make the fix fail.
+type TagStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Label    string
+	Cache    *RunCache
+}
+
+// Fix ...
+func (s TagStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil {
+		return err
+	}
+	id := m.QueueElem.Series.ID
+	if m.Type == api.TypeMovie {
+		id = m.QueueElem.Movie.ID
+	} else if m.Type == api.TypeMusic {
+		id = m.QueueElem.Artist.ID
+	}
+	tag, err := s.Cache.Tag(ctx, s.API, s.Label)
+	if err == nil {
+		err = s.Cache.ApplyTag(ctx, s.API, id, tag.ID)
+	}
+	if err != nil {
+		helpers.Logf(ctx, "cannot tag %s with %s: %s", m.QueueElem.Title, s.Label, err)
+		return nil
+	}
+	helpers.Logf(ctx, "%s tagged with %s", m.QueueElem.Title, s.Label)
+	return nil
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvItemTimeout Max time fixing a single item can take, 0 disables it
+	EnvItemTimeout = "PARSERR_ITEM_TIMEOUT"
+	// ItemTimedOut Fixing the item took too long and has been skipped
+	ItemTimedOut = "timed-out"
+)
+
+// TimedOutError Returned when fixing a media took too long, or when a fix
+// of its file that took too long is still running
+type TimedOutError struct {
+	Name    string
+	Timeout time.Duration
+	// Running A previous fix of the file is still running in the background
+	Running bool
+}
+
+func (e TimedOutError) Error() string {
+	if e.Running {
+		return fmt.Sprintf("a fix of %s that took more than %s is still running, skipped", e.Name, e.Timeout)
+	}
+	return fmt.Sprintf("fixing %s took more than %s, skipped", e.Name, e.Timeout)
+}
+
+// running Files whose fix timed out and is still running in the background,
+// shared by every TimeoutStrategy so later runs of a watch don't fix them
+// again meanwhile
+var running = struct {
+	sync.Mutex
+	files map[string]bool
+}{files: make(map[string]bool)}
+
+// startFix Mark the file as being fixed, false if it already is
+func startFix(file string) bool {
+	running.Lock()
+	defer running.Unlock()
+	if running.files[file] {
+		return false
+	}
+	running.files[file] = true
+	return true
+}
+
+func endFix(file string) {
+	running.Lock()
+	defer running.Unlock()
+	delete(running.files, file)
+}
+
+// TimeoutStrategy Stop waiting for fixes that take too long, like the ones
+// blocked reading from a hung NFS mount, so the rest of the items are still
+// processed. Pending api calls are cancelled, but blocked filesystem calls
+// can't be interrupted, the fix keeps running in the background until they
+// return, and the file is skipped until then.
+type TimeoutStrategy struct {
+	Strategy FixStrategy
+	Timeout  time.Duration
+}
+
+// Fix ...
+func (s TimeoutStrategy) Fix(ctx context.Context, m *api.Media) error {
+	type result struct {
+		err   error
+		panic interface{}
+	}
+	file := m.FileLocOri
+	if !startFix(file) {
+		return TimedOutError{Name: m.QueueElem.Title, Timeout: s.Timeout, Running: true}
+	}
+	// The media is copied so the background fix doesn't modify it once the
+	// timeout has been reached
+	media := *m
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	done := make(chan result, 1)
+	go func() {
+		defer endFix(file)
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{panic: p}
+			}
+		}()
+		done <- result{err: s.Strategy.Fix(ctx, &media)}
+	}()
+	select {
+	case r := <-done:
+		if r.panic != nil {
+			panic(r.panic)
+		}
+		*m = media
+		return r.err
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			// the run was cancelled or reached its deadline, not the item
+			return fmt.Errorf("fixing %s interrupted: %s", m.QueueElem.Title, err)
+		}
+		return TimedOutError{Name: m.QueueElem.Title, Timeout: s.Timeout}
+	}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"testing"
+	"time"
+)
+
+// blockingStrategy Strategy blocked until release is closed, like a fix
+// reading from a hung mount
+type blockingStrategy struct {
+	release chan struct{}
+	fixed   chan struct{}
+}
+
+func (s blockingStrategy) Fix(ctx context.Context, m *api.Media) error {
+	<-s.release
+	s.fixed <- struct{}{}
+	return nil
+}
+
+func TestTimeoutStrategy(t *testing.T) {
+	blocked := blockingStrategy{release: make(chan struct{}), fixed: make(chan struct{}, 2)}
+	s := TimeoutStrategy{Strategy: blocked, Timeout: 10 * time.Millisecond}
+	m := &api.Media{FileLocOri: "/downloads/timeout.mkv"}
+	err := s.Fix(context.Background(), m)
+	if e, ok := err.(TimedOutError); !ok || e.Running {
+		t.Fatalf("first fix: %v, want a timeout", err)
+	}
+	err = s.Fix(context.Background(), m)
+	if e, ok := err.(TimedOutError); !ok || !e.Running {
+		t.Fatalf("fix while the first one runs: %v, want it skipped", err)
+	}
+	close(blocked.release)
+	<-blocked.fixed
+	deadline := time.Now().Add(time.Second)
+	for !startFix(m.FileLocOri) {
+		if time.Now().After(deadline) {
+			t.Fatal("the file is still marked as running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	endFix(m.FileLocOri)
+	if err = s.Fix(context.Background(), m); err != nil {
+		t.Errorf("fix once the first one returned: %v", err)
+	}
+}
+
+func TestTimeoutStrategyCancelled(t *testing.T) {
+	blocked := blockingStrategy{release: make(chan struct{}), fixed: make(chan struct{}, 1)}
+	defer close(blocked.release)
+	s := TimeoutStrategy{Strategy: blocked, Timeout: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.Fix(ctx, &api.Media{FileLocOri: "/downloads/cancelled.mkv"})
+	if _, ok := err.(TimedOutError); ok || err == nil {
+		t.Errorf("cancelled fix: %v, want a cancellation", err)
+	}
+}
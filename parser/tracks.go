@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"parserr/api"
+	"parserr/helpers"
+)
+
+// TrackRenameStrategy Name music files after the track of the album they
+// match, lidarr can't import files named after the release. Other media is
+// fixed untouched.
+type TrackRenameStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+}
+
+// Fix ...
+func (s TrackRenameStrategy) Fix(ctx context.Context, m *api.Media) error {
+	if m.Type == api.TypeMusic {
+		err := RenameTrack(ctx, s.API, m)
+		if err != nil {
+			helpers.Logf(ctx, "keeping the name of %s: %s", m.FilenameOri, err)
+		}
+	}
+	return s.Strategy.Fix(ctx, m)
+}
+
+// RenameTrack Set the final name of the music file to the one of the track
+// of its album it matches
+func RenameTrack(ctx context.Context, a api.RRAPI, m *api.Media) error {
+	tracks, err := a.GetTracks(ctx, m.QueueElem.Album.ID)
+	if err != nil {
+		return fmt.Errorf("cannot get tracks of %s: %s", m.QueueElem.Album.Title, err)
+	}
+	track, ok := api.MatchTrack(tracks, m.FilenameOri)
+	if !ok {
+		return fmt.Errorf("does not match any track of %s", m.QueueElem.Album.Title)
+	}
+	m.FilenameFinal = api.TrackFilename(track, m.FileExtension)
+	return nil
+}
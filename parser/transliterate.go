@@ -0,0 +1,22 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"parserr/helpers"
+)
+
+// EnvTransliterate Replace non-ASCII characters of the destination names
+const EnvTransliterate = "PARSERR_TRANSLITERATE"
+
+// TransliterateStrategy Use only ASCII characters in the destination name,
+// for media servers and shares that don't handle unicode file names
+type TransliterateStrategy struct {
+	Strategy FixStrategy
+}
+
+// Fix ...
+func (s TransliterateStrategy) Fix(ctx context.Context, m *api.Media) error {
+	m.FilenameFinal = helpers.Transliterate(m.FilenameFinal)
+	return s.Strategy.Fix(ctx, m)
+}
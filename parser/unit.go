@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"context"
+	"parserr/api"
+	"strconv"
+	"sync"
+)
+
+// DownloadUnit Media sharing a download, like the episodes of a season pack,
+// which sonarr v3 queues as an element each, or the tracks of an album. They
+// are fixed one after the other and their download is cleaned or
+// blacklisted once, when the last of them has been fixed. Units with only
+// some of the media of their download, the rest being filtered out of the
+// run or not failed yet, are never cleaned nor blacklisted. Safe for
+// concurrent use.
+type DownloadUnit struct {
+	DownloadID string
+	Media      []*api.Media
+	// Size Media of the download, len(Media) unless some aren't in the unit
+	Size  int
+	mu    sync.Mutex
+	done  int
+	fixed int
+}
+
+// GroupByDownload Group the media by download, in the order of the first
+// media of every download. Media without download id are grouped by queue
+// element, like the tracks of an album, or are units on their own.
+func GroupByDownload(files []*api.Media) (units []*DownloadUnit) {
+	byID := make(map[string]*DownloadUnit)
+	for _, m := range files {
+		id := m.QueueElem.DownloadID
+		key := id
+		if key == "" && m.QueueElem.ID != 0 {
+			key = "queue:" + strconv.Itoa(m.QueueElem.ID)
+		}
+		if u, ok := byID[key]; ok && key != "" {
+			u.Media = append(u.Media, m)
+			continue
+		}
+		u := &DownloadUnit{DownloadID: id, Media: []*api.Media{m}}
+		byID[key] = u
+		units = append(units, u)
+	}
+	for _, u := range units {
+		u.Size = len(u.Media)
+		for _, m := range u.Media {
+			if m.DownloadMedia > u.Size {
+				u.Size = m.DownloadMedia
+			}
+		}
+	}
+	return
+}
+
+// Partial Return true if the unit lacks some media of its download
+func (u *DownloadUnit) Partial() bool {
+	return u.Size > len(u.Media)
+}
+
+// record Record the result of fixing a media of the unit
+func (u *DownloadUnit) record(fixed bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.done++
+	if fixed {
+		u.fixed++
+	}
+}
+
+// last Return true if the media being fixed is the last one of its
+// download
+func (u *DownloadUnit) last() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.done == u.Size-1
+}
+
+// Fixed Return how many media of the unit have been fixed so far
+func (u *DownloadUnit) Fixed() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.fixed
+}
+
+// complete Return true if the media being fixed is the last one of its
+// download and every other one has been fixed, so the download can be
+// cleaned
+func (u *DownloadUnit) complete() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.done == u.Size-1 && u.fixed == u.done
+}
+
+type unitKey struct{}
+
+// withUnit Return a context telling strategies the unit of the media they fix
+func withUnit(ctx context.Context, u *DownloadUnit) context.Context {
+	return context.WithValue(ctx, unitKey{}, u)
+}
+
+// unitOf Return the unit of the media being fixed with the context, nil if
+// it's being fixed on its own
+func unitOf(ctx context.Context) *DownloadUnit {
+	u, _ := ctx.Value(unitKey{}).(*DownloadUnit)
+	return u
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"parserr/api"
+	"testing"
+)
+
+func unitMedia(downloadID string, downloadMedia int) *api.Media {
+	m := &api.Media{DownloadMedia: downloadMedia}
+	m.QueueElem.DownloadID = downloadID
+	return m
+}
+
+func TestDownloadUnitComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		media []*api.Media
+		fixed []bool
+		want  bool
+	}{
+		{"single", []*api.Media{unitMedia("A", 1)}, nil, true},
+		{"whole pack", []*api.Media{unitMedia("A", 2), unitMedia("A", 2)}, []bool{true}, true},
+		{"sibling not fixed", []*api.Media{unitMedia("A", 2), unitMedia("A", 2)}, []bool{false}, false},
+		{"sibling filtered out", []*api.Media{unitMedia("A", 3), unitMedia("A", 3)}, []bool{true}, false},
+		{"size unknown", []*api.Media{unitMedia("A", 0), unitMedia("A", 0)}, []bool{true}, true},
+	}
+	for _, tt := range tests {
+		units := GroupByDownload(tt.media)
+		if len(units) != 1 {
+			t.Fatalf("%s: %d units, want 1", tt.name, len(units))
+		}
+		u := units[0]
+		for _, fixed := range tt.fixed {
+			u.record(fixed)
+		}
+		if got := u.complete(); got != tt.want {
+			t.Errorf("%s: complete = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithDownloadSizes(t *testing.T) {
+	media := []*api.Media{unitMedia("A", 0), unitMedia("A", 0), unitMedia("B", 0), unitMedia("", 0)}
+	queue := []api.QueueElem{
+		{DownloadID: "A", Status: api.StatusCompleted, TrackedDownloadStatus: api.TrackedDownloadStatusWarning},
+		{DownloadID: "A", Status: api.StatusCompleted, TrackedDownloadStatus: api.TrackedDownloadStatusWarning},
+		{DownloadID: "A", Status: "downloading"},
+		{DownloadID: "B", Status: api.StatusCompleted, TrackedDownloadStatus: api.TrackedDownloadStatusWarning},
+		{DownloadID: "C", Status: "downloading"},
+	}
+	want := []int{3, 3, 1, 0}
+	for i, m := range withDownloadSizes(media, queue) {
+		if m.DownloadMedia != want[i] {
+			t.Errorf("media %d of %s: %d download media, want %d", i, m.QueueElem.DownloadID, m.DownloadMedia, want[i])
+		}
+	}
+}
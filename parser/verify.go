@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"parserr/mediaserver"
+	"path"
+	"time"
+)
+
+// verifyInterval Time between checks while waiting for the media server
+const verifyInterval = time.Second * 10
+
+// VerifyStrategy Check the media server shows the media once it's been
+// fixed, recording the result in the media for the report. The fix doesn't
+// fail if the media server doesn't show it.
+type VerifyStrategy struct {
+	Strategy FixStrategy
+	API      api.RRAPI
+	Server   mediaserver.Server
+	// Wait Max time to wait for the media server to scan the file, it's
+	// checked once if 0
+	Wait time.Duration
+	// Clock Used to wait for the media server, RealClock if nil
+	Clock api.Clock
+}
+
+// Fix ...
+func (s VerifyStrategy) Fix(ctx context.Context, m *api.Media) error {
+	err := s.Strategy.Fix(ctx, m)
+	if err != nil {
+		return err
+	}
+	m.Verification = s.verify(ctx, m)
+	helpers.Logf(ctx, "%s: %s", m.QueueElem.Title, m.Verification)
+	return nil
+}
+
+// verify Return whether the media server shows the media and can play it
+func (s VerifyStrategy) verify(ctx context.Context, m *api.Media) string {
+	server := s.Server.GetType()
+	if m.Type == api.TypeMusic {
+		return "music not verified in " + server
+	}
+	title := m.QueueElem.Series.Title
+	if m.Type == api.TypeMovie {
+		title = m.QueueElem.Movie.Title
+	}
+	clock := api.ClockOrReal(s.Clock)
+	deadline := clock.Now().Add(s.Wait)
+	for {
+		// The instance may import the file while waiting, the name of the
+		// imported file is the one the media server shows
+		file, err := libraryFile(ctx, s.API, m)
+		if err != nil {
+			file = m.FileLocFinal
+		}
+		item, ok, err := s.Server.Find(ctx, title, file)
+		if err != nil {
+			return fmt.Sprintf("cannot verify in %s: %s", server, err)
+		}
+		if ok && item.Playable {
+			return "playable in " + server
+		}
+		if !clock.Now().Add(verifyInterval).Before(deadline) {
+			if ok {
+				return "not playable in " + server
+			}
+			return "not found in " + server
+		}
+		if err := api.SleepContext(ctx, clock, verifyInterval); err != nil {
+			return fmt.Sprintf("cannot verify in %s: %s", server, err)
+		}
+	}
+}
+
+// ImportedFile Return where the media is in the library, asking the
+// instance where it has imported it unless the file was moved there. A file
+// renamed in the download folder isn't the imported one, the instance may
+// have copied or hardlinked it.
+func ImportedFile(ctx context.Context, a api.RRAPI, m *api.Media) (string, error) {
+	if !inFolder(m.FileLocFinal, m.DownloadFolder.Path) {
+		if _, err := os.Stat(m.FileLocFinal); err == nil {
+			return m.FileLocFinal, nil
+		}
+	}
+	return libraryFile(ctx, a, m)
+}
+
+// libraryFile Return the file of the media in the library of the instance
+func libraryFile(ctx context.Context, a api.RRAPI, m *api.Media) (string, error) {
+	if m.Type == api.TypeMovie {
+		movie, err := a.GetMovie(ctx, m.QueueElem.Movie.ID)
+		if err != nil {
+			return "", err
+		}
+		if !movie.HasFile {
+			return "", fmt.Errorf("movie has not been imported")
+		}
+		if movie.MovieFile.Path != "" {
+			return movie.MovieFile.Path, nil
+		}
+		// old versions leave the file out of the movie
+		files, err := a.GetMovieFiles(ctx, movie.ID)
+		if err != nil {
+			return "", err
+		}
+		for _, file := range files {
+			if file.Path != "" {
+				return file.Path, nil
+			}
+			if file.RelativePath != "" {
+				return path.Join(movie.Path, file.RelativePath), nil
+			}
+		}
+		return "", fmt.Errorf("movie has not been imported")
+	}
+	episode, err := a.GetEpisode(ctx, m.QueueElem.Episode.ID)
+	if err != nil {
+		return "", err
+	}
+	if episode.EpisodeFileID == 0 {
+		return "", fmt.Errorf("episode has not been imported")
+	}
+	file, err := a.GetEpisodeFile(ctx, episode.EpisodeFileID)
+	return file.Path, err
+}
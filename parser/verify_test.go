@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"parserr/api"
+	"parserr/mediaserver"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// libraryAPI API whose episodes have been imported to file
+type libraryAPI struct {
+	api.RRAPI
+	file string
+}
+
+func (a libraryAPI) GetEpisode(ctx context.Context, id int) (api.Episode, error) {
+	return api.Episode{EpisodeFileID: 1}, nil
+}
+
+func (a libraryAPI) GetEpisodeFile(ctx context.Context, id int) (api.MediaFile, error) {
+	return api.MediaFile{Path: a.file}, nil
+}
+
+func TestImportedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	downloads, library := path.Join(dir, "downloads"), path.Join(dir, "tv")
+	for _, d := range []string{downloads, library} {
+		if err = os.Mkdir(d, 0775); err != nil {
+			t.Fatal(err)
+		}
+	}
+	renamed, moved := path.Join(downloads, "Show S01E01.mkv"), path.Join(library, "Show S01E02.mkv")
+	for _, f := range []string{renamed, moved} {
+		if err = ioutil.WriteFile(f, []byte("video"), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imported := path.Join(library, "Show S01E01.mkv")
+	tests := []struct {
+		name  string
+		final string
+		want  string
+	}{
+		{"renamed in the download folder", renamed, imported},
+		{"moved to the library", moved, moved},
+	}
+	for _, tt := range tests {
+		m := &api.Media{Type: api.TypeShow, FileLocFinal: tt.final}
+		m.DownloadFolder.Path = downloads
+		got, err := ImportedFile(context.Background(), libraryAPI{file: imported}, m)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// emptyServer Media server that never shows the media
+type emptyServer struct{}
+
+func (s emptyServer) GetType() string { return "plex" }
+
+func (s emptyServer) Find(ctx context.Context, title, file string) (mediaserver.Item, bool, error) {
+	return mediaserver.Item{}, false, nil
+}
+
+func TestVerifyStopsWithContext(t *testing.T) {
+	s := VerifyStrategy{Strategy: loggingStrategy{}, API: libraryAPI{file: "/tv/Show S01E01.mkv"}, Server: emptyServer{}, Wait: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	m := &api.Media{Type: api.TypeShow}
+	start := time.Now()
+	if err := s.Fix(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("waited %s for the media server after the context was done", time.Since(start))
+	}
+	if !strings.HasPrefix(m.Verification, "cannot verify") {
+		t.Errorf("verification %q, want it not verified", m.Verification)
+	}
+}
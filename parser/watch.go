@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// EnvWatchInterval Time between checks for changes in watch mode
+	EnvWatchInterval = "PARSERR_WATCH_INTERVAL"
+	// DefaultWatchInterval ...
+	DefaultWatchInterval = time.Minute
+)
+
+// PollingWatcher Detect changes in folders comparing snapshots of the
+// modification times of their files, so it works on filesystems without
+// inotify like NFS or FUSE mounts
+type PollingWatcher struct {
+	Dirs     []string
+	Interval time.Duration
+	last     map[string]time.Time
+}
+
+// NewPollingWatcher Return a watcher of the folders taking their current
+// state as the starting point
+func NewPollingWatcher(dirs []string, interval time.Duration) *PollingWatcher {
+	return &PollingWatcher{Dirs: dirs, Interval: interval, last: snapshot(dirs)}
+}
+
+// Changed Return true if any file has been added, removed or modified since
+// the last call
+func (w *PollingWatcher) Changed() bool {
+	current := snapshot(w.Dirs)
+	changed := len(current) != len(w.last)
+	for file, modified := range current {
+		if changed {
+			break
+		}
+		last, ok := w.last[file]
+		changed = !ok || !last.Equal(modified)
+	}
+	w.last = current
+	return changed
+}
+
+// Reset Take the current state as the starting point, so changes done by
+// parserr itself are ignored
+func (w *PollingWatcher) Reset() {
+	w.last = snapshot(w.Dirs)
+}
+
+// Wait Block until there is a change in the folders or something is
+// received from wake, returning true in the latter case
+func (w *PollingWatcher) Wait(wake <-chan struct{}) (woken bool) {
+	for {
+		select {
+		case <-wake:
+			return true
+		case <-time.After(w.Interval):
+		}
+		if w.Changed() {
+			return false
+		}
+	}
+}
+
+// snapshot Return the modification time of every file in the folders,
+// unreadable files are ignored
+func snapshot(dirs []string) map[string]time.Time {
+	files := make(map[string]time.Time)
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			files[path] = info.ModTime()
+			return nil
+		})
+	}
+	return files
+}
@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+// CheckWritable Return an actionable error if files can't be created in the
+// folder, so runs fail before touching anything instead of halfway through
+func CheckWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".parserr-check")
+	if err != nil {
+		return writableError(dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func writableError(dir string, err error) error {
+	cause := err
+	if pathErr, ok := err.(*os.PathError); ok {
+		cause = pathErr.Err
+	}
+	switch cause {
+	case syscall.EROFS:
+		return fmt.Errorf("%s is on a read-only filesystem, mount it read-write", dir)
+	case syscall.EACCES, syscall.EPERM:
+		return fmt.Errorf("%s is not writable by uid %d gid %d, it needs write and execute permission",
+			dir, os.Getuid(), os.Getgid())
+	case syscall.ENOENT:
+		return fmt.Errorf("%s doesn't exist", dir)
+	}
+	return fmt.Errorf("cannot write on %s: %s", dir, err)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"parserr/api"
+	"parserr/helpers"
+	"parserr/parser"
+	"strings"
+)
+
+// yesFlag Value of --yes, apply the run without showing its plan first
+var yesFlag bool
+
+// interactive Return true if parserr runs in a terminal someone can answer
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmPlan Return true if the run can be applied. Interactive runs are
+// done first in read-only mode, printing the moves, deletions and other
+// changes it would make, and only applied once confirmed with yes. Runs
+// with --yes, in read-only mode, without a terminal or with nothing planned
+// are applied, the real run may still find something to do.
+func confirmPlan(ctx context.Context, safety parser.Safety, state *parser.State, phases runPhases) bool {
+	if yesFlag || readOnly() || !interactive() {
+		return true
+	}
+	previous, set := os.LookupEnv(api.EnvReadOnly)
+	os.Setenv(api.EnvReadOnly, "true")
+	log.Print("planning the run in read-only mode")
+	plan := helpers.StartPlan()
+	runAll(ctx, getAPIs(), safety, state, nil, phases)
+	helpers.StopPlan()
+	if set {
+		os.Setenv(api.EnvReadOnly, previous)
+	} else {
+		os.Unsetenv(api.EnvReadOnly)
+	}
+	fmt.Printf("\nplan: %s\n", plan.Summary())
+	for _, action := range plan.Actions() {
+		fmt.Printf("  %s: %s\n", action.Kind, action.Description)
+	}
+	if plan.Empty() {
+		return true
+	}
+	fmt.Print("\napply the plan? only yes is accepted, --yes skips this: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil || strings.TrimSpace(answer) != "yes" {
+		fmt.Println("run cancelled")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"parserr/client"
+	"parserr/parser"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// envProfile Profile used when --profile isn't given
+const envProfile = "PARSERR_PROFILE"
+
+// profile Run mode overriding the configuration, so the same install can
+// run cautiously on a schedule and aggressively by hand
+type profile struct {
+	// Env Variables set by the profile, overriding the configuration
+	Env map[string]string
+	// Phases Parts of the run of parserr and parserr watch, every one if nil
+	Phases *runPhases
+}
+
+// profiles Profiles built in, a .env.<name> file overrides the variables of
+// the one with its name or defines a new one
+var profiles = map[string]profile{
+	"conservative": {Env: map[string]string{
+		parser.EnvSafety:               parser.SafetyStrict,
+		parser.EnvBlacklistFailed:      "false",
+		parser.EnvDeleteSuperseded:     "false",
+		parser.EnvCreateLibraryFolders: "false",
+		parser.EnvWarningGrace:         "1h",
+		client.EnvClientCleanup:        "false",
+	}},
+	"aggressive": {Env: map[string]string{
+		parser.EnvSafety:               parser.SafetyPermissive,
+		parser.EnvBlacklistFailed:      "true",
+		parser.EnvCreateLibraryFolders: "true",
+		parser.EnvWarningGrace:         "0s",
+		client.EnvClientCleanup:        "true",
+	}},
+	"cleanup-only": {Env: map[string]string{
+		parser.EnvDeferredClean: "true",
+	}, Phases: &runPhases{clean: true}},
+}
+
+// profileFlag Value of --profile, overriding envProfile
+var profileFlag string
+
+// defaultPhases Parts of the runs of parserr and parserr watch, as selected
+// by the profile
+var defaultPhases = allPhases
+
+// currentProfile Return the name of the profile to use, empty if none
+func currentProfile() string {
+	if profileFlag != "" {
+		return profileFlag
+	}
+	return os.Getenv(envProfile)
+}
+
+// applyProfile Set the variables of the profile and of its .env.<name>
+// file, in this order, overriding the configuration
+func applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	p, builtIn := profiles[name]
+	file := ".env." + name
+	custom, err := godotenv.Read(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read profile %s: %s", file, err)
+	}
+	if !builtIn && err != nil {
+		return fmt.Errorf("unknown profile %q, use %s or create %s", name, strings.Join(profileNames(), ", "), file)
+	}
+	for key, value := range p.Env {
+		os.Setenv(key, value)
+	}
+	for key, value := range custom {
+		os.Setenv(key, value)
+	}
+	if p.Phases != nil {
+		defaultPhases = *p.Phases
+	}
+	log.Printf("using profile %s", name)
+	return nil
+}
+
+// checkProfile Exit if the profile only cleans without the state the clean
+// phase needs
+func checkProfile(state *parser.State) {
+	if !defaultPhases.fix && state == nil {
+		log.Fatalf("profile %s only cleans, which requires %s", currentProfile(), parser.EnvStateFile)
+	}
+}
+
+// profileNames Return the names of the built in profiles
+func profileNames() (names []string) {
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"parserr/helpers"
+	"parserr/parser"
+	"reflect"
+	"sort"
+
+	"github.com/joho/godotenv"
+)
+
+// envPatterns Format of the values of every type when given as strings
+var envPatterns = map[string]string{
+	envInt:      `^-?[0-9]+$`,
+	envBool:     `^(1|0|t|f|T|F|true|false|TRUE|FALSE|True|False)$`,
+	envDuration: `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`,
+}
+
+// configCommand parserr config validate [file] | schema, check the
+// configuration, the environment or a .env file, or print its JSON Schema
+func configCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: parserr config validate [file] | schema")
+	}
+	switch args[0] {
+	case "validate":
+		values := make(map[string]string)
+		for _, v := range envVars {
+			values[v.Name] = os.Getenv(v.Name)
+		}
+		unknown := unknownEnv()
+		if len(args) > 1 {
+			var err error
+			values, err = godotenv.Read(args[1])
+			if err != nil {
+				log.Fatalf("cannot read %s: %s", args[1], err)
+			}
+			var names []string
+			for name := range values {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			unknown = unknownNames(names)
+		}
+		errors := envErrors(unknown, func(v envVar) error { return v.checkValue(values[v.Name]) })
+		for _, err := range errors {
+			fmt.Println(err)
+		}
+		if len(errors) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("configuration is valid")
+	case "schema":
+		printJSON(envSchema())
+	default:
+		log.Fatalf("unknown config command %q", args[0])
+	}
+}
+
+// reportCommand parserr report schema, print the JSON Schema of the lines
+// of the report file
+func reportCommand(args []string) {
+	if len(args) == 0 || args[0] != "schema" {
+		log.Fatal("usage: parserr report schema")
+	}
+	schema := helpers.JSONSchema(reflect.TypeOf(parser.Report{}))
+	schema["$schema"] = helpers.SchemaDraft
+	schema["title"] = "parserr run report"
+	schema["description"] = "Line of " + parser.EnvReportFile
+	printJSON(schema)
+}
+
+// envSchema Return the JSON Schema of the configuration as an object with
+// the variables as properties, like the environment of a compose file.
+// Values can be strings, as in the environment, or of their own type.
+func envSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, v := range envVars {
+		property := map[string]interface{}{"description": v.Description}
+		switch v.Type {
+		case envInt:
+			property["type"] = []string{"integer", "string"}
+		case envBool:
+			property["type"] = []string{"boolean", "string"}
+		default:
+			property["type"] = "string"
+		}
+		if pattern, ok := envPatterns[v.Type]; ok {
+			property["pattern"] = pattern
+		}
+		if v.Secret {
+			property["writeOnly"] = true
+		}
+		properties[v.Name] = property
+	}
+	return map[string]interface{}{
+		"$schema":     helpers.SchemaDraft,
+		"title":       "parserr configuration",
+		"type":        "object",
+		"properties":  properties,
+		"description": "Environment variables recognized by parserr, other variables are allowed",
+	}
+}
+
+func printJSON(v interface{}) {
+	j, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(j))
+}
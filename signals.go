@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySignals Request a run through wake on SIGUSR1 and call status on
+// SIGUSR2, runs requested while running are merged into a single one
+func notifySignals(wake chan<- struct{}, status func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR2 {
+				status()
+				continue
+			}
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}()
+}
@@ -0,0 +1,4 @@
+package main
+
+// notifySignals There are no SIGUSR1 and SIGUSR2 on windows
+func notifySignals(wake chan<- struct{}, status func()) {}
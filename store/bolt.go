@@ -0,0 +1,136 @@
+//go:build bbolt
+// +build bbolt
+
+package store
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	backends[BackendBolt] = func(path string) (Store, error) { return OpenBolt(path) }
+}
+
+// Bolt Store kept in a bbolt database, every bucket in a bucket of the
+// database. The file is locked while open so only one parserr uses it.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt Open or create the database at path
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0664, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Get ...
+func (s *Bolt) Get(bucket, key string) (value []byte, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(key))
+		// values are only valid during the transaction
+		value, ok = copyBytes(v), v != nil
+		return nil
+	})
+	return
+}
+
+// Put ...
+func (s *Bolt) Put(bucket, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+// Create ...
+func (s *Bolt) Create(bucket, key string, value []byte) (existing []byte, created bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		if v := b.Get([]byte(key)); v != nil {
+			existing = copyBytes(v)
+			return nil
+		}
+		created = true
+		return b.Put([]byte(key), value)
+	})
+	return
+}
+
+// Swap ...
+func (s *Bolt) Swap(bucket, key string, old, value []byte) (swapped bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(key)); v == nil || !sameJSON(v, old) {
+			return nil
+		}
+		swapped = true
+		return b.Put([]byte(key), value)
+	})
+	return
+}
+
+// DeleteIf ...
+func (s *Bolt) DeleteIf(bucket, key string, old []byte) (deleted bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(key)); v == nil || !sameJSON(v, old) {
+			return nil
+		}
+		deleted = true
+		return b.Delete([]byte(key))
+	})
+	return
+}
+
+// Delete ...
+func (s *Bolt) Delete(bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// All ...
+func (s *Bolt) All(bucket string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			values[string(k)] = copyBytes(v)
+			return nil
+		})
+	})
+	return values, err
+}
+
+// Close ...
+func (s *Bolt) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// BucketState Items processed by previous runs
+	BucketState = "state"
+	// BucketQuarantine Entries describing the quarantined files
+	BucketQuarantine = "quarantine"
+	// BucketLocks Files being fixed by a parserr right now
+	BucketLocks = "locks"
+	// BucketHistory History records of every instance seen by previous runs
+	BucketHistory = "history"
+	// fileLockTimeout Max time waiting for another process to save a file
+	fileLockTimeout = 10 * time.Second
+	// fileLockStale Age of the lock files left by crashed processes
+	fileLockStale = time.Minute
+	// fileLockInterval Time between attempts to lock a file
+	fileLockInterval = 50 * time.Millisecond
+)
+
+// File Store keeping every bucket in a JSON file written atomically, the
+// state in Path and the other buckets next to it with the bucket name as
+// suffix, so it's readable and editable by hand. Changes are made to the
+// file as it is on disk, so processes can share it.
+type File struct {
+	Path    string
+	mu      sync.Mutex
+	buckets map[string]map[string]json.RawMessage
+}
+
+// OpenFile Read the state kept at path, a missing file is an empty state
+func OpenFile(path string) (*File, error) {
+	s := &File{Path: path, buckets: make(map[string]map[string]json.RawMessage)}
+	_, err := s.bucket(BucketState)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get ...
+func (s *File) Get(bucket, key string) (value []byte, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.bucket(bucket)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok = values[key]
+	return copyBytes(value), ok, nil
+}
+
+// Put ...
+func (s *File) Put(bucket, key string, value []byte) error {
+	if !json.Valid(value) {
+		return fmt.Errorf("value of %s is not valid JSON", key)
+	}
+	return s.update(bucket, func(values map[string]json.RawMessage) bool {
+		values[key] = copyBytes(value)
+		return true
+	})
+}
+
+// Create ...
+func (s *File) Create(bucket, key string, value []byte) (existing []byte, created bool, err error) {
+	if !json.Valid(value) {
+		return nil, false, fmt.Errorf("value of %s is not valid JSON", key)
+	}
+	err = s.update(bucket, func(values map[string]json.RawMessage) bool {
+		if v, ok := values[key]; ok {
+			existing = copyBytes(v)
+			return false
+		}
+		values[key] = copyBytes(value)
+		created = true
+		return true
+	})
+	return
+}
+
+// Delete ...
+func (s *File) Delete(bucket, key string) error {
+	return s.update(bucket, func(values map[string]json.RawMessage) bool {
+		if _, ok := values[key]; !ok {
+			return false
+		}
+		delete(values, key)
+		return true
+	})
+}
+
+// Swap ...
+func (s *File) Swap(bucket, key string, old, value []byte) (swapped bool, err error) {
+	if !json.Valid(value) {
+		return false, fmt.Errorf("value of %s is not valid JSON", key)
+	}
+	err = s.update(bucket, func(values map[string]json.RawMessage) bool {
+		existing, ok := values[key]
+		if !ok || !sameJSON(existing, old) {
+			return false
+		}
+		values[key] = copyBytes(value)
+		swapped = true
+		return true
+	})
+	return
+}
+
+// DeleteIf ...
+func (s *File) DeleteIf(bucket, key string, old []byte) (deleted bool, err error) {
+	err = s.update(bucket, func(values map[string]json.RawMessage) bool {
+		existing, ok := values[key]
+		if !ok || !sameJSON(existing, old) {
+			return false
+		}
+		delete(values, key)
+		deleted = true
+		return true
+	})
+	return
+}
+
+// update Modify the bucket as it is on disk, saving it if modify returns
+// true. The file is locked meanwhile so other processes sharing it don't
+// lose each other's changes.
+func (s *File) update(bucket string, modify func(values map[string]json.RawMessage) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.filename(bucket) + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	delete(s.buckets, bucket)
+	values, err := s.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	if !modify(values) {
+		return nil
+	}
+	return s.save(bucket)
+}
+
+// lockFile Create the lock file, waiting for other processes to remove it.
+// Lock files older than fileLockStale are left by crashed processes and
+// are removed.
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0664)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > fileLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another process", path)
+		}
+		time.Sleep(fileLockInterval)
+	}
+}
+
+// All ...
+func (s *File) All(bucket string) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string][]byte, len(values))
+	for key, value := range values {
+		all[key] = copyBytes(value)
+	}
+	return all, nil
+}
+
+// Close ...
+func (s *File) Close() error {
+	return nil
+}
+
+// filename Return the file the bucket is kept in
+func (s *File) filename(bucket string) string {
+	if bucket == BucketState {
+		return s.Path
+	}
+	return s.Path + "." + bucket
+}
+
+// bucket Return the values of the bucket, reading its file the first time
+func (s *File) bucket(bucket string) (map[string]json.RawMessage, error) {
+	if values, ok := s.buckets[bucket]; ok {
+		return values, nil
+	}
+	values := make(map[string]json.RawMessage)
+	j, err := ioutil.ReadFile(s.filename(bucket))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		err = json.Unmarshal(j, &values)
+		if err != nil {
+			return nil, fmt.Errorf("corrupted %s file %s: %s", bucket, s.filename(bucket), err)
+		}
+	}
+	s.buckets[bucket] = values
+	return values, nil
+}
+
+// save Write the bucket to its file atomically
+func (s *File) save(bucket string) error {
+	j, err := json.MarshalIndent(s.buckets[bucket], "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.filename(bucket)
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, j, 0664)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
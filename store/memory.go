@@ -0,0 +1,104 @@
+package store
+
+import "sync"
+
+// Memory Store kept in memory and lost when the process exits, for ephemeral
+// deployments and tests
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory ...
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+// Get ...
+func (s *Memory) Get(bucket, key string) (value []byte, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok = s.buckets[bucket][key]
+	return copyBytes(value), ok, nil
+}
+
+// Put ...
+func (s *Memory) Put(bucket, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+	s.buckets[bucket][key] = copyBytes(value)
+	return nil
+}
+
+// Create ...
+func (s *Memory) Create(bucket, key string, value []byte) (existing []byte, created bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.buckets[bucket][key]; ok {
+		return copyBytes(existing), false, nil
+	}
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+	s.buckets[bucket][key] = copyBytes(value)
+	return nil, true, nil
+}
+
+// Swap ...
+func (s *Memory) Swap(bucket, key string, old, value []byte) (swapped bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.buckets[bucket][key]
+	if !ok || !sameJSON(existing, old) {
+		return false, nil
+	}
+	s.buckets[bucket][key] = copyBytes(value)
+	return true, nil
+}
+
+// DeleteIf ...
+func (s *Memory) DeleteIf(bucket, key string, old []byte) (deleted bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.buckets[bucket][key]
+	if !ok || !sameJSON(existing, old) {
+		return false, nil
+	}
+	delete(s.buckets[bucket], key)
+	return true, nil
+}
+
+// Delete ...
+func (s *Memory) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets[bucket], key)
+	return nil
+}
+
+// All ...
+func (s *Memory) All(bucket string) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make(map[string][]byte, len(s.buckets[bucket]))
+	for key, value := range s.buckets[bucket] {
+		values[key] = copyBytes(value)
+	}
+	return values, nil
+}
+
+// Close ...
+func (s *Memory) Close() error {
+	return nil
+}
+
+// copyBytes Return a copy of b so callers can't modify the stored values
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
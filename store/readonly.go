@@ -0,0 +1,44 @@
+package store
+
+// readOnly Store answering as the one it wraps would but dropping every
+// change, for read-only runs
+type readOnly struct {
+	Store
+}
+
+// ReadOnly Return a view of the store that doesn't modify it
+func ReadOnly(st Store) Store {
+	return readOnly{st}
+}
+
+// Put ...
+func (s readOnly) Put(bucket, key string, value []byte) error {
+	return nil
+}
+
+// Create ...
+func (s readOnly) Create(bucket, key string, value []byte) (existing []byte, created bool, err error) {
+	existing, ok, err := s.Get(bucket, key)
+	return existing, !ok && err == nil, err
+}
+
+// Swap ...
+func (s readOnly) Swap(bucket, key string, old, value []byte) (swapped bool, err error) {
+	existing, ok, err := s.Get(bucket, key)
+	return ok && sameJSON(existing, old), err
+}
+
+// Delete ...
+func (s readOnly) Delete(bucket, key string) error {
+	return nil
+}
+
+// DeleteIf ...
+func (s readOnly) DeleteIf(bucket, key string, old []byte) (deleted bool, err error) {
+	return s.Swap(bucket, key, old, nil)
+}
+
+// Close The wrapped store is closed by its owner
+func (s readOnly) Close() error {
+	return nil
+}
@@ -0,0 +1,125 @@
+//go:build sqlite
+// +build sqlite
+
+package store
+
+import (
+	"database/sql"
+
+	// registers the sqlite3 driver, requires cgo
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	backends[BackendSQLite] = func(path string) (Store, error) { return OpenSQLite(path) }
+}
+
+// sqliteSchema Every bucket is kept in the same table
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS items (
+	bucket TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+)`
+
+// SQLite Store kept in a SQLite database
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite Open or create the database at path
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=10000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Get ...
+func (s *SQLite) Get(bucket, key string) (value []byte, ok bool, err error) {
+	err = s.db.QueryRow("SELECT value FROM items WHERE bucket = ? AND key = ?", bucket, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Put ...
+func (s *SQLite) Put(bucket, key string, value []byte) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO items (bucket, key, value) VALUES (?, ?, ?)", bucket, key, value)
+	return err
+}
+
+// Create ...
+func (s *SQLite) Create(bucket, key string, value []byte) (existing []byte, created bool, err error) {
+	res, err := s.db.Exec("INSERT OR IGNORE INTO items (bucket, key, value) VALUES (?, ?, ?)", bucket, key, value)
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return nil, n > 0, err
+	}
+	existing, _, err = s.Get(bucket, key)
+	return existing, false, err
+}
+
+// Swap ...
+func (s *SQLite) Swap(bucket, key string, old, value []byte) (swapped bool, err error) {
+	res, err := s.db.Exec("UPDATE items SET value = ? WHERE bucket = ? AND key = ? AND value = ?", value, bucket, key, old)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteIf ...
+func (s *SQLite) DeleteIf(bucket, key string, old []byte) (deleted bool, err error) {
+	res, err := s.db.Exec("DELETE FROM items WHERE bucket = ? AND key = ? AND value = ?", bucket, key, old)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Delete ...
+func (s *SQLite) Delete(bucket, key string) error {
+	_, err := s.db.Exec("DELETE FROM items WHERE bucket = ? AND key = ?", bucket, key)
+	return err
+}
+
+// All ...
+func (s *SQLite) All(bucket string) (map[string][]byte, error) {
+	rows, err := s.db.Query("SELECT key, value FROM items WHERE bucket = ?", bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		err = rows.Scan(&key, &value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+// Close ...
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,98 @@
+// Package store Persistence of what parserr needs to remember between runs,
+// the state of the items and the quarantine entries, with backends for every
+// kind of deployment.
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	// EnvStateBackend Where the state is kept: json (default), memory, bbolt
+	// or sqlite
+	EnvStateBackend = "PARSERR_STATE_BACKEND"
+	// BackendJSON ...
+	BackendJSON = "json"
+	// BackendMemory ...
+	BackendMemory = "memory"
+	// BackendBolt ...
+	BackendBolt = "bbolt"
+	// BackendSQLite ...
+	BackendSQLite = "sqlite"
+)
+
+// Store Key value persistence grouped in buckets, values are JSON documents.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get Return the value of the key, ok is false if there is none
+	Get(bucket, key string) (value []byte, ok bool, err error)
+	// Put Create or replace the value of the key, persisting it
+	Put(bucket, key string, value []byte) error
+	// Create Create the key only if it doesn't exist, atomically even
+	// between processes sharing the store, returning the value it has
+	// otherwise
+	Create(bucket, key string, value []byte) (existing []byte, created bool, err error)
+	// Swap Replace the value of the key only if it's still old, atomically
+	// even between processes sharing the store
+	Swap(bucket, key string, old, value []byte) (swapped bool, err error)
+	// Delete Remove the key, missing keys are not an error
+	Delete(bucket, key string) error
+	// DeleteIf Remove the key only if its value is still old, atomically
+	// even between processes sharing the store
+	DeleteIf(bucket, key string, old []byte) (deleted bool, err error)
+	// All Return every value of the bucket by key
+	All(bucket string) (map[string][]byte, error)
+	Close() error
+}
+
+// opener Open the store of a backend kept at the path
+type opener func(path string) (Store, error)
+
+// backends Backends available in this build, the ones with dependencies are
+// registered by the files of their build tags
+var backends = map[string]opener{
+	BackendJSON: func(path string) (Store, error) { return OpenFile(path) },
+	BackendMemory: func(path string) (Store, error) {
+		return NewMemory(), nil
+	},
+}
+
+// optional Build tag of the backends that aren't built by default
+var optional = map[string]string{
+	BackendBolt:   "bbolt",
+	BackendSQLite: "sqlite",
+}
+
+// Open Return the store of the backend kept at the path, json if backend is
+// empty
+func Open(backend, path string) (Store, error) {
+	if backend == "" {
+		backend = BackendJSON
+	}
+	open, ok := backends[backend]
+	if ok {
+		return open(path)
+	}
+	if tag, ok := optional[backend]; ok {
+		return nil, fmt.Errorf("%s backend not available, build parserr with -tags %s", backend, tag)
+	}
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown %s %q, available: %v", EnvStateBackend, backend, names)
+}
+
+// sameJSON Return true if both values are the same JSON document, the json
+// backend may indent the values it keeps
+func sameJSON(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
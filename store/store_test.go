@@ -0,0 +1,120 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testStore Check the behaviour every backend must have
+func testStore(t *testing.T, s Store) {
+	if _, ok, err := s.Get(BucketState, "a"); ok || err != nil {
+		t.Fatalf("missing key found: %v %v", ok, err)
+	}
+	if err := s.Put(BucketState, "a", []byte(`{"n":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := s.Get(BucketState, "a"); !ok || err != nil || !sameJSON(v, []byte(`{"n":1}`)) {
+		t.Errorf("got %s %v %v, want the value put", v, ok, err)
+	}
+	if _, ok, _ := s.Get(BucketLocks, "a"); ok {
+		t.Error("key of another bucket found")
+	}
+
+	existing, created, err := s.Create(BucketState, "a", []byte(`{"n":2}`))
+	if created || err != nil || !sameJSON(existing, []byte(`{"n":1}`)) {
+		t.Errorf("created over an existing key: %s %v %v", existing, created, err)
+	}
+	if _, created, err = s.Create(BucketState, "b", []byte(`{"n":2}`)); !created || err != nil {
+		t.Errorf("missing key not created: %v", err)
+	}
+
+	if swapped, err := s.Swap(BucketState, "a", []byte(`{"n":3}`), []byte(`{"n":4}`)); swapped || err != nil {
+		t.Errorf("swapped a value that changed: %v", err)
+	}
+	if swapped, err := s.Swap(BucketState, "a", []byte(`{ "n": 1 }`), []byte(`{"n":4}`)); !swapped || err != nil {
+		t.Errorf("value not swapped: %v", err)
+	}
+	if swapped, _ := s.Swap(BucketState, "missing", []byte(`{}`), []byte(`{}`)); swapped {
+		t.Error("swapped a missing key")
+	}
+
+	if deleted, err := s.DeleteIf(BucketState, "a", []byte(`{"n":1}`)); deleted || err != nil {
+		t.Errorf("deleted a value that changed: %v", err)
+	}
+	if deleted, err := s.DeleteIf(BucketState, "a", []byte(`{"n":4}`)); !deleted || err != nil {
+		t.Errorf("value not deleted: %v", err)
+	}
+	if err := s.Delete(BucketState, "missing"); err != nil {
+		t.Errorf("deleting a missing key: %s", err)
+	}
+
+	all, err := s.All(BucketState)
+	if err != nil || len(all) != 1 || !sameJSON(all["b"], []byte(`{"n":2}`)) {
+		t.Errorf("All = %s %v, want only b", all, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.Get(BucketState, "b"); !ok {
+		t.Error("value lost once the store is opened again")
+	}
+}
+
+func TestMemoryCopies(t *testing.T) {
+	s := NewMemory()
+	value := []byte(`{"n":1}`)
+	s.Put(BucketState, "a", value)
+	value[1] = 'x'
+	got, _, _ := s.Get(BucketState, "a")
+	got[2] = 'x'
+	if again, _, _ := s.Get(BucketState, "a"); string(again) != `{"n":1}` {
+		t.Errorf("stored value changed to %s by its callers", again)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s := NewMemory()
+	s.Put(BucketState, "a", []byte(`{"n":1}`))
+	ro := ReadOnly(s)
+	ro.Put(BucketState, "a", []byte(`{"n":2}`))
+	ro.Delete(BucketState, "a")
+	if swapped, _ := ro.Swap(BucketState, "a", []byte(`{"n":1}`), []byte(`{"n":3}`)); !swapped {
+		t.Error("read-only swap doesn't answer like the store")
+	}
+	if v, ok, _ := s.Get(BucketState, "a"); !ok || string(v) != `{"n":1}` {
+		t.Errorf("read-only view changed the store to %s", v)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(BackendMemory, ""); err != nil {
+		t.Error(err)
+	}
+	if _, err := Open("redis", ""); err == nil {
+		t.Error("unknown backend opened")
+	}
+}
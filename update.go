@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// envUpdateCheck Log on startup when a newer release exists
+	envUpdateCheck = "PARSERR_UPDATE_CHECK"
+	// envUpdateChannel Releases considered by the update check and
+	// self-update, stable (default) or prerelease
+	envUpdateChannel = "PARSERR_UPDATE_CHANNEL"
+	// channelStable Only releases that aren't prereleases
+	channelStable = "stable"
+	// channelPrerelease Prereleases too
+	channelPrerelease = "prerelease"
+	// releasesURL GitHub releases of parserr, newest first
+	releasesURL = "https://api.github.com/repos/ivandelabeldad/Parserr/releases"
+	// updateTimeout Max time to ask for the releases
+	updateTimeout = 10 * time.Second
+	// downloadTimeout Max time to download the binary of a release
+	downloadTimeout = 5 * time.Minute
+	// checksumsAsset Asset of every release with the SHA-256 of its binaries,
+	// in the format of sha256sum
+	checksumsAsset = "SHA256SUMS"
+	// signatureAsset ECDSA signature of checksumsAsset, made with
+	// openssl dgst -sha256 -sign
+	signatureAsset = "SHA256SUMS.sig"
+)
+
+var (
+	// version Release of this build, set with -ldflags "-X main.version=v1.2.3"
+	version = "dev"
+	// updateKey Base64 of the PKIX ECDSA public key releases are signed
+	// with, set with -ldflags "-X main.updateKey=...". Builds with a key only
+	// update to releases signed with it.
+	updateKey = ""
+	// updateClient Client of the update requests, which are also bound by
+	// their context
+	updateClient = &http.Client{Timeout: downloadTimeout}
+)
+
+// release GitHub release of parserr
+type release struct {
+	TagName    string `json:"tag_name"`
+	HTMLURL    string `json:"html_url"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	Assets     []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// updateChannel Return the configured release channel
+func updateChannel() string {
+	channel := os.Getenv(envUpdateChannel)
+	switch channel {
+	case "":
+		return channelStable
+	case channelStable, channelPrerelease:
+		return channel
+	}
+	log.Fatalf("unknown %s %q, use %s or %s", envUpdateChannel, channel, channelStable, channelPrerelease)
+	return ""
+}
+
+// latestRelease Return the newest release of the channel
+func latestRelease(ctx context.Context, channel string) (r release, err error) {
+	req, err := http.NewRequest("GET", releasesURL, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	res, err := updateClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return r, fmt.Errorf("cannot list releases, status code %d", res.StatusCode)
+	}
+	var releases []release
+	err = json.NewDecoder(res.Body).Decode(&releases)
+	if err != nil {
+		return
+	}
+	for _, r := range releases {
+		if r.Draft || (r.Prerelease && channel != channelPrerelease) {
+			continue
+		}
+		return r, nil
+	}
+	return r, fmt.Errorf("no %s releases found", channel)
+}
+
+// checkUpdate Log if there is a release newer than this build, if enabled
+func checkUpdate() {
+	if !envBoolValue(envUpdateCheck) || version == "dev" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+	r, err := latestRelease(ctx, updateChannel())
+	if err != nil {
+		log.Printf("cannot check for updates: %s", err)
+		return
+	}
+	if compareVersions(r.TagName, version) > 0 {
+		log.Printf("parserr %s is available, running %s: %s", r.TagName, version, r.HTMLURL)
+	}
+}
+
+// selfUpdateCommand parserr self-update, replace the executable with the
+// binary of the newest release of the channel once its checksum, and the
+// signature of the checksums if the build has an updateKey, are verified
+func selfUpdateCommand() {
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+	r, err := latestRelease(ctx, updateChannel())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if version != "dev" && compareVersions(r.TagName, version) <= 0 {
+		log.Printf("parserr %s is up to date", version)
+		return
+	}
+	name := fmt.Sprintf("parserr_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	assetURL, checksumsURL, signatureURL := r.asset(name), r.asset(checksumsAsset), r.asset(signatureAsset)
+	if assetURL == "" || checksumsURL == "" {
+		log.Fatalf("release %s has no %s binary or no %s, update it manually: %s", r.TagName, name, checksumsAsset, r.HTMLURL)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	downloadCtx, cancelDownload := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancelDownload()
+	sum, err := releaseChecksum(downloadCtx, name, checksumsURL, signatureURL)
+	if err != nil {
+		log.Fatalf("cannot verify release %s: %s", r.TagName, err)
+	}
+	log.Printf("updating %s from %s to %s", exe, version, r.TagName)
+	err = replaceExecutable(downloadCtx, exe, assetURL, sum)
+	if err != nil {
+		log.Fatalf("cannot update: %s", err)
+	}
+	log.Printf("updated to %s", r.TagName)
+}
+
+// asset Return the download url of the asset of the release with the name,
+// empty if it has none
+func (r release) asset(name string) string {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.BrowserDownloadURL
+		}
+	}
+	return ""
+}
+
+// download Return the body of the url
+func download(ctx context.Context, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := updateClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("download of %s failed, status code %d", u, res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+// downloadAll Return the whole body of the url, which must be small
+func downloadAll(ctx context.Context, u string) ([]byte, error) {
+	body, err := download(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(io.LimitReader(body, 1<<20))
+}
+
+// releaseChecksum Return the SHA-256 of the binary with the name listed in
+// the checksums of the release, verifying their signature if the build has
+// an updateKey
+func releaseChecksum(ctx context.Context, name, checksumsURL, signatureURL string) ([]byte, error) {
+	checksums, err := downloadAll(ctx, checksumsURL)
+	if err != nil {
+		return nil, err
+	}
+	if updateKey != "" {
+		if signatureURL == "" {
+			return nil, fmt.Errorf("%s isn't signed", checksumsAsset)
+		}
+		signature, err := downloadAll(ctx, signatureURL)
+		if err != nil {
+			return nil, err
+		}
+		err = verifySignature(updateKey, checksums, signature)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return findChecksum(checksums, name)
+}
+
+// findChecksum Return the checksum of the file with the name in a
+// sha256sum output
+func findChecksum(checksums []byte, name string) ([]byte, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum of %s", name)
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("%s has no checksum of %s", checksumsAsset, name)
+}
+
+// verifySignature Return an error unless the signature, an ASN.1 ECDSA one,
+// is the one of data made with the key, the base64 of a PKIX public key
+func verifySignature(key string, data, signature []byte) error {
+	der, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("invalid update key: %s", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("invalid update key: %s", err)
+	}
+	ecKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("update key isn't an ECDSA key")
+	}
+	var sig struct{ R, S *big.Int }
+	if _, err = asn1.Unmarshal(signature, &sig); err != nil {
+		return fmt.Errorf("invalid signature of %s: %s", checksumsAsset, err)
+	}
+	hash := sha256.Sum256(data)
+	if !ecdsa.Verify(ecKey, hash[:], sig.R, sig.S) {
+		return fmt.Errorf("wrong signature of %s", checksumsAsset)
+	}
+	return nil
+}
+
+// replaceExecutable Download the binary next to the executable and rename
+// it over the executable once its SHA-256 is sum, so an interrupted or
+// tampered update leaves it untouched
+func replaceExecutable(ctx context.Context, exe, u string, sum []byte) error {
+	body, err := download(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(exe), ".parserr-update")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash.Sum(nil), sum) {
+		return fmt.Errorf("checksum of the downloaded binary doesn't match %s", checksumsAsset)
+	}
+	err = os.Chmod(tmp.Name(), 0755)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		// a running executable can't be replaced, only renamed
+		old := exe + ".old"
+		os.Remove(old)
+		err = os.Rename(exe, old)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), exe)
+}
+
+// compareVersions Compare two versions like v1.2.3 or 1.2.3-rc.1, returning
+// 1 if a is newer, -1 if b is newer and 0 if they are the same. Prereleases
+// are older than their release.
+func compareVersions(a, b string) int {
+	aNums, aPre := splitVersion(a)
+	bNums, bPre := splitVersion(b)
+	for i := 0; i < len(aNums) || i < len(bNums); i++ {
+		var x, y int
+		if i < len(aNums) {
+			x = aNums[i]
+		}
+		if i < len(bNums) {
+			y = bNums[i]
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre > bPre:
+		return 1
+	}
+	return -1
+}
+
+// splitVersion Return the numbers and the prerelease of a version
+func splitVersion(v string) (nums []int, pre string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		if v[i] == '-' {
+			pre = strings.SplitN(v[i+1:], "+", 2)[0]
+		}
+		v = v[:i]
+	}
+	for _, part := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(part)
+		nums = append(nums, n)
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestFindChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("parserr"))
+	hexSum := hex.EncodeToString(sum[:])
+	checksums := []byte(hexSum + "  parserr_linux_amd64\n" + hexSum + " *parserr_windows_amd64.exe\nbad  parserr_linux_arm\n")
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"parserr_linux_amd64", false},
+		{"parserr_windows_amd64.exe", false},
+		{"parserr_linux_arm", true},
+		{"parserr_darwin_amd64", true},
+	}
+	for _, tt := range tests {
+		got, err := findChecksum(checksums, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && hex.EncodeToString(got) != hexSum {
+			t.Errorf("%s: checksum %x, want %s", tt.name, got, hexSum)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(der)
+	data := []byte("checksums")
+	hash := sha256.Sum256(data)
+	signature, err := priv.Sign(rand.Reader, hash[:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		key       string
+		data      []byte
+		signature []byte
+		wantErr   bool
+	}{
+		{"signed", key, data, signature, false},
+		{"tampered", key, []byte("checksumz"), signature, true},
+		{"not a signature", key, data, []byte("sig"), true},
+		{"invalid key", "key", data, signature, true},
+	}
+	for _, tt := range tests {
+		err := verifySignature(tt.key, tt.data, tt.signature)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}